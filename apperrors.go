@@ -245,6 +245,45 @@ func IsAppErrCode(e error, code string) bool {
 	return err.code == code
 }
 
+// AppErrs returns all *AppError found in the error tree
+// unlike IsAppErr, it walks all errors joined by errors.Join (or others implementing Unwrap() []error)
+func AppErrs(e error) []*AppError {
+	var r []*AppError
+	var walk func(e error)
+	walk = func(e error) {
+		for e != nil {
+			if appErr, ok := e.(*AppError); ok {
+				r = append(r, appErr)
+				return
+			}
+			switch u := e.(type) {
+			case interface{ Unwrap() []error }:
+				for _, err := range u.Unwrap() {
+					walk(err)
+				}
+				return
+			case interface{ Unwrap() error }:
+				e = u.Unwrap()
+			default:
+				return
+			}
+		}
+	}
+	walk(e)
+	return r
+}
+
+// HasAppErrCode checks if any *AppError in the error tree contains specific code
+// unlike IsAppErrCode, it checks all errors joined by errors.Join
+func HasAppErrCode(e error, code string) bool {
+	for _, appErr := range AppErrs(e) {
+		if appErr.code == code {
+			return true
+		}
+	}
+	return false
+}
+
 type withStackAppErr struct {
 	*AppError
 }
@@ -2,6 +2,7 @@ package kit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -115,3 +116,23 @@ func Test_NewWithBuilder_WrapWithFields(t *testing.T) {
 	}
 	t.Fatal()
 }
+
+func Test_AppErrs_WhenJoined(t *testing.T) {
+	e := errors.Join(
+		NewAppError("ERR-1", "first"),
+		errors.New("not app error"),
+		fmt.Errorf("wrapped: %w", NewAppError("ERR-2", "second")),
+	)
+	appErrs := AppErrs(e)
+	assert.Len(t, appErrs, 2)
+	assert.Equal(t, "ERR-1", appErrs[0].Code())
+	assert.Equal(t, "ERR-2", appErrs[1].Code())
+	// IsAppErrCode reaches the first *AppError only
+	assert.True(t, IsAppErrCode(e, "ERR-1"))
+	assert.False(t, IsAppErrCode(e, "ERR-2"))
+	assert.True(t, HasAppErrCode(e, "ERR-1"))
+	assert.True(t, HasAppErrCode(e, "ERR-2"))
+	assert.False(t, HasAppErrCode(e, "ERR-3"))
+	assert.Empty(t, AppErrs(errors.New("not app error")))
+	assert.Empty(t, AppErrs(nil))
+}
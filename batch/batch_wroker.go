@@ -2,21 +2,51 @@ package batch
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/mikhailbolshakov/kit"
 	"github.com/mikhailbolshakov/kit/goroutine"
 )
 
+const (
+	defaultName          = "default"
+	defaultCloseTimeout  = time.Second * 30
+	closeGrace           = time.Second // closeGrace time Close waits for batches left to be passed to the failure sink once writing is cancelled
+	defaultRetryDelay    = time.Millisecond * 100
+	defaultRetryMaxDelay = time.Second * 10
+)
+
+// OverflowPolicy defines TryWrite behavior when the queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for space in the queue unless ctx is done
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest item waiting in the queue to put the new one
+	OverflowDropOldest
+	// OverflowDropNewest drops the new item
+	OverflowDropNewest
+	// OverflowError rejects the new item with ErrCodeBatchQueueFull
+	OverflowError
+)
+
 // Worker allows writing data in a batch manner
 // events to write fire either by time interval or by number of items waiting to be written
 type Worker[T any] interface {
 	// Start starts worker
 	Start(ctx context.Context)
 	// Write calls to pass a single item to be written
+	// it blocks while the queue is full, the item is dropped if ctx is done or the worker is closed
 	Write(ctx context.Context, item *T)
-	// Close closes worker
-	Close(ctx context.Context)
+	// TryWrite passes a single item to be written, a full queue is handled according to the overflow policy
+	// it fails with ErrCodeBatchWorkerClosed if the worker isn't started or closed
+	TryWrite(ctx context.Context, item *T) error
+	// WithFailureSink sets a sink receiving batches failed to be written after all retries
+	WithFailureSink(sink FailureSink[T]) Worker[T]
+	// Close stops accepting items and waits for items in the queue to be written
+	// it waits no longer than CloseTimeout (or ctx deadline), then writing is cancelled and ErrCodeBatchCloseTimeout is returned
+	Close(ctx context.Context) error
 }
 
 // Writer interface must be implemented and passed by calling side
@@ -25,64 +55,219 @@ type Writer[T any] interface {
 	Write(ctx context.Context, items []*T) error
 }
 
+// FailureSink receives a batch which failed to be written, e.g. to put it to a dead-letter storage
+type FailureSink[T any] func(ctx context.Context, items []*T, err error)
+
 type Options struct {
-	Interval    time.Duration // Interval after which writing happens
-	MaxItems    int           // MaxItems specifies number of items in a queue to be written
-	MaxCapacity int           // MaxCapacity capacity of channel before locking
+	Interval      time.Duration  // Interval after which writing happens
+	MaxItems      int            // MaxItems specifies number of items in a queue to be written
+	MaxCapacity   int            // MaxCapacity capacity of channel before locking
+	Name          string         // Name of the worker used as a metrics label (default: "default")
+	Overflow      OverflowPolicy // Overflow TryWrite behavior when the queue is full (default: OverflowBlock)
+	Writers       int            // Writers number of goroutines collecting and writing batches in parallel (default: 1)
+	RetryAttempts int            // RetryAttempts number of retries of a failed batch (default: no retries)
+	RetryDelay    time.Duration  // RetryDelay delay before the first retry, it's doubled on each next retry (default: 100ms)
+	RetryMaxDelay time.Duration  // RetryMaxDelay max delay between retries (default: 10s)
+	CloseTimeout  time.Duration  // CloseTimeout max time Close waits for items to be written (default: 30s)
 }
 
 type batchWorker[T any] struct {
-	itemChan  chan *T
-	cancelCtx context.Context
-	cancelFn  context.CancelFunc
-	opt       *Options
-	logger    kit.CLoggerFunc
-	writer    Writer[T]
+	sync.RWMutex // guards the channel, so that it isn't closed while written
+	itemChan     chan *T
+	closed       bool
+	closing      context.Context // closing is done once Close is called, it releases writers blocked on the full queue
+	closingFn    context.CancelFunc
+	cancelCtx    context.Context
+	cancelFn     context.CancelFunc
+	routines     sync.WaitGroup
+	opt          *Options
+	logger       kit.CLoggerFunc
+	writer       Writer[T]
+	sink         FailureSink[T]
 }
 
 func NewBatchWorker[T any](writer Writer[T], opt *Options, logger kit.CLoggerFunc) Worker[T] {
+	o := &Options{}
+	if opt != nil {
+		*o = *opt
+	}
+	if o.Name == "" {
+		o.Name = defaultName
+	}
+	if o.Writers <= 0 {
+		o.Writers = 1
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = defaultRetryDelay
+	}
+	if o.RetryMaxDelay <= 0 {
+		o.RetryMaxDelay = defaultRetryMaxDelay
+	}
+	if o.CloseTimeout <= 0 {
+		o.CloseTimeout = defaultCloseTimeout
+	}
 	r := &batchWorker[T]{
-		opt:    opt,
+		opt:    o,
 		logger: logger,
 		writer: writer,
+		closed: true,
 	}
 	return r
 }
 
 func (f *batchWorker[T]) l() kit.CLogger {
-	return f.logger().Cmp("batch-worker")
+	return f.logger().Cmp("batch-worker").F(kit.KV{"worker": f.opt.Name})
+}
+
+func (f *batchWorker[T]) WithFailureSink(sink FailureSink[T]) Worker[T] {
+	f.sink = sink
+	return f
 }
 
 func (f *batchWorker[T]) Start(ctx context.Context) {
 	f.l().C(ctx).Mth("start").Dbg()
 
+	f.Lock()
+	defer f.Unlock()
+
 	// cancel running forcibly
 	if f.cancelFn != nil {
 		f.cancelFn()
 	}
 	f.itemChan = make(chan *T, f.opt.MaxCapacity)
+	f.closed = false
+	f.closing, f.closingFn = context.WithCancel(context.Background())
 
 	// init cancellation context
 	f.cancelCtx, f.cancelFn = context.WithCancel(ctx)
 
-	// run writer in a separate goroutine
-	goroutine.New().WithLogger(f.l().C(ctx).Mth("writer")).WithRetry(goroutine.Unrestricted).Go(ctx, func() { f.worker(ctx) })
+	// run writers in separate goroutines
+	itemChan, cancelCtx := f.itemChan, f.cancelCtx
+	f.routines.Add(f.opt.Writers)
+	for i := 0; i < f.opt.Writers; i++ {
+		goroutine.New().WithLogger(f.l().C(ctx).Mth("writer")).WithRetry(goroutine.Unrestricted).Go(ctx, func() {
+			f.worker(cancelCtx, itemChan)
+			// a panicked worker is restarted, so it's done only when it returns
+			f.routines.Done()
+		})
+	}
 }
 
 func (f *batchWorker[T]) Write(ctx context.Context, item *T) {
-	f.itemChan <- item
+	if err := f.put(ctx, item, OverflowBlock); err != nil {
+		f.l().C(ctx).Mth("write").E(err).Warn("dropped")
+	}
 }
 
-func (f *batchWorker[T]) Close(ctx context.Context) {
-	f.l().C(ctx).Mth("close").Dbg()
-	if f.cancelFn != nil {
-		f.cancelFn()
+func (f *batchWorker[T]) TryWrite(ctx context.Context, item *T) error {
+	return f.put(ctx, item, f.opt.Overflow)
+}
+
+func (f *batchWorker[T]) put(ctx context.Context, item *T, policy OverflowPolicy) error {
+	f.RLock()
+	defer f.RUnlock()
+
+	if f.closed {
+		metrics.dropped(f.opt.Name)
+		return ErrBatchWorkerClosed(ctx, f.opt.Name)
 	}
-	f.cancelFn = nil
+	defer func() { metrics.queue(f.opt.Name, len(f.itemChan)) }()
+
+	for {
+		select {
+		case f.itemChan <- item:
+			return nil
+		default:
+		}
+
+		switch policy {
+		case OverflowDropOldest:
+			select {
+			case <-f.itemChan:
+				metrics.dropped(f.opt.Name)
+			default:
+			}
+		case OverflowDropNewest:
+			metrics.dropped(f.opt.Name)
+			return nil
+		case OverflowError:
+			metrics.dropped(f.opt.Name)
+			return ErrBatchQueueFull(ctx, f.opt.Name)
+		default:
+			select {
+			case f.itemChan <- item:
+				return nil
+			case <-ctx.Done():
+				metrics.dropped(f.opt.Name)
+				return ErrBatchWriteCancelled(ctx, f.opt.Name)
+			// the lock is held while waiting, so Close releases it before taking the lock
+			case <-f.closing.Done():
+				metrics.dropped(f.opt.Name)
+				return ErrBatchWorkerClosed(ctx, f.opt.Name)
+			}
+		}
+	}
+}
+
+func (f *batchWorker[T]) Close(ctx context.Context) error {
+	l := f.l().C(ctx).Mth("close").Dbg()
+
+	// the deadline includes waiting for the lock
+	timer := time.NewTimer(f.opt.CloseTimeout)
+	defer timer.Stop()
+
+	// release writers blocked on the full queue, otherwise the lock isn't taken while the writer hangs
+	f.RLock()
+	if f.closed {
+		f.RUnlock()
+		return nil
+	}
+	f.closingFn()
+	f.RUnlock()
+
+	// stop accepting items, writers finish once the queue is drained
+	f.Lock()
+	if f.closed {
+		f.Unlock()
+		return nil
+	}
+	f.closed = true
 	close(f.itemChan)
+	cancelFn := f.cancelFn
+	f.cancelFn = nil
+	f.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.routines.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		cancelFn()
+		l.Dbg("ok")
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	// cancel writing, batches left are passed to the failure sink
+	cancelFn()
+	grace := time.NewTimer(closeGrace)
+	defer grace.Stop()
+	select {
+	case <-done:
+	case <-grace.C:
+		l.Warn("writers aren't done")
+	}
+
+	err := ErrBatchCloseTimeout(ctx, f.opt.Name)
+	l.E(err).Err()
+	return err
 }
 
-func (f *batchWorker[T]) worker(ctx context.Context) {
+func (f *batchWorker[T]) worker(ctx context.Context, itemChan chan *T) {
 	l := f.l().C(ctx).Mth("worker").Dbg()
 
 	for keepGoing := true; keepGoing; {
@@ -94,11 +279,12 @@ func (f *batchWorker[T]) worker(ctx context.Context) {
 		for {
 			select {
 
-			case ev, ok := <-f.itemChan:
+			case ev, ok := <-itemChan:
 				if !ok {
 					keepGoing = false
 					goto flush
 				}
+				metrics.queue(f.opt.Name, len(itemChan))
 				batch = append(batch, ev)
 				if len(batch) >= f.opt.MaxItems {
 					goto flush
@@ -108,19 +294,67 @@ func (f *batchWorker[T]) worker(ctx context.Context) {
 			case <-expire:
 				goto flush
 
-			// leave when context cancelled
+			// leave when context cancelled, items waiting are taken to be passed to the failure sink
 			case <-ctx.Done():
 				keepGoing = false
 				l.Inf("close")
+				batch = append(batch, drain(itemChan)...)
 				goto flush
 			}
 		}
 	flush:
 		if len(batch) > 0 {
-			// write
-			if err := f.writer.Write(ctx, batch); err != nil {
-				f.l().C(ctx).Mth("writer").E(err).Err()
+			f.flush(ctx, batch)
+		}
+	}
+}
+
+// flush writes a batch with retries, a batch failed is passed to the failure sink
+func (f *batchWorker[T]) flush(ctx context.Context, batch []*T) {
+	metrics.batch(f.opt.Name, len(batch))
+
+	err := f.write(ctx, batch)
+	if err == nil {
+		return
+	}
+
+	f.l().C(ctx).Mth("writer").F(kit.KV{"items": len(batch)}).E(err).Err()
+	metrics.failed(f.opt.Name, len(batch))
+	if f.sink != nil {
+		// writing may be cancelled on close, the sink still has to store the batch
+		f.sink(context.WithoutCancel(ctx), batch, err)
+	}
+}
+
+func (f *batchWorker[T]) write(ctx context.Context, batch []*T) error {
+	delay := f.opt.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := f.writer.Write(ctx, batch)
+		if err == nil || attempt >= f.opt.RetryAttempts {
+			return err
+		}
+		f.l().C(ctx).Mth("writer").F(kit.KV{"attempt": attempt + 1}).E(err).Warn("retry")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay = min(delay*2, f.opt.RetryMaxDelay)
+	}
+}
+
+// drain takes items waiting in the queue with no blocking
+func drain[T any](itemChan chan *T) []*T {
+	var r []*T
+	for {
+		select {
+		case ev, ok := <-itemChan:
+			if !ok {
+				return r
 			}
+			r = append(r, ev)
+		default:
+			return r
 		}
 	}
 }
@@ -2,10 +2,14 @@ package batch
 
 import (
 	"context"
-	"github.com/mikhailbolshakov/kit"
-	"github.com/stretchr/testify/suite"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/stretchr/testify/suite"
 )
 
 type batchWorkerTestSuite struct {
@@ -21,14 +25,60 @@ func (s *batchWorkerTestSuite) SetupSuite() {
 }
 
 type mockWriter struct {
-	items []*message
+	sync.Mutex
+	items   []*message
+	batches int
+	fails   int           // fails number of first calls failed
+	delay   time.Duration // delay of each call, it's interrupted when ctx is done
 }
 
 func (b *mockWriter) Write(ctx context.Context, items []*message) error {
+	if b.delay > 0 {
+		select {
+		case <-time.After(b.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.fails > 0 {
+		b.fails--
+		return errors.New("write failed")
+	}
 	b.items = append(b.items, items...)
+	b.batches++
 	return nil
 }
 
+func (b *mockWriter) len() int {
+	b.Lock()
+	defer b.Unlock()
+	return len(b.items)
+}
+
+// failures collects batches passed to the failure sink
+type failures struct {
+	sync.Mutex
+	items   []*message
+	errs    []error
+	ctxErrs []error // ctxErrs errors of ctx passed to the sink
+}
+
+func (f *failures) sink(ctx context.Context, items []*message, err error) {
+	f.Lock()
+	defer f.Unlock()
+	f.items = append(f.items, items...)
+	f.errs = append(f.errs, err)
+	f.ctxErrs = append(f.ctxErrs, ctx.Err())
+}
+
+func (f *failures) len() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.items)
+}
+
 func (s *batchWorkerTestSuite) SetupTest() {
 }
 
@@ -57,7 +107,7 @@ func (s *batchWorkerTestSuite) Test_WhenWriteByTimeout() {
 	}
 	// await
 	if err := <-kit.Await(func() (bool, error) {
-		return len(mw.items) == 5, nil
+		return mw.len() == 5, nil
 	}, time.Millisecond*100, time.Second*2); err != nil {
 		s.Fatal(err)
 	}
@@ -82,7 +132,7 @@ func (s *batchWorkerTestSuite) Test_WhenWriteByMaxItems() {
 	}
 	// await
 	if err := <-kit.Await(func() (bool, error) {
-		return len(mw.items) == 5, nil
+		return mw.len() == 5, nil
 	}, time.Millisecond*100, time.Second*2); err != nil {
 		s.Fatal(err)
 	}
@@ -109,8 +159,224 @@ func (s *batchWorkerTestSuite) Test_WhenWRiteOnClose() {
 
 	// await
 	if err := <-kit.Await(func() (bool, error) {
-		return len(mw.items) == 5, nil
+		return mw.len() == 5, nil
 	}, time.Millisecond*100, time.Second*2); err != nil {
 		s.Fatal(err)
 	}
 }
+
+func (s *batchWorkerTestSuite) Test_Close_WaitsForFlush() {
+	mw := &mockWriter{delay: time.Millisecond * 200}
+	worker := NewBatchWorker[message](mw, &Options{
+		Interval:    time.Second * 5,
+		MaxItems:    10,
+		MaxCapacity: 999,
+	}, s.L)
+	worker.Start(s.Ctx)
+	for i := 0; i < 25; i++ {
+		worker.Write(s.Ctx, &message{Value: kit.NewRandString()})
+	}
+	s.NoError(worker.Close(s.Ctx))
+	// all items are written once Close returns
+	s.Equal(25, mw.len())
+	s.NoError(worker.Close(s.Ctx))
+}
+
+func (s *batchWorkerTestSuite) Test_Close_WhenTimeout_Fail() {
+	mw := &mockWriter{delay: time.Second * 5}
+	fl := &failures{}
+	worker := NewBatchWorker[message](mw, &Options{
+		Interval:     time.Second * 5,
+		MaxItems:     5,
+		MaxCapacity:  999,
+		CloseTimeout: time.Millisecond * 100,
+	}, s.L).WithFailureSink(fl.sink)
+	worker.Start(s.Ctx)
+	for i := 0; i < 10; i++ {
+		worker.Write(s.Ctx, &message{Value: kit.NewRandString()})
+	}
+	s.AssertAppErr(worker.Close(s.Ctx), ErrCodeBatchCloseTimeout)
+	// items not written are passed to the failure sink before Close returns
+	s.Equal(10, fl.len())
+	s.Equal(0, mw.len())
+	// the sink gets a ctx which isn't cancelled along with writing
+	for _, err := range fl.ctxErrs {
+		s.NoError(err)
+	}
+}
+
+func (s *batchWorkerTestSuite) Test_Write_WhenClosed_Fail() {
+	mw := &mockWriter{}
+	worker := NewBatchWorker[message](mw, &Options{Interval: time.Second, MaxItems: 10, MaxCapacity: 10}, s.L)
+	s.AssertAppErr(worker.TryWrite(s.Ctx, &message{}), ErrCodeBatchWorkerClosed)
+	worker.Start(s.Ctx)
+	s.NoError(worker.Close(s.Ctx))
+	s.AssertAppErr(worker.TryWrite(s.Ctx, &message{}), ErrCodeBatchWorkerClosed)
+	// doesn't block or panic
+	worker.Write(s.Ctx, &message{})
+}
+
+// blockedWorker returns a started worker which writer is blocked until release is closed, its queue is full
+func (s *batchWorkerTestSuite) blockedWorker(overflow OverflowPolicy, mw *mockWriter, release chan struct{}) Worker[message] {
+	worker := NewBatchWorker[message](writerFn(func(ctx context.Context, items []*message) error {
+		<-release
+		return mw.Write(ctx, items)
+	}), &Options{
+		Interval:    time.Second * 5,
+		MaxItems:    1,
+		MaxCapacity: 2,
+		Overflow:    overflow,
+	}, s.L)
+	worker.Start(s.Ctx)
+	// the first item is taken by the blocked writer, two more fill the queue
+	s.NoError(worker.TryWrite(s.Ctx, &message{Value: "1"}))
+	if err := <-kit.Await(func() (bool, error) {
+		return len(worker.(*batchWorker[message]).itemChan) == 0, nil
+	}, time.Millisecond*10, time.Second); err != nil {
+		s.Fatal(err)
+	}
+	s.NoError(worker.TryWrite(s.Ctx, &message{Value: "2"}))
+	s.NoError(worker.TryWrite(s.Ctx, &message{Value: "3"}))
+	return worker
+}
+
+type writerFn func(ctx context.Context, items []*message) error
+
+func (f writerFn) Write(ctx context.Context, items []*message) error {
+	return f(ctx, items)
+}
+
+func (s *batchWorkerTestSuite) values(mw *mockWriter) []string {
+	mw.Lock()
+	defer mw.Unlock()
+	var r []string
+	for _, it := range mw.items {
+		r = append(r, it.Value)
+	}
+	return r
+}
+
+func (s *batchWorkerTestSuite) Test_TryWrite_WhenQueueFull_Error() {
+	mw, release := &mockWriter{}, make(chan struct{})
+	worker := s.blockedWorker(OverflowError, mw, release)
+	s.AssertAppErr(worker.TryWrite(s.Ctx, &message{Value: "4"}), ErrCodeBatchQueueFull)
+	close(release)
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal([]string{"1", "2", "3"}, s.values(mw))
+}
+
+func (s *batchWorkerTestSuite) Test_TryWrite_WhenQueueFull_DropNewest() {
+	mw, release := &mockWriter{}, make(chan struct{})
+	worker := s.blockedWorker(OverflowDropNewest, mw, release)
+	s.NoError(worker.TryWrite(s.Ctx, &message{Value: "4"}))
+	close(release)
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal([]string{"1", "2", "3"}, s.values(mw))
+}
+
+func (s *batchWorkerTestSuite) Test_TryWrite_WhenQueueFull_DropOldest() {
+	mw, release := &mockWriter{}, make(chan struct{})
+	worker := s.blockedWorker(OverflowDropOldest, mw, release)
+	s.NoError(worker.TryWrite(s.Ctx, &message{Value: "4"}))
+	close(release)
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal([]string{"1", "3", "4"}, s.values(mw))
+}
+
+func (s *batchWorkerTestSuite) Test_TryWrite_WhenQueueFull_BlockUntilCtxDone() {
+	mw, release := &mockWriter{}, make(chan struct{})
+	worker := s.blockedWorker(OverflowBlock, mw, release)
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Millisecond*100)
+	defer cancel()
+	s.AssertAppErr(worker.TryWrite(ctx, &message{Value: "4"}), ErrCodeBatchWriteCancelled)
+	close(release)
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal([]string{"1", "2", "3"}, s.values(mw))
+}
+
+func (s *batchWorkerTestSuite) Test_Close_WhenWriterHangsAndWriteBlocked_Timeout() {
+	mw, release := &mockWriter{}, make(chan struct{})
+	defer close(release)
+	worker := s.blockedWorker(OverflowBlock, mw, release)
+
+	// a writer blocked on the full queue
+	blocked := make(chan error)
+	go func() {
+		blocked <- worker.TryWrite(s.Ctx, &message{Value: "4"})
+	}()
+	time.Sleep(time.Millisecond * 50)
+
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Millisecond*100)
+	defer cancel()
+	s.AssertAppErr(worker.Close(ctx), ErrCodeBatchCloseTimeout)
+	s.AssertAppErr(<-blocked, ErrCodeBatchWorkerClosed)
+}
+
+func (s *batchWorkerTestSuite) Test_Retry_WhenSucceeds() {
+	mw := &mockWriter{fails: 2}
+	fl := &failures{}
+	worker := NewBatchWorker[message](mw, &Options{
+		Interval:      time.Second * 5,
+		MaxItems:      5,
+		MaxCapacity:   999,
+		RetryAttempts: 2,
+		RetryDelay:    time.Millisecond * 10,
+	}, s.L).WithFailureSink(fl.sink)
+	worker.Start(s.Ctx)
+	for i := 0; i < 5; i++ {
+		worker.Write(s.Ctx, &message{Value: kit.NewRandString()})
+	}
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal(5, mw.len())
+	s.Equal(0, fl.len())
+}
+
+func (s *batchWorkerTestSuite) Test_Retry_WhenExhausted_FailureSink() {
+	mw := &mockWriter{fails: 3}
+	fl := &failures{}
+	worker := NewBatchWorker[message](mw, &Options{
+		Interval:      time.Second * 5,
+		MaxItems:      5,
+		MaxCapacity:   999,
+		RetryAttempts: 2,
+		RetryDelay:    time.Millisecond * 10,
+	}, s.L).WithFailureSink(fl.sink)
+	worker.Start(s.Ctx)
+	for i := 0; i < 5; i++ {
+		worker.Write(s.Ctx, &message{Value: kit.NewRandString()})
+	}
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal(0, mw.len())
+	s.Equal(5, fl.len())
+	s.Len(fl.errs, 1)
+	s.EqualError(fl.errs[0], "write failed")
+}
+
+func (s *batchWorkerTestSuite) Test_ParallelWriters() {
+	var running, maxRunning atomic.Int32
+	mw := &mockWriter{}
+	worker := NewBatchWorker[message](writerFn(func(ctx context.Context, items []*message) error {
+		r := running.Add(1)
+		defer running.Add(-1)
+		for m := maxRunning.Load(); r > m && !maxRunning.CompareAndSwap(m, r); m = maxRunning.Load() {
+		}
+		time.Sleep(time.Millisecond * 50)
+		return mw.Write(ctx, items)
+	}), &Options{
+		Interval:    time.Second * 5,
+		MaxItems:    5,
+		MaxCapacity: 999,
+		Writers:     4,
+	}, s.L)
+	worker.Start(s.Ctx)
+	for i := 0; i < 100; i++ {
+		worker.Write(s.Ctx, &message{Value: kit.NewRandString()})
+	}
+	s.NoError(worker.Close(s.Ctx))
+	s.Equal(100, mw.len())
+	s.Greater(maxRunning.Load(), int32(1))
+}
+
+func (s *batchWorkerTestSuite) Test_Metrics() {
+	s.Len(Metrics().GetCollector()(), 6)
+}
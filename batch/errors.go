@@ -0,0 +1,29 @@
+package batch
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	ErrCodeBatchWorkerClosed   = "BTCH-001"
+	ErrCodeBatchQueueFull      = "BTCH-002"
+	ErrCodeBatchWriteCancelled = "BTCH-003"
+	ErrCodeBatchCloseTimeout   = "BTCH-004"
+)
+
+var (
+	ErrBatchWorkerClosed = func(ctx context.Context, worker string) error {
+		return kit.NewAppErrBuilder(ErrCodeBatchWorkerClosed, "worker isn't started or closed").C(ctx).F(kit.KV{"worker": worker}).Err()
+	}
+	ErrBatchQueueFull = func(ctx context.Context, worker string) error {
+		return kit.NewAppErrBuilder(ErrCodeBatchQueueFull, "queue is full").C(ctx).F(kit.KV{"worker": worker}).Err()
+	}
+	ErrBatchWriteCancelled = func(ctx context.Context, worker string) error {
+		return kit.NewAppErrBuilder(ErrCodeBatchWriteCancelled, "write cancelled while queue is full").Wrap(ctx.Err()).C(ctx).F(kit.KV{"worker": worker}).Err()
+	}
+	ErrBatchCloseTimeout = func(ctx context.Context, worker string) error {
+		return kit.NewAppErrBuilder(ErrCodeBatchCloseTimeout, "close timeout, writing cancelled").C(ctx).F(kit.KV{"worker": worker}).Err()
+	}
+)
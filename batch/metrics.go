@@ -0,0 +1,94 @@
+package batch
+
+import (
+	"github.com/mikhailbolshakov/kit/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	QueueDepthGauge      = "batch_queue_depth"
+	BatchSizeGauge       = "batch_size"
+	BatchSizeHistogram   = "batch_size_distribution"
+	FailedBatchesCounter = "batch_failed_counter"
+	FailedItemsCounter   = "batch_failed_items_counter"
+	DroppedItemsCounter  = "batch_dropped_items_counter"
+)
+
+// batchMetrics batch worker prometheus metrics labeled by worker name
+// metrics are shared by all workers, so that they are registered once
+type batchMetrics struct {
+	queueDepth    *prometheus.GaugeVec
+	lastBatchSize *prometheus.GaugeVec
+	batchSize     *prometheus.HistogramVec
+	failedBatches *prometheus.CounterVec
+	failedItems   *prometheus.CounterVec
+	droppedItems  *prometheus.CounterVec
+}
+
+var metrics = newMetrics()
+
+func newMetrics() *batchMetrics {
+	return &batchMetrics{
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: QueueDepthGauge,
+			Help: "Number of items waiting in the queue",
+		}, []string{"worker"}),
+		lastBatchSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: BatchSizeGauge,
+			Help: "Number of items of the last batch written",
+		}, []string{"worker"}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    BatchSizeHistogram,
+			Help:    "Number of items of batches written",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"worker"}),
+		failedBatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: FailedBatchesCounter,
+			Help: "Counts batches failed to be written after all retries",
+		}, []string{"worker"}),
+		failedItems: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: FailedItemsCounter,
+			Help: "Counts items of batches failed to be written after all retries",
+		}, []string{"worker"}),
+		droppedItems: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: DroppedItemsCounter,
+			Help: "Counts items dropped as the queue is full or the worker is closed",
+		}, []string{"worker"}),
+	}
+}
+
+func (m *batchMetrics) queue(worker string, depth int) {
+	m.queueDepth.WithLabelValues(worker).Set(float64(depth))
+}
+
+func (m *batchMetrics) batch(worker string, size int) {
+	m.lastBatchSize.WithLabelValues(worker).Set(float64(size))
+	m.batchSize.WithLabelValues(worker).Observe(float64(size))
+}
+
+func (m *batchMetrics) failed(worker string, size int) {
+	m.failedBatches.WithLabelValues(worker).Inc()
+	m.failedItems.WithLabelValues(worker).Add(float64(size))
+}
+
+func (m *batchMetrics) dropped(worker string) {
+	m.droppedItems.WithLabelValues(worker).Inc()
+}
+
+func (m *batchMetrics) GetCollector() monitoring.MetricsCollector {
+	return func() monitoring.MetricsCollection {
+		return monitoring.MetricsCollection{
+			m.queueDepth,
+			m.lastBatchSize,
+			m.batchSize,
+			m.failedBatches,
+			m.failedItems,
+			m.droppedItems,
+		}
+	}
+}
+
+// Metrics returns metrics of all batch workers, metrics are labeled by worker name (Options.Name)
+func Metrics() monitoring.MetricsProvider {
+	return metrics
+}
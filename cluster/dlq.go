@@ -0,0 +1,304 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/spf13/cobra"
+)
+
+const (
+	dlqTopicFlagName  = "topic"
+	dlqFromFlagName   = "from"
+	dlqToFlagName     = "to"
+	dlqLimitFlagName  = "limit"
+	dlqOffsetFlagName = "offset"
+	dlqRangeFlagName  = "range"
+	dlqDryRunFlagName = "dry-run"
+	dlqRateFlagName   = "rate"
+	dlqModeFlagName   = "mode"
+)
+
+// DLQConfig configures dlq commands
+type DLQConfig struct {
+	Broker *kafka.BrokerConfig // Broker kafka broker config
+	Topic  string              // Topic dead-letter topic
+	Mode   string              // Mode message mode of origin topics used on replay (envelope by default)
+}
+
+// WithDLQ adds dlq commands allowing to inspect and replay messages of a dead-letter topic
+//
+//	dlq list    prints DLQ messages
+//	dlq count   counts DLQ messages by origin topic
+//	dlq dump    dumps DLQ messages as JSON, one message per line
+//	dlq replay  sends DLQ messages back to their origin topics
+//
+// messages are filtered by origin topic (--topic), time they landed in DLQ (--from, --to),
+// positions (--offset <partition>:<offset>) and ranges (--range <partition>:<from>-<to>)
+func (s *ServiceInstance[TCfg]) WithDLQ(getDLQConfigFn func(cfg *TCfg) (*DLQConfig, error)) *ServiceInstance[TCfg] {
+
+	dlqCmd := &cobra.Command{
+		Use:   "dlq",
+		Short: "inspect and replay dead-letter queue",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "print DLQ messages",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.executeDLQCmd(cmd, getDLQConfigFn, dlqList)
+		},
+	}
+	countCmd := &cobra.Command{
+		Use:   "count",
+		Short: "count DLQ messages by origin topic",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.executeDLQCmd(cmd, getDLQConfigFn, dlqCount)
+		},
+	}
+	dumpCmd := &cobra.Command{
+		Use:   "dump",
+		Short: "dump DLQ messages as JSON",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.executeDLQCmd(cmd, getDLQConfigFn, dlqDump)
+		},
+	}
+	replayCmd := &cobra.Command{
+		Use:   "replay",
+		Short: "send DLQ messages back to their origin topics",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return s.executeDLQCmd(cmd, getDLQConfigFn, dlqReplay)
+		},
+	}
+
+	dlqCmd.AddCommand(listCmd, countCmd, dumpCmd, replayCmd)
+	s.rootCmd.AddCommand(dlqCmd)
+
+	// set flags
+	flags := dlqCmd.PersistentFlags()
+	flags.String(dlqTopicFlagName, "", "--topic <origin-topic>")
+	flags.String(dlqFromFlagName, "", "--from <RFC3339 time or duration ago, e.g. 2h>")
+	flags.String(dlqToFlagName, "", "--to <RFC3339 time or duration ago, e.g. 30m>")
+	flags.Int(dlqLimitFlagName, 0, "--limit <max-messages>")
+	flags.StringArray(dlqOffsetFlagName, nil, "--offset <partition>:<offset>")
+	flags.StringArray(dlqRangeFlagName, nil, "--range <partition>:<from-offset>-<to-offset>")
+
+	flags = replayCmd.Flags()
+	flags.Bool(dlqDryRunFlagName, false, "--dry-run")
+	flags.Float64(dlqRateFlagName, 0, "--rate <messages-per-second>")
+	flags.String(dlqModeFlagName, "", "--mode <envelope/headers>")
+
+	return s
+}
+
+// dlqAction executes a dlq command
+type dlqAction func(ctx context.Context, cmd *cobra.Command, cfg *DLQConfig, inspector kafka.DLQInspector, filter *kafka.DLQFilter) error
+
+func (s *ServiceInstance[TCfg]) executeDLQCmd(cmd *cobra.Command, getDLQConfigFn func(cfg *TCfg) (*DLQConfig, error), action dlqAction) error {
+
+	// load config
+	config, err := s.loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	// extract config
+	dlqCfg, err := getDLQConfigFn(config)
+	if err != nil {
+		return err
+	}
+	if dlqCfg == nil || dlqCfg.Broker == nil || dlqCfg.Topic == "" {
+		return ErrDLQConfigInvalid()
+	}
+
+	filter, err := parseDLQFilter(cmd, kit.Now())
+	if err != nil {
+		return err
+	}
+
+	ctx, cancelFn := context.WithCancel(kit.NewRequestCtx().Empty().WithNewRequestId().WithApp(s.svcCode).ToContext(context.Background()))
+	defer cancelFn()
+
+	// init broker
+	broker := kafka.NewBroker(s.GetLogger())
+	if err := broker.Init(ctx, dlqCfg.Broker); err != nil {
+		return err
+	}
+	defer broker.Close(ctx)
+
+	return action(ctx, cmd, dlqCfg, broker.DLQ(dlqCfg.Topic), filter)
+}
+
+func dlqList(ctx context.Context, cmd *cobra.Command, _ *DLQConfig, inspector kafka.DLQInspector, filter *kafka.DLQFilter) error {
+	w := cmd.OutOrStdout()
+	return inspector.Read(ctx, filter, func(ctx context.Context, r *kafka.DLQRecord) error {
+		_, err := fmt.Fprintf(w, "%d:%d\t%s\t%s\t%s\n", r.Partition, r.Offset, r.Time.UTC().Format(time.RFC3339), r.Topic, r.Key)
+		return err
+	})
+}
+
+func dlqCount(ctx context.Context, cmd *cobra.Command, _ *DLQConfig, inspector kafka.DLQInspector, filter *kafka.DLQFilter) error {
+	counts, err := inspector.Count(ctx, filter)
+	if err != nil {
+		return err
+	}
+	topics := make([]string, 0, len(counts))
+	total := 0
+	for t, c := range counts {
+		topics = append(topics, t)
+		total += c
+	}
+	sort.Strings(topics)
+
+	w := cmd.OutOrStdout()
+	for _, t := range topics {
+		if _, err := fmt.Fprintf(w, "%s\t%d\n", t, counts[t]); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "total\t%d\n", total)
+	return err
+}
+
+func dlqDump(ctx context.Context, cmd *cobra.Command, _ *DLQConfig, inspector kafka.DLQInspector, filter *kafka.DLQFilter) error {
+	enc := json.NewEncoder(cmd.OutOrStdout())
+	return inspector.Read(ctx, filter, func(ctx context.Context, r *kafka.DLQRecord) error {
+		return enc.Encode(r)
+	})
+}
+
+func dlqReplay(ctx context.Context, cmd *cobra.Command, cfg *DLQConfig, inspector kafka.DLQInspector, filter *kafka.DLQFilter) error {
+	opts := &kafka.DLQReplayOptions{Mode: cfg.Mode}
+	opts.DryRun, _ = cmd.Flags().GetBool(dlqDryRunFlagName)
+	opts.Rate, _ = cmd.Flags().GetFloat64(dlqRateFlagName)
+	if mode, _ := cmd.Flags().GetString(dlqModeFlagName); mode != "" {
+		opts.Mode = mode
+	}
+	if opts.Rate < 0 {
+		return ErrDLQParamInvalid(dlqRateFlagName)
+	}
+
+	res, replayErr := inspector.Replay(ctx, filter, opts)
+	if res != nil {
+		if err := printDLQReplayResult(cmd.OutOrStdout(), res, opts.DryRun); err != nil {
+			return err
+		}
+	}
+	return replayErr
+}
+
+func printDLQReplayResult(w io.Writer, res *kafka.DLQReplayResult, dryRun bool) error {
+	topics := make([]string, 0, len(res.ByTopic))
+	for t := range res.ByTopic {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	for _, t := range topics {
+		if _, err := fmt.Fprintf(w, "%s\t%d\n", t, res.ByTopic[t]); err != nil {
+			return err
+		}
+	}
+	title := "replayed"
+	if dryRun {
+		title = "to be replayed (dry-run)"
+	}
+	if _, err := fmt.Fprintf(w, "%s\t%d\n", title, res.Replayed); err != nil {
+		return err
+	}
+	if res.Last != nil {
+		if _, err := fmt.Fprintf(w, "last\t%d:%d\n", res.Last.Partition, res.Last.Offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDLQFilter builds a filter by command flags
+func parseDLQFilter(cmd *cobra.Command, now time.Time) (*kafka.DLQFilter, error) {
+	f := &kafka.DLQFilter{}
+	var err error
+
+	flags := cmd.Flags()
+	f.Topic, _ = flags.GetString(dlqTopicFlagName)
+	f.Limit, _ = flags.GetInt(dlqLimitFlagName)
+	if f.Limit < 0 {
+		return nil, ErrDLQParamInvalid(dlqLimitFlagName)
+	}
+
+	if v, _ := flags.GetString(dlqFromFlagName); v != "" {
+		if f.From, err = parseDLQTime(v, now); err != nil {
+			return nil, ErrDLQParamInvalid(dlqFromFlagName)
+		}
+	}
+	if v, _ := flags.GetString(dlqToFlagName); v != "" {
+		if f.To, err = parseDLQTime(v, now); err != nil {
+			return nil, ErrDLQParamInvalid(dlqToFlagName)
+		}
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		return nil, ErrDLQParamInvalid(dlqToFlagName)
+	}
+
+	offsets, _ := flags.GetStringArray(dlqOffsetFlagName)
+	for _, v := range offsets {
+		partition, offset, ok := strings.Cut(v, ":")
+		if !ok {
+			return nil, ErrDLQParamInvalid(dlqOffsetFlagName)
+		}
+		p, err := strconv.Atoi(partition)
+		if err != nil || p < 0 {
+			return nil, ErrDLQParamInvalid(dlqOffsetFlagName)
+		}
+		o, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || o < 0 {
+			return nil, ErrDLQParamInvalid(dlqOffsetFlagName)
+		}
+		f.Positions = append(f.Positions, kafka.DLQPosition{Partition: p, Offset: o})
+	}
+
+	ranges, _ := flags.GetStringArray(dlqRangeFlagName)
+	for _, v := range ranges {
+		partition, rng, ok := strings.Cut(v, ":")
+		if !ok {
+			return nil, ErrDLQParamInvalid(dlqRangeFlagName)
+		}
+		from, to, ok := strings.Cut(rng, "-")
+		if !ok {
+			return nil, ErrDLQParamInvalid(dlqRangeFlagName)
+		}
+		p, err := strconv.Atoi(partition)
+		if err != nil || p < 0 {
+			return nil, ErrDLQParamInvalid(dlqRangeFlagName)
+		}
+		r := kafka.DLQRange{Partition: p}
+		if r.From, err = strconv.ParseInt(from, 10, 64); err != nil || r.From < 0 {
+			return nil, ErrDLQParamInvalid(dlqRangeFlagName)
+		}
+		if r.To, err = strconv.ParseInt(to, 10, 64); err != nil || r.To < r.From {
+			return nil, ErrDLQParamInvalid(dlqRangeFlagName)
+		}
+		f.Ranges = append(f.Ranges, r)
+	}
+
+	return f, nil
+}
+
+// parseDLQTime parses either RFC3339 time or duration ago
+func parseDLQTime(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(-d), nil
+}
@@ -0,0 +1,171 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/suite"
+)
+
+type dlqTestInspector struct {
+	records []*kafka.DLQRecord
+	opts    *kafka.DLQReplayOptions
+}
+
+func (i *dlqTestInspector) Read(ctx context.Context, filter *kafka.DLQFilter, fn kafka.DLQRecordFn) error {
+	for _, r := range i.records {
+		if filter.Topic != "" && filter.Topic != r.Topic {
+			continue
+		}
+		if err := fn(ctx, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *dlqTestInspector) Count(ctx context.Context, filter *kafka.DLQFilter) (map[string]int, error) {
+	r := map[string]int{}
+	_ = i.Read(ctx, filter, func(ctx context.Context, rec *kafka.DLQRecord) error {
+		r[rec.Topic]++
+		return nil
+	})
+	return r, nil
+}
+
+func (i *dlqTestInspector) Replay(ctx context.Context, filter *kafka.DLQFilter, opts *kafka.DLQReplayOptions) (*kafka.DLQReplayResult, error) {
+	i.opts = opts
+	res := &kafka.DLQReplayResult{ByTopic: map[string]int{}}
+	_ = i.Read(ctx, filter, func(ctx context.Context, rec *kafka.DLQRecord) error {
+		res.Replayed++
+		res.ByTopic[rec.Topic]++
+		res.Last = &kafka.DLQPosition{Partition: rec.Partition, Offset: rec.Offset}
+		return nil
+	})
+	return res, nil
+}
+
+type dlqTestSuite struct {
+	kit.Suite
+	inspector *dlqTestInspector
+	cmd       *cobra.Command
+	out       *bytes.Buffer
+}
+
+func (s *dlqTestSuite) SetupSuite() {
+	s.Suite.Init(func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) })
+}
+
+func (s *dlqTestSuite) SetupTest() {
+	tm := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	s.inspector = &dlqTestInspector{records: []*kafka.DLQRecord{
+		{Partition: 0, Offset: 1, Time: tm, Topic: "topic-1", Key: "key-1", FailedMessage: []byte(`{"payload":1}`)},
+		{Partition: 0, Offset: 2, Time: tm, Topic: "topic-2", Key: "key-2", FailedMessage: []byte(`{"payload":2}`)},
+		{Partition: 1, Offset: 1, Time: tm, Topic: "topic-1", Key: "key-3", FailedMessage: []byte("raw")},
+	}}
+
+	// build commands the same way the service instance does
+	s.out = &bytes.Buffer{}
+	svc := &ServiceInstance[TestConfigType]{rootCmd: &cobra.Command{}}
+	svc.WithDLQ(func(cfg *TestConfigType) (*DLQConfig, error) { return nil, nil })
+	s.cmd = svc.rootCmd
+	s.cmd.SetOut(s.out)
+}
+
+func TestDlqSuite(t *testing.T) {
+	suite.Run(t, new(dlqTestSuite))
+}
+
+// subCmd returns a dlq subcommand with parsed args
+func (s *dlqTestSuite) subCmd(args ...string) *cobra.Command {
+	cmd, rest, err := s.cmd.Find(append([]string{"dlq"}, args...))
+	s.NoError(err)
+	s.NoError(cmd.ParseFlags(rest))
+	return cmd
+}
+
+func (s *dlqTestSuite) filter(cmd *cobra.Command) *kafka.DLQFilter {
+	f, err := parseDLQFilter(cmd, time.Now())
+	s.NoError(err)
+	return f
+}
+
+func (s *dlqTestSuite) Test_ParseFilter() {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	cmd := s.subCmd("list", "--topic", "topic", "--from", "2h", "--to", "2025-01-01T09:30:00Z", "--limit", "10",
+		"--offset", "0:5", "--offset", "1:7", "--range", "2:10-20")
+	f, err := parseDLQFilter(cmd, now)
+	s.NoError(err)
+	s.Equal("topic", f.Topic)
+	s.Equal(now.Add(-time.Hour*2), f.From)
+	s.Equal(time.Date(2025, 1, 1, 9, 30, 0, 0, time.UTC), f.To)
+	s.Equal(10, f.Limit)
+	s.Equal([]kafka.DLQPosition{{Partition: 0, Offset: 5}, {Partition: 1, Offset: 7}}, f.Positions)
+	s.Equal([]kafka.DLQRange{{Partition: 2, From: 10, To: 20}}, f.Ranges)
+}
+
+func (s *dlqTestSuite) Test_ParseFilter_Invalid() {
+	for _, args := range [][]string{
+		{"--from", "yesterday"},
+		{"--from", "1h", "--to", "2h"},
+		{"--limit", "-1"},
+		{"--offset", "5"},
+		{"--offset", "a:5"},
+		{"--range", "1:10"},
+		{"--range", "1:20-10"},
+	} {
+		_, err := parseDLQFilter(s.subCmd(append([]string{"list"}, args...)...), time.Now())
+		s.AssertAppErr(err, ErrCodeDLQParamInvalid)
+	}
+}
+
+func (s *dlqTestSuite) Test_List() {
+	cmd := s.subCmd("list", "--topic", "topic-1")
+	s.NoError(dlqList(s.Ctx, cmd, &DLQConfig{}, s.inspector, s.filter(cmd)))
+	s.Equal("0:1\t2025-01-01T10:00:00Z\ttopic-1\tkey-1\n1:1\t2025-01-01T10:00:00Z\ttopic-1\tkey-3\n", s.out.String())
+}
+
+func (s *dlqTestSuite) Test_Count() {
+	cmd := s.subCmd("count")
+	s.NoError(dlqCount(s.Ctx, cmd, &DLQConfig{}, s.inspector, s.filter(cmd)))
+	s.Equal("topic-1\t2\ntopic-2\t1\ntotal\t3\n", s.out.String())
+}
+
+func (s *dlqTestSuite) Test_Dump() {
+	cmd := s.subCmd("dump")
+	s.NoError(dlqDump(s.Ctx, cmd, &DLQConfig{}, s.inspector, s.filter(cmd)))
+
+	lines := bytes.Split(bytes.TrimSpace(s.out.Bytes()), []byte("\n"))
+	s.Len(lines, 3)
+	var r map[string]any
+	s.NoError(json.Unmarshal(lines[0], &r))
+	s.Equal("topic-1", r["topic"])
+	s.Equal(map[string]any{"payload": float64(1)}, r["failedMessage"])
+	s.NoError(json.Unmarshal(lines[2], &r))
+	s.Equal("raw", r["failedMessage"])
+}
+
+func (s *dlqTestSuite) Test_Replay() {
+	cmd := s.subCmd("replay", "--topic", "topic-1", "--dry-run", "--rate", "5", "--mode", kafka.MessageModeHeaders)
+	s.NoError(dlqReplay(s.Ctx, cmd, &DLQConfig{}, s.inspector, s.filter(cmd)))
+	s.Equal(&kafka.DLQReplayOptions{Mode: kafka.MessageModeHeaders, DryRun: true, Rate: 5}, s.inspector.opts)
+	s.Equal("topic-1\t2\nto be replayed (dry-run)\t2\nlast\t1:1\n", s.out.String())
+}
+
+func (s *dlqTestSuite) Test_Replay_ModeFromConfig() {
+	cmd := s.subCmd("replay")
+	s.NoError(dlqReplay(s.Ctx, cmd, &DLQConfig{Mode: kafka.MessageModeHeaders}, s.inspector, s.filter(cmd)))
+	s.Equal(&kafka.DLQReplayOptions{Mode: kafka.MessageModeHeaders}, s.inspector.opts)
+	s.Contains(s.out.String(), "replayed\t3\n")
+}
+
+func (s *dlqTestSuite) Test_Replay_WhenRateInvalid_Fail() {
+	cmd := s.subCmd("replay", "--rate", "-1")
+	s.AssertAppErr(dlqReplay(s.Ctx, cmd, &DLQConfig{}, s.inspector, s.filter(cmd)), ErrCodeDLQParamInvalid)
+}
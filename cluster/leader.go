@@ -0,0 +1,259 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+)
+
+const (
+	leaderDefaultRenewPeriod = time.Second * 5
+	leaderDefaultRetryPeriod = time.Second * 5
+)
+
+// LeaderCallback is called when leadership is acquired or lost
+type LeaderCallback func(ctx context.Context)
+
+// LeaderElectionConfig leader election configuration
+type LeaderElectionConfig struct {
+	Key         string        // Key identifies the election, all the candidates must use the same key
+	RenewPeriod time.Duration // RenewPeriod how often a leader renews leadership (default: 5s)
+	RetryPeriod time.Duration // RetryPeriod how often a follower tries to become a leader (default: 5s)
+}
+
+// LeaderElection elects a single leader among service replicas on top of kit.DistributedLock
+// leadership is renewed automatically, once renewal fails leadership is revoked
+type LeaderElection interface {
+	goroutine.Leadership
+	// OnElected registers a callback called when the node becomes a leader
+	// context passed to the callback is cancelled once leadership is lost, callbacks run in separate goroutines, so they may block
+	OnElected(cb LeaderCallback)
+	// OnRevoked registers a callback called when the node loses leadership, callbacks run in separate goroutines
+	OnRevoked(cb LeaderCallback)
+	// IsLeader returns true if the node is currently a leader
+	IsLeader() bool
+	// Start starts campaigning
+	Start(ctx context.Context) error
+	// Close stops campaigning and resigns leadership
+	Close(ctx context.Context)
+}
+
+type leaderElectionImpl struct {
+	sync.RWMutex
+	lock       kit.DistributedLock
+	cfg        *LeaderElectionConfig
+	logger     kit.CLoggerFunc
+	nodeId     string
+	onElected  []LeaderCallback
+	onRevoked  []LeaderCallback
+	releaseId  string
+	leaderCtx  context.Context
+	leaderStop context.CancelFunc
+	electedCh  chan struct{}
+	cancelFn   context.CancelFunc
+	stoppedCh  chan struct{}
+}
+
+func NewLeaderElection(lock kit.DistributedLock, cfg *LeaderElectionConfig, nodeId string, logger kit.CLoggerFunc) LeaderElection {
+	c := &LeaderElectionConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.RenewPeriod <= 0 {
+		c.RenewPeriod = leaderDefaultRenewPeriod
+	}
+	if c.RetryPeriod <= 0 {
+		c.RetryPeriod = leaderDefaultRetryPeriod
+	}
+	return &leaderElectionImpl{
+		lock:      lock,
+		cfg:       c,
+		logger:    logger,
+		nodeId:    nodeId,
+		electedCh: make(chan struct{}),
+	}
+}
+
+func (e *leaderElectionImpl) l() kit.CLogger {
+	return e.logger().Cmp("leader-election").F(kit.KV{"key": e.cfg.Key, "node": e.nodeId})
+}
+
+func (e *leaderElectionImpl) OnElected(cb LeaderCallback) {
+	e.Lock()
+	defer e.Unlock()
+	e.onElected = append(e.onElected, cb)
+}
+
+func (e *leaderElectionImpl) OnRevoked(cb LeaderCallback) {
+	e.Lock()
+	defer e.Unlock()
+	e.onRevoked = append(e.onRevoked, cb)
+}
+
+func (e *leaderElectionImpl) IsLeader() bool {
+	e.RLock()
+	defer e.RUnlock()
+	return e.releaseId != ""
+}
+
+func (e *leaderElectionImpl) AwaitLeadership(ctx context.Context) (context.Context, error) {
+	for {
+		e.RLock()
+		leaderCtx, electedCh := e.leaderCtx, e.electedCh
+		e.RUnlock()
+
+		if leaderCtx != nil && leaderCtx.Err() == nil {
+			return leaderCtx, nil
+		}
+
+		select {
+		case <-electedCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (e *leaderElectionImpl) Start(ctx context.Context) error {
+	e.l().C(ctx).Mth("start").Dbg()
+
+	if e.cfg.Key == "" {
+		return ErrLeaderElectionKeyEmpty(ctx)
+	}
+
+	e.Lock()
+	var campaignCtx context.Context
+	campaignCtx, e.cancelFn = context.WithCancel(ctx)
+	e.stoppedCh = make(chan struct{})
+	stoppedCh := e.stoppedCh
+	e.Unlock()
+
+	goroutine.New().
+		WithLogger(e.l().Mth("campaign")).
+		Go(ctx, func() {
+			defer close(stoppedCh)
+			e.campaign(campaignCtx)
+		})
+
+	return nil
+}
+
+func (e *leaderElectionImpl) Close(ctx context.Context) {
+	e.l().C(ctx).Mth("close").Dbg()
+
+	e.Lock()
+	cancelFn, stoppedCh := e.cancelFn, e.stoppedCh
+	e.cancelFn = nil
+	e.Unlock()
+
+	if cancelFn == nil {
+		return
+	}
+	cancelFn()
+	<-stoppedCh
+}
+
+func (e *leaderElectionImpl) campaign(ctx context.Context) {
+	l := e.l().C(ctx).Mth("campaign").Dbg("started")
+
+	// resign when campaign stops, ctx is cancelled by then, so unlocking runs on a detached one
+	defer e.revoke(kit.Detach(ctx), true)
+
+	for {
+		period := e.cfg.RetryPeriod
+		if e.IsLeader() {
+			period = e.cfg.RenewPeriod
+			e.renew(ctx)
+		} else {
+			e.tryAcquire(ctx)
+		}
+		select {
+		case <-time.After(period):
+		case <-ctx.Done():
+			l.Dbg("stopped")
+			return
+		}
+	}
+}
+
+func (e *leaderElectionImpl) tryAcquire(ctx context.Context) {
+	releaseId, ok, err := e.lock.TryLock(ctx, e.cfg.Key)
+	if err != nil {
+		e.l().C(ctx).Mth("acquire").E(err).Err()
+		return
+	}
+	if !ok {
+		return
+	}
+
+	// become a leader
+	e.Lock()
+	e.releaseId = releaseId
+	// leader ctx is cancelled on revoking only
+	e.leaderCtx, e.leaderStop = context.WithCancel(kit.Detach(ctx))
+	leaderCtx := e.leaderCtx
+	callbacks := append([]LeaderCallback{}, e.onElected...)
+	// notify awaiting parties
+	close(e.electedCh)
+	e.electedCh = make(chan struct{})
+	e.Unlock()
+
+	e.l().C(ctx).Mth("acquire").Inf("elected")
+
+	for _, cb := range callbacks {
+		e.execCallback(leaderCtx, cb)
+	}
+}
+
+func (e *leaderElectionImpl) renew(ctx context.Context) {
+	e.RLock()
+	releaseId := e.releaseId
+	e.RUnlock()
+
+	extended, err := e.lock.Extend(ctx, e.cfg.Key, releaseId)
+	if err != nil {
+		// it's not safe to stay a leader if leadership cannot be confirmed
+		e.l().C(ctx).Mth("renew").E(err).Err()
+		e.revoke(ctx, true)
+		return
+	}
+	if !extended {
+		e.l().C(ctx).Mth("renew").Warn("leadership lost")
+		e.revoke(ctx, false)
+	}
+}
+
+func (e *leaderElectionImpl) revoke(ctx context.Context, unlock bool) {
+	e.Lock()
+	releaseId, leaderStop := e.releaseId, e.leaderStop
+	if releaseId == "" {
+		e.Unlock()
+		return
+	}
+	e.releaseId, e.leaderCtx, e.leaderStop = "", nil, nil
+	callbacks := append([]LeaderCallback{}, e.onRevoked...)
+	e.Unlock()
+
+	// cancel leader-only routines first
+	leaderStop()
+
+	if unlock {
+		e.lock.UnLock(ctx, e.cfg.Key, releaseId)
+	}
+
+	e.l().C(ctx).Mth("revoke").Inf("revoked")
+
+	for _, cb := range callbacks {
+		e.execCallback(ctx, cb)
+	}
+}
+
+// execCallback runs a callback in a separate goroutine, so that a callback awaiting ctx.Done() doesn't block campaigning
+func (e *leaderElectionImpl) execCallback(ctx context.Context, cb LeaderCallback) {
+	goroutine.New().WithLogger(e.l().C(ctx).Mth("callback")).Go(ctx, func() {
+		cb(ctx)
+	})
+}
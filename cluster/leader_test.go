@@ -0,0 +1,240 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/atomic"
+)
+
+type memLockStorage struct {
+	mu    sync.Mutex
+	locks map[string]string
+}
+
+func (m *memLockStorage) Lock(ctx context.Context, ref, releaseId string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.locks[ref]; ok {
+		return false, nil
+	}
+	m.locks[ref] = releaseId
+	return true, nil
+}
+
+func (m *memLockStorage) UnLock(ctx context.Context, ref, releaseId string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[ref] == releaseId {
+		delete(m.locks, ref)
+	}
+	return nil
+}
+
+func (m *memLockStorage) Extend(ctx context.Context, ref, releaseId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.locks[ref] == releaseId, nil
+}
+
+func (m *memLockStorage) held(ref string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.locks[ref]
+	return ok
+}
+
+func (m *memLockStorage) steal(ref string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.locks[ref] = kit.NewRandString()
+}
+
+func (m *memLockStorage) release(ref string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.locks, ref)
+}
+
+type leaderTestSuite struct {
+	kit.Suite
+	storage *memLockStorage
+	lock    kit.DistributedLock
+}
+
+func (s *leaderTestSuite) SetupSuite() {
+	s.Suite.Init(func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) })
+}
+
+func (s *leaderTestSuite) SetupTest() {
+	s.storage = &memLockStorage{locks: map[string]string{}}
+	s.lock = kit.NewDistributedLock(s.storage, &kit.DistributedLockCfg{}, s.L)
+}
+
+func TestLeaderSuite(t *testing.T) {
+	suite.Run(t, new(leaderTestSuite))
+}
+
+func (s *leaderTestSuite) cfg(key string) *LeaderElectionConfig {
+	return &LeaderElectionConfig{
+		Key:         key,
+		RenewPeriod: time.Millisecond * 50,
+		RetryPeriod: time.Millisecond * 50,
+	}
+}
+
+func (s *leaderTestSuite) Test_WhenKeyEmpty_Fail() {
+	le := NewLeaderElection(s.lock, &LeaderElectionConfig{}, "node", s.L)
+	s.AssertAppErr(le.Start(s.Ctx), ErrCodeLeaderElectionKeyEmpty)
+}
+
+func (s *leaderTestSuite) Test_SingleLeader() {
+	key := kit.NewRandString()
+
+	elected := atomic.NewInt32(0)
+	var nodes []LeaderElection
+	for i := 0; i < 3; i++ {
+		le := NewLeaderElection(s.lock, s.cfg(key), kit.NewRandString(), s.L)
+		le.OnElected(func(ctx context.Context) { elected.Inc() })
+		s.NoError(le.Start(s.Ctx))
+		nodes = append(nodes, le)
+	}
+
+	// await a leader elected
+	s.NoError(<-kit.Await(func() (bool, error) { return elected.Load() == 1, nil }, time.Millisecond*50, time.Second*3))
+	time.Sleep(time.Millisecond * 200)
+	s.Equal(int32(1), elected.Load())
+
+	// only one leader
+	var leader LeaderElection
+	for _, n := range nodes {
+		if n.IsLeader() {
+			s.Nil(leader)
+			leader = n
+		}
+	}
+	s.NotNil(leader)
+
+	// leader resigns, another one is elected
+	leader.Close(s.Ctx)
+	s.False(leader.IsLeader())
+	s.NoError(<-kit.Await(func() (bool, error) { return elected.Load() == 2, nil }, time.Millisecond*50, time.Second*3))
+
+	for _, n := range nodes {
+		n.Close(s.Ctx)
+	}
+}
+
+func (s *leaderTestSuite) Test_WhenCallbackBlocks_LeadershipRevoked() {
+	key := kit.NewRandString()
+
+	released := make(chan struct{})
+	le := NewLeaderElection(s.lock, s.cfg(key), kit.NewRandString(), s.L)
+	// the callback blocks until leadership is lost
+	le.OnElected(func(ctx context.Context) {
+		<-ctx.Done()
+		close(released)
+	})
+	s.NoError(le.Start(s.Ctx))
+	defer le.Close(s.Ctx)
+
+	_, err := le.AwaitLeadership(s.Ctx)
+	s.NoError(err)
+
+	// campaigning isn't blocked, so leadership loss is detected
+	s.storage.steal(key)
+
+	select {
+	case <-released:
+	case <-time.After(time.Second * 3):
+		s.Fail("not revoked")
+	}
+	s.False(le.IsLeader())
+}
+
+func (s *leaderTestSuite) Test_Close_LockReleasedForAnotherNode() {
+	key := kit.NewRandString()
+
+	// campaign ctx has no request context, so it's cancelled as it is on closing
+	le1 := NewLeaderElection(s.lock, s.cfg(key), kit.NewRandString(), s.L)
+	s.NoError(le1.Start(context.Background()))
+	_, err := le1.AwaitLeadership(s.Ctx)
+	s.NoError(err)
+
+	le1.Close(s.Ctx)
+	s.False(s.storage.held(key))
+
+	// another node acquires the lock at once
+	le2 := NewLeaderElection(s.lock, s.cfg(key), kit.NewRandString(), s.L)
+	s.NoError(le2.Start(context.Background()))
+	defer le2.Close(s.Ctx)
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Millisecond*500)
+	defer cancel()
+	_, err = le2.AwaitLeadership(ctx)
+	s.NoError(err)
+}
+
+func (s *leaderTestSuite) Test_WhenLeadershipLost_Revoked() {
+	key := kit.NewRandString()
+
+	revoked := make(chan struct{})
+	le := NewLeaderElection(s.lock, s.cfg(key), kit.NewRandString(), s.L)
+	le.OnRevoked(func(ctx context.Context) { close(revoked) })
+	s.NoError(le.Start(s.Ctx))
+	defer le.Close(s.Ctx)
+
+	leaderCtx, err := le.AwaitLeadership(s.Ctx)
+	s.NoError(err)
+	s.True(le.IsLeader())
+
+	// someone else takes the lock
+	s.storage.steal(key)
+
+	select {
+	case <-revoked:
+	case <-time.After(time.Second * 3):
+		s.Fail("not revoked")
+	}
+	s.False(le.IsLeader())
+	s.Error(leaderCtx.Err())
+}
+
+func (s *leaderTestSuite) Test_GoLeader() {
+	key := kit.NewRandString()
+
+	le := NewLeaderElection(s.lock, s.cfg(key), kit.NewRandString(), s.L)
+	s.NoError(le.Start(s.Ctx))
+	defer le.Close(s.Ctx)
+
+	ctx, cancel := context.WithCancel(s.Ctx)
+	defer cancel()
+
+	running, terms := atomic.NewBool(false), atomic.NewInt32(0)
+	goroutine.New().WithLoggerFn(s.L).GoLeader(ctx, le, func(ctx context.Context) {
+		terms.Inc()
+		running.Store(true)
+		<-ctx.Done()
+		running.Store(false)
+	})
+
+	s.NoError(<-kit.Await(func() (bool, error) { return running.Load(), nil }, time.Millisecond*50, time.Second*3))
+
+	// leadership lost, routine is cancelled
+	s.storage.steal(key)
+	s.NoError(<-kit.Await(func() (bool, error) { return !running.Load(), nil }, time.Millisecond*50, time.Second*3))
+
+	// leadership is back, routine is run again
+	s.storage.release(key)
+	s.NoError(<-kit.Await(func() (bool, error) { return running.Load() && terms.Load() == 2, nil }, time.Millisecond*50, time.Second*3))
+}
@@ -45,6 +45,9 @@ const (
 	ErrCodeApplyConfigMkdirTempFailed  = "SVS-007"
 	ErrCodeApplyConfigReadFileFailed   = "SVS-008"
 	ErrCodeApplyConfigWriteFileFailed  = "SVS-009"
+	ErrCodeLeaderElectionKeyEmpty      = "SVS-010"
+	ErrCodeDLQConfigInvalid            = "SVS-011"
+	ErrCodeDLQParamInvalid             = "SVS-012"
 )
 
 var (
@@ -75,6 +78,15 @@ var (
 	ErrApplyConfigWriteFileFailed = func(err error) error {
 		return kit.NewAppErrBuilder(ErrCodeApplyConfigWriteFileFailed, "apply config writing file failed").Wrap(err).Err()
 	}
+	ErrLeaderElectionKeyEmpty = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeLeaderElectionKeyEmpty, "leader election key empty").C(ctx).Err()
+	}
+	ErrDLQConfigInvalid = func() error {
+		return kit.NewAppErrBuilder(ErrCodeDLQConfigInvalid, "dlq config isn't valid").Business().Err()
+	}
+	ErrDLQParamInvalid = func(param string) error {
+		return kit.NewAppErrBuilder(ErrCodeDLQParamInvalid, "dlq command param isn't valid").F(kit.KV{"param": param}).Business().Err()
+	}
 )
 
 type ServiceInstance[TCfg any] struct {
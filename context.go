@@ -192,6 +192,18 @@ func FromMap(ctx context.Context, mp map[string]interface{}) (context.Context, e
 	return r.ToContext(ctx), nil
 }
 
+// Detach returns a context which isn't cancelled along with ctx, values of ctx are kept
+// the request context is cloned, so that modifying it on either side doesn't affect the other one
+func Detach(ctx context.Context) context.Context {
+	detached := context.WithoutCancel(ctx)
+	if r, ok := Request(ctx); ok {
+		if ct, err := FromMap(detached, r.ToMap()); err == nil {
+			return ct
+		}
+	}
+	return detached
+}
+
 func Copy(ctx context.Context) context.Context {
 	if r, ok := Request(ctx); ok {
 		ct, err := FromMap(context.TODO(), r.ToMap())
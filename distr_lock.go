@@ -7,12 +7,16 @@ import (
 
 const (
 	ErrCodeDistributedLockFailed = "DLK-001"
+	ErrCodeDistributedLockExtend = "DLK-002"
 )
 
 var (
 	ErrLock = func(ctx context.Context, err error, ref string) error {
 		return NewAppErrBuilder(ErrCodeDistributedLockFailed, "failed to acquire lock").Wrap(err).C(ctx).F(KV{"ref": ref}).Err()
 	}
+	ErrLockExtend = func(ctx context.Context, err error, ref string) error {
+		return NewAppErrBuilder(ErrCodeDistributedLockExtend, "failed to extend lock").Wrap(err).C(ctx).F(KV{"ref": ref}).Err()
+	}
 )
 
 type DistributedLockStorage interface {
@@ -22,8 +26,23 @@ type DistributedLockStorage interface {
 	UnLock(ctx context.Context, ref, releaseId string) error
 }
 
+// DistributedLockExtender might be implemented by DistributedLockStorage supporting leases
+type DistributedLockExtender interface {
+	// Extend prolongs the lease of a lock owned by releaseId
+	// it returns false if the lock isn't owned by releaseId anymore
+	Extend(ctx context.Context, ref, releaseId string) (bool, error)
+}
+
 type DistributedLock interface {
+	// Lock acquires a lock awaiting for AwaitPeriod, it returns releaseId which must be passed to UnLock
 	Lock(ctx context.Context, ref string) (string, error)
+	// TryLock makes a single attempt to acquire a lock
+	// if the lock is held by someone else, it returns false without error
+	TryLock(ctx context.Context, ref string) (string, bool, error)
+	// Extend prolongs the lease of the lock if storage supports it (see DistributedLockExtender)
+	// it returns false if the lock isn't held by releaseId anymore
+	Extend(ctx context.Context, ref, releaseId string) (bool, error)
+	// UnLock releases the lock
 	UnLock(ctx context.Context, ref, releaseId string)
 }
 
@@ -69,6 +88,35 @@ func (s *distributedLockSvcImpl) Lock(ctx context.Context, ref string) (string,
 	return releaseId, nil
 }
 
+func (s *distributedLockSvcImpl) TryLock(ctx context.Context, ref string) (string, bool, error) {
+	l := s.l().C(ctx).Mth("try-lock").F(KV{"ref": ref}).Dbg()
+
+	releaseId := NewRandString()
+	locked, err := s.storage.Lock(ctx, ref, releaseId)
+	if err != nil {
+		return "", false, ErrLock(ctx, err, ref)
+	}
+	if !locked {
+		return "", false, nil
+	}
+
+	l.Dbg("locked")
+	return releaseId, true, nil
+}
+
+func (s *distributedLockSvcImpl) Extend(ctx context.Context, ref, releaseId string) (bool, error) {
+	extender, ok := s.storage.(DistributedLockExtender)
+	if !ok {
+		// no leases, the lock is held unless released
+		return true, nil
+	}
+	extended, err := extender.Extend(ctx, ref, releaseId)
+	if err != nil {
+		return false, ErrLockExtend(ctx, err, ref)
+	}
+	return extended, nil
+}
+
 func (s *distributedLockSvcImpl) UnLock(ctx context.Context, ref, releaseId string) {
 	l := s.l().C(ctx).Mth("unlock").F(KV{"ref": ref, "releaseId": releaseId}).Dbg()
 	if err := s.storage.UnLock(ctx, ref, releaseId); err != nil {
@@ -0,0 +1,153 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/event"
+	"github.com/mikhailbolshakov/kit/kafka"
+)
+
+// HeaderOrigin kafka header carrying id of the node which published an event
+const HeaderOrigin = "x-event-origin"
+
+// DeliveryMode defines where events published to a bridged topic are handled
+type DeliveryMode int
+
+const (
+	// DeliveryLocalAndRemote events are handled by local subscribers at once and sent to kafka
+	// events received from kafka are handled locally unless they are published by the node itself
+	DeliveryLocalAndRemote DeliveryMode = iota
+	// DeliveryRemoteOnly events are only sent to kafka, local subscribers handle events received from kafka
+	// so that all nodes (including the publisher) handle an event the same way
+	DeliveryRemoteOnly
+)
+
+// TopicConfig binding of a bus topic to a kafka topic
+type TopicConfig[T any] struct {
+	Topic      *kafka.TopicConfig      // Topic kafka topic
+	Producer   *kafka.ProducerConfig   // Producer producer configuration (default: kafka.NewProducerCfgBuilder().Build())
+	Subscriber *kafka.SubscriberConfig // Subscriber subscriber configuration, if nil events aren't received from kafka; each node should have its own group to receive all events
+	Mode       DeliveryMode            // Mode delivery mode (default: DeliveryLocalAndRemote)
+	Key        func(data T) string     // Key returns a kafka key of an event, events with the same key keep order (default: no key)
+}
+
+// Bridge maps bus topics to kafka topics in both directions
+// the request context is carried by kafka messages and restored for local subscribers
+type Bridge struct {
+	nodeId string
+	broker kafka.Broker
+	logger kit.CLoggerFunc
+}
+
+// New creates a bridge, nodeId identifies the node among publishers of events (default: random)
+func New(logger kit.CLoggerFunc, broker kafka.Broker, nodeId string) *Bridge {
+	if nodeId == "" {
+		nodeId = kit.NewId()
+	}
+	return &Bridge{
+		nodeId: nodeId,
+		broker: broker,
+		logger: logger,
+	}
+}
+
+func (b *Bridge) l() kit.CLogger {
+	return b.logger().Cmp("event-bridge")
+}
+
+// NodeId returns id of the node
+func (b *Bridge) NodeId() string {
+	return b.nodeId
+}
+
+// remoteTopic is a bus topic which sends published events to kafka
+type remoteTopic[T any] struct {
+	event.Topic[T]
+	bridge   *Bridge
+	producer kafka.Producer
+	cfg      *TopicConfig[T]
+}
+
+// Bind binds the bus topic to the kafka topic, it must be called before the broker is started
+// events must be published to the returned topic to reach kafka, subscribing to it is the same as to the bus topic
+// events received from kafka are published to the bus topic, so they are never sent back
+func Bind[T any](ctx context.Context, b *Bridge, topic event.Topic[T], cfg *TopicConfig[T]) (event.Topic[T], error) {
+	l := b.l().C(ctx).Mth("bind").F(kit.KV{"topic": topic.Name()})
+
+	if cfg == nil || cfg.Topic == nil {
+		return nil, ErrBridgeTopicEmpty(ctx, topic.Name())
+	}
+	producerCfg := cfg.Producer
+	if producerCfg == nil {
+		producerCfg = kafka.NewProducerCfgBuilder().Build()
+	}
+	producer, err := b.broker.AddProducer(ctx, cfg.Topic, producerCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Subscriber != nil {
+		if err := b.broker.AddMessageSubscriber(ctx, cfg.Topic, cfg.Subscriber, receive(b, topic, cfg)); err != nil {
+			return nil, err
+		}
+	}
+
+	l.F(kit.KV{"kafkaTopic": cfg.Topic.Topic, "mode": cfg.Mode}).Dbg("ok")
+	return &remoteTopic[T]{
+		Topic:    topic,
+		bridge:   b,
+		producer: producer,
+		cfg:      cfg,
+	}, nil
+}
+
+// Publish delivers an event according to the delivery mode
+// with DeliveryLocalAndRemote an event failed by local subscribers isn't sent to kafka
+// an event published while handling the same topic's event received from kafka is delivered locally only, so it isn't sent back to kafka
+func (t *remoteTopic[T]) Publish(ctx context.Context, data T) error {
+	if event.IsRemoteOrigin(ctx, t.Name()) {
+		return t.Topic.Publish(ctx, data)
+	}
+	if t.cfg.Mode == DeliveryLocalAndRemote {
+		if err := t.Topic.Publish(ctx, data); err != nil {
+			return err
+		}
+	}
+	return t.send(ctx, data)
+}
+
+func (t *remoteTopic[T]) send(ctx context.Context, data T) error {
+	msg := &kafka.Message{
+		Payload: data,
+		Headers: map[string]string{HeaderOrigin: t.bridge.nodeId},
+	}
+	if t.cfg.Key != nil {
+		msg.Key = t.cfg.Key(data)
+	}
+	if err := t.producer.SendMany(ctx, msg); err != nil {
+		return err
+	}
+	t.bridge.l().C(ctx).Mth("send").F(kit.KV{"topic": t.Name(), "kafkaTopic": t.cfg.Topic.Topic}).Trc()
+	return nil
+}
+
+// receive publishes events received from kafka to the bus topic
+func receive[T any](b *Bridge, topic event.Topic[T], cfg *TopicConfig[T]) kafka.MessageHandlerFn {
+	return func(ctx context.Context, m *kafka.MessageDescriptor) error {
+		l := b.l().C(ctx).Mth("receive").F(kit.KV{"topic": topic.Name(), "kafkaTopic": m.Topic})
+
+		// the event has been handled locally on publishing
+		if cfg.Mode == DeliveryLocalAndRemote && m.Headers[HeaderOrigin] == b.nodeId {
+			l.Trc("own event, skip")
+			return nil
+		}
+
+		var data T
+		if err := m.Decode(ctx, &data); err != nil {
+			return err
+		}
+		l.Trc()
+		return topic.Publish(event.WithRemoteOrigin(ctx, topic.Name()), data)
+	}
+}
@@ -0,0 +1,183 @@
+package bridge
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/event"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/stretchr/testify/suite"
+)
+
+type bridgeTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *bridgeTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestBridgeSuite(t *testing.T) {
+	suite.Run(t, new(bridgeTestSuite))
+}
+
+type orderCreated struct {
+	Id string `json:"id"`
+}
+
+// received collects events handled by a node
+type received struct {
+	sync.Mutex
+	events []*orderCreated
+	rids   []string
+}
+
+func (r *received) handler(ctx context.Context, e *orderCreated) error {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, e)
+	if rq, ok := kit.Request(ctx); ok {
+		r.rids = append(r.rids, rq.GetRequestId())
+	}
+	return nil
+}
+
+func (r *received) len() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.events)
+}
+
+// node is a process with a broker and a bridged topic
+type node struct {
+	broker kafka.Broker
+	local  event.Topic[*orderCreated]
+	topic  event.Topic[*orderCreated]
+	rcv    *received
+}
+
+func (s *bridgeTestSuite) node(nodeId, url, kafkaTopic string, mode DeliveryMode) *node {
+	broker := kafka.NewMemoryBroker(s.logger)
+	s.NoError(broker.Init(s.Ctx, &kafka.BrokerConfig{ClientId: nodeId, Url: url, TopicAutoCreation: true}))
+	n := &node{
+		broker: broker,
+		local:  event.NewTopic[*orderCreated](s.logger, "order.created", event.ErrorPolicyStop),
+		rcv:    &received{},
+	}
+	n.local.Subscribe(n.rcv.handler)
+	var err error
+	n.topic, err = Bind(s.Ctx, New(s.logger, broker, nodeId), n.local, &TopicConfig[*orderCreated]{
+		Topic:      &kafka.TopicConfig{Topic: kafkaTopic},
+		Subscriber: kafka.NewSubscriberCfgBuilder().GroupId(nodeId).Workers(1).CommitInterval(time.Millisecond * 50).Build(),
+		Mode:       mode,
+		Key:        func(e *orderCreated) string { return e.Id },
+	})
+	s.NoError(err)
+	s.NoError(broker.Start(s.Ctx))
+	return n
+}
+
+func (s *bridgeTestSuite) await(f func() bool) {
+	if err := <-kit.Await(func() (bool, error) { return f(), nil }, time.Millisecond*20, time.Second*3); err != nil {
+		s.Fatal(err)
+	}
+}
+
+func (s *bridgeTestSuite) Test_LocalAndRemote() {
+	url, kafkaTopic := kit.NewRandString(), kit.NewRandString()
+	node1 := s.node("node-1", url, kafkaTopic, DeliveryLocalAndRemote)
+	defer node1.broker.Close(s.Ctx)
+	node2 := s.node("node-2", url, kafkaTopic, DeliveryLocalAndRemote)
+	defer node2.broker.Close(s.Ctx)
+
+	rqCtx := kit.NewRequestCtx().WithNewRequestId()
+	s.NoError(node1.topic.Publish(rqCtx.ToContext(s.Ctx), &orderCreated{Id: "1"}))
+
+	// handled locally at once
+	s.Equal(1, node1.rcv.len())
+	// handled remotely with the request context
+	s.await(func() bool { return node2.rcv.len() == 1 })
+	s.Equal("1", node2.rcv.events[0].Id)
+	s.Equal([]string{rqCtx.GetRequestId()}, node2.rcv.rids)
+
+	// the own event isn't handled again
+	time.Sleep(time.Millisecond * 200)
+	s.Equal(1, node1.rcv.len())
+}
+
+func (s *bridgeTestSuite) Test_RemoteOnly() {
+	url, kafkaTopic := kit.NewRandString(), kit.NewRandString()
+	node1 := s.node("node-1", url, kafkaTopic, DeliveryRemoteOnly)
+	defer node1.broker.Close(s.Ctx)
+	node2 := s.node("node-2", url, kafkaTopic, DeliveryRemoteOnly)
+	defer node2.broker.Close(s.Ctx)
+
+	s.NoError(node1.topic.Publish(s.Ctx, &orderCreated{Id: "1"}))
+
+	// not handled locally on publishing, but once received from kafka
+	s.Equal(0, node1.rcv.len())
+	s.await(func() bool { return node1.rcv.len() == 1 && node2.rcv.len() == 1 })
+	time.Sleep(time.Millisecond * 200)
+	s.Equal(1, node1.rcv.len())
+	s.Equal(1, node2.rcv.len())
+}
+
+func (s *bridgeTestSuite) Test_ReceivedEventRepublished_NotSentBack() {
+	url, kafkaTopic := kit.NewRandString(), kit.NewRandString()
+	node1 := s.node("node-1", url, kafkaTopic, DeliveryLocalAndRemote)
+	defer node1.broker.Close(s.Ctx)
+	node2 := s.node("node-2", url, kafkaTopic, DeliveryLocalAndRemote)
+	defer node2.broker.Close(s.Ctx)
+
+	// node-2 republishes events it receives, they are delivered locally only
+	republished := &received{}
+	node2.local.Subscribe(func(ctx context.Context, e *orderCreated) error {
+		if e.Id != "1" {
+			return nil
+		}
+		return node2.topic.Publish(ctx, &orderCreated{Id: "republished"})
+	})
+	node2.local.Subscribe(republished.handler)
+
+	s.NoError(node1.topic.Publish(s.Ctx, &orderCreated{Id: "1"}))
+	s.await(func() bool { return republished.len() == 2 })
+	time.Sleep(time.Millisecond * 200)
+	s.Equal(1, node1.rcv.len())
+}
+
+func (s *bridgeTestSuite) Test_ReceivedEventRepublishedAsync_NotSentBack() {
+	url, kafkaTopic := kit.NewRandString(), kit.NewRandString()
+	node1 := s.node("node-1", url, kafkaTopic, DeliveryLocalAndRemote)
+	defer node1.broker.Close(s.Ctx)
+	node2 := s.node("node-2", url, kafkaTopic, DeliveryLocalAndRemote)
+	defer node2.broker.Close(s.Ctx)
+
+	// node-2 republishes events it receives by an async handler, which context is copied
+	republished := &received{}
+	node2.local.SubscribeAsync(func(ctx context.Context, e *orderCreated) error {
+		if e.Id != "1" {
+			return nil
+		}
+		return node2.topic.Publish(ctx, &orderCreated{Id: "republished"})
+	}, false)
+	node2.local.Subscribe(republished.handler)
+
+	rqCtx := kit.NewRequestCtx().WithNewRequestId()
+	s.NoError(node1.topic.Publish(rqCtx.ToContext(s.Ctx), &orderCreated{Id: "1"}))
+	s.await(func() bool { return republished.len() == 2 })
+	s.Equal([]string{rqCtx.GetRequestId(), rqCtx.GetRequestId()}, republished.rids)
+	time.Sleep(time.Millisecond * 200)
+	s.Equal(1, node1.rcv.len())
+}
+
+func (s *bridgeTestSuite) Test_Bind_WhenNoTopic_Fail() {
+	broker := kafka.NewMemoryBroker(s.logger)
+	s.NoError(broker.Init(s.Ctx, &kafka.BrokerConfig{ClientId: "node", Url: kit.NewRandString()}))
+	_, err := Bind(s.Ctx, New(s.logger, broker, ""), event.NewTopic[int](s.logger, "topic", event.ErrorPolicyStop), &TopicConfig[int]{})
+	s.AssertAppErr(err, ErrCodeBridgeTopicEmpty)
+}
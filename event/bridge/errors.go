@@ -0,0 +1,17 @@
+package bridge
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	ErrCodeBridgeTopicEmpty = "BRG-001"
+)
+
+var (
+	ErrBridgeTopicEmpty = func(ctx context.Context, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeBridgeTopicEmpty, "kafka topic must be specified").C(ctx).F(kit.KV{"topic": topic}).Err()
+	}
+)
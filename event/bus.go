@@ -11,6 +11,7 @@ import (
 const (
 	ErrCodeBusNotReflectType = "BUS-001"
 	ErrCodeBusTopicNotExists = "BUS-002"
+	ErrCodeBusHandlerPanic   = "BUS-003"
 )
 
 var (
@@ -20,6 +21,9 @@ var (
 	ErrBusTopicNotExists = func(topic string) error {
 		return kit.NewAppErrBuilder(ErrCodeBusTopicNotExists, "topic doesn't exists (%s)", topic).Err()
 	}
+	ErrBusHandlerPanic = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeBusHandlerPanic, "handler panic").Wrap(cause).C(ctx).F(kit.KV{"topic": topic}).Err()
+	}
 )
 
 // BusSubscriber defines subscription-related bus behavior
@@ -43,6 +47,8 @@ type BusController interface {
 }
 
 // Bus is a global (subscribe, publish, control) bus behavior
+//
+// Deprecated: handlers are called by reflection, so a wrong signature panics on publishing and errors are lost; use Topic
 type Bus interface {
 	BusController
 	BusSubscriber
@@ -0,0 +1,60 @@
+package event
+
+import (
+	"time"
+
+	"github.com/mikhailbolshakov/kit/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	EventsHandledCounter     = "event_bus_handled_counter"
+	HandlerErrorsCounter     = "event_bus_handler_errors_counter"
+	HandlerDurationHistogram = "event_bus_handler_duration"
+)
+
+// Metrics event bus prometheus metrics, it's populated by MetricsMiddleware
+// metrics are labeled by topic, so a single instance might be shared by all topics
+type Metrics struct {
+	handled  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics creates event bus metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		handled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: EventsHandledCounter,
+			Help: "Counts data handled by topic handlers",
+		}, []string{"topic"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: HandlerErrorsCounter,
+			Help: "Counts failed handler executions (including panics recovered)",
+		}, []string{"topic"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    HandlerDurationHistogram,
+			Help:    "Handler execution duration in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}, []string{"topic"}),
+	}
+}
+
+func (m *Metrics) handle(topic string, duration time.Duration, err error) {
+	m.handled.WithLabelValues(topic).Inc()
+	m.duration.WithLabelValues(topic).Observe(duration.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(topic).Inc()
+	}
+}
+
+// GetCollector implements monitoring.MetricsProvider
+func (m *Metrics) GetCollector() monitoring.MetricsCollector {
+	return func() monitoring.MetricsCollection {
+		return monitoring.MetricsCollection{
+			m.handled,
+			m.errors,
+			m.duration,
+		}
+	}
+}
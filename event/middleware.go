@@ -0,0 +1,50 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+// LoggingMiddleware logs handling of data and handler errors
+func LoggingMiddleware[T any](logger kit.CLoggerFunc) Middleware[T] {
+	return func(topic string, next Handler[T]) Handler[T] {
+		return func(ctx context.Context, data T) error {
+			l := logger().Cmp("event-topic").Mth("handle").C(ctx).F(kit.KV{"topic": topic})
+			l.TrcObj("%+v", data)
+			if err := next(ctx, data); err != nil {
+				l.E(err).Err()
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// RecoveryMiddleware converts a handler's panic to ErrCodeBusHandlerPanic error
+// it's recommended as the innermost middleware, so that other middlewares see the error
+func RecoveryMiddleware[T any]() Middleware[T] {
+	return func(topic string, next Handler[T]) Handler[T] {
+		return func(ctx context.Context, data T) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = ErrBusHandlerPanic(ctx, kit.ErrPanic(ctx, r), topic)
+				}
+			}()
+			return next(ctx, data)
+		}
+	}
+}
+
+// MetricsMiddleware collects handling metrics of a topic
+func MetricsMiddleware[T any](metrics *Metrics) Middleware[T] {
+	return func(topic string, next Handler[T]) Handler[T] {
+		return func(ctx context.Context, data T) error {
+			started := time.Now()
+			err := next(ctx, data)
+			metrics.handle(topic, time.Since(started), err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+)
+
+// Handler handles data published to a topic
+type Handler[T any] func(ctx context.Context, data T) error
+
+// Middleware wraps handlers of a topic (e.g. logging, metrics, panic recovery)
+type Middleware[T any] func(topic string, next Handler[T]) Handler[T]
+
+// ErrorPolicy defines how errors of synchronous handlers are treated on publishing
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyStop stops publishing on the first error and returns it, the rest of handlers aren't called
+	ErrorPolicyStop ErrorPolicy = iota
+	// ErrorPolicyContinue calls all handlers, errors are logged and publishing succeeds
+	ErrorPolicyContinue
+	// ErrorPolicyCollect calls all handlers and returns all errors joined
+	ErrorPolicyCollect
+)
+
+// Subscription allows unsubscribing a handler from a topic
+type Subscription interface {
+	// Unsubscribe removes the handler from the topic, calling it more than once does nothing
+	Unsubscribe()
+}
+
+// Topic is a type-safe bus of a single topic
+// handlers are called in order of subscription, sync handlers are called by the publisher, async ones in goroutines
+// errors of async handlers are logged as there is no one to return them to
+type Topic[T any] interface {
+	// Name returns name of the topic
+	Name() string
+	// Use adds middlewares wrapping all handlers, the first one added is the outermost
+	Use(middlewares ...Middleware[T])
+	// Subscribe subscribes a handler called synchronously
+	Subscribe(handler Handler[T]) Subscription
+	// SubscribeAsync subscribes a handler called asynchronously
+	// transactional handler processes published data serially in order of publishing, otherwise concurrently
+	SubscribeAsync(handler Handler[T], transactional bool) Subscription
+	// SubscribeOnce subscribes a handler called synchronously once, then it's removed
+	SubscribeOnce(handler Handler[T]) Subscription
+	// SubscribeOnceAsync subscribes a handler called asynchronously once, then it's removed
+	SubscribeOnceAsync(handler Handler[T]) Subscription
+	// Publish calls handlers subscribed with data, errors of sync handlers are treated according to the error policy
+	Publish(ctx context.Context, data T) error
+	// HasSubscribers returns true if any handler is subscribed
+	HasSubscribers() bool
+	// WaitAsync waits for all async handlers to complete
+	WaitAsync()
+}
+
+type subscription[T any] struct {
+	topic         *topic[T]
+	handler       Handler[T]
+	once          bool
+	async         bool
+	transactional bool
+	fired         atomic.Bool // fired once handler is called
+	mu            sync.Mutex  // protects queue of a transactional handler
+	queue         []func()
+	running       bool
+}
+
+type topic[T any] struct {
+	mu          sync.RWMutex
+	name        string
+	policy      ErrorPolicy
+	subs        []*subscription[T]
+	middlewares []Middleware[T]
+	wg          sync.WaitGroup
+	logger      kit.CLoggerFunc
+}
+
+// remoteCtxKey marks context of handling data delivered to a topic from outside of the process with the topic name
+type remoteCtxKey struct{}
+
+// WithRemoteOrigin marks ctx as handling data of the topic delivered from outside of the process (e.g. received from kafka)
+// the mark is kept for async handlers, so that data republished by them isn't sent out again
+func WithRemoteOrigin(ctx context.Context, topic string) context.Context {
+	return context.WithValue(ctx, remoteCtxKey{}, topic)
+}
+
+// IsRemoteOrigin returns true if ctx belongs to handling data of the topic delivered from outside of the process
+func IsRemoteOrigin(ctx context.Context, topic string) bool {
+	v, _ := ctx.Value(remoteCtxKey{}).(string)
+	return v == topic
+}
+
+// NewTopic creates a topic with the error policy
+func NewTopic[T any](logger kit.CLoggerFunc, name string, policy ErrorPolicy) Topic[T] {
+	return &topic[T]{
+		name:   name,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func (t *topic[T]) l() kit.CLogger {
+	return t.logger().Cmp("event-topic").F(kit.KV{"topic": t.name})
+}
+
+func (t *topic[T]) Name() string {
+	return t.name
+}
+
+func (t *topic[T]) Use(middlewares ...Middleware[T]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.middlewares = append(t.middlewares, middlewares...)
+}
+
+func (t *topic[T]) Subscribe(handler Handler[T]) Subscription {
+	return t.subscribe(&subscription[T]{handler: handler})
+}
+
+func (t *topic[T]) SubscribeAsync(handler Handler[T], transactional bool) Subscription {
+	return t.subscribe(&subscription[T]{handler: handler, async: true, transactional: transactional})
+}
+
+func (t *topic[T]) SubscribeOnce(handler Handler[T]) Subscription {
+	return t.subscribe(&subscription[T]{handler: handler, once: true})
+}
+
+func (t *topic[T]) SubscribeOnceAsync(handler Handler[T]) Subscription {
+	return t.subscribe(&subscription[T]{handler: handler, once: true, async: true})
+}
+
+func (t *topic[T]) subscribe(sub *subscription[T]) Subscription {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sub.topic = t
+	t.subs = append(t.subs, sub)
+	return sub
+}
+
+func (s *subscription[T]) Unsubscribe() {
+	s.topic.unsubscribe(s)
+}
+
+func (t *topic[T]) unsubscribe(sub *subscription[T]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// copy on write, so that publishers iterate their snapshots safely
+	subs := make([]*subscription[T], 0, len(t.subs))
+	for _, s := range t.subs {
+		if s != sub {
+			subs = append(subs, s)
+		}
+	}
+	t.subs = subs
+}
+
+func (t *topic[T]) HasSubscribers() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.subs) > 0
+}
+
+func (t *topic[T]) WaitAsync() {
+	t.wg.Wait()
+}
+
+func (t *topic[T]) Publish(ctx context.Context, data T) error {
+	l := t.l().C(ctx).Mth("publish")
+
+	// the lock isn't held while handlers are called, so handlers might publish and subscribe
+	t.mu.RLock()
+	subs, middlewares := t.subs, t.middlewares
+	t.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subs {
+		// once handler is called by the first publisher only
+		if sub.once {
+			if !sub.fired.CompareAndSwap(false, true) {
+				continue
+			}
+			sub.Unsubscribe()
+		}
+
+		handler := t.wrap(sub.handler, middlewares)
+		if sub.async {
+			t.publishAsync(ctx, sub, handler, data)
+			continue
+		}
+
+		if err := handler(ctx, data); err != nil {
+			switch t.policy {
+			case ErrorPolicyContinue:
+				l.E(err).Err()
+			case ErrorPolicyCollect:
+				errs = append(errs, err)
+			default:
+				return err
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// wrap wraps a handler with middlewares, the first middleware is the outermost
+func (t *topic[T]) wrap(handler Handler[T], middlewares []Middleware[T]) Handler[T] {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](t.name, handler)
+	}
+	return handler
+}
+
+func (t *topic[T]) publishAsync(ctx context.Context, sub *subscription[T], handler Handler[T], data T) {
+	l := t.l().C(ctx).Mth("publish-async")
+
+	// async handler shouldn't be cancelled along with the publisher's request, values (e.g. the remote origin mark) are kept
+	// and the request context is cloned, so that the handler doesn't share it with the publisher
+	ctx = kit.Detach(ctx)
+	run := func() {
+		defer t.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				l.E(kit.ErrPanic(ctx, r)).St().Err()
+			}
+		}()
+		if err := handler(ctx, data); err != nil {
+			l.E(err).Err()
+		}
+	}
+
+	t.wg.Add(1)
+	if !sub.transactional {
+		goroutine.New().WithLogger(l).Go(ctx, run)
+		return
+	}
+
+	// transactional handler has a queue drained by a single goroutine, so that data is handled in order of publishing
+	if sub.enqueue(run) {
+		goroutine.New().WithLogger(l).Go(ctx, func() {
+			for f, ok := sub.next(); ok; f, ok = sub.next() {
+				f()
+			}
+		})
+	}
+}
+
+// enqueue adds f to the queue, it returns true if the queue isn't drained and must be started
+func (s *subscription[T]) enqueue(f func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, f)
+	if s.running {
+		return false
+	}
+	s.running = true
+	return true
+}
+
+// next takes the next func from the queue, the queue is stopped if it's empty
+func (s *subscription[T]) next() (func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		s.running = false
+		return nil, false
+	}
+	f := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	return f, true
+}
@@ -0,0 +1,271 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/stretchr/testify/suite"
+)
+
+type topicTestSuite struct {
+	kit.Suite
+}
+
+func (s *topicTestSuite) SetupSuite() {
+	s.Suite.Init(func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) })
+}
+
+func TestTopicSuite(t *testing.T) {
+	suite.Run(t, new(topicTestSuite))
+}
+
+type topicEvent struct {
+	Val int
+}
+
+func (s *topicTestSuite) Test_Subscribe_Publish() {
+	topic := NewTopic[*topicEvent](s.L, "topic", ErrorPolicyStop)
+	s.False(topic.HasSubscribers())
+	var got []int
+	topic.Subscribe(func(ctx context.Context, e *topicEvent) error {
+		got = append(got, e.Val)
+		return nil
+	})
+	topic.Subscribe(func(ctx context.Context, e *topicEvent) error {
+		got = append(got, e.Val*10)
+		return nil
+	})
+	s.True(topic.HasSubscribers())
+	s.NoError(topic.Publish(s.Ctx, &topicEvent{Val: 1}))
+	s.Equal([]int{1, 10}, got)
+}
+
+func (s *topicTestSuite) Test_Unsubscribe() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	cnt := 0
+	sub := topic.Subscribe(func(ctx context.Context, v int) error {
+		cnt += v
+		return nil
+	})
+	s.NoError(topic.Publish(s.Ctx, 1))
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.Equal(1, cnt)
+	s.False(topic.HasSubscribers())
+}
+
+func (s *topicTestSuite) Test_SubscribeOnce() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	once, many := 0, 0
+	topic.SubscribeOnce(func(ctx context.Context, v int) error {
+		once++
+		return nil
+	})
+	topic.Subscribe(func(ctx context.Context, v int) error {
+		many++
+		return nil
+	})
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.Equal(1, once)
+	s.Equal(2, many)
+}
+
+func (s *topicTestSuite) Test_SubscribeOnceAsync_Concurrent() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	cnt := atomic.Int32{}
+	topic.SubscribeOnceAsync(func(ctx context.Context, v int) error {
+		cnt.Add(1)
+		return nil
+	})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.NoError(topic.Publish(s.Ctx, 1))
+		}()
+	}
+	wg.Wait()
+	topic.WaitAsync()
+	s.Equal(int32(1), cnt.Load())
+	s.False(topic.HasSubscribers())
+}
+
+func (s *topicTestSuite) Test_SubscribeAsync() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	sum := atomic.Int32{}
+	topic.SubscribeAsync(func(ctx context.Context, v int) error {
+		sum.Add(int32(v))
+		return errors.New("logged only")
+	}, false)
+	for i := 1; i <= 10; i++ {
+		s.NoError(topic.Publish(s.Ctx, i))
+	}
+	topic.WaitAsync()
+	s.Equal(int32(55), sum.Load())
+}
+
+func (s *topicTestSuite) Test_SubscribeAsync_DetachedCtx() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	rq := kit.NewRequestCtx().WithNewRequestId()
+	rid := rq.GetRequestId()
+	ctx, cancel := context.WithCancel(WithRemoteOrigin(rq.ToContext(context.Background()), "topic"))
+	release := make(chan struct{})
+	var errs []error
+	topic.SubscribeAsync(func(ctx context.Context, v int) error {
+		<-release
+		// the handler isn't cancelled along with the publisher's request
+		errs = append(errs, ctx.Err())
+		// the remote origin mark is kept
+		if !IsRemoteOrigin(ctx, "topic") {
+			errs = append(errs, errors.New("remote origin lost"))
+		}
+		// the request context is cloned, publisher's changes aren't seen
+		if r, ok := kit.Request(ctx); !ok || r.GetRequestId() != rid {
+			errs = append(errs, errors.New("request context isn't cloned"))
+		}
+		return nil
+	}, false)
+	s.NoError(topic.Publish(ctx, 1))
+	cancel()
+	rq.WithNewRequestId()
+	close(release)
+	topic.WaitAsync()
+	s.Equal([]error{nil}, errs)
+}
+
+func (s *topicTestSuite) Test_SubscribeAsync_Transactional_KeepsOrder() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	var got []int
+	topic.SubscribeAsync(func(ctx context.Context, v int) error {
+		// the earlier data takes longer, but it's still handled first
+		time.Sleep(time.Millisecond * time.Duration(10-v))
+		got = append(got, v)
+		return nil
+	}, true)
+	for i := 0; i < 10; i++ {
+		s.NoError(topic.Publish(s.Ctx, i))
+	}
+	topic.WaitAsync()
+	s.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, got)
+}
+
+func (s *topicTestSuite) Test_SubscribeAsync_Transactional_DoesNotBlockPublisher() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	release := make(chan struct{})
+	topic.SubscribeAsync(func(ctx context.Context, v int) error {
+		<-release
+		return nil
+	}, true)
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.NoError(topic.Publish(s.Ctx, 2))
+	close(release)
+	topic.WaitAsync()
+}
+
+func (s *topicTestSuite) Test_ErrorPolicyStop() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	called := false
+	topic.Subscribe(func(ctx context.Context, v int) error { return errors.New("first") })
+	topic.Subscribe(func(ctx context.Context, v int) error {
+		called = true
+		return nil
+	})
+	s.EqualError(topic.Publish(s.Ctx, 1), "first")
+	s.False(called)
+}
+
+func (s *topicTestSuite) Test_ErrorPolicyContinue() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyContinue)
+	called := false
+	topic.Subscribe(func(ctx context.Context, v int) error { return errors.New("first") })
+	topic.Subscribe(func(ctx context.Context, v int) error {
+		called = true
+		return nil
+	})
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.True(called)
+}
+
+func (s *topicTestSuite) Test_ErrorPolicyCollect() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyCollect)
+	errFirst := errors.New("first")
+	topic.Subscribe(func(ctx context.Context, v int) error { return errFirst })
+	topic.Subscribe(func(ctx context.Context, v int) error { return nil })
+	topic.Subscribe(func(ctx context.Context, v int) error {
+		return kit.NewAppErrBuilder("TST-001", "second").Err()
+	})
+	err := topic.Publish(s.Ctx, 1)
+	s.ErrorIs(err, errFirst)
+	s.AssertAppErr(err, "TST-001")
+}
+
+func (s *topicTestSuite) Test_Middlewares_Order() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	var calls []string
+	mw := func(name string) Middleware[int] {
+		return func(topic string, next Handler[int]) Handler[int] {
+			return func(ctx context.Context, v int) error {
+				calls = append(calls, name+":"+topic)
+				return next(ctx, v)
+			}
+		}
+	}
+	topic.Use(mw("outer"), mw("inner"))
+	topic.Subscribe(func(ctx context.Context, v int) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.Equal([]string{"outer:topic", "inner:topic", "handler"}, calls)
+}
+
+func (s *topicTestSuite) Test_RecoveryMiddleware() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	metrics := NewMetrics()
+	topic.Use(LoggingMiddleware[int](s.L), MetricsMiddleware[int](metrics), RecoveryMiddleware[int]())
+	topic.Subscribe(func(ctx context.Context, v int) error {
+		panic("boom")
+	})
+	s.AssertAppErr(topic.Publish(s.Ctx, 1), ErrCodeBusHandlerPanic)
+	s.Len(metrics.GetCollector()(), 3)
+}
+
+func (s *topicTestSuite) Test_AsyncPanic_Recovered() {
+	topic := NewTopic[int](s.L, "topic", ErrorPolicyStop)
+	cnt := atomic.Int32{}
+	topic.SubscribeAsync(func(ctx context.Context, v int) error {
+		if cnt.Add(1) == 1 {
+			panic("boom")
+		}
+		return nil
+	}, true)
+	s.NoError(topic.Publish(s.Ctx, 1))
+	s.NoError(topic.Publish(s.Ctx, 2))
+	topic.WaitAsync()
+	s.Equal(int32(2), cnt.Load())
+}
+
+func (s *topicTestSuite) Test_PublishInHandler() {
+	topic1 := NewTopic[int](s.L, "topic1", ErrorPolicyStop)
+	topic2 := NewTopic[int](s.L, "topic2", ErrorPolicyStop)
+	sum := 0
+	topic2.Subscribe(func(ctx context.Context, v int) error {
+		sum += v
+		return nil
+	})
+	topic1.Subscribe(func(ctx context.Context, v int) error {
+		// subscribing and publishing within a handler doesn't deadlock
+		topic1.SubscribeOnce(func(ctx context.Context, v int) error { return nil })
+		return topic2.Publish(ctx, v+5)
+	})
+	s.NoError(topic1.Publish(s.Ctx, 10))
+	s.Equal(15, sum)
+}
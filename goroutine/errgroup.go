@@ -2,22 +2,41 @@ package goroutine
 
 import (
 	"context"
-	"github.com/mikhailbolshakov/kit"
+	"errors"
+	"fmt"
 	"sync"
+
+	"github.com/mikhailbolshakov/kit"
 )
 
 // ErrGroup is a replica of a standard errgroup with panic handling and custom logging
 // it allows to run multiple goroutines and wait unless all finished either one of them failed
 // Once one fail, all other are cancelled at once
 type ErrGroup interface {
+	// Ctx returns the context derived from the group's one, it's cancelled once a function fails or Wait returns
+	// functions should use it to stop when the group is cancelled
+	Ctx() context.Context
 	// Go calls the given function in a new goroutine.
+	// It blocks until the new goroutine can be added without the number of active goroutines exceeding the limit.
 	//
 	// The first call to return a non-nil error cancels the group; its error will be
 	// returned by Wait.
 	Go(f func() error)
+	// TryGo calls the given function in a new goroutine only if the number of active goroutines is below the limit
+	// it returns false if the goroutine wasn't started
+	TryGo(f func() error) bool
 	// Wait blocks until all function calls from the Go method have returned, then
 	// returns the first non-nil error (if any) from them.
+	// With WithCollectErrors all errors are returned joined
 	Wait() error
+	// SetLimit limits the number of active goroutines to at most n, a negative value indicates no limit
+	// it must not be modified while any goroutines are active
+	SetLimit(n int) ErrGroup
+	// WithCollectErrors makes the group collect errors of all functions, Wait returns them joined by errors.Join,
+	// errors.Is inspects each of them, whereas errors.As and kit.IsAppErr/IsAppErrCode reach the first match only,
+	// use kit.AppErrs/HasAppErrCode to inspect all app errors
+	// a failed function doesn't cancel the group in this mode
+	WithCollectErrors() ErrGroup
 	// Cancel cancels all goroutines running
 	Cancel()
 	// CancelFunc returns cancel function defined by cancelled context
@@ -39,22 +58,47 @@ type errGroup struct {
 	wg       sync.WaitGroup
 	errOnce  sync.Once
 	err      error
+	errMu    sync.Mutex
+	errs     []error
+	collect  bool
+	sem      chan struct{}
 	logger   kit.CLogger
 	loggerFn kit.CLoggerFunc
 	ctx      context.Context
 	mth, cmp string
 }
 
-// NewGroup returns a new Group and an associated Context derived from ctx.
+// NewGroup returns a new Group with an associated Context derived from ctx (see Ctx).
 //
 // The derived Context is canceled the first time a function passed to Go
 // returns a non-nil error or the first time Wait returns, whichever occurs
 // first.
 func NewGroup(ctx context.Context) ErrGroup {
-	_, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancel(ctx)
 	return &errGroup{cancel: cancel, ctx: ctx}
 }
 
+func (g *errGroup) Ctx() context.Context {
+	return g.ctx
+}
+
+func (g *errGroup) SetLimit(n int) ErrGroup {
+	if n < 0 {
+		g.sem = nil
+		return g
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("goroutine: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+	return g
+}
+
+func (g *errGroup) WithCollectErrors() ErrGroup {
+	g.collect = true
+	return g
+}
+
 func (g *errGroup) WithLogger(logger kit.CLogger) ErrGroup {
 	g.logger = logger
 	return g
@@ -90,10 +134,41 @@ func (g *errGroup) Wait() error {
 	if g.cancel != nil {
 		g.cancel()
 	}
+	if g.collect {
+		return errors.Join(g.errs...)
+	}
 	return g.err
 }
 
 func (g *errGroup) Go(f func() error) {
+	logger := g.log()
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.do(f, logger)
+}
+
+func (g *errGroup) TryGo(f func() error) bool {
+	logger := g.log()
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.do(f, logger)
+	return true
+}
+
+func (g *errGroup) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+func (g *errGroup) log() kit.CLogger {
 
 	// check if logger passed
 	if g.logger == nil && g.loggerFn == nil {
@@ -101,12 +176,13 @@ func (g *errGroup) Go(f func() error) {
 	}
 
 	// define logger params
-	var logger kit.CLogger
 	if g.logger != nil {
-		logger = g.logger.C(g.ctx)
-	} else {
-		logger = g.loggerFn().Cmp(g.cmp).Mth(g.mth).C(g.ctx)
+		return g.logger.C(g.ctx)
 	}
+	return g.loggerFn().Cmp(g.cmp).Mth(g.mth).C(g.ctx)
+}
+
+func (g *errGroup) do(f func() error, logger kit.CLogger) {
 
 	// prepare panic wrapper
 	wrapper := func() (err error) {
@@ -123,15 +199,23 @@ func (g *errGroup) Go(f func() error) {
 	g.wg.Add(1)
 
 	go func() {
-		defer g.wg.Done()
-
-		if err := wrapper(); err != nil {
-			g.errOnce.Do(func() {
-				g.err = err
-				if g.cancel != nil {
-					g.cancel()
-				}
-			})
+		defer g.done()
+
+		err := wrapper()
+		if err == nil {
+			return
+		}
+		if g.collect {
+			g.errMu.Lock()
+			g.errs = append(g.errs, err)
+			g.errMu.Unlock()
+			return
 		}
+		g.errOnce.Do(func() {
+			g.err = err
+			if g.cancel != nil {
+				g.cancel()
+			}
+		})
 	}()
 }
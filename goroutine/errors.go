@@ -6,11 +6,27 @@ import (
 )
 
 const (
-	ErrCodeGoroutineNoLogger = "GORTN-001"
+	ErrCodeGoroutineNoLogger   = "GORTN-001"
+	ErrCodePoolStopped         = "GORTN-002"
+	ErrCodePoolQueueFull       = "GORTN-003"
+	ErrCodePoolSubmitCancelled = "GORTN-004"
+	ErrCodePoolStopTimeout     = "GORTN-005"
 )
 
 var (
 	ErrGoroutineNoLogger = func(ctx context.Context) error {
 		return kit.NewAppErrBuilder(ErrCodeGoroutineNoLogger, "either logger or logger func must be specified").C(ctx).Err()
 	}
+	ErrPoolStopped = func(ctx context.Context, pool string) error {
+		return kit.NewAppErrBuilder(ErrCodePoolStopped, "pool stopped").C(ctx).F(kit.KV{"pool": pool}).Err()
+	}
+	ErrPoolQueueFull = func(ctx context.Context, pool string) error {
+		return kit.NewAppErrBuilder(ErrCodePoolQueueFull, "pool queue is full").C(ctx).F(kit.KV{"pool": pool}).Err()
+	}
+	ErrPoolSubmitCancelled = func(ctx context.Context, pool string) error {
+		return kit.NewAppErrBuilder(ErrCodePoolSubmitCancelled, "submit cancelled while queue is full").Wrap(ctx.Err()).C(ctx).F(kit.KV{"pool": pool}).Err()
+	}
+	ErrPoolStopTimeout = func(ctx context.Context, pool string) error {
+		return kit.NewAppErrBuilder(ErrCodePoolStopTimeout, "pool stop timeout, tasks cancelled").C(ctx).F(kit.KV{"pool": pool}).Err()
+	}
 )
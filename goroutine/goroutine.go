@@ -11,10 +11,21 @@ const (
 	Unrestricted = -1
 )
 
+// Leadership allows awaiting leadership in cluster (see cluster.LeaderElection)
+type Leadership interface {
+	// AwaitLeadership blocks until the node becomes a leader or ctx is cancelled
+	// returned context is cancelled as soon as leadership is lost
+	AwaitLeadership(ctx context.Context) (context.Context, error)
+}
+
 // Goroutine provides a wrapper around native GO goroutine with panic recovery and retry support
 type Goroutine interface {
 	// Go executes a f func as a goroutine
 	Go(ctx context.Context, f func())
+	// GoLeader executes f as a goroutine each time the node is elected as a leader
+	// context passed to f is cancelled once leadership is lost, so f must stop
+	// f is executed once per leadership term, it stops completely when ctx is cancelled
+	GoLeader(ctx context.Context, leadership Leadership, f func(ctx context.Context))
 	// WithLogger allows to specify prepared logger
 	WithLogger(logger kit.CLogger) Goroutine
 	// WithLoggerFn allows to specify logger func
@@ -88,12 +99,7 @@ func (g *goroutine) Go(ctx context.Context, f func()) {
 	}
 
 	// define logger params
-	var logger kit.CLogger
-	if g.logger != nil {
-		logger = g.logger.C(ctx)
-	} else {
-		logger = g.loggerFn().Cmp(g.cmp).Mth(g.mth).C(ctx)
-	}
+	logger := g.log(ctx)
 
 	// prepare panic wrapper
 	wrapper := func() (err error) {
@@ -121,3 +127,45 @@ func (g *goroutine) Go(ctx context.Context, f func()) {
 		}
 	}()
 }
+
+func (g *goroutine) GoLeader(ctx context.Context, leadership Leadership, f func(ctx context.Context)) {
+
+	// check if logger passed
+	if g.logger == nil && g.loggerFn == nil {
+		panic(ErrGoroutineNoLogger(ctx))
+	}
+
+	g.Go(ctx, func() {
+		for {
+			// wait for leadership
+			leaderCtx, err := leadership.AwaitLeadership(ctx)
+			if err != nil {
+				return
+			}
+
+			// run f within the term, panic is handled by the wrapper
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						g.log(ctx).E(kit.ErrPanic(ctx, r)).St().Err()
+					}
+				}()
+				f(leaderCtx)
+			}()
+
+			// f is run once per term, so wait unless leadership is lost
+			select {
+			case <-leaderCtx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+func (g *goroutine) log(ctx context.Context) kit.CLogger {
+	if g.logger != nil {
+		return g.logger.C(ctx)
+	}
+	return g.loggerFn().Cmp(g.cmp).Mth(g.mth).C(ctx)
+}
@@ -2,10 +2,12 @@ package goroutine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/mikhailbolshakov/kit"
 	"github.com/stretchr/testify/assert"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -165,3 +167,114 @@ func Test_ErrGroup_WhenFirstPanics(t *testing.T) {
 	err := eg.Wait()
 	assert.Error(t, err)
 }
+
+func Test_ErrGroup_WhenFails_CtxCancelled(t *testing.T) {
+
+	eg := NewGroup(context.Background()).
+		WithLoggerFn(logf)
+
+	eg.Go(func() error {
+		return fmt.Errorf("error")
+	})
+
+	// the sibling sees cancellation of the group context
+	eg.Go(func() error {
+		select {
+		case <-eg.Ctx().Done():
+			return nil
+		case <-time.After(time.Second * 5):
+			return fmt.Errorf("not cancelled")
+		}
+	})
+
+	assert.EqualError(t, eg.Wait(), "error")
+	assert.Error(t, eg.Ctx().Err())
+}
+
+func Test_ErrGroup_WhenParentCancelled_CtxCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	eg := NewGroup(ctx).WithLoggerFn(logf)
+	eg.Go(func() error {
+		<-eg.Ctx().Done()
+		return eg.Ctx().Err()
+	})
+	cancel()
+	assert.ErrorIs(t, eg.Wait(), context.Canceled)
+}
+
+func Test_ErrGroup_SetLimit(t *testing.T) {
+	eg := NewGroup(context.Background()).
+		WithLoggerFn(logf).
+		SetLimit(2)
+
+	var running, maxRunning atomic.Int32
+	for i := 0; i < 10; i++ {
+		eg.Go(func() error {
+			r := running.Add(1)
+			defer running.Add(-1)
+			for m := maxRunning.Load(); r > m && !maxRunning.CompareAndSwap(m, r); m = maxRunning.Load() {
+			}
+			time.Sleep(time.Millisecond * 20)
+			return nil
+		})
+	}
+	assert.NoError(t, eg.Wait())
+	assert.Equal(t, int32(2), maxRunning.Load())
+}
+
+func Test_ErrGroup_TryGo(t *testing.T) {
+	eg := NewGroup(context.Background()).
+		WithLoggerFn(logf).
+		SetLimit(1)
+
+	release := make(chan struct{})
+	assert.True(t, eg.TryGo(func() error {
+		<-release
+		return nil
+	}))
+	assert.False(t, eg.TryGo(func() error { return nil }))
+	close(release)
+	assert.NoError(t, eg.Wait())
+	assert.True(t, eg.TryGo(func() error { return nil }))
+	assert.NoError(t, eg.Wait())
+}
+
+func Test_ErrGroup_WithCollectErrors(t *testing.T) {
+	eg := NewGroup(context.Background()).
+		WithLoggerFn(logf).
+		WithCollectErrors()
+
+	errFirst := errors.New("first")
+	eg.Go(func() error { return errFirst })
+	eg.Go(func() error { return kit.NewAppErrBuilder("TST-001", "second").Err() })
+	eg.Go(func() error { panic("panic") })
+	eg.Go(func() error {
+		// the group isn't cancelled by failed siblings
+		time.Sleep(time.Millisecond * 100)
+		return eg.Ctx().Err()
+	})
+
+	err := eg.Wait()
+	assert.ErrorIs(t, err, errFirst)
+	appErr, ok := kit.IsAppErr(err)
+	assert.True(t, ok)
+	assert.NotNil(t, appErr)
+	assert.Len(t, err.(interface{ Unwrap() []error }).Unwrap(), 3)
+}
+
+func Test_ErrGroup_WithCollectErrors_AllAppErrCodes(t *testing.T) {
+	eg := NewGroup(context.Background()).
+		WithLoggerFn(logf).
+		WithCollectErrors()
+
+	eg.Go(func() error { return kit.NewAppErrBuilder("TST-001", "first").Err() })
+	eg.Go(func() error {
+		time.Sleep(time.Millisecond * 50)
+		return kit.NewAppErrBuilder("TST-002", "second").Err()
+	})
+
+	err := eg.Wait()
+	assert.True(t, kit.HasAppErrCode(err, "TST-001"))
+	assert.True(t, kit.HasAppErrCode(err, "TST-002"))
+	assert.Len(t, kit.AppErrs(err), 2)
+}
@@ -0,0 +1,241 @@
+package goroutine
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	defaultPoolName = "default"
+)
+
+// Task is a function executed by a pool
+// ctx keeps values of the submitter's context with a copy of the request context, it's cancelled only if the pool is stopped forcibly
+type Task func(ctx context.Context) error
+
+// Future allows awaiting a result of a task submitted to a pool
+type Future interface {
+	// Done is closed when the task is completed
+	Done() <-chan struct{}
+	// Wait blocks until the task is completed or ctx is done, it returns an error of the task
+	Wait(ctx context.Context) error
+}
+
+// Pool executes tasks by a fixed number of workers taking tasks from a bounded queue
+// panics are recovered and logged, a panicked task fails with kit.ErrPanic and the worker keeps working
+type Pool interface {
+	// Submit puts a task to the queue, it blocks while the queue is full unless ctx is done
+	Submit(ctx context.Context, task Task) (Future, error)
+	// TrySubmit puts a task to the queue, it fails with ErrCodePoolQueueFull at once if the queue is full
+	TrySubmit(ctx context.Context, task Task) (Future, error)
+	// Stop stops accepting tasks and waits for the queued and running tasks to complete
+	// if ctx is done before, contexts of running tasks are cancelled, queued tasks fail with ErrCodePoolStopped and ErrCodePoolStopTimeout is returned
+	Stop(ctx context.Context) error
+}
+
+// PoolConfig pool configuration
+type PoolConfig struct {
+	Name      string // Name of the pool used as a metrics label (default: "default")
+	Workers   int    // Workers number of workers (default: number of CPUs)
+	QueueSize int    // QueueSize number of tasks waiting for a worker (default: Workers)
+}
+
+type future struct {
+	done chan struct{}
+	err  error
+}
+
+func (f *future) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+type poolTask struct {
+	ctx      context.Context
+	task     Task
+	future   *future
+	enqueued time.Time
+}
+
+type pool struct {
+	sync.RWMutex // guards the queue, so that it isn't closed while written
+	queue        chan *poolTask
+	stopped      bool
+	stopping     context.Context // stopping is done once Stop is called, it releases submitters blocked on the full queue
+	stopFn       context.CancelFunc
+	ctx          context.Context // ctx is cancelled on forced stop
+	cancel       context.CancelFunc
+	workers      sync.WaitGroup
+	cfg          *PoolConfig
+	logger       kit.CLoggerFunc
+}
+
+// NewPool creates a pool and starts its workers
+func NewPool(cfg *PoolConfig, logger kit.CLoggerFunc) Pool {
+	c := &PoolConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Name == "" {
+		c.Name = defaultPoolName
+	}
+	if c.Workers <= 0 {
+		c.Workers = runtime.NumCPU()
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = c.Workers
+	}
+	p := &pool{
+		queue:  make(chan *poolTask, c.QueueSize),
+		cfg:    c,
+		logger: logger,
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.stopping, p.stopFn = context.WithCancel(context.Background())
+	p.workers.Add(c.Workers)
+	for i := 0; i < c.Workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *pool) l() kit.CLogger {
+	return p.logger().Cmp("goroutine-pool").F(kit.KV{"pool": p.cfg.Name})
+}
+
+func (p *pool) Submit(ctx context.Context, task Task) (Future, error) {
+	return p.submit(ctx, task, true)
+}
+
+func (p *pool) TrySubmit(ctx context.Context, task Task) (Future, error) {
+	return p.submit(ctx, task, false)
+}
+
+func (p *pool) submit(ctx context.Context, task Task, wait bool) (Future, error) {
+	p.RLock()
+	defer p.RUnlock()
+
+	if p.stopped {
+		return nil, ErrPoolStopped(ctx, p.cfg.Name)
+	}
+
+	t := &poolTask{
+		// task shouldn't be cancelled along with the submitter's request, the request context is copied per task
+		ctx:      kit.Detach(ctx),
+		task:     task,
+		future:   &future{done: make(chan struct{})},
+		enqueued: time.Now(),
+	}
+
+	select {
+	case p.queue <- t:
+	default:
+		if !wait {
+			return nil, ErrPoolQueueFull(ctx, p.cfg.Name)
+		}
+		select {
+		case p.queue <- t:
+		case <-ctx.Done():
+			return nil, ErrPoolSubmitCancelled(ctx, p.cfg.Name)
+		// the lock is held while waiting, so Stop releases it before taking the lock
+		case <-p.stopping.Done():
+			return nil, ErrPoolStopped(ctx, p.cfg.Name)
+		}
+	}
+	poolMetrics.queue(p.cfg.Name, len(p.queue))
+	return t.future, nil
+}
+
+func (p *pool) Stop(ctx context.Context) error {
+	l := p.l().C(ctx).Mth("stop").Dbg()
+
+	// release submitters blocked on the full queue, otherwise the lock isn't taken while tasks hang
+	p.stopFn()
+
+	// stop accepting tasks, workers finish once the queue is drained
+	p.Lock()
+	if p.stopped {
+		p.Unlock()
+		return nil
+	}
+	p.stopped = true
+	close(p.queue)
+	p.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		l.Dbg("ok")
+		return nil
+	case <-ctx.Done():
+	}
+
+	// cancel running tasks, queued ones are failed by workers
+	p.cancel()
+	err := ErrPoolStopTimeout(ctx, p.cfg.Name)
+	l.E(err).Err()
+	return err
+}
+
+func (p *pool) worker() {
+	defer p.workers.Done()
+	for t := range p.queue {
+		poolMetrics.queue(p.cfg.Name, len(p.queue))
+		if p.ctx.Err() != nil {
+			t.future.complete(ErrPoolStopped(t.ctx, p.cfg.Name))
+			continue
+		}
+		p.run(t)
+	}
+}
+
+// run executes a task recovering panic
+func (p *pool) run(t *poolTask) {
+	started := time.Now()
+	poolMetrics.active(p.cfg.Name, 1)
+	poolMetrics.wait(p.cfg.Name, started.Sub(t.enqueued))
+
+	// task ctx is cancelled on forced stop
+	ctx, cancel := context.WithCancel(t.ctx)
+	stop := context.AfterFunc(p.ctx, cancel)
+
+	var err error
+	defer func() {
+		stop()
+		cancel()
+		poolMetrics.active(p.cfg.Name, -1)
+		poolMetrics.latency(p.cfg.Name, time.Since(started))
+		t.future.complete(err)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			err = kit.ErrPanic(ctx, r)
+			p.l().C(ctx).Mth("run").E(err).St().Err()
+		}
+	}()
+
+	err = t.task(ctx)
+}
@@ -0,0 +1,75 @@
+package goroutine
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	PoolActiveWorkersGauge    = "goroutine_pool_active_workers"
+	PoolQueueLengthGauge      = "goroutine_pool_queue_length"
+	PoolTaskWaitHistogram     = "goroutine_pool_task_wait_seconds"
+	PoolTaskDurationHistogram = "goroutine_pool_task_duration_seconds"
+)
+
+// poolMetricsImpl pool prometheus metrics labeled by pool name
+// metrics are shared by all pools, so that they are registered once
+type poolMetricsImpl struct {
+	activeWorkers *prometheus.GaugeVec
+	queueLength   *prometheus.GaugeVec
+	taskWait      *prometheus.HistogramVec
+	taskDuration  *prometheus.HistogramVec
+}
+
+var poolMetrics = newPoolMetrics()
+
+func newPoolMetrics() *poolMetricsImpl {
+	return &poolMetricsImpl{
+		activeWorkers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: PoolActiveWorkersGauge,
+			Help: "Number of workers executing tasks",
+		}, []string{"pool"}),
+		queueLength: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: PoolQueueLengthGauge,
+			Help: "Number of tasks waiting in the queue",
+		}, []string{"pool"}),
+		taskWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    PoolTaskWaitHistogram,
+			Help:    "Time tasks wait in the queue",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"pool"}),
+		taskDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    PoolTaskDurationHistogram,
+			Help:    "Time of task execution",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"pool"}),
+	}
+}
+
+func (m *poolMetricsImpl) active(pool string, delta int) {
+	m.activeWorkers.WithLabelValues(pool).Add(float64(delta))
+}
+
+func (m *poolMetricsImpl) queue(pool string, length int) {
+	m.queueLength.WithLabelValues(pool).Set(float64(length))
+}
+
+func (m *poolMetricsImpl) wait(pool string, d time.Duration) {
+	m.taskWait.WithLabelValues(pool).Observe(d.Seconds())
+}
+
+func (m *poolMetricsImpl) latency(pool string, d time.Duration) {
+	m.taskDuration.WithLabelValues(pool).Observe(d.Seconds())
+}
+
+// PoolCollectors returns prometheus collectors of all pools, metrics are labeled by pool name (PoolConfig.Name)
+// monitoring imports goroutine, so use monitoring.PoolMetrics() to register them with the metrics server
+func PoolCollectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		poolMetrics.activeWorkers,
+		poolMetrics.queueLength,
+		poolMetrics.taskWait,
+		poolMetrics.taskDuration,
+	}
+}
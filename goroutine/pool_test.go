@@ -0,0 +1,236 @@
+package goroutine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Pool_Submit(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 2, QueueSize: 10}, logf)
+	defer p.Stop(context.Background())
+
+	f1, err := p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.NoError(t, err)
+	f2, err := p.Submit(context.Background(), func(ctx context.Context) error { return errors.New("failed") })
+	assert.NoError(t, err)
+
+	assert.NoError(t, f1.Wait(context.Background()))
+	assert.EqualError(t, f2.Wait(context.Background()), "failed")
+	<-f2.Done()
+}
+
+func Test_Pool_WhenPanic_Recovered(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1}, logf)
+	defer p.Stop(context.Background())
+
+	f, err := p.Submit(context.Background(), func(ctx context.Context) error { panic("panic") })
+	assert.NoError(t, err)
+	assert.True(t, kit.IsAppErrCode(f.Wait(context.Background()), kit.ErrCodePanic))
+
+	// the worker keeps working
+	f, err = p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.NoError(t, err)
+	assert.NoError(t, f.Wait(context.Background()))
+}
+
+func Test_Pool_RequestContextCopied(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1}, logf)
+	defer p.Stop(context.Background())
+
+	rq := kit.NewRequestCtx().WithNewRequestId()
+	ctx, cancel := context.WithCancel(rq.ToContext(context.Background()))
+	release := make(chan struct{})
+	f, err := p.Submit(ctx, func(ctx context.Context) error {
+		<-release
+		// the task isn't cancelled along with the submitter's request
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		r, ok := kit.Request(ctx)
+		if !ok || r.GetRequestId() != rq.GetRequestId() {
+			return errors.New("request context lost")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	cancel()
+	close(release)
+	assert.NoError(t, f.Wait(context.Background()))
+}
+
+func Test_Pool_RequestContextCopiedPerTask(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1}, logf)
+	defer p.Stop(context.Background())
+
+	rq := kit.NewRequestCtx().WithNewRequestId().WithKv("key", "submitted")
+	rid := rq.GetRequestId()
+	submitted, release := make(chan struct{}), make(chan struct{})
+	f, err := p.Submit(rq.ToContext(context.Background()), func(ctx context.Context) error {
+		<-release
+		r, _ := kit.Request(ctx)
+		if r.GetRequestId() != rid || r.GetKv()["key"] != "submitted" {
+			return errors.New("submitter's changes seen by the task")
+		}
+		// the task's changes aren't seen by the submitter
+		r.WithKv("key", "task")
+		close(submitted)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// the submitter keeps changing its request context
+	rq.WithNewRequestId().WithKv("key", "changed")
+	close(release)
+	assert.NoError(t, f.Wait(context.Background()))
+	<-submitted
+	assert.Equal(t, "changed", rq.GetKv()["key"])
+}
+
+func Test_Pool_ConcurrencyLimited(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 3, QueueSize: 100}, logf)
+	var running, maxRunning atomic.Int32
+	for i := 0; i < 30; i++ {
+		_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+			r := running.Add(1)
+			defer running.Add(-1)
+			for m := maxRunning.Load(); r > m && !maxRunning.CompareAndSwap(m, r); m = maxRunning.Load() {
+			}
+			time.Sleep(time.Millisecond * 10)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, p.Stop(context.Background()))
+	assert.Equal(t, int32(3), maxRunning.Load())
+}
+
+func Test_Pool_WhenQueueFull(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1, QueueSize: 1}, logf)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	task := func(ctx context.Context) error {
+		<-release
+		return nil
+	}
+
+	// the first task is taken by the worker, the second one fills the queue
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		return task(ctx)
+	})
+	assert.NoError(t, err)
+	<-started
+	_, err = p.Submit(context.Background(), task)
+	assert.NoError(t, err)
+
+	_, err = p.TrySubmit(context.Background(), task)
+	assert.True(t, kit.IsAppErrCode(err, ErrCodePoolQueueFull))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	_, err = p.Submit(ctx, task)
+	assert.True(t, kit.IsAppErrCode(err, ErrCodePoolSubmitCancelled))
+
+	close(release)
+	assert.NoError(t, p.Stop(context.Background()))
+}
+
+func Test_Pool_Stop_Drains(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 2, QueueSize: 20}, logf)
+	var cnt atomic.Int32
+	var futures []Future
+	for i := 0; i < 20; i++ {
+		f, err := p.Submit(context.Background(), func(ctx context.Context) error {
+			time.Sleep(time.Millisecond * 10)
+			cnt.Add(1)
+			return nil
+		})
+		assert.NoError(t, err)
+		futures = append(futures, f)
+	}
+	assert.NoError(t, p.Stop(context.Background()))
+	assert.Equal(t, int32(20), cnt.Load())
+	for _, f := range futures {
+		assert.NoError(t, f.Wait(context.Background()))
+	}
+
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.True(t, kit.IsAppErrCode(err, ErrCodePoolStopped))
+	assert.NoError(t, p.Stop(context.Background()))
+}
+
+func Test_Pool_Stop_WhenTimeout(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1, QueueSize: 1}, logf)
+	started := make(chan struct{})
+	running, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.NoError(t, err)
+	<-started
+	queued, err := p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	assert.True(t, kit.IsAppErrCode(p.Stop(ctx), ErrCodePoolStopTimeout))
+
+	// the running task is cancelled, the queued one isn't executed
+	assert.ErrorIs(t, running.Wait(context.Background()), context.Canceled)
+	assert.True(t, kit.IsAppErrCode(queued.Wait(context.Background()), ErrCodePoolStopped))
+}
+
+func Test_Pool_Stop_WhenSubmitBlocked(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1, QueueSize: 1}, logf)
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	_, err := p.Submit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	assert.NoError(t, err)
+	<-started
+	_, err = p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+	assert.NoError(t, err)
+
+	// a submitter blocked on the full queue
+	blocked := make(chan error)
+	go func() {
+		_, err := p.Submit(context.Background(), func(ctx context.Context) error { return nil })
+		blocked <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	assert.True(t, kit.IsAppErrCode(p.Stop(ctx), ErrCodePoolStopTimeout))
+	assert.True(t, kit.IsAppErrCode(<-blocked, ErrCodePoolStopped))
+}
+
+func Test_Pool_WhenNoRequestContext_TaskNotCancelled(t *testing.T) {
+	p := NewPool(&PoolConfig{Workers: 1}, logf)
+	defer p.Stop(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	f, err := p.Submit(ctx, func(ctx context.Context) error {
+		<-release
+		return ctx.Err()
+	})
+	assert.NoError(t, err)
+	cancel()
+	close(release)
+	assert.NoError(t, f.Wait(context.Background()))
+}
+
+func Test_Pool_Metrics(t *testing.T) {
+	assert.Len(t, PoolCollectors(), 4)
+}
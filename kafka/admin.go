@@ -0,0 +1,381 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// configSourceDynamicTopic config source of topic level overrides (describe configs v1+)
+	configSourceDynamicTopic = 1
+)
+
+// TopicDescription describes an actual topic state
+type TopicDescription struct {
+	Topic         string            // Topic name
+	Partitions    int               // Partitions number of partitions
+	ReplicaFactor int               // ReplicaFactor replication factor
+	Configs       map[string]string // Configs topic level config overrides
+}
+
+// TopicReconcileResult contains changes made (or planned in dry-run mode) by topics reconciliation
+type TopicReconcileResult struct {
+	Created             []string // Created topics created
+	PartitionsIncreased []string // PartitionsIncreased topics whose number of partitions is increased
+	ConfigsAltered      []string // ConfigsAltered topics whose configs are altered
+	Mismatches          []string // Mismatches differences which cannot be fixed automatically (e.g. partitions decrease, replication factor)
+}
+
+// TopicAdmin manages kafka topics
+type TopicAdmin interface {
+	// ListTopics returns names of all non-internal topics
+	ListTopics(ctx context.Context) ([]string, error)
+	// DescribeTopics returns actual state of the given topics
+	DescribeTopics(ctx context.Context, topics ...string) ([]*TopicDescription, error)
+	// DeleteTopics deletes topics
+	DeleteTopics(ctx context.Context, topics ...string) error
+	// IncreasePartitions increases number of topic partitions up to count
+	IncreasePartitions(ctx context.Context, topic string, count int) error
+	// AlterTopicConfigs sets topic configs, configs not specified remain unchanged
+	AlterTopicConfigs(ctx context.Context, topic string, configs map[string]string) error
+	// ReconcileTopics brings actual topics in line with topics declared by producers and subscribers
+	// missing topics are created, partitions are increased and configs are altered
+	// if dryRun is set, nothing is changed, but the result contains planned changes
+	ReconcileTopics(ctx context.Context, dryRun bool) (*TopicReconcileResult, error)
+}
+
+func (b *brokerImpl) Admin() TopicAdmin {
+	return b
+}
+
+func (b *brokerImpl) ListTopics(ctx context.Context) ([]string, error) {
+	b.l().C(ctx).Mth("list-topics").Dbg()
+
+	if b.client == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+
+	rs, err := b.client.Metadata(ctx, &kafka.MetadataRequest{})
+	if err != nil {
+		return nil, ErrKafkaListTopics(ctx, err)
+	}
+
+	var r []string
+	for _, t := range rs.Topics {
+		if !t.Internal {
+			r = append(r, t.Name)
+		}
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+func (b *brokerImpl) DescribeTopics(ctx context.Context, topics ...string) ([]*TopicDescription, error) {
+	b.l().C(ctx).Mth("describe-topics").F(kit.KV{"topics": topics}).Dbg()
+
+	if b.client == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+	if len(topics) == 0 {
+		return nil, nil
+	}
+
+	// partitions and replicas
+	md, err := b.client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return nil, ErrKafkaDescribeTopics(ctx, err)
+	}
+	descriptions := make(map[string]*TopicDescription, len(md.Topics))
+	for _, t := range md.Topics {
+		if t.Error != nil {
+			return nil, ErrKafkaDescribeTopics(ctx, fmt.Errorf("%s: %w", t.Name, t.Error))
+		}
+		d := &TopicDescription{
+			Topic:      t.Name,
+			Partitions: len(t.Partitions),
+			Configs:    map[string]string{},
+		}
+		if len(t.Partitions) > 0 {
+			d.ReplicaFactor = len(t.Partitions[0].Replicas)
+		}
+		descriptions[t.Name] = d
+	}
+
+	// configs
+	req := &kafka.DescribeConfigsRequest{}
+	for _, t := range topics {
+		req.Resources = append(req.Resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: t,
+		})
+	}
+	cfgs, err := b.client.DescribeConfigs(ctx, req)
+	if err != nil {
+		return nil, ErrKafkaDescribeTopics(ctx, err)
+	}
+	for _, res := range cfgs.Resources {
+		if res.Error != nil {
+			return nil, ErrKafkaDescribeTopics(ctx, fmt.Errorf("%s: %w", res.ResourceName, res.Error))
+		}
+		d, ok := descriptions[res.ResourceName]
+		if !ok {
+			continue
+		}
+		for _, e := range res.ConfigEntries {
+			// v0 reports IsDefault only, v1+ reports config source
+			if e.ConfigSource == configSourceDynamicTopic || (e.ConfigSource == 0 && !e.IsDefault) {
+				d.Configs[e.ConfigName] = e.ConfigValue
+			}
+		}
+	}
+
+	// keep requested order
+	r := make([]*TopicDescription, 0, len(topics))
+	for _, t := range topics {
+		d, ok := descriptions[t]
+		if !ok {
+			return nil, ErrKafkaTopicNotFound(ctx, t)
+		}
+		r = append(r, d)
+	}
+	return r, nil
+}
+
+func (b *brokerImpl) DeleteTopics(ctx context.Context, topics ...string) error {
+	l := b.l().C(ctx).Mth("delete-topics").F(kit.KV{"topics": topics}).Dbg()
+
+	if b.client == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+	if len(topics) == 0 {
+		return nil
+	}
+
+	rs, err := b.client.DeleteTopics(ctx, &kafka.DeleteTopicsRequest{Topics: topics})
+	if err != nil {
+		return ErrKafkaDeleteTopics(ctx, err)
+	}
+	if err := responseErr(rs.Errors); err != nil {
+		return ErrKafkaDeleteTopics(ctx, err)
+	}
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *brokerImpl) IncreasePartitions(ctx context.Context, topic string, count int) error {
+	l := b.l().C(ctx).Mth("increase-partitions").F(kit.KV{"topic": topic, "count": count}).Dbg()
+
+	if b.client == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+
+	rs, err := b.client.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{
+		Topics: []kafka.TopicPartitionsConfig{{Name: topic, Count: int32(count)}},
+	})
+	if err != nil {
+		return ErrKafkaIncreasePartitions(ctx, err, topic)
+	}
+	if err := responseErr(rs.Errors); err != nil {
+		return ErrKafkaIncreasePartitions(ctx, err, topic)
+	}
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *brokerImpl) AlterTopicConfigs(ctx context.Context, topic string, configs map[string]string) error {
+	l := b.l().C(ctx).Mth("alter-configs").F(kit.KV{"topic": topic}).Dbg()
+
+	if b.client == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+
+	res := kafka.IncrementalAlterConfigsRequestResource{
+		ResourceType: kafka.ResourceTypeTopic,
+		ResourceName: topic,
+	}
+	for _, e := range configEntries(configs) {
+		res.Configs = append(res.Configs, kafka.IncrementalAlterConfigsRequestConfig{
+			Name:            e.ConfigName,
+			Value:           e.ConfigValue,
+			ConfigOperation: kafka.ConfigOperationSet,
+		})
+	}
+	rs, err := b.client.IncrementalAlterConfigs(ctx, &kafka.IncrementalAlterConfigsRequest{
+		Resources: []kafka.IncrementalAlterConfigsRequestResource{res},
+	})
+	if err != nil {
+		return ErrKafkaAlterTopicConfigs(ctx, err, topic)
+	}
+	for _, r := range rs.Resources {
+		if r.Error != nil {
+			return ErrKafkaAlterTopicConfigs(ctx, r.Error, topic)
+		}
+	}
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *brokerImpl) ReconcileTopics(ctx context.Context, dryRun bool) (*TopicReconcileResult, error) {
+	if b.client == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+
+	// declared topics
+	b.RLock()
+	declared := make(map[string]kafka.TopicConfig, len(b.topics))
+	for k, v := range b.topics {
+		declared[k] = v
+	}
+	b.RUnlock()
+
+	return reconcileTopics(ctx, b.l(), b, declared, dryRun)
+}
+
+func (b *brokerImpl) createTopics(ctx context.Context, topics []kafka.TopicConfig) error {
+	rs, err := b.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: topics})
+	if err != nil {
+		return ErrKafkaCreateTopics(ctx, err)
+	}
+	// a topic might be created concurrently by another instance
+	for t, err := range rs.Errors {
+		if errors.Is(err, kafka.TopicAlreadyExists) {
+			delete(rs.Errors, t)
+		}
+	}
+	if err := responseErr(rs.Errors); err != nil {
+		return ErrKafkaCreateTopics(ctx, err)
+	}
+	return nil
+}
+
+// reconcilableAdmin topic admin able to create topics as declared
+type reconcilableAdmin interface {
+	TopicAdmin
+	createTopics(ctx context.Context, topics []kafka.TopicConfig) error
+}
+
+// reconcileTopics brings actual topics of the admin in line with the declared ones
+func reconcileTopics(ctx context.Context, logger kit.CLogger, admin reconcilableAdmin, declared map[string]kafka.TopicConfig, dryRun bool) (*TopicReconcileResult, error) {
+	l := logger.C(ctx).Mth("reconcile").F(kit.KV{"dryRun": dryRun}).Dbg()
+
+	// actual topics
+	actual, err := admin.ListTopics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]struct{}, len(actual))
+	for _, t := range actual {
+		exists[t] = struct{}{}
+	}
+
+	r := &TopicReconcileResult{}
+
+	// create missing topics
+	var toCreate []kafka.TopicConfig
+	var toCheck []string
+	for name, t := range declared {
+		if _, ok := exists[name]; ok {
+			toCheck = append(toCheck, name)
+		} else {
+			toCreate = append(toCreate, t)
+			r.Created = append(r.Created, name)
+		}
+	}
+	sort.Strings(r.Created)
+	sort.Strings(toCheck)
+	if len(toCreate) > 0 && !dryRun {
+		if err := admin.createTopics(ctx, toCreate); err != nil {
+			return nil, err
+		}
+	}
+
+	// compare existing topics
+	descriptions, err := admin.DescribeTopics(ctx, toCheck...)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range descriptions {
+		t := declared[d.Topic]
+
+		// partitions
+		if t.NumPartitions > 0 {
+			if t.NumPartitions > d.Partitions {
+				r.PartitionsIncreased = append(r.PartitionsIncreased, d.Topic)
+				if !dryRun {
+					if err := admin.IncreasePartitions(ctx, d.Topic, t.NumPartitions); err != nil {
+						return nil, err
+					}
+				}
+			} else if t.NumPartitions < d.Partitions {
+				r.Mismatches = append(r.Mismatches, fmt.Sprintf("%s: partitions declared %d, actual %d", d.Topic, t.NumPartitions, d.Partitions))
+			}
+		}
+
+		// replication factor
+		if t.ReplicationFactor > 0 && t.ReplicationFactor != d.ReplicaFactor {
+			r.Mismatches = append(r.Mismatches, fmt.Sprintf("%s: replication factor declared %d, actual %d", d.Topic, t.ReplicationFactor, d.ReplicaFactor))
+		}
+
+		// configs, only declared ones are considered
+		diff := map[string]string{}
+		for _, e := range t.ConfigEntries {
+			if v, ok := d.Configs[e.ConfigName]; !ok || v != e.ConfigValue {
+				diff[e.ConfigName] = e.ConfigValue
+			}
+		}
+		if len(diff) > 0 {
+			r.ConfigsAltered = append(r.ConfigsAltered, d.Topic)
+			if !dryRun {
+				if err := admin.AlterTopicConfigs(ctx, d.Topic, diff); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	l.F(kit.KV{"created": r.Created, "partitions": r.PartitionsIncreased, "configs": r.ConfigsAltered}).Inf("ok")
+	if len(r.Mismatches) > 0 {
+		l.F(kit.KV{"mismatches": strings.Join(r.Mismatches, "; ")}).Warn("mismatches")
+	}
+	return r, nil
+}
+
+// configEntries converts configs to kafka config entries sorted by name
+func configEntries(configs map[string]string) []kafka.ConfigEntry {
+	if len(configs) == 0 {
+		return nil
+	}
+	r := make([]kafka.ConfigEntry, 0, len(configs))
+	for k, v := range configs {
+		r = append(r, kafka.ConfigEntry{ConfigName: k, ConfigValue: v})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].ConfigName < r[j].ConfigName })
+	return r
+}
+
+// responseErr joins per-topic errors of an admin response
+func responseErr(errs map[string]error) error {
+	var msgs []string
+	for t, err := range errs {
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %v", t, err))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	sort.Strings(msgs)
+	return fmt.Errorf("%s", strings.Join(msgs, "; "))
+}
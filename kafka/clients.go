@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// messageReader reads messages of a topic, it's implemented by kafka.Reader
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Stats() kafka.ReaderStats
+	Close() error
+}
+
+// messageWriter writes messages, it's implemented by kafka.Writer
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// clients creates readers and writers used by producers and subscribers
+// it allows running the same producers and subscribers on top of the in-memory broker
+type clients interface {
+	// reader creates a reader, brokers and dialer are set by clients
+	reader(cfg kafka.ReaderConfig) messageReader
+	// writer creates a writer, address and transport are set by clients
+	writer(w *kafka.Writer) messageWriter
+}
+
+// kafkaClients creates readers and writers connected to kafka
+type kafkaClients struct {
+	urls      []string
+	dialer    *kafka.Dialer
+	transport *kafka.Transport
+}
+
+func (c *kafkaClients) reader(cfg kafka.ReaderConfig) messageReader {
+	cfg.Brokers = c.urls
+	cfg.Dialer = c.dialer
+	return kafka.NewReader(cfg)
+}
+
+func (c *kafkaClients) writer(w *kafka.Writer) messageWriter {
+	w.Addr = kafka.TCP(c.urls...)
+	w.Transport = c.transport
+	return w
+}
@@ -13,6 +13,8 @@ type Message struct {
 	Key string `json:"key"`
 	// Payload arbitrary data
 	Payload any `json:"payload"`
+	// Headers custom kafka headers sent along with the message
+	Headers map[string]string `json:"-"`
 }
 
 type MessageT[T any] struct {
@@ -22,11 +24,14 @@ type MessageT[T any] struct {
 	Key string `json:"key"`
 	// Payload arbitrary data
 	Payload T `json:"payload"`
+	// Headers custom kafka headers sent along with the message
+	Headers map[string]string `json:"-"`
 }
 
 type DLQMessage struct {
-	Topic         string `json:"topic"`
-	FailedMessage []byte `json:"failedMessage"`
+	Topic         string            `json:"topic"`
+	FailedMessage []byte            `json:"failedMessage"`
+	Headers       map[string]string `json:"headers,omitempty"`
 }
 
 func Decode[T any](parentCtx context.Context, msg []byte) (T, context.Context, error) {
@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	defaultMemoryDedupTtl = time.Hour
+	// expired ids are evicted once per this number of marks
+	memoryDedupEvictPeriod = 1000
+)
+
+// DedupStore keeps ids of processed messages
+// see storages/redis and storages/pg for persistent implementations
+type DedupStore interface {
+	// Processed checks if a message with the given id has been already processed
+	Processed(ctx context.Context, id string) (bool, error)
+	// MarkProcessed marks a message id as processed
+	MarkProcessed(ctx context.Context, id string) error
+}
+
+// DedupConfig configures deduplication of consumed messages
+type DedupConfig struct {
+	Store   DedupStore // Store keeps processed ids
+	IdField string     // IdField if specified, a message id is taken from the payload field (dot separated path), otherwise groupId:topic:partition:offset is used (the retry topic for retried messages)
+}
+
+// deduplicator skips messages already processed by the subscriber group
+// nil deduplicator doesn't filter anything
+type deduplicator struct {
+	cfg     *DedupConfig
+	groupId string
+	mode    string
+	logger  kit.CLoggerFunc
+}
+
+func newDeduplicator(logger kit.CLoggerFunc, cfg *DedupConfig, groupId, mode string) *deduplicator {
+	if cfg == nil || cfg.Store == nil {
+		return nil
+	}
+	return &deduplicator{
+		cfg:     cfg,
+		groupId: groupId,
+		mode:    mode,
+		logger:  logger,
+	}
+}
+
+func (d *deduplicator) l() kit.CLogger {
+	return d.logger().Cmp("kafka-dedup")
+}
+
+// messageId derives a message id which is unique within a subscriber group
+func (d *deduplicator) messageId(ctx context.Context, topic string, m kafka.Message) string {
+	prefix := d.groupId + ":" + topic + ":"
+	if d.cfg.IdField != "" {
+		id, err := payloadField(m.Value, d.cfg.IdField, d.mode)
+		if err == nil {
+			return prefix + id
+		}
+		// fallback to topic, partition and offset
+		d.l().C(ctx).Mth("id").F(kit.KV{"topic": topic, "field": d.cfg.IdField}).E(err).Warn("id field")
+	}
+	// offsets are unique within a partition only: messages with no key are spread across partitions
+	// and keys move to other partitions when partitions are added
+	// retried messages are read from retry topics having their own offsets, so the physical topic is taken
+	if m.Topic != "" && m.Topic != topic {
+		prefix = d.groupId + ":" + m.Topic + ":"
+	}
+	return prefix + strconv.Itoa(m.Partition) + ":" + strconv.FormatInt(m.Offset, 10)
+}
+
+// check returns message id and true if the message has been already processed
+func (d *deduplicator) check(ctx context.Context, topic string, m kafka.Message) (string, bool) {
+	if d == nil {
+		return "", false
+	}
+	id := d.messageId(ctx, topic, m)
+	processed, err := d.cfg.Store.Processed(ctx, id)
+	if err != nil {
+		// it's safer to process twice than lose a message
+		d.l().C(ctx).Mth("check").F(kit.KV{"id": id}).E(err).Err()
+		return id, false
+	}
+	if processed {
+		d.l().C(ctx).Mth("check").F(kit.KV{"id": id}).Dbg("duplicate")
+	}
+	return id, processed
+}
+
+// markProcessed marks the message processed, must be called only after successful handling
+func (d *deduplicator) markProcessed(ctx context.Context, id string) {
+	if d == nil || id == "" {
+		return
+	}
+	if err := d.cfg.Store.MarkProcessed(ctx, id); err != nil {
+		d.l().C(ctx).Mth("mark").F(kit.KV{"id": id}).E(err).Err()
+	}
+}
+
+// payloadField retrieves a field value from the message payload by a dot separated path
+// in headers mode the whole message value is a payload
+func payloadField(msg []byte, path, mode string) (string, error) {
+	m := MessageT[map[string]any]{}
+	if mode == MessageModeHeaders {
+		if err := kit.Unmarshal(msg, &m.Payload); err != nil {
+			return "", err
+		}
+	} else if err := kit.Unmarshal(msg, &m); err != nil {
+		return "", err
+	}
+	var v any = m.Payload
+	for _, p := range strings.Split(path, ".") {
+		mp, ok := v.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("field %s not found", path)
+		}
+		if v, ok = mp[p]; !ok {
+			return "", fmt.Errorf("field %s not found", path)
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return "", fmt.Errorf("field %s empty", path)
+		}
+		return val, nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case nil:
+		return "", fmt.Errorf("field %s empty", path)
+	default:
+		return fmt.Sprintf("%v", val), nil
+	}
+}
+
+// memoryDedupStore keeps processed ids in memory
+type memoryDedupStore struct {
+	sync.Mutex
+	ttl   time.Duration
+	ids   map[string]time.Time
+	marks int
+}
+
+// NewMemoryDedupStore creates an in-memory dedup store, ids expire after ttl (default: 1h)
+// it's suitable for tests and single instance services only
+func NewMemoryDedupStore(ttl time.Duration) DedupStore {
+	if ttl <= 0 {
+		ttl = defaultMemoryDedupTtl
+	}
+	return &memoryDedupStore{
+		ttl: ttl,
+		ids: map[string]time.Time{},
+	}
+}
+
+func (s *memoryDedupStore) Processed(ctx context.Context, id string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	expiresAt, ok := s.ids[id]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *memoryDedupStore) MarkProcessed(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	// evict expired ids lazily
+	s.marks++
+	if s.marks%memoryDedupEvictPeriod == 0 {
+		for k, exp := range s.ids {
+			if !now.Before(exp) {
+				delete(s.ids, k)
+			}
+		}
+	}
+	s.ids[id] = now.Add(s.ttl)
+	return nil
+}
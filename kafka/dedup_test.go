@@ -0,0 +1,122 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type dedupTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *dedupTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestDedupSuite(t *testing.T) {
+	suite.Run(t, new(dedupTestSuite))
+}
+
+func (s *dedupTestSuite) msg(key string, offset int64, payload any) kafka.Message {
+	value, err := kit.Marshal(&Message{Ctx: kit.NewRequestCtx().WithNewRequestId(), Key: key, Payload: payload})
+	s.NoError(err)
+	return kafka.Message{Key: []byte(key), Partition: 1, Offset: offset, Value: value}
+}
+
+func (s *dedupTestSuite) Test_WhenNoStore_Disabled() {
+	d := newDeduplicator(s.logger, &DedupConfig{}, "group", "")
+	s.Nil(d)
+	id, processed := d.check(s.Ctx, "topic", s.msg("key", 1, "payload"))
+	s.Empty(id)
+	s.False(processed)
+	d.markProcessed(s.Ctx, "id")
+}
+
+func (s *dedupTestSuite) Test_MessageId_PartitionOffset() {
+	d := newDeduplicator(s.logger, &DedupConfig{Store: NewMemoryDedupStore(0)}, "group", "")
+	s.Equal("group:topic:1:10", d.messageId(s.Ctx, "topic", s.msg("key", 10, "payload")))
+}
+
+func (s *dedupTestSuite) Test_SameOffsetDifferentPartitions_NotDuplicates() {
+	d := newDeduplicator(s.logger, &DedupConfig{Store: NewMemoryDedupStore(0)}, "group", MessageModeHeaders)
+	// messages with no key are spread across partitions, each partition starts from offset 0
+	m1 := kafka.Message{Partition: 0, Offset: 0, Value: []byte(`{"id":"1"}`)}
+	m2 := kafka.Message{Partition: 1, Offset: 0, Value: []byte(`{"id":"2"}`)}
+
+	id, processed := d.check(s.Ctx, "topic", m1)
+	s.False(processed)
+	d.markProcessed(s.Ctx, id)
+
+	id2, processed := d.check(s.Ctx, "topic", m2)
+	s.NotEqual(id, id2)
+	s.False(processed)
+}
+
+func (s *dedupTestSuite) Test_RetryTopicSameOffset_NotDuplicates() {
+	d := newDeduplicator(s.logger, &DedupConfig{Store: NewMemoryDedupStore(0)}, "group", MessageModeHeaders)
+	// a retry topic has its own offsets, which overlap with offsets of the main topic
+	m1 := kafka.Message{Topic: "topic", Partition: 0, Offset: 5, Value: []byte(`{"id":"1"}`)}
+	m2 := kafka.Message{Topic: RetryTopicName("topic", time.Second*5), Partition: 0, Offset: 5, Value: []byte(`{"id":"2"}`)}
+
+	id, processed := d.check(s.Ctx, "topic", m1)
+	s.False(processed)
+	d.markProcessed(s.Ctx, id)
+	s.Equal("group:topic:0:5", id)
+
+	id2, processed := d.check(s.Ctx, "topic", m2)
+	s.Equal("group:topic.retry.5s:0:5", id2)
+	s.False(processed)
+}
+
+func (s *dedupTestSuite) Test_MessageId_IdField() {
+	d := newDeduplicator(s.logger, &DedupConfig{Store: NewMemoryDedupStore(0), IdField: "event.id"}, "group", "")
+	s.Equal("group:topic:123", d.messageId(s.Ctx, "topic", s.msg("key", 10, map[string]any{"event": map[string]any{"id": "123"}})))
+	s.Equal("group:topic:456", d.messageId(s.Ctx, "topic", s.msg("key", 10, map[string]any{"event": map[string]any{"id": 456}})))
+	// fallback to topic, partition and offset
+	s.Equal("group:topic:1:10", d.messageId(s.Ctx, "topic", s.msg("key", 10, map[string]any{"event": "no-id"})))
+	s.Equal("group:topic:1:11", d.messageId(s.Ctx, "topic", s.msg("key", 11, "payload")))
+}
+
+func (s *dedupTestSuite) Test_CheckAndMark() {
+	d := newDeduplicator(s.logger, &DedupConfig{Store: NewMemoryDedupStore(0)}, "group", "")
+	m := s.msg("key", 1, "payload")
+
+	id, processed := d.check(s.Ctx, "topic", m)
+	s.NotEmpty(id)
+	s.False(processed)
+
+	// not marked yet (e.g. handler failed), still not processed
+	_, processed = d.check(s.Ctx, "topic", m)
+	s.False(processed)
+
+	d.markProcessed(s.Ctx, id)
+	_, processed = d.check(s.Ctx, "topic", m)
+	s.True(processed)
+
+	// another group processes the message independently
+	_, processed = newDeduplicator(s.logger, d.cfg, "another", "").check(s.Ctx, "topic", m)
+	s.False(processed)
+}
+
+func (s *dedupTestSuite) Test_MemoryStore_Expiration() {
+	store := NewMemoryDedupStore(time.Millisecond * 100)
+	s.NoError(store.MarkProcessed(s.Ctx, "id"))
+	processed, err := store.Processed(s.Ctx, "id")
+	s.NoError(err)
+	s.True(processed)
+	time.Sleep(time.Millisecond * 150)
+	processed, err = store.Processed(s.Ctx, "id")
+	s.NoError(err)
+	s.False(processed)
+}
+
+func (s *dedupTestSuite) Test_Builder_WhenNoStore_Fail() {
+	s.AssertAppErr(NewSubscriberCfgBuilder().DedupIdField("id").Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+	s.NoError(NewSubscriberCfgBuilder().Dedup(NewMemoryDedupStore(0)).Validate(s.Ctx))
+}
@@ -0,0 +1,471 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// dlqReadTimeout if no message is fetched within the timeout, a partition is considered read
+	dlqReadTimeout = time.Second * 10
+)
+
+// DLQRecord a message read from a dead-letter topic
+type DLQRecord struct {
+	Partition     int                 `json:"partition"`         // Partition DLQ partition
+	Offset        int64               `json:"offset"`            // Offset DLQ offset
+	Time          time.Time           `json:"time"`              // Time when the message landed in DLQ
+	Key           string              `json:"key"`               // Key message key
+	Topic         string              `json:"topic"`             // Topic origin topic
+	Ctx           *kit.RequestContext `json:"ctx,omitempty"`     // Ctx request context the message was sent to DLQ with
+	Headers       map[string]string   `json:"headers,omitempty"` // Headers kafka headers of the failed message
+	FailedMessage []byte              `json:"failedMessage"`     // FailedMessage kafka value of the failed message
+}
+
+// MarshalJSON renders a failed message as JSON if it's a valid JSON, otherwise as a string
+func (r *DLQRecord) MarshalJSON() ([]byte, error) {
+	type record DLQRecord
+	var failedMessage any = string(r.FailedMessage)
+	if json.Valid(r.FailedMessage) {
+		failedMessage = json.RawMessage(r.FailedMessage)
+	}
+	return json.Marshal(&struct {
+		*record
+		FailedMessage any `json:"failedMessage"`
+	}{
+		record:        (*record)(r),
+		FailedMessage: failedMessage,
+	})
+}
+
+// DLQPosition position of a message in a dead-letter topic
+type DLQPosition struct {
+	Partition int   // Partition DLQ partition
+	Offset    int64 // Offset DLQ offset
+}
+
+// DLQRange range of offsets of a partition of a dead-letter topic, bounds are inclusive
+type DLQRange struct {
+	Partition int   // Partition DLQ partition
+	From      int64 // From first offset
+	To        int64 // To last offset
+}
+
+// DLQFilter selects DLQ messages, empty fields aren't applied
+type DLQFilter struct {
+	Topic     string        // Topic origin topic
+	From      time.Time     // From messages landed in DLQ not earlier
+	To        time.Time     // To messages landed in DLQ not later
+	Positions []DLQPosition // Positions selected messages
+	Ranges    []DLQRange    // Ranges selected ranges, a message matches if it's either in Positions or in Ranges
+	Limit     int           // Limit max number of messages
+}
+
+// DLQReplayOptions options of replaying DLQ messages
+type DLQReplayOptions struct {
+	Mode   string  // Mode message mode of origin topics (envelope by default)
+	DryRun bool    // DryRun if set, messages aren't sent
+	Rate   float64 // Rate max messages per second, unlimited if zero
+}
+
+// DLQReplayResult result of replaying DLQ messages
+type DLQReplayResult struct {
+	Replayed int            // Replayed number of replayed messages (to be replayed in dry-run mode)
+	ByTopic  map[string]int // ByTopic number of replayed messages by origin topic
+	Last     *DLQPosition   // Last position of the last replayed message
+}
+
+// DLQRecordFn callback receiving DLQ messages
+type DLQRecordFn func(ctx context.Context, r *DLQRecord) error
+
+// DLQInspector allows inspecting and replaying messages of a dead-letter topic
+type DLQInspector interface {
+	// Read reads DLQ messages matching the filter, messages are passed to fn partition by partition in offset order
+	Read(ctx context.Context, filter *DLQFilter, fn DLQRecordFn) error
+	// Count counts DLQ messages matching the filter by origin topic
+	Count(ctx context.Context, filter *DLQFilter) (map[string]int, error)
+	// Replay sends DLQ messages matching the filter back to their origin topics
+	// replay stops on the first failure, the result contains position of the last replayed message
+	Replay(ctx context.Context, filter *DLQFilter, opts *DLQReplayOptions) (*DLQReplayResult, error)
+}
+
+// dlqProducerFn returns a producer for the origin topic
+type dlqProducerFn func(ctx context.Context, topic string) (Producer, error)
+
+// dlqMessageFn callback receiving raw messages of a dead-letter topic
+type dlqMessageFn func(m kafka.Message) error
+
+// dlqSource reads raw messages of dead-letter topics
+type dlqSource interface {
+	// partitions returns sorted partitions of the topic
+	partitions(ctx context.Context, topic string) ([]int, error)
+	// read passes messages of the partition landed not earlier than from to fn in offset order
+	read(ctx context.Context, topic string, partition int, from time.Time, fn dlqMessageFn) error
+}
+
+type dlqInspector struct {
+	logger   kit.CLoggerFunc
+	source   dlqSource
+	broker   Broker
+	topic    string
+	producer dlqProducerFn
+}
+
+// DLQ returns inspector of the given dead-letter topic
+func (b *brokerImpl) DLQ(topic string) DLQInspector {
+	return &dlqInspector{
+		logger: b.logger,
+		source: &kafkaDLQSource{broker: b},
+		broker: b,
+		topic:  topic,
+	}
+}
+
+func (d *dlqInspector) l() kit.CLogger {
+	return d.logger().Cmp("kafka-dlq")
+}
+
+func (d *dlqInspector) Read(ctx context.Context, filter *DLQFilter, fn DLQRecordFn) error {
+	l := d.l().C(ctx).Mth("read").F(kit.KV{"dlq": d.topic}).Dbg()
+
+	if filter == nil {
+		filter = &DLQFilter{}
+	}
+
+	partitions, err := d.source.partitions(ctx, d.topic)
+	if err != nil {
+		return err
+	}
+
+	matched := 0
+	for _, p := range partitions {
+		if !filter.partition(p) {
+			continue
+		}
+		err := d.readPartition(ctx, p, filter, func(ctx context.Context, r *DLQRecord) error {
+			if filter.Limit > 0 && matched >= filter.Limit {
+				return errDLQLimitReached
+			}
+			matched++
+			return fn(ctx, r)
+		})
+		if errors.Is(err, errDLQLimitReached) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	l.F(kit.KV{"matched": matched}).Dbg("ok")
+	return nil
+}
+
+// errDLQLimitReached stops reading once the limit is reached
+var errDLQLimitReached = errors.New("limit reached")
+
+func (d *dlqInspector) readPartition(ctx context.Context, partition int, filter *DLQFilter, fn DLQRecordFn) error {
+	l := d.l().C(ctx).Mth("read-partition").F(kit.KV{"dlq": d.topic, "partition": partition})
+	return d.source.read(ctx, d.topic, partition, filter.From, func(m kafka.Message) error {
+		r, err := decodeDLQRecord(ctx, m)
+		if err != nil {
+			l.F(kit.KV{"offset": m.Offset}).E(err).Warn("skipped")
+			return nil
+		}
+		if !filter.match(r) {
+			return nil
+		}
+		return fn(ctx, r)
+	})
+}
+
+// kafkaDLQSource reads dead-letter topics from kafka
+type kafkaDLQSource struct {
+	broker *brokerImpl
+}
+
+func (k *kafkaDLQSource) partitions(ctx context.Context, topic string) ([]int, error) {
+	if k.broker.conn == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+	partitions, err := k.broker.conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, ErrKafkaDLQRead(ctx, err, topic)
+	}
+	r := make([]int, 0, len(partitions))
+	for _, p := range partitions {
+		r = append(r, p.ID)
+	}
+	sort.Ints(r)
+	return r, nil
+}
+
+func (k *kafkaDLQSource) read(ctx context.Context, topic string, partition int, from time.Time, fn dlqMessageFn) error {
+	l := k.broker.l().C(ctx).Mth("dlq-read").F(kit.KV{"dlq": topic, "partition": partition})
+
+	// get bounds of the partition
+	conn, err := k.broker.dialer.DialLeader(ctx, "tcp", k.broker.urls[0], topic, partition)
+	if err != nil {
+		return ErrKafkaDLQRead(ctx, err, topic)
+	}
+	defer func() { _ = conn.Close() }()
+
+	first, last, err := conn.ReadOffsets()
+	if err != nil {
+		return ErrKafkaDLQRead(ctx, err, topic)
+	}
+	start := first
+	if !from.IsZero() {
+		if start, err = conn.ReadOffset(from); err != nil {
+			return ErrKafkaDLQRead(ctx, err, topic)
+		}
+	}
+	if start < first || start >= last {
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   k.broker.urls,
+		Topic:     topic,
+		Partition: partition,
+		Dialer:    k.broker.dialer,
+	})
+	defer func() { _ = reader.Close() }()
+	if err := reader.SetOffset(start); err != nil {
+		return ErrKafkaDLQRead(ctx, err, topic)
+	}
+
+	for next := start; next < last; {
+		m, err := k.fetch(ctx, reader)
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			// the rest of offsets might be taken by transaction markers
+			l.F(kit.KV{"next": next, "last": last}).Warn("no more messages")
+			return nil
+		}
+		if err != nil {
+			return ErrKafkaDLQRead(ctx, err, topic)
+		}
+		next = m.Offset + 1
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *kafkaDLQSource) fetch(ctx context.Context, reader *kafka.Reader) (kafka.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, dlqReadTimeout)
+	defer cancel()
+	return reader.FetchMessage(ctx)
+}
+
+func (d *dlqInspector) Count(ctx context.Context, filter *DLQFilter) (map[string]int, error) {
+	r := map[string]int{}
+	err := d.Read(ctx, filter, func(ctx context.Context, rec *DLQRecord) error {
+		r[rec.Topic]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (d *dlqInspector) Replay(ctx context.Context, filter *DLQFilter, opts *DLQReplayOptions) (*DLQReplayResult, error) {
+	l := d.l().C(ctx).Mth("replay").F(kit.KV{"dlq": d.topic})
+
+	replayer, err := d.replayer(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = d.Read(ctx, filter, replayer.replay)
+	if err != nil {
+		return replayer.result, err
+	}
+
+	l.F(kit.KV{"replayed": replayer.result.Replayed, "dryRun": replayer.opts.DryRun}).Inf("ok")
+	return replayer.result, nil
+}
+
+func (d *dlqInspector) replayer(ctx context.Context, opts *DLQReplayOptions) (*dlqReplayer, error) {
+	o := &DLQReplayOptions{}
+	if opts != nil {
+		*o = *opts
+	}
+	if !validMessageMode(o.Mode) {
+		return nil, ErrKafkaMessageModeInvalid(ctx, o.Mode)
+	}
+
+	producer := d.producer
+	if producer == nil {
+		producer = func(ctx context.Context, topic string) (Producer, error) {
+			return d.broker.AddProducer(ctx, &TopicConfig{Topic: topic}, &ProducerConfig{Mode: o.Mode})
+		}
+	}
+
+	r := &dlqReplayer{
+		opts:      o,
+		producer:  producer,
+		producers: map[string]Producer{},
+		result:    &DLQReplayResult{ByTopic: map[string]int{}},
+	}
+	if o.Rate > 0 {
+		r.limiter = rate.NewLimiter(rate.Limit(o.Rate), 1)
+	}
+	return r, nil
+}
+
+// dlqReplayer sends DLQ messages back to their origin topics
+type dlqReplayer struct {
+	opts      *DLQReplayOptions
+	producer  dlqProducerFn
+	producers map[string]Producer
+	limiter   *rate.Limiter
+	result    *DLQReplayResult
+}
+
+func (r *dlqReplayer) replay(ctx context.Context, rec *DLQRecord) error {
+	msg, err := replayMessage(ctx, r.opts.Mode, rec)
+	if err != nil {
+		return err
+	}
+
+	if !r.opts.DryRun {
+		p, ok := r.producers[rec.Topic]
+		if !ok {
+			if p, err = r.producer(ctx, rec.Topic); err != nil {
+				return err
+			}
+			r.producers[rec.Topic] = p
+		}
+		if r.limiter != nil {
+			if err := r.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		if err := p.SendMany(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	r.result.Replayed++
+	r.result.ByTopic[rec.Topic]++
+	r.result.Last = &DLQPosition{Partition: rec.Partition, Offset: rec.Offset}
+	return nil
+}
+
+// decodeDLQRecord decodes a DLQ message sent either in envelope or headers mode
+func decodeDLQRecord(ctx context.Context, m kafka.Message) (*DLQRecord, error) {
+	r := &DLQRecord{
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Time:      m.Time,
+		Key:       string(m.Key),
+	}
+
+	var dlqMsg *DLQMessage
+	var envelope MessageT[*DLQMessage]
+	if err := kit.Unmarshal(m.Value, &envelope); err == nil && envelope.Payload != nil && envelope.Payload.Topic != "" {
+		dlqMsg = envelope.Payload
+		r.Ctx = envelope.Ctx
+	} else {
+		// headers mode
+		if err := kit.Unmarshal(m.Value, &dlqMsg); err != nil {
+			return nil, ErrKafkaDLQDecode(ctx, err)
+		}
+		if dlqMsg == nil || dlqMsg.Topic == "" {
+			return nil, ErrKafkaDLQDecode(ctx, nil)
+		}
+		r.Ctx, _ = requestCtxFromHeaders(headersToMap(m.Headers))
+	}
+
+	r.Topic = dlqMsg.Topic
+	r.Headers = dlqMsg.Headers
+	r.FailedMessage = dlqMsg.FailedMessage
+	return r, nil
+}
+
+// replayMessage restores the failed message to be sent to the origin topic
+// retry headers are dropped, so the message passes all retry tiers again
+func replayMessage(ctx context.Context, mode string, r *DLQRecord) (*Message, error) {
+	headers := map[string]string{}
+	for k, v := range r.Headers {
+		switch k {
+		case HeaderRetryAttempt, HeaderRetryTopic, HeaderRetryNotBefore, HeaderRetryError:
+		case HeaderRequestId, HeaderSessionId, HeaderUserId, HeaderUsername, HeaderApp, HeaderClientIp, HeaderRoles, HeaderLang, HeaderKv:
+			// request context is restored from the message
+			if mode != MessageModeHeaders {
+				headers[k] = v
+			}
+		default:
+			headers[k] = v
+		}
+	}
+
+	if mode == MessageModeHeaders {
+		rCtx, _ := requestCtxFromHeaders(r.Headers)
+		return &Message{Ctx: rCtx, Key: r.Key, Payload: r.FailedMessage, Headers: headers}, nil
+	}
+
+	var m MessageT[json.RawMessage]
+	if err := kit.Unmarshal(r.FailedMessage, &m); err != nil {
+		return nil, ErrKafkaDecodeMsgUnmarshal(ctx, err)
+	}
+	return &Message{Ctx: m.Ctx, Key: m.Key, Payload: m.Payload, Headers: headers}, nil
+}
+
+func (f *DLQFilter) selective() bool {
+	return len(f.Positions) > 0 || len(f.Ranges) > 0
+}
+
+// partition checks if the partition might contain selected messages
+func (f *DLQFilter) partition(partition int) bool {
+	if !f.selective() {
+		return true
+	}
+	for _, p := range f.Positions {
+		if p.Partition == partition {
+			return true
+		}
+	}
+	for _, rg := range f.Ranges {
+		if rg.Partition == partition {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *DLQFilter) match(r *DLQRecord) bool {
+	if f.Topic != "" && f.Topic != r.Topic {
+		return false
+	}
+	if !f.From.IsZero() && r.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && r.Time.After(f.To) {
+		return false
+	}
+	if !f.selective() {
+		return true
+	}
+	for _, p := range f.Positions {
+		if p.Partition == r.Partition && p.Offset == r.Offset {
+			return true
+		}
+	}
+	for _, rg := range f.Ranges {
+		if rg.Partition == r.Partition && r.Offset >= rg.From && r.Offset <= rg.To {
+			return true
+		}
+	}
+	return false
+}
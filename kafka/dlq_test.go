@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type dlqTestSuite struct {
+	kit.Suite
+}
+
+func (s *dlqTestSuite) SetupSuite() {
+	s.Suite.Init(func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) })
+}
+
+func TestDlqSuite(t *testing.T) {
+	suite.Run(t, new(dlqTestSuite))
+}
+
+func (s *dlqTestSuite) failedEnvelope(payload any) []byte {
+	value, err := kit.Marshal(&Message{Ctx: kit.NewRequestCtx().WithNewRequestId().WithUser("uid", "un"), Key: "key", Payload: payload})
+	s.NoError(err)
+	return value
+}
+
+func (s *dlqTestSuite) dlqMessage(dlqMsg *DLQMessage) kafka.Message {
+	value, err := kit.Marshal(&Message{Ctx: kit.NewRequestCtx().WithNewRequestId(), Key: "key", Payload: dlqMsg})
+	s.NoError(err)
+	return kafka.Message{Partition: 1, Offset: 10, Key: []byte("key"), Value: value, Time: time.Now()}
+}
+
+func (s *dlqTestSuite) replayer(opts *DLQReplayOptions, producers map[string]*typedTestProducer) *dlqReplayer {
+	d := &dlqInspector{
+		producer: func(ctx context.Context, topic string) (Producer, error) {
+			p := &typedTestProducer{}
+			producers[topic] = p
+			return p, nil
+		},
+	}
+	r, err := d.replayer(s.Ctx, opts)
+	s.NoError(err)
+	return r
+}
+
+func (s *dlqTestSuite) Test_DecodeRecord_Envelope() {
+	failed := s.failedEnvelope("payload")
+	r, err := decodeDLQRecord(s.Ctx, s.dlqMessage(&DLQMessage{Topic: "topic", FailedMessage: failed, Headers: map[string]string{"h": "v"}}))
+	s.NoError(err)
+	s.Equal("topic", r.Topic)
+	s.Equal("key", r.Key)
+	s.Equal(1, r.Partition)
+	s.Equal(int64(10), r.Offset)
+	s.Equal(failed, r.FailedMessage)
+	s.Equal("v", r.Headers["h"])
+	s.NotNil(r.Ctx)
+}
+
+func (s *dlqTestSuite) Test_DecodeRecord_Headers() {
+	value, err := kit.Marshal(&DLQMessage{Topic: "topic", FailedMessage: []byte("raw")})
+	s.NoError(err)
+	r, err := decodeDLQRecord(s.Ctx, kafka.Message{Key: []byte("key"), Value: value, Headers: []kafka.Header{{Key: HeaderRequestId, Value: []byte("rid")}}})
+	s.NoError(err)
+	s.Equal("topic", r.Topic)
+	s.Equal([]byte("raw"), r.FailedMessage)
+	s.Equal("rid", r.Ctx.Rid)
+}
+
+func (s *dlqTestSuite) Test_DecodeRecord_Invalid() {
+	_, err := decodeDLQRecord(s.Ctx, kafka.Message{Value: []byte("not a json")})
+	s.AssertAppErr(err, ErrCodeKafkaDLQDecode)
+	_, err = decodeDLQRecord(s.Ctx, kafka.Message{Value: []byte(`{"some":"json"}`)})
+	s.AssertAppErr(err, ErrCodeKafkaDLQDecode)
+}
+
+func (s *dlqTestSuite) Test_Record_MarshalJSON() {
+	r := &DLQRecord{Topic: "topic", FailedMessage: []byte(`{"a":1}`)}
+	b, err := json.Marshal(r)
+	s.NoError(err)
+	s.Contains(string(b), `"failedMessage":{"a":1}`)
+	s.Contains(string(b), `"topic":"topic"`)
+
+	r.FailedMessage = []byte("raw")
+	b, err = json.Marshal(r)
+	s.NoError(err)
+	s.Contains(string(b), `"failedMessage":"raw"`)
+}
+
+func (s *dlqTestSuite) Test_Filter() {
+	now := time.Now()
+	r := &DLQRecord{Topic: "topic", Partition: 1, Offset: 10, Time: now}
+
+	s.True((&DLQFilter{}).match(r))
+	s.True((&DLQFilter{Topic: "topic"}).match(r))
+	s.False((&DLQFilter{Topic: "another"}).match(r))
+	s.True((&DLQFilter{From: now.Add(-time.Minute), To: now.Add(time.Minute)}).match(r))
+	s.False((&DLQFilter{From: now.Add(time.Minute)}).match(r))
+	s.False((&DLQFilter{To: now.Add(-time.Minute)}).match(r))
+	s.True((&DLQFilter{Positions: []DLQPosition{{Partition: 1, Offset: 10}}}).match(r))
+	s.False((&DLQFilter{Positions: []DLQPosition{{Partition: 0, Offset: 10}}}).match(r))
+	s.True((&DLQFilter{Ranges: []DLQRange{{Partition: 1, From: 5, To: 10}}}).match(r))
+	s.False((&DLQFilter{Ranges: []DLQRange{{Partition: 1, From: 11, To: 20}}}).match(r))
+	s.True((&DLQFilter{Positions: []DLQPosition{{Partition: 0, Offset: 1}}, Ranges: []DLQRange{{Partition: 1, From: 0, To: 10}}}).match(r))
+
+	s.True((&DLQFilter{}).partition(2))
+	s.True((&DLQFilter{Ranges: []DLQRange{{Partition: 2}}}).partition(2))
+	s.False((&DLQFilter{Positions: []DLQPosition{{Partition: 1}}}).partition(2))
+}
+
+func (s *dlqTestSuite) Test_ReplayMessage_Envelope() {
+	failed := s.failedEnvelope(map[string]any{"id": "1"})
+	m, err := replayMessage(s.Ctx, "", &DLQRecord{Topic: "topic", FailedMessage: failed, Headers: map[string]string{
+		"h":                  "v",
+		HeaderRetryAttempt:   "2",
+		HeaderRetryNotBefore: "1",
+	}})
+	s.NoError(err)
+	s.Equal("key", m.Key)
+	s.Equal("uid", m.Ctx.Uid)
+	s.Equal(map[string]string{"h": "v"}, m.Headers)
+
+	// restored envelope equals the failed one
+	value, err := kit.Marshal(m)
+	s.NoError(err)
+	s.JSONEq(string(failed), string(value))
+}
+
+func (s *dlqTestSuite) Test_ReplayMessage_Headers() {
+	m, err := replayMessage(s.Ctx, MessageModeHeaders, &DLQRecord{Topic: "topic", Key: "key", FailedMessage: []byte("raw"), Headers: map[string]string{
+		"h":              "v",
+		HeaderRequestId:  "rid",
+		HeaderUserId:     "uid",
+		HeaderRetryTopic: "topic",
+	}})
+	s.NoError(err)
+	s.Equal("key", m.Key)
+	s.Equal([]byte("raw"), m.Payload)
+	s.Equal("rid", m.Ctx.Rid)
+	s.Equal("uid", m.Ctx.Uid)
+	s.Equal(map[string]string{"h": "v"}, m.Headers)
+}
+
+func (s *dlqTestSuite) Test_ReplayMessage_WhenEnvelopeInvalid_Fail() {
+	_, err := replayMessage(s.Ctx, MessageModeEnvelope, &DLQRecord{Topic: "topic", FailedMessage: []byte("raw")})
+	s.AssertAppErr(err, ErrCodeKafkaDecodeMsgUnmarshal)
+}
+
+func (s *dlqTestSuite) Test_Replay() {
+	producers := map[string]*typedTestProducer{}
+	r := s.replayer(&DLQReplayOptions{}, producers)
+
+	s.NoError(r.replay(s.Ctx, &DLQRecord{Topic: "topic-1", Partition: 0, Offset: 1, FailedMessage: s.failedEnvelope("1")}))
+	s.NoError(r.replay(s.Ctx, &DLQRecord{Topic: "topic-1", Partition: 0, Offset: 2, FailedMessage: s.failedEnvelope("2")}))
+	s.NoError(r.replay(s.Ctx, &DLQRecord{Topic: "topic-2", Partition: 1, Offset: 1, FailedMessage: s.failedEnvelope("3")}))
+
+	s.Len(producers, 2)
+	s.Len(producers["topic-1"].messages, 2)
+	s.Len(producers["topic-2"].messages, 1)
+	s.Equal(3, r.result.Replayed)
+	s.Equal(map[string]int{"topic-1": 2, "topic-2": 1}, r.result.ByTopic)
+	s.Equal(&DLQPosition{Partition: 1, Offset: 1}, r.result.Last)
+}
+
+func (s *dlqTestSuite) Test_Replay_DryRun() {
+	producers := map[string]*typedTestProducer{}
+	r := s.replayer(&DLQReplayOptions{DryRun: true}, producers)
+
+	s.NoError(r.replay(s.Ctx, &DLQRecord{Topic: "topic", FailedMessage: s.failedEnvelope("1")}))
+	s.Empty(producers)
+	s.Equal(1, r.result.Replayed)
+
+	// invalid messages are reported in dry-run mode
+	s.AssertAppErr(r.replay(s.Ctx, &DLQRecord{Topic: "topic", FailedMessage: []byte("raw")}), ErrCodeKafkaDecodeMsgUnmarshal)
+	s.Equal(1, r.result.Replayed)
+}
+
+func (s *dlqTestSuite) Test_Replay_RateLimited() {
+	producers := map[string]*typedTestProducer{}
+	r := s.replayer(&DLQReplayOptions{Rate: 20}, producers)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		s.NoError(r.replay(s.Ctx, &DLQRecord{Topic: "topic", FailedMessage: s.failedEnvelope(i)}))
+	}
+	// the first message is sent immediately, the rest each 50ms
+	s.GreaterOrEqual(time.Since(start), time.Millisecond*190)
+	s.Len(producers["topic"].messages, 5)
+}
+
+func (s *dlqTestSuite) Test_Replayer_WhenModeInvalid_Fail() {
+	_, err := (&dlqInspector{}).replayer(s.Ctx, &DLQReplayOptions{Mode: "unknown"})
+	s.AssertAppErr(err, ErrCodeKafkaMessageModeInvalid)
+}
@@ -26,6 +26,24 @@ const (
 	ErrCodeKafkaManualCommitRetryCountExceeded              = "KF-019"
 	ErrCodeKafkaHandleMessageManualCommitRetryCountExceeded = "KF-020"
 	ErrCodeKafkaSubscriberConfigInvalid                     = "KF-021"
+	ErrCodeKafkaMessageModeInvalid                          = "KF-022"
+	ErrCodeKafkaListTopics                                  = "KF-023"
+	ErrCodeKafkaDescribeTopics                              = "KF-024"
+	ErrCodeKafkaDeleteTopics                                = "KF-025"
+	ErrCodeKafkaIncreasePartitions                          = "KF-026"
+	ErrCodeKafkaAlterTopicConfigs                           = "KF-027"
+	ErrCodeKafkaTopicNotFound                               = "KF-028"
+	ErrCodeKafkaRetryPublish                                = "KF-029"
+	ErrCodeKafkaDLQRead                                     = "KF-030"
+	ErrCodeKafkaDLQDecode                                   = "KF-031"
+	ErrCodeKafkaSubscriberNotFound                          = "KF-032"
+	ErrCodeKafkaSerializerModeInvalid                       = "KF-033"
+	ErrCodeKafkaSerialize                                   = "KF-034"
+	ErrCodeKafkaWireFormatInvalid                           = "KF-035"
+	ErrCodeKafkaSchemaIncompatible                          = "KF-036"
+	ErrCodeKafkaSchemaRegister                              = "KF-037"
+	ErrCodeKafkaSchemaNotFound                              = "KF-038"
+	ErrCodeKafkaSchemaRegistryFile                          = "KF-039"
 )
 
 var (
@@ -86,4 +104,58 @@ var (
 	ErrKafkaSaslGetMechanism = func(ctx context.Context, cause error) error {
 		return kit.NewAppErrBuilder(ErrCodeKafkaSaslGetMechanism, "sasl mechanism").C(ctx).Err()
 	}
+	ErrKafkaMessageModeInvalid = func(ctx context.Context, mode string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaMessageModeInvalid, "message mode invalid").F(kit.KV{"mode": mode}).C(ctx).Err()
+	}
+	ErrKafkaListTopics = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaListTopics, "list topics").Wrap(cause).C(ctx).Err()
+	}
+	ErrKafkaDescribeTopics = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaDescribeTopics, "describe topics").Wrap(cause).C(ctx).Err()
+	}
+	ErrKafkaDeleteTopics = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaDeleteTopics, "delete topics").Wrap(cause).C(ctx).Err()
+	}
+	ErrKafkaIncreasePartitions = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaIncreasePartitions, "increase partitions").Wrap(cause).F(kit.KV{"topic": topic}).C(ctx).Err()
+	}
+	ErrKafkaAlterTopicConfigs = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaAlterTopicConfigs, "alter topic configs").Wrap(cause).F(kit.KV{"topic": topic}).C(ctx).Err()
+	}
+	ErrKafkaTopicNotFound = func(ctx context.Context, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaTopicNotFound, "topic not found").F(kit.KV{"topic": topic}).C(ctx).Err()
+	}
+	ErrKafkaRetryPublish = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaRetryPublish, "retry publish").Wrap(cause).F(kit.KV{"topic": topic}).C(ctx).Err()
+	}
+	ErrKafkaDLQRead = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaDLQRead, "dlq read").Wrap(cause).F(kit.KV{"topic": topic}).C(ctx).Err()
+	}
+	ErrKafkaDLQDecode = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaDLQDecode, "dlq message invalid").Wrap(cause).C(ctx).Err()
+	}
+	ErrKafkaSubscriberNotFound = func(ctx context.Context, topic, groupId string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSubscriberNotFound, "subscriber not found").F(kit.KV{"topic": topic, "groupId": groupId}).C(ctx).Err()
+	}
+	ErrKafkaSerializerModeInvalid = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSerializerModeInvalid, "serializer requires headers mode").C(ctx).Err()
+	}
+	ErrKafkaSerialize = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSerialize, "serialize payload").Wrap(cause).F(kit.KV{"topic": topic}).C(ctx).Err()
+	}
+	ErrKafkaWireFormatInvalid = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaWireFormatInvalid, "message isn't in schema registry wire format").C(ctx).Err()
+	}
+	ErrKafkaSchemaIncompatible = func(ctx context.Context, subject, reason string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSchemaIncompatible, "schema incompatible: %s", reason).F(kit.KV{"subject": subject}).C(ctx).Err()
+	}
+	ErrKafkaSchemaRegister = func(ctx context.Context, cause error, subject string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSchemaRegister, "schema register").Wrap(cause).F(kit.KV{"subject": subject}).C(ctx).Err()
+	}
+	ErrKafkaSchemaNotFound = func(ctx context.Context, kv kit.KV) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSchemaNotFound, "schema not found").F(kv).C(ctx).Err()
+	}
+	ErrKafkaSchemaRegistryFile = func(ctx context.Context, cause error, path string) error {
+		return kit.NewAppErrBuilder(ErrCodeKafkaSchemaRegistryFile, "schema registry file").Wrap(cause).F(kit.KV{"path": path}).C(ctx).Err()
+	}
 )
@@ -0,0 +1,198 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"golang.org/x/text/language"
+)
+
+const (
+	// MessageModeEnvelope kafka value is a JSON envelope Message{Ctx, Key, Payload} (default)
+	MessageModeEnvelope = "envelope"
+	// MessageModeHeaders request context is carried by kafka headers and kafka value is a raw payload
+	// it allows interoperating with non-kit producers and consumers
+	MessageModeHeaders = "headers"
+)
+
+// request context headers
+const (
+	HeaderRequestId = "x-request-id" // HeaderRequestId request ID
+	HeaderSessionId = "x-session-id" // HeaderSessionId session ID
+	HeaderUserId    = "x-user-id"    // HeaderUserId user ID
+	HeaderUsername  = "x-username"   // HeaderUsername username
+	HeaderApp       = "x-app"        // HeaderApp application
+	HeaderClientIp  = "x-client-ip"  // HeaderClientIp client IP
+	HeaderRoles     = "x-roles"      // HeaderRoles comma separated list of roles
+	HeaderLang      = "x-lang"       // HeaderLang client language
+	HeaderKv        = "x-kv"         // HeaderKv arbitrary key-value as JSON object
+)
+
+// MessageDescriptor describes a consumed message
+type MessageDescriptor struct {
+	Topic      string              // Topic message topic
+	Key        string              // Key message key
+	Partition  int                 // Partition message partition
+	Offset     int64               // Offset message offset
+	Time       time.Time           // Time when the message was produced
+	Headers    map[string]string   // Headers kafka headers
+	Payload    []byte              // Payload raw payload (in envelope mode it's JSON of the envelope payload)
+	Ctx        *kit.RequestContext // Ctx request context restored from the message
+	mode       string
+	serializer Serializer
+}
+
+// Decode decodes the payload to v, which must be a pointer
+// the payload is deserialized with the subscriber's serializer if it's configured, otherwise it's unmarshaled from JSON
+func (m *MessageDescriptor) Decode(ctx context.Context, v any) error {
+	if m.serializer != nil {
+		return m.serializer.Deserialize(ctx, m.Topic, m.Payload, v)
+	}
+	if err := kit.Unmarshal(m.Payload, v); err != nil {
+		return ErrKafkaMsgUnmarshalPayload(ctx, err)
+	}
+	return nil
+}
+
+// MessageHandlerFn handler function receiving context with the restored request context and the message descriptor
+type MessageHandlerFn func(ctx context.Context, m *MessageDescriptor) error
+
+func validMessageMode(mode string) bool {
+	return mode == "" || mode == MessageModeEnvelope || mode == MessageModeHeaders
+}
+
+// requestCtxToHeaders converts request context to kafka headers, empty fields are omitted
+func requestCtxToHeaders(r *kit.RequestContext) ([]kafka.Header, error) {
+	if r == nil {
+		return nil, nil
+	}
+	var hs []kafka.Header
+	add := func(k, v string) {
+		if v != "" {
+			hs = append(hs, kafka.Header{Key: k, Value: []byte(v)})
+		}
+	}
+	add(HeaderRequestId, r.Rid)
+	add(HeaderSessionId, r.Sid)
+	add(HeaderUserId, r.Uid)
+	add(HeaderUsername, r.Un)
+	add(HeaderApp, r.App)
+	add(HeaderClientIp, r.ClIp)
+	add(HeaderRoles, strings.Join(r.Roles, ","))
+	if r.Lang != language.Und {
+		add(HeaderLang, r.Lang.String())
+	}
+	if len(r.Kv) > 0 {
+		kv, err := json.Marshal(r.Kv)
+		if err != nil {
+			return nil, err
+		}
+		add(HeaderKv, string(kv))
+	}
+	return hs, nil
+}
+
+// requestCtxFromHeaders restores request context from kafka headers
+// returns false if there is no request id in headers (e.g. the message is sent by a non-kit producer)
+func requestCtxFromHeaders(headers map[string]string) (*kit.RequestContext, bool) {
+	rid, ok := headers[HeaderRequestId]
+	if !ok || rid == "" {
+		return nil, false
+	}
+	r := &kit.RequestContext{
+		Rid:  rid,
+		Sid:  headers[HeaderSessionId],
+		Uid:  headers[HeaderUserId],
+		Un:   headers[HeaderUsername],
+		App:  headers[HeaderApp],
+		ClIp: headers[HeaderClientIp],
+	}
+	if roles := headers[HeaderRoles]; roles != "" {
+		r.Roles = strings.Split(roles, ",")
+	}
+	if lang := headers[HeaderLang]; lang != "" {
+		if tag, err := language.Parse(lang); err == nil {
+			r.Lang = tag
+		}
+	}
+	if kv := headers[HeaderKv]; kv != "" {
+		_ = json.Unmarshal([]byte(kv), &r.Kv)
+	}
+	return r, true
+}
+
+func headersToMap(hs []kafka.Header) map[string]string {
+	if len(hs) == 0 {
+		return nil
+	}
+	r := make(map[string]string, len(hs))
+	for _, h := range hs {
+		r[h.Key] = string(h.Value)
+	}
+	return r
+}
+
+func mapToHeaders(m map[string]string) []kafka.Header {
+	if len(m) == 0 {
+		return nil
+	}
+	r := make([]kafka.Header, 0, len(m))
+	for k, v := range m {
+		r = append(r, kafka.Header{Key: k, Value: []byte(v)})
+	}
+	return r
+}
+
+// rawPayload converts payload to kafka value in headers mode
+// bytes and strings are sent as is, everything else is marshaled to JSON
+func rawPayload(payload any) ([]byte, error) {
+	switch p := payload.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return p, nil
+	case json.RawMessage:
+		return p, nil
+	case string:
+		return []byte(p), nil
+	default:
+		return kit.Marshal(payload)
+	}
+}
+
+// describe builds a message descriptor and a context with request context restored from the message
+// if a message carries no request context, a new one is generated
+func describe(parentCtx context.Context, mode, topic string, m kafka.Message) (context.Context, *MessageDescriptor, error) {
+	d := &MessageDescriptor{
+		Topic:     topic,
+		Key:       string(m.Key),
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Time:      m.Time,
+		Headers:   headersToMap(m.Headers),
+		mode:      mode,
+	}
+
+	var rCtx *kit.RequestContext
+	if mode == MessageModeHeaders {
+		d.Payload = m.Value
+		rCtx, _ = requestCtxFromHeaders(d.Headers)
+	} else {
+		var msg MessageT[json.RawMessage]
+		if err := kit.Unmarshal(m.Value, &msg); err != nil {
+			return nil, nil, ErrKafkaDecodeMsgUnmarshal(parentCtx, err)
+		}
+		d.Payload = msg.Payload
+		rCtx = msg.Ctx
+	}
+	if rCtx == nil {
+		rCtx = kit.NewRequestCtx().WithNewRequestId()
+	}
+	d.Ctx = rCtx
+
+	return rCtx.ToContext(parentCtx), d, nil
+}
@@ -0,0 +1,190 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/text/language"
+)
+
+type headersTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *headersTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestHeadersSuite(t *testing.T) {
+	suite.Run(t, new(headersTestSuite))
+}
+
+type headersTestPayload struct {
+	Id string `json:"id"`
+}
+
+func (s *headersTestSuite) Test_RequestCtx_RoundTrip() {
+	rCtx := kit.NewRequestCtx().
+		WithNewRequestId().
+		WithSessionId("sid").
+		WithUser("uid", "un").
+		WithApp("app").
+		WithRoles("admin", "user").
+		WithLang(language.German).
+		WithKv("k", "v")
+
+	hs, err := requestCtxToHeaders(rCtx)
+	s.NoError(err)
+	actual, ok := requestCtxFromHeaders(headersToMap(hs))
+	s.True(ok)
+	s.Equal(rCtx.Rid, actual.Rid)
+	s.Equal(rCtx.Sid, actual.Sid)
+	s.Equal(rCtx.Uid, actual.Uid)
+	s.Equal(rCtx.Un, actual.Un)
+	s.Equal(rCtx.App, actual.App)
+	s.Equal(rCtx.Roles, actual.Roles)
+	s.Equal(rCtx.Lang, actual.Lang)
+	s.Equal("v", actual.Kv["k"])
+}
+
+func (s *headersTestSuite) Test_RequestCtx_WhenNoRequestId() {
+	_, ok := requestCtxFromHeaders(map[string]string{HeaderUserId: "uid"})
+	s.False(ok)
+	_, ok = requestCtxFromHeaders(nil)
+	s.False(ok)
+}
+
+func (s *headersTestSuite) Test_Producer_HeadersMode() {
+	p := &producerImpl{mode: MessageModeHeaders}
+	rCtx := kit.NewRequestCtx().WithNewRequestId()
+
+	m, err := p.toKafkaMessage(s.Ctx, &Message{Ctx: rCtx, Key: "key", Payload: &headersTestPayload{Id: "1"}, Headers: map[string]string{"custom": "value"}})
+	s.NoError(err)
+	s.Equal("key", string(m.Key))
+	s.JSONEq(`{"id":"1"}`, string(m.Value))
+	hs := headersToMap(m.Headers)
+	s.Equal(rCtx.Rid, hs[HeaderRequestId])
+	s.Equal("value", hs["custom"])
+
+	// bytes are sent as is
+	m, err = p.toKafkaMessage(s.Ctx, &Message{Ctx: rCtx, Key: "key", Payload: []byte("raw")})
+	s.NoError(err)
+	s.Equal("raw", string(m.Value))
+}
+
+func (s *headersTestSuite) Test_Producer_EnvelopeMode() {
+	p := &producerImpl{}
+	rCtx := kit.NewRequestCtx().WithNewRequestId()
+
+	m, err := p.toKafkaMessage(s.Ctx, &Message{Ctx: rCtx, Key: "key", Payload: &headersTestPayload{Id: "1"}, Headers: map[string]string{"custom": "value"}})
+	s.NoError(err)
+	pl, ctx, err := Decode[*headersTestPayload](s.Ctx, m.Value)
+	s.NoError(err)
+	s.Equal("1", pl.Id)
+	actual, _ := kit.Request(ctx)
+	s.Equal(rCtx.Rid, actual.Rid)
+	// custom headers aren't put into envelope
+	s.NotContains(string(m.Value), "custom")
+	s.Equal("value", headersToMap(m.Headers)["custom"])
+}
+
+func (s *headersTestSuite) Test_Describe() {
+	rCtx := kit.NewRequestCtx().WithNewRequestId()
+	now := kit.Now()
+
+	for _, mode := range []string{MessageModeEnvelope, MessageModeHeaders} {
+		m, err := (&producerImpl{mode: mode}).toKafkaMessage(s.Ctx, &Message{Ctx: rCtx, Key: "key", Payload: &headersTestPayload{Id: "1"}})
+		s.NoError(err)
+		m.Partition, m.Offset, m.Time = 2, 10, now
+
+		ctx, d, err := describe(s.Ctx, mode, "topic", m)
+		s.NoError(err)
+		actual, ok := kit.Request(ctx)
+		s.True(ok)
+		s.Equal(rCtx.Rid, actual.Rid)
+		s.Equal("topic", d.Topic)
+		s.Equal("key", d.Key)
+		s.Equal(2, d.Partition)
+		s.Equal(int64(10), d.Offset)
+		s.Equal(now, d.Time)
+		s.JSONEq(`{"id":"1"}`, string(d.Payload))
+	}
+}
+
+func (s *headersTestSuite) Test_DecodeMessage_BytesAndStrings_RoundTrip() {
+	// payloads which look like JSON must be kept as they are
+	bytesPl := []byte(`"quoted"`)
+	stringPl := `"quoted"`
+
+	for _, mode := range []string{MessageModeEnvelope, MessageModeHeaders} {
+		p := &producerImpl{mode: mode}
+
+		m, err := p.toKafkaMessage(s.Ctx, &Message{Key: "key", Payload: bytesPl})
+		s.NoError(err)
+		_, d, err := describe(s.Ctx, mode, "topic", m)
+		s.NoError(err)
+		actualBytes, err := decodeMessage[[]byte](s.Ctx, d)
+		s.NoError(err)
+		s.Equal(bytesPl, actualBytes, mode)
+
+		m, err = p.toKafkaMessage(s.Ctx, &Message{Key: "key", Payload: stringPl})
+		s.NoError(err)
+		_, d, err = describe(s.Ctx, mode, "topic", m)
+		s.NoError(err)
+		actualString, err := decodeMessage[string](s.Ctx, d)
+		s.NoError(err)
+		s.Equal(stringPl, actualString, mode)
+	}
+}
+
+func (s *headersTestSuite) Test_Describe_WhenNonKitProducer_NewRequestCtx() {
+	ctx, d, err := describe(s.Ctx, MessageModeHeaders, "topic", kafka.Message{Value: []byte("raw")})
+	s.NoError(err)
+	s.Equal("raw", string(d.Payload))
+	actual, ok := kit.Request(ctx)
+	s.True(ok)
+	s.NotEmpty(actual.Rid)
+}
+
+func (s *headersTestSuite) Test_Dispatcher() {
+	var legacy, msg int
+	d := &dispatcher{
+		mode:  MessageModeHeaders,
+		topic: "topic",
+		handlers: []HandlerFn{
+			func(payload []byte) error { legacy++; return errors.New("legacy") },
+		},
+		msgHandlers: []MessageHandlerFn{
+			func(ctx context.Context, m *MessageDescriptor) error { msg++; return nil },
+		},
+	}
+	m := kafka.Message{Value: []byte("raw"), Time: time.Now()}
+	s.True(d.accept(m))
+	s.False(d.accept(kafka.Message{Key: []byte("key")}))
+
+	// fail fast stops on the first error
+	s.Error(d.dispatch(s.Ctx, m, true))
+	s.Equal(1, legacy)
+	s.Equal(0, msg)
+
+	// all handlers are executed otherwise
+	s.Error(d.dispatch(s.Ctx, m, false))
+	s.Equal(2, legacy)
+	s.Equal(1, msg)
+
+	// envelope mode requires a key
+	d.mode = MessageModeEnvelope
+	s.False(d.accept(m))
+}
+
+func (s *headersTestSuite) Test_Builder_WhenInvalidMode_Fail() {
+	s.AssertAppErr(NewSubscriberCfgBuilder().Mode("unknown").Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+	s.NoError(NewSubscriberCfgBuilder().Mode(MessageModeHeaders).Validate(s.Ctx))
+}
@@ -3,13 +3,14 @@ package kafka
 import (
 	"context"
 	"github.com/mikhailbolshakov/kit"
-	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/mikhailbolshakov/kit/monitoring"
 	"github.com/segmentio/kafka-go"
 	"github.com/segmentio/kafka-go/sasl"
 	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/segmentio/kafka-go/sasl/scram"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -27,29 +28,48 @@ type Sasl struct {
 
 // BrokerConfig kafka broker configuration
 type BrokerConfig struct {
-	ClientId          string `mapstructure:"client_id"`           // ClientId identifies client
-	TopicAutoCreation bool   `mapstructure:"topic_auto_creation"` // TopicAutoCreation if true topics are created by code (otherwise must be preliminary declared)
-	Url               string // Url comma separated host-port pairs ("localhost:9092,localhost:9093")
-	Sasl              Sasl   // Sasl configuration
+	ClientId          string        `mapstructure:"client_id"`           // ClientId identifies client
+	TopicAutoCreation bool          `mapstructure:"topic_auto_creation"` // TopicAutoCreation if true topics are created by code (otherwise must be preliminary declared)
+	Url               string        // Url comma separated host-port pairs ("localhost:9092,localhost:9093")
+	Sasl              Sasl          // Sasl configuration
+	DrainTimeout      time.Duration `mapstructure:"drain_timeout"` // DrainTimeout max time to wait for fetched messages to be handled and committed on close (default: 30s)
 }
 
 // HandlerFn handler function
+// in envelope mode payload is the whole envelope (see Decode), in headers mode it's a raw payload
 type HandlerFn func(payload []byte) error
 
 // Broker kafka
 type Broker interface {
+	// MetricsProvider exposes metrics of producers and subscribers
+	monitoring.MetricsProvider
 	// Init initializes broker
 	Init(ctx context.Context, cfg *BrokerConfig) error
 	// AddProducer adds a producer with configuration
 	AddProducer(ctx context.Context, topic *TopicConfig, cfg *ProducerConfig) (Producer, error)
 	// AddSubscriber adds a subscriber with configuration
 	AddSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handlers ...HandlerFn) error
+	// AddMessageSubscriber adds a subscriber with configuration
+	// handlers receive context with the request context restored from the message and the message descriptor
+	AddMessageSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handlers ...MessageHandlerFn) error
+	// AddBatchSubscriber adds a subscriber handling messages in batches per partition (manual commit only)
+	// batch sizing is set by SubscriberConfig.Batch, the highest offset of a batch is committed after the handler succeeds
+	AddBatchSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handler BatchHandlerFn) error
 	// DeclareTopics declares topics in kafka broker
 	// must be executed after all producer and subscribers added
 	DeclareTopics(ctx context.Context) error
+	// Admin returns topic admin
+	Admin() TopicAdmin
+	// DLQ returns inspector of the given dead-letter topic
+	DLQ(topic string) DLQInspector
 	// Start starts listening
 	Start(ctx context.Context) error
+	// Pause pauses fetching of the subscriber, fetched messages are still handled and the subscriber stays in its group
+	Pause(ctx context.Context, topic, groupId string) error
+	// Resume resumes fetching of the paused subscriber
+	Resume(ctx context.Context, topic, groupId string) error
 	// Close closes broker
+	// fetching stops, fetched messages are handled and committed within DrainTimeout (or ctx deadline) and then readers are closed
 	Close(ctx context.Context)
 }
 
@@ -72,6 +92,9 @@ type brokerImpl struct {
 	saslMechanism   sasl.Mechanism
 	conn            *kafka.Conn
 	dialer          *kafka.Dialer
+	client          *kafka.Client
+	clients         *kafkaClients
+	metrics         *metrics
 }
 
 func NewBroker(logger kit.CLoggerFunc) Broker {
@@ -86,6 +109,15 @@ func (b *brokerImpl) l() kit.CLogger {
 	return b.logger().Cmp("kafka")
 }
 
+func (b *brokerImpl) GetCollector() monitoring.MetricsCollector {
+	// metrics are created on Init, as they are labeled by the broker config
+	return func() monitoring.MetricsCollection {
+		b.RLock()
+		defer b.RUnlock()
+		return b.metrics.collector()()
+	}
+}
+
 func (b *brokerImpl) Init(ctx context.Context, cfg *BrokerConfig) error {
 	l := b.l().Mth("init").F(kit.KV{"client": cfg.ClientId, "url": cfg.Url}).Dbg()
 
@@ -103,6 +135,8 @@ func (b *brokerImpl) Init(ctx context.Context, cfg *BrokerConfig) error {
 	b.Lock()
 	defer b.Unlock()
 
+	b.metrics = newMetrics(brokerLabel(cfg))
+
 	// get sasl mechanism
 	b.saslMechanism, err = b.getSaslMechanism(ctx)
 	if err != nil {
@@ -125,6 +159,19 @@ func (b *brokerImpl) Init(ctx context.Context, cfg *BrokerConfig) error {
 		return ErrKafkaConnection(ctx, err)
 	}
 
+	// readers and writers
+	b.clients = &kafkaClients{
+		urls:      b.urls,
+		dialer:    b.dialer,
+		transport: b.transport,
+	}
+
+	// admin client
+	b.client = &kafka.Client{
+		Addr:      kafka.TCP(b.urls...),
+		Transport: b.transport,
+	}
+
 	// setup cancellation context
 	b.cancellationCtx, b.cancelFunc = context.WithCancel(ctx)
 
@@ -142,6 +189,18 @@ func (b *brokerImpl) AddProducer(ctx context.Context, topic *TopicConfig, cfg *P
 	if topic.Topic == "" {
 		return nil, ErrKafkaProducerTopicEmpty(ctx)
 	}
+	if !validMessageMode(cfg.Mode) {
+		return nil, ErrKafkaMessageModeInvalid(ctx, cfg.Mode)
+	}
+	if cfg.Serializer != nil {
+		if cfg.Mode != MessageModeHeaders {
+			return nil, ErrKafkaSerializerModeInvalid(ctx)
+		}
+		// register the schema, an incompatible schema fails the producer
+		if err := cfg.Serializer.Prepare(ctx, topic.Topic); err != nil {
+			return nil, err
+		}
+	}
 
 	b.Lock()
 	defer b.Unlock()
@@ -150,11 +209,28 @@ func (b *brokerImpl) AddProducer(ctx context.Context, topic *TopicConfig, cfg *P
 	b.topics[topic.Topic] = getTopicCfg(topic)
 
 	// create and return producer
-	return newProducer(b.cancellationCtx, b.logger, topic, cfg, b.urls, b.transport), nil
+	return newProducer(b.cancellationCtx, b.logger, topic, cfg, b.clients, b.metrics), nil
 }
 
 func (b *brokerImpl) AddSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handlers ...HandlerFn) error {
 	b.l().Mth("add-subscriber").F(kit.KV{"topic": topic.Topic}).Dbg()
+	return b.addSubscriber(ctx, topic, cfg, &dispatcher{mode: cfg.Mode, serializer: cfg.Serializer, topic: topic.Topic, handlers: handlers})
+}
+
+func (b *brokerImpl) AddMessageSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handlers ...MessageHandlerFn) error {
+	b.l().Mth("add-msg-subscriber").F(kit.KV{"topic": topic.Topic}).Dbg()
+	return b.addSubscriber(ctx, topic, cfg, &dispatcher{mode: cfg.Mode, serializer: cfg.Serializer, topic: topic.Topic, msgHandlers: handlers})
+}
+
+func (b *brokerImpl) AddBatchSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handler BatchHandlerFn) error {
+	b.l().Mth("add-batch-subscriber").F(kit.KV{"topic": topic.Topic}).Dbg()
+	if err := validateBatchSubscriber(ctx, cfg); err != nil {
+		return err
+	}
+	return b.addSubscriber(ctx, topic, cfg, &dispatcher{mode: cfg.Mode, serializer: cfg.Serializer, topic: topic.Topic, batchHandler: handler})
+}
+
+func (b *brokerImpl) addSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, dispatcher *dispatcher) error {
 
 	// validation
 	if b.transport == nil {
@@ -163,18 +239,27 @@ func (b *brokerImpl) AddSubscriber(ctx context.Context, topic *TopicConfig, cfg
 	if topic.Topic == "" {
 		return ErrKafkaSubTopicEmpty(ctx)
 	}
-	if len(handlers) == 0 {
+	if dispatcher.empty() {
 		return ErrKafkaSubNoHandlers(ctx)
 	}
+	if !validMessageMode(cfg.Mode) {
+		return ErrKafkaMessageModeInvalid(ctx, cfg.Mode)
+	}
+	if cfg.Serializer != nil && cfg.Mode != MessageModeHeaders {
+		return ErrKafkaSerializerModeInvalid(ctx)
+	}
 
 	b.Lock()
 	defer b.Unlock()
 
-	// register topic
+	// register topic and its retry topics
 	b.topics[topic.Topic] = getTopicCfg(topic)
+	for _, t := range retryTopicConfigs(topic, cfg.RetryTopics) {
+		b.topics[t.Topic] = getTopicCfg(t)
+	}
 
 	// register subscriber
-	b.subscribers[subKey{Topic: topic.Topic, GroupId: cfg.GroupId}] = newSubscriber(b.logger, topic, cfg, b.urls, b.dialer, handlers...)
+	b.subscribers[subKey{Topic: topic.Topic, GroupId: cfg.GroupId}] = newSubscriber(b.logger, topic, cfg, b.clients, dispatcher, b.metrics)
 	return nil
 }
 
@@ -224,6 +309,28 @@ func (b *brokerImpl) Start(ctx context.Context) error {
 	return nil
 }
 
+func (b *brokerImpl) Pause(ctx context.Context, topic, groupId string) error {
+	b.RLock()
+	defer b.RUnlock()
+	sub, ok := b.subscribers[subKey{Topic: topic, GroupId: groupId}]
+	if !ok {
+		return ErrKafkaSubscriberNotFound(ctx, topic, groupId)
+	}
+	sub.pause(ctx)
+	return nil
+}
+
+func (b *brokerImpl) Resume(ctx context.Context, topic, groupId string) error {
+	b.RLock()
+	defer b.RUnlock()
+	sub, ok := b.subscribers[subKey{Topic: topic, GroupId: groupId}]
+	if !ok {
+		return ErrKafkaSubscriberNotFound(ctx, topic, groupId)
+	}
+	sub.resume(ctx)
+	return nil
+}
+
 func (b *brokerImpl) Close(ctx context.Context) {
 	l := b.l().C(ctx).Mth("close").Dbg()
 	if b.cancellationCtx == nil {
@@ -233,15 +340,10 @@ func (b *brokerImpl) Close(ctx context.Context) {
 	b.Lock()
 	defer b.Unlock()
 
-	// close all the readers
-	eg := goroutine.NewGroup(ctx).WithLogger(l)
-	for _, sub := range b.subscribers {
-		eg.Go(func() error {
-			sub.close()
-			return nil
-		})
+	// stop fetching and let fetched messages be handled and committed, readers are closed then
+	if !drainSubscribers(ctx, b.subscribers, b.cfg.DrainTimeout) {
+		l.Warn("drain timeout, in-flight messages are interrupted")
 	}
-	_ = eg.Wait()
 
 	_ = b.conn.Close()
 	b.cancelFunc()
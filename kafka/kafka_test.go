@@ -3,6 +3,7 @@
 package kafka
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
@@ -668,6 +669,259 @@ func (s *kafkaTestSuite) Test_SubscriberRestart() {
 
 }
 
+func (s *kafkaTestSuite) Test_HeadersMode_MessageSubscriber() {
+
+	// declare topic
+	part := 1
+	topic := &TopicConfig{
+		Topic:      kit.NewRandString(),
+		Partitions: &part,
+	}
+
+	// init sub broker
+	subBroker := NewBroker(s.logger)
+	err := subBroker.Init(s.Ctx, s.brokerCfg)
+	if err != nil {
+		s.Fatal(err)
+	}
+
+	// declare sub
+	rCtx, _ := kit.Request(s.Ctx)
+	wg := kit.NewWG()
+	err = subBroker.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		Workers(1).
+		JoinGroupBackoff(time.Millisecond*500).
+		StartOffset(kafka.FirstOffset).
+		MaxWait(time.Second).
+		Mode(MessageModeHeaders).
+		Build(), func(ctx context.Context, m *MessageDescriptor) error {
+		defer wg.Done()
+		actual, ok := kit.Request(ctx)
+		s.True(ok)
+		s.Equal(rCtx.GetRequestId(), actual.GetRequestId())
+		s.Equal("k", m.Key)
+		s.Equal(topic.Topic, m.Topic)
+		s.Equal("value", m.Headers["custom"])
+		s.False(m.Time.IsZero())
+		s.JSONEq(`{"Value":"v"}`, string(m.Payload))
+		return nil
+	})
+	if err != nil {
+		s.Fatal(err)
+	}
+
+	// start sub broker
+	err = subBroker.Start(s.Ctx)
+	if err != nil {
+		s.Fatal(err)
+	}
+	defer func() { subBroker.Close(s.Ctx) }()
+
+	// init pub broker
+	pubBroker := NewBroker(s.logger)
+	err = pubBroker.Init(s.Ctx, s.brokerCfg)
+	if err != nil {
+		s.Fatal(err)
+	}
+
+	// declare producer
+	producer, err := pubBroker.AddProducer(s.Ctx, topic,
+		NewProducerCfgBuilder().
+			BatchTimeout(time.Millisecond*300).
+			BatchSize(1).
+			Mode(MessageModeHeaders).
+			Build())
+	if err != nil {
+		s.Fatal(err)
+	}
+	err = pubBroker.Start(s.Ctx)
+	if err != nil {
+		s.Fatal(err)
+	}
+	defer func() { pubBroker.Close(s.Ctx) }()
+
+	// produce messages
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		s.NoError(producer.SendMany(s.Ctx, &Message{Key: "k", Payload: &payload{Value: "v"}, Headers: map[string]string{"custom": "value"}}))
+	}
+	s.True(wg.Wait(time.Second * 5))
+}
+
+func (s *kafkaTestSuite) Test_TopicAdmin() {
+
+	broker := NewBroker(s.logger)
+	s.NoError(broker.Init(s.Ctx, s.brokerCfg))
+	defer broker.Close(s.Ctx)
+
+	// declare topic with configs
+	topic := NewTopicCfgBuilder(kit.NewRandString()).
+		WithPartitionNum(2).
+		WithReplicaFactor(1).
+		WithParams(TopicParam{Name: "retention.ms", Value: "60000"}).
+		Build()
+	_, err := broker.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(broker.DeclareTopics(s.Ctx))
+	defer func() { _ = broker.Admin().DeleteTopics(s.Ctx, topic.Topic) }()
+
+	admin := broker.Admin()
+	topics, err := admin.ListTopics(s.Ctx)
+	s.NoError(err)
+	s.Contains(topics, topic.Topic)
+
+	descr, err := admin.DescribeTopics(s.Ctx, topic.Topic)
+	s.NoError(err)
+	s.Len(descr, 1)
+	s.Equal(2, descr[0].Partitions)
+	s.Equal(1, descr[0].ReplicaFactor)
+	s.Equal("60000", descr[0].Configs["retention.ms"])
+
+	// nothing to reconcile
+	rs, err := admin.ReconcileTopics(s.Ctx, false)
+	s.NoError(err)
+	s.Empty(rs.Created)
+	s.Empty(rs.PartitionsIncreased)
+	s.Empty(rs.ConfigsAltered)
+
+	// change declaration
+	topic.Partitions = kit.IntPtr(3)
+	topic.Configs["retention.ms"] = "120000"
+	_, err = broker.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+
+	// dry-run doesn't change anything
+	rs, err = admin.ReconcileTopics(s.Ctx, true)
+	s.NoError(err)
+	s.Equal([]string{topic.Topic}, rs.PartitionsIncreased)
+	s.Equal([]string{topic.Topic}, rs.ConfigsAltered)
+	descr, err = admin.DescribeTopics(s.Ctx, topic.Topic)
+	s.NoError(err)
+	s.Equal(2, descr[0].Partitions)
+
+	// apply
+	_, err = admin.ReconcileTopics(s.Ctx, false)
+	s.NoError(err)
+	descr, err = admin.DescribeTopics(s.Ctx, topic.Topic)
+	s.NoError(err)
+	s.Equal(3, descr[0].Partitions)
+	s.Equal("120000", descr[0].Configs["retention.ms"])
+
+	// delete
+	s.NoError(admin.DeleteTopics(s.Ctx, topic.Topic))
+}
+
+func (s *kafkaTestSuite) Test_ManualCommit_RetryTopics() {
+
+	broker := NewBroker(s.logger)
+	s.NoError(broker.Init(s.Ctx, s.brokerCfg))
+	defer broker.Close(s.Ctx)
+
+	topic := NewTopicCfgBuilder(kit.NewRandString()).WithPartitionNum(1).Build()
+
+	// handler fails on the main topic and the first retry tier, succeeds on the second one
+	var attempts []string
+	var mu sync.Mutex
+	wg := kit.NewWG()
+	wg.Add(3)
+	s.NoError(broker.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		Workers(1).
+		StartOffset(kafka.FirstOffset).
+		MaxWait(time.Second).
+		ManualCommitHandleMessageMaxRetryCount(3).
+		RetryTopics(time.Millisecond*500, time.Second).
+		Build(), func(ctx context.Context, m *MessageDescriptor) error {
+		mu.Lock()
+		defer mu.Unlock()
+		defer wg.Done()
+		attempts = append(attempts, m.Headers[HeaderRetryAttempt])
+		if len(attempts) < 3 {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}))
+
+	producer, err := broker.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().BatchSize(1).Build())
+	s.NoError(err)
+	s.NoError(broker.Start(s.Ctx))
+	defer func() {
+		_ = broker.Admin().DeleteTopics(s.Ctx, topic.Topic, RetryTopicName(topic.Topic, time.Millisecond*500), RetryTopicName(topic.Topic, time.Second))
+	}()
+
+	// retry topics are declared
+	topics, err := broker.Admin().ListTopics(s.Ctx)
+	s.NoError(err)
+	s.Contains(topics, RetryTopicName(topic.Topic, time.Millisecond*500))
+	s.Contains(topics, RetryTopicName(topic.Topic, time.Second))
+
+	s.NoError(producer.Send(s.Ctx, "k", &payload{Value: "v"}))
+	s.True(wg.Wait(time.Second * 30))
+	s.Equal([]string{"", "1", "2"}, attempts)
+}
+
+func (s *kafkaTestSuite) Test_DLQ_ReadReplay() {
+
+	broker := NewBroker(s.logger)
+	s.NoError(broker.Init(s.Ctx, s.brokerCfg))
+	defer broker.Close(s.Ctx)
+
+	// declare origin and dead-letter topics
+	topic := NewTopicCfgBuilder(kit.NewRandString()).WithPartitionNum(1).Build()
+	dlqTopic := NewTopicCfgBuilder(kit.NewRandString()).WithPartitionNum(1).Build()
+	_, err := broker.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	dlqProducer, err := broker.AddProducer(s.Ctx, dlqTopic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(broker.DeclareTopics(s.Ctx))
+	defer func() { _ = broker.Admin().DeleteTopics(s.Ctx, topic.Topic, dlqTopic.Topic) }()
+
+	// put failed messages to DLQ
+	for i := 0; i < 3; i++ {
+		failed, err := kit.Marshal(&Message{Ctx: kit.NewRequestCtx().WithNewRequestId(), Key: "key", Payload: i})
+		s.NoError(err)
+		s.NoError(dlqProducer.Send(s.Ctx, "key", &DLQMessage{Topic: topic.Topic, FailedMessage: failed}))
+	}
+	s.NoError(dlqProducer.Send(s.Ctx, "key", &DLQMessage{Topic: "another", FailedMessage: []byte("{}")}))
+
+	dlq := broker.DLQ(dlqTopic.Topic)
+	counts, err := dlq.Count(s.Ctx, &DLQFilter{})
+	s.NoError(err)
+	s.Equal(map[string]int{topic.Topic: 3, "another": 1}, counts)
+
+	var records []*DLQRecord
+	s.NoError(dlq.Read(s.Ctx, &DLQFilter{Topic: topic.Topic, Ranges: []DLQRange{{Partition: 0, From: 1, To: 2}}}, func(ctx context.Context, r *DLQRecord) error {
+		records = append(records, r)
+		return nil
+	}))
+	s.Len(records, 2)
+
+	// dry-run
+	res, err := dlq.Replay(s.Ctx, &DLQFilter{Topic: topic.Topic}, &DLQReplayOptions{DryRun: true})
+	s.NoError(err)
+	s.Equal(3, res.Replayed)
+
+	// replay to the origin topic
+	received := atomic.NewInt32(0)
+	err = broker.AddSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId(kit.NewRandString()).
+		StartOffset(kafka.FirstOffset).
+		CommitInterval(time.Millisecond*50).
+		Build(), func(payload []byte) error {
+		_, _, err := Decode[int](s.Ctx, payload)
+		s.NoError(err)
+		received.Inc()
+		return nil
+	})
+	s.NoError(err)
+	s.NoError(broker.Start(s.Ctx))
+
+	res, err = dlq.Replay(s.Ctx, &DLQFilter{Topic: topic.Topic, Limit: 2}, &DLQReplayOptions{Rate: 10})
+	s.NoError(err)
+	s.Equal(2, res.Replayed)
+	s.Equal(&DLQPosition{Partition: 0, Offset: 1}, res.Last)
+	s.Eventually(func() bool { return received.Load() == 2 }, time.Second*10, time.Millisecond*100)
+}
+
 func (s *kafkaTestSuite) handler(i int, workTime time.Duration, wg *kit.WaitGroup, callback func(int, []byte)) HandlerFn {
 	return func(payload []byte) error {
 		time.Sleep(workTime)
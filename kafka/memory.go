@@ -0,0 +1,441 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/monitoring"
+	"github.com/segmentio/kafka-go"
+)
+
+// memoryBroker in-memory broker
+// producers and subscribers are the same as ones of kafka broker, only readers and writers are in-memory
+type memoryBroker struct {
+	sync.RWMutex
+	logger          kit.CLoggerFunc
+	cfg             *BrokerConfig
+	cluster         *memoryCluster
+	clients         *memoryClients
+	cancellationCtx context.Context
+	cancelFunc      context.CancelFunc
+	subscribers     map[subKey]*subscriber
+	topics          map[string]kafka.TopicConfig
+	metrics         *metrics
+}
+
+// NewMemoryBroker creates an in-memory broker for unit and component tests
+// brokers initialized with the same url share topics, partitions and consumer groups,
+// so that services (e.g. RPC client and server) communicate with no network
+func NewMemoryBroker(logger kit.CLoggerFunc) Broker {
+	return &memoryBroker{
+		logger:      logger,
+		subscribers: map[subKey]*subscriber{},
+		topics:      map[string]kafka.TopicConfig{},
+	}
+}
+
+func (b *memoryBroker) l() kit.CLogger {
+	return b.logger().Cmp("kafka-memory")
+}
+
+func (b *memoryBroker) GetCollector() monitoring.MetricsCollector {
+	// metrics are created on Init, as they are labeled by the broker config
+	return func() monitoring.MetricsCollection {
+		b.RLock()
+		defer b.RUnlock()
+		return b.metrics.collector()()
+	}
+}
+
+func (b *memoryBroker) Init(ctx context.Context, cfg *BrokerConfig) error {
+	// validate
+	if cfg == nil || cfg.Url == "" {
+		return ErrKafkaInvalidConfig(ctx)
+	}
+
+	l := b.l().Mth("init").F(kit.KV{"client": cfg.ClientId, "url": cfg.Url}).Dbg()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.cfg = cfg
+	b.cluster = memoryClusterByUrl(cfg.Url)
+	b.metrics = newMetrics(brokerLabel(cfg))
+	b.clients = &memoryClients{cluster: b.cluster}
+
+	// setup cancellation context
+	b.cancellationCtx, b.cancelFunc = context.WithCancel(ctx)
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *memoryBroker) AddProducer(ctx context.Context, topic *TopicConfig, cfg *ProducerConfig) (Producer, error) {
+	b.l().Mth("add-producer").F(kit.KV{"topic": topic.Topic}).Dbg()
+
+	// validate
+	if b.cluster == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+	if topic.Topic == "" {
+		return nil, ErrKafkaProducerTopicEmpty(ctx)
+	}
+	if !validMessageMode(cfg.Mode) {
+		return nil, ErrKafkaMessageModeInvalid(ctx, cfg.Mode)
+	}
+	if cfg.Serializer != nil {
+		if cfg.Mode != MessageModeHeaders {
+			return nil, ErrKafkaSerializerModeInvalid(ctx)
+		}
+		// register the schema, an incompatible schema fails the producer
+		if err := cfg.Serializer.Prepare(ctx, topic.Topic); err != nil {
+			return nil, err
+		}
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// register topic
+	b.topics[topic.Topic] = getTopicCfg(topic)
+
+	// create and return producer
+	return newProducer(b.cancellationCtx, b.logger, topic, cfg, b.clients, b.metrics), nil
+}
+
+func (b *memoryBroker) AddSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handlers ...HandlerFn) error {
+	b.l().Mth("add-subscriber").F(kit.KV{"topic": topic.Topic}).Dbg()
+	return b.addSubscriber(ctx, topic, cfg, &dispatcher{mode: cfg.Mode, serializer: cfg.Serializer, topic: topic.Topic, handlers: handlers})
+}
+
+func (b *memoryBroker) AddMessageSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handlers ...MessageHandlerFn) error {
+	b.l().Mth("add-msg-subscriber").F(kit.KV{"topic": topic.Topic}).Dbg()
+	return b.addSubscriber(ctx, topic, cfg, &dispatcher{mode: cfg.Mode, serializer: cfg.Serializer, topic: topic.Topic, msgHandlers: handlers})
+}
+
+func (b *memoryBroker) AddBatchSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, handler BatchHandlerFn) error {
+	b.l().Mth("add-batch-subscriber").F(kit.KV{"topic": topic.Topic}).Dbg()
+	if err := validateBatchSubscriber(ctx, cfg); err != nil {
+		return err
+	}
+	return b.addSubscriber(ctx, topic, cfg, &dispatcher{mode: cfg.Mode, serializer: cfg.Serializer, topic: topic.Topic, batchHandler: handler})
+}
+
+func (b *memoryBroker) addSubscriber(ctx context.Context, topic *TopicConfig, cfg *SubscriberConfig, dispatcher *dispatcher) error {
+
+	// validation
+	if b.cluster == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+	if topic.Topic == "" {
+		return ErrKafkaSubTopicEmpty(ctx)
+	}
+	if dispatcher.empty() {
+		return ErrKafkaSubNoHandlers(ctx)
+	}
+	if !validMessageMode(cfg.Mode) {
+		return ErrKafkaMessageModeInvalid(ctx, cfg.Mode)
+	}
+	if cfg.Serializer != nil && cfg.Mode != MessageModeHeaders {
+		return ErrKafkaSerializerModeInvalid(ctx)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// register topic and its retry topics
+	b.topics[topic.Topic] = getTopicCfg(topic)
+	for _, t := range retryTopicConfigs(topic, cfg.RetryTopics) {
+		b.topics[t.Topic] = getTopicCfg(t)
+	}
+
+	// register subscriber
+	b.subscribers[subKey{Topic: topic.Topic, GroupId: cfg.GroupId}] = newSubscriber(b.logger, topic, cfg, b.clients, dispatcher, b.metrics)
+	return nil
+}
+
+func (b *memoryBroker) DeclareTopics(ctx context.Context) error {
+	l := b.l().C(ctx).Mth("declare").Dbg()
+
+	if b.cluster == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+
+	// skip if auto-creation isn't configured
+	if !b.cfg.TopicAutoCreation {
+		l.Dbg("skip")
+		return nil
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	// existing topics are kept as is
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+	for _, t := range b.topics {
+		b.cluster.createTopic(t)
+	}
+
+	l.Dbg("ok")
+	return nil
+}
+
+func (b *memoryBroker) Admin() TopicAdmin {
+	return b
+}
+
+func (b *memoryBroker) DLQ(topic string) DLQInspector {
+	return &dlqInspector{
+		logger: b.logger,
+		source: b,
+		broker: b,
+		topic:  topic,
+	}
+}
+
+func (b *memoryBroker) Start(ctx context.Context) error {
+	b.l().C(ctx).Mth("start").Dbg()
+
+	// declare topics first
+	err := b.DeclareTopics(ctx)
+	if err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// start all subscribers
+	for key, sub := range b.subscribers {
+		sub.start(b.cancellationCtx, key.Topic)
+	}
+	return nil
+}
+
+func (b *memoryBroker) Pause(ctx context.Context, topic, groupId string) error {
+	b.RLock()
+	defer b.RUnlock()
+	sub, ok := b.subscribers[subKey{Topic: topic, GroupId: groupId}]
+	if !ok {
+		return ErrKafkaSubscriberNotFound(ctx, topic, groupId)
+	}
+	sub.pause(ctx)
+	return nil
+}
+
+func (b *memoryBroker) Resume(ctx context.Context, topic, groupId string) error {
+	b.RLock()
+	defer b.RUnlock()
+	sub, ok := b.subscribers[subKey{Topic: topic, GroupId: groupId}]
+	if !ok {
+		return ErrKafkaSubscriberNotFound(ctx, topic, groupId)
+	}
+	sub.resume(ctx)
+	return nil
+}
+
+func (b *memoryBroker) Close(ctx context.Context) {
+	l := b.l().C(ctx).Mth("close").Dbg()
+	if b.cancellationCtx == nil {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	// stop fetching and let fetched messages be handled and committed, readers are closed then
+	if !drainSubscribers(ctx, b.subscribers, b.cfg.DrainTimeout) {
+		l.Warn("drain timeout, in-flight messages are interrupted")
+	}
+
+	// readers leave their groups immediately, so that partitions are reassigned to other brokers
+	b.cancelFunc()
+	b.clients.close()
+}
+
+func (b *memoryBroker) ListTopics(ctx context.Context) ([]string, error) {
+	b.l().C(ctx).Mth("list-topics").Dbg()
+
+	if b.cluster == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+
+	var r []string
+	for t := range b.cluster.topics {
+		r = append(r, t)
+	}
+	sort.Strings(r)
+	return r, nil
+}
+
+func (b *memoryBroker) DescribeTopics(ctx context.Context, topics ...string) ([]*TopicDescription, error) {
+	b.l().C(ctx).Mth("describe-topics").F(kit.KV{"topics": topics}).Dbg()
+
+	if b.cluster == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+	if len(topics) == 0 {
+		return nil, nil
+	}
+
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+
+	r := make([]*TopicDescription, 0, len(topics))
+	for _, name := range topics {
+		t, ok := b.cluster.topics[name]
+		if !ok {
+			return nil, ErrKafkaTopicNotFound(ctx, name)
+		}
+		d := &TopicDescription{
+			Topic:         name,
+			Partitions:    len(t.partitions),
+			ReplicaFactor: t.replicas,
+			Configs:       make(map[string]string, len(t.configs)),
+		}
+		for k, v := range t.configs {
+			d.Configs[k] = v
+		}
+		r = append(r, d)
+	}
+	return r, nil
+}
+
+func (b *memoryBroker) DeleteTopics(ctx context.Context, topics ...string) error {
+	l := b.l().C(ctx).Mth("delete-topics").F(kit.KV{"topics": topics}).Dbg()
+
+	if b.cluster == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+
+	errs := map[string]error{}
+	for _, t := range topics {
+		if !b.cluster.deleteTopic(t) {
+			errs[t] = kafka.UnknownTopicOrPartition
+		}
+	}
+	if err := responseErr(errs); err != nil {
+		return ErrKafkaDeleteTopics(ctx, err)
+	}
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *memoryBroker) IncreasePartitions(ctx context.Context, topic string, count int) error {
+	l := b.l().C(ctx).Mth("increase-partitions").F(kit.KV{"topic": topic, "count": count}).Dbg()
+
+	if b.cluster == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+
+	if err := b.cluster.increasePartitions(topic, count); err != nil {
+		return ErrKafkaIncreasePartitions(ctx, err, topic)
+	}
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *memoryBroker) AlterTopicConfigs(ctx context.Context, topic string, configs map[string]string) error {
+	l := b.l().C(ctx).Mth("alter-configs").F(kit.KV{"topic": topic}).Dbg()
+
+	if b.cluster == nil {
+		return ErrKafkaNotInitialized(ctx)
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+
+	t, ok := b.cluster.topics[topic]
+	if !ok {
+		return ErrKafkaAlterTopicConfigs(ctx, kafka.UnknownTopicOrPartition, topic)
+	}
+	for k, v := range configs {
+		t.configs[k] = v
+	}
+
+	l.Inf("ok")
+	return nil
+}
+
+func (b *memoryBroker) ReconcileTopics(ctx context.Context, dryRun bool) (*TopicReconcileResult, error) {
+	if b.cluster == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+
+	// declared topics
+	b.RLock()
+	declared := make(map[string]kafka.TopicConfig, len(b.topics))
+	for k, v := range b.topics {
+		declared[k] = v
+	}
+	b.RUnlock()
+
+	return reconcileTopics(ctx, b.l(), b, declared, dryRun)
+}
+
+func (b *memoryBroker) createTopics(ctx context.Context, topics []kafka.TopicConfig) error {
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+	// a topic might be created by another broker sharing the cluster
+	for _, t := range topics {
+		b.cluster.createTopic(t)
+	}
+	return nil
+}
+
+func (b *memoryBroker) partitions(ctx context.Context, topic string) ([]int, error) {
+	if b.cluster == nil {
+		return nil, ErrKafkaNotInitialized(ctx)
+	}
+
+	b.cluster.Lock()
+	defer b.cluster.Unlock()
+
+	t, ok := b.cluster.topics[topic]
+	if !ok {
+		return nil, ErrKafkaDLQRead(ctx, fmt.Errorf("%s: %w", topic, kafka.UnknownTopicOrPartition), topic)
+	}
+	r := make([]int, len(t.partitions))
+	for i := range r {
+		r[i] = i
+	}
+	return r, nil
+}
+
+func (b *memoryBroker) read(ctx context.Context, topic string, partition int, from time.Time, fn dlqMessageFn) error {
+	msgs, err := b.cluster.snapshot(topic, partition)
+	if err != nil {
+		return ErrKafkaDLQRead(ctx, err, topic)
+	}
+	for _, m := range msgs {
+		if err := ctx.Err(); err != nil {
+			return ErrKafkaDLQRead(ctx, err, topic)
+		}
+		if !from.IsZero() && m.Time.Before(from) {
+			continue
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// memoryDefaultPartitions number of partitions of a topic created without partitions specified
+	memoryDefaultPartitions = 1
+	// memoryDefaultReplicaFactor replication factor of a topic created without replication factor specified
+	memoryDefaultReplicaFactor = 1
+)
+
+// errMemoryNoGroup mirrors kafka-go error returned on commit by a reader without a group
+var errMemoryNoGroup = errors.New("unavailable when GroupID is not set")
+
+// memoryClusters in-memory clusters by url
+// brokers initialized with the same url share topics and consumer groups
+var memoryClusters = struct {
+	sync.Mutex
+	clusters map[string]*memoryCluster
+}{clusters: map[string]*memoryCluster{}}
+
+// memoryClusterByUrl returns a cluster by url, the cluster is created on the first call
+func memoryClusterByUrl(url string) *memoryCluster {
+	memoryClusters.Lock()
+	defer memoryClusters.Unlock()
+	c, ok := memoryClusters.clusters[url]
+	if !ok {
+		c = newMemoryCluster()
+		memoryClusters.clusters[url] = c
+	}
+	return c
+}
+
+// memoryTopic topic with partitions as message logs
+type memoryTopic struct {
+	partitions [][]kafka.Message
+	replicas   int
+	configs    map[string]string
+}
+
+// memoryGroupKey consumer group of a topic
+type memoryGroupKey struct {
+	group string
+	topic string
+}
+
+// memoryGroup tracks committed offsets and members of a consumer group
+type memoryGroup struct {
+	startOffset int64
+	committed   map[int]int64 // committed next offset to be read by partition
+	members     []*memoryReader
+}
+
+// memoryCluster in-memory kafka cluster
+type memoryCluster struct {
+	sync.Mutex
+	topics map[string]*memoryTopic
+	groups map[memoryGroupKey]*memoryGroup
+	notify chan struct{} // notify is closed and replaced on every change
+}
+
+func newMemoryCluster() *memoryCluster {
+	return &memoryCluster{
+		topics: map[string]*memoryTopic{},
+		groups: map[memoryGroupKey]*memoryGroup{},
+		notify: make(chan struct{}),
+	}
+}
+
+// broadcast wakes up all waiting readers, must be called under lock
+func (c *memoryCluster) broadcast() {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// createTopic creates a topic if it doesn't exist, must be called under lock
+func (c *memoryCluster) createTopic(cfg kafka.TopicConfig) bool {
+	if _, ok := c.topics[cfg.Topic]; ok {
+		return false
+	}
+	partitions := cfg.NumPartitions
+	if partitions <= 0 {
+		partitions = memoryDefaultPartitions
+	}
+	t := &memoryTopic{
+		partitions: make([][]kafka.Message, partitions),
+		replicas:   cfg.ReplicationFactor,
+		configs:    map[string]string{},
+	}
+	if t.replicas <= 0 {
+		t.replicas = memoryDefaultReplicaFactor
+	}
+	for _, e := range cfg.ConfigEntries {
+		t.configs[e.ConfigName] = e.ConfigValue
+	}
+	c.topics[cfg.Topic] = t
+	c.topicChanged(cfg.Topic)
+	return true
+}
+
+// deleteTopic deletes a topic and offsets committed by groups, must be called under lock
+func (c *memoryCluster) deleteTopic(topic string) bool {
+	if _, ok := c.topics[topic]; !ok {
+		return false
+	}
+	delete(c.topics, topic)
+	for k, g := range c.groups {
+		if k.topic == topic {
+			g.committed = map[int]int64{}
+		}
+	}
+	c.topicChanged(topic)
+	return true
+}
+
+// increasePartitions adds partitions to a topic, must be called under lock
+func (c *memoryCluster) increasePartitions(topic string, count int) error {
+	t, ok := c.topics[topic]
+	if !ok {
+		return kafka.UnknownTopicOrPartition
+	}
+	if count <= len(t.partitions) {
+		return kafka.InvalidPartitionNumber
+	}
+	t.partitions = append(t.partitions, make([][]kafka.Message, count-len(t.partitions))...)
+	c.topicChanged(topic)
+	return nil
+}
+
+// topicChanged rebalances groups of the topic and wakes up readers, must be called under lock
+func (c *memoryCluster) topicChanged(topic string) {
+	for k, g := range c.groups {
+		if k.topic == topic {
+			c.rebalance(k.topic, g)
+		}
+	}
+	c.broadcast()
+}
+
+// write appends messages to partitions chosen by the balancer, returns written messages
+// a batch is either written as a whole or not written at all
+func (c *memoryCluster) write(topic string, balancer kafka.Balancer, msgs []kafka.Message) ([]kafka.Message, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	// validate first
+	for i := range msgs {
+		t := topic
+		if t == "" {
+			t = msgs[i].Topic
+		}
+		if _, ok := c.topics[t]; !ok {
+			return msgs, kafka.UnknownTopicOrPartition
+		}
+	}
+
+	now := time.Now()
+	written := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		if topic != "" {
+			m.Topic = topic
+		}
+		t := c.topics[m.Topic]
+		partitions := make([]int, len(t.partitions))
+		for i := range partitions {
+			partitions[i] = i
+		}
+		m.Partition = balancer.Balance(m, partitions...)
+		m.Offset = int64(len(t.partitions[m.Partition]))
+		if m.Time.IsZero() {
+			m.Time = now
+		}
+		t.partitions[m.Partition] = append(t.partitions[m.Partition], m)
+		written = append(written, m)
+	}
+
+	c.broadcast()
+	return written, nil
+}
+
+// join adds a reader to its group and rebalances the group, must be called under lock
+func (c *memoryCluster) join(r *memoryReader) {
+	key := memoryGroupKey{group: r.cfg.GroupID, topic: r.cfg.Topic}
+	g, ok := c.groups[key]
+	if !ok {
+		g = &memoryGroup{
+			startOffset: r.cfg.StartOffset,
+			committed:   map[int]int64{},
+		}
+		c.groups[key] = g
+	}
+	g.members = append(g.members, r)
+	c.rebalance(key.topic, g)
+}
+
+// leave removes a reader from its group and rebalances the group, must be called under lock
+func (c *memoryCluster) leave(r *memoryReader) {
+	key := memoryGroupKey{group: r.cfg.GroupID, topic: r.cfg.Topic}
+	g, ok := c.groups[key]
+	if !ok {
+		return
+	}
+	for i, m := range g.members {
+		if m == r {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	c.rebalance(key.topic, g)
+}
+
+// rebalance assigns partitions to group members round-robin
+// members continue from committed offsets, so uncommitted messages are redelivered
+// a partition without committed offset starts from the group's start offset resolved at the first assignment
+func (c *memoryCluster) rebalance(topic string, g *memoryGroup) {
+	for _, m := range g.members {
+		m.assigned = map[int]int64{}
+	}
+	t, ok := c.topics[topic]
+	if !ok || len(g.members) == 0 {
+		return
+	}
+	for p := range t.partitions {
+		pos, ok := g.committed[p]
+		if !ok {
+			pos = startOffset(g.startOffset, t.partitions[p])
+			g.committed[p] = pos
+		}
+		g.members[p%len(g.members)].assigned[p] = pos
+	}
+}
+
+// commit commits offsets of messages, must be called under lock
+func (c *memoryCluster) commit(r *memoryReader, msgs []kafka.Message) {
+	g, ok := c.groups[memoryGroupKey{group: r.cfg.GroupID, topic: r.cfg.Topic}]
+	if !ok {
+		return
+	}
+	for _, m := range msgs {
+		if m.Offset+1 > g.committed[m.Partition] {
+			g.committed[m.Partition] = m.Offset + 1
+		}
+	}
+}
+
+// snapshot returns messages of the partition
+func (c *memoryCluster) snapshot(topic string, partition int) ([]kafka.Message, error) {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.topics[topic]
+	if !ok || partition < 0 || partition >= len(t.partitions) {
+		return nil, kafka.UnknownTopicOrPartition
+	}
+	return t.partitions[partition], nil
+}
+
+// startOffset resolves kafka start offset (first or last) of the partition
+func startOffset(start int64, partition []kafka.Message) int64 {
+	if start == kafka.LastOffset {
+		return int64(len(partition))
+	}
+	return 0
+}
+
+// memoryReader reads messages from the in-memory cluster the same way kafka.Reader does
+// a reader with a group joins the group on creation, otherwise it reads a single partition
+type memoryReader struct {
+	cluster  *memoryCluster
+	cfg      kafka.ReaderConfig
+	assigned map[int]int64 // assigned next offset to be fetched by partition, guarded by cluster
+	next     int           // next partition to fetch from, guarded by cluster
+	closed   bool          // guarded by cluster
+	stats    kafka.ReaderStats
+}
+
+func newMemoryReader(cluster *memoryCluster, cfg kafka.ReaderConfig) *memoryReader {
+	if cfg.StartOffset == 0 {
+		cfg.StartOffset = kafka.FirstOffset
+	}
+	r := &memoryReader{
+		cluster: cluster,
+		cfg:     cfg,
+	}
+	cluster.Lock()
+	defer cluster.Unlock()
+	if cfg.GroupID != "" {
+		cluster.join(r)
+	}
+	return r
+}
+
+// assignPartition assigns the configured partition to a reader without a group once the partition exists
+// must be called under lock
+func (r *memoryReader) assignPartition() {
+	if r.cfg.GroupID != "" || r.assigned != nil {
+		return
+	}
+	t, ok := r.cluster.topics[r.cfg.Topic]
+	if !ok || r.cfg.Partition >= len(t.partitions) {
+		return
+	}
+	r.assigned = map[int]int64{r.cfg.Partition: startOffset(r.cfg.StartOffset, t.partitions[r.cfg.Partition])}
+}
+
+// tryFetch returns the next message of assigned partitions if any, must be called under lock
+func (r *memoryReader) tryFetch() (kafka.Message, bool) {
+	t, ok := r.cluster.topics[r.cfg.Topic]
+	if !ok || len(r.assigned) == 0 {
+		return kafka.Message{}, false
+	}
+	partitions := make([]int, 0, len(r.assigned))
+	for p := range r.assigned {
+		partitions = append(partitions, p)
+	}
+	sort.Ints(partitions)
+
+	// go round the partitions, so that a busy partition doesn't starve others
+	for i := range partitions {
+		p := partitions[(r.next+i)%len(partitions)]
+		pos := r.assigned[p]
+		if p >= len(t.partitions) || pos >= int64(len(t.partitions[p])) {
+			continue
+		}
+		r.assigned[p] = pos + 1
+		r.next = (r.next + i + 1) % len(partitions)
+		m := t.partitions[p][pos]
+		r.stats.Messages++
+		r.stats.Bytes += int64(len(m.Key) + len(m.Value))
+		return m, true
+	}
+	return kafka.Message{}, false
+}
+
+func (r *memoryReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return kafka.Message{}, err
+		}
+		r.cluster.Lock()
+		if r.closed {
+			r.cluster.Unlock()
+			return kafka.Message{}, io.EOF
+		}
+		r.assignPartition()
+		r.stats.Fetches++
+		m, ok := r.tryFetch()
+		notify := r.cluster.notify
+		r.cluster.Unlock()
+		if ok {
+			return m, nil
+		}
+
+		// wait for changes
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return kafka.Message{}, ctx.Err()
+		}
+	}
+}
+
+func (r *memoryReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	m, err := r.FetchMessage(ctx)
+	if err != nil {
+		return m, err
+	}
+	if r.cfg.GroupID != "" {
+		if err := r.CommitMessages(ctx, m); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+func (r *memoryReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if r.cfg.GroupID == "" {
+		return errMemoryNoGroup
+	}
+	r.cluster.Lock()
+	defer r.cluster.Unlock()
+	if r.closed {
+		return io.ErrClosedPipe
+	}
+	r.cluster.commit(r, msgs)
+	return nil
+}
+
+func (r *memoryReader) Stats() kafka.ReaderStats {
+	r.cluster.Lock()
+	defer r.cluster.Unlock()
+	stats := r.stats
+	stats.Topic = r.cfg.Topic
+	if t, ok := r.cluster.topics[r.cfg.Topic]; ok {
+		for p, pos := range r.assigned {
+			if p < len(t.partitions) {
+				stats.Lag += int64(len(t.partitions[p])) - pos
+			}
+		}
+	}
+	return stats
+}
+
+func (r *memoryReader) Close() error {
+	r.cluster.Lock()
+	defer r.cluster.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.cfg.GroupID != "" {
+		r.cluster.leave(r)
+	}
+	r.cluster.broadcast()
+	return nil
+}
+
+// memoryWriter writes messages to the in-memory cluster the same way kafka.Writer does
+type memoryWriter struct {
+	cluster *memoryCluster
+	writer  *kafka.Writer
+}
+
+func (w *memoryWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	balancer := w.writer.Balancer
+	if balancer == nil {
+		balancer = &kafka.RoundRobin{}
+	}
+	written, err := w.cluster.write(w.writer.Topic, balancer, msgs)
+	if w.writer.Completion != nil {
+		w.writer.Completion(written, err)
+	}
+
+	// async writer reports errors to completion only
+	if w.writer.Async {
+		return nil
+	}
+	return err
+}
+
+// memoryClients creates readers and writers of the in-memory cluster
+// created readers are tracked, so that they leave their groups as soon as the broker is closed
+type memoryClients struct {
+	sync.Mutex
+	cluster *memoryCluster
+	readers []*memoryReader
+}
+
+func (c *memoryClients) reader(cfg kafka.ReaderConfig) messageReader {
+	r := newMemoryReader(c.cluster, cfg)
+	c.Lock()
+	defer c.Unlock()
+	c.readers = append(c.readers, r)
+	return r
+}
+
+// close closes all created readers
+func (c *memoryClients) close() {
+	c.Lock()
+	defer c.Unlock()
+	for _, r := range c.readers {
+		_ = r.Close()
+	}
+	c.readers = nil
+}
+
+func (c *memoryClients) writer(w *kafka.Writer) messageWriter {
+	return &memoryWriter{cluster: c.cluster, writer: w}
+}
@@ -0,0 +1,376 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type memoryTestSuite struct {
+	kit.Suite
+	logger    kit.CLoggerFunc
+	brokerCfg *BrokerConfig
+}
+
+func (s *memoryTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func (s *memoryTestSuite) SetupTest() {
+	// each test runs on its own cluster
+	s.brokerCfg = &BrokerConfig{
+		ClientId:          kit.NewRandString(),
+		Url:               kit.NewRandString(),
+		TopicAutoCreation: true,
+	}
+}
+
+func TestMemorySuite(t *testing.T) {
+	suite.Run(t, new(memoryTestSuite))
+}
+
+func (s *memoryTestSuite) broker() Broker {
+	b := NewMemoryBroker(s.logger)
+	s.NoError(b.Init(s.Ctx, s.brokerCfg))
+	return b
+}
+
+func (s *memoryTestSuite) cluster() *memoryCluster {
+	return memoryClusterByUrl(s.brokerCfg.Url)
+}
+
+func (s *memoryTestSuite) topic(partitions int) *TopicConfig {
+	return NewTopicCfgBuilder(kit.NewRandString()).WithPartitionNum(partitions).Build()
+}
+
+// collector collects handled payloads
+type memoryTestCollector struct {
+	sync.Mutex
+	keys []string
+}
+
+func (c *memoryTestCollector) handler(ctx context.Context, m *MessageDescriptor) error {
+	c.Lock()
+	defer c.Unlock()
+	c.keys = append(c.keys, m.Key)
+	return nil
+}
+
+func (c *memoryTestCollector) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.keys)
+}
+
+func (s *memoryTestSuite) await(fn func() bool) {
+	if err := <-kit.Await(func() (bool, error) { return fn(), nil }, time.Millisecond*10, time.Second*3); err != nil {
+		s.Fatal(err)
+	}
+}
+
+func (s *memoryTestSuite) Test_Init_WhenUrlEmpty_Fail() {
+	s.AssertAppErr(NewMemoryBroker(s.logger).Init(s.Ctx, &BrokerConfig{}), ErrCodeKafkaInvalidConfig)
+	_, err := NewMemoryBroker(s.logger).AddProducer(s.Ctx, s.topic(1), &ProducerConfig{})
+	s.AssertAppErr(err, ErrCodeKafkaNotInitialized)
+}
+
+func (s *memoryTestSuite) Test_PubSub_AutoCommit() {
+	topic := s.topic(4)
+
+	// subscriber and producer share a cluster by url
+	sub := s.broker()
+	collector := &memoryTestCollector{}
+	s.NoError(sub.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().GroupId("group").CommitInterval(time.Millisecond).Build(), collector.handler))
+	s.NoError(sub.Start(s.Ctx))
+	defer sub.Close(s.Ctx)
+
+	pub := s.broker()
+	producer, err := pub.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(pub.Start(s.Ctx))
+	defer pub.Close(s.Ctx)
+
+	for i := 0; i < 10; i++ {
+		s.NoError(producer.Send(s.Ctx, kit.NewRandString(), i))
+	}
+	s.await(func() bool { return collector.len() == 10 })
+
+	// all messages are committed
+	s.cluster().Lock()
+	g := s.cluster().groups[memoryGroupKey{group: "group", topic: topic.Topic}]
+	committed := int64(0)
+	for _, offset := range g.committed {
+		committed += offset
+	}
+	s.cluster().Unlock()
+	s.Equal(int64(10), committed)
+}
+
+func (s *memoryTestSuite) Test_PubSub_ManualCommit_Typed() {
+	topic := s.topic(2)
+
+	b := s.broker()
+	var received []string
+	var mu sync.Mutex
+	s.NoError(AddTypedSubscriber(s.Ctx, b, topic, NewSubscriberCfgBuilder().GroupId("group").Build(), func(ctx context.Context, msg *typedTestPayload) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, msg.Id)
+		return nil
+	}))
+	producer, err := AddTypedProducer[*typedTestPayload](s.Ctx, b, topic, NewProducerCfgBuilder().Mode(MessageModeEnvelope).Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	// messages of the same key are handled in order
+	for _, v := range []string{"1", "2", "3"} {
+		s.NoError(producer.Send(s.Ctx, "key", &typedTestPayload{Id: v}))
+	}
+	s.await(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(received) == 3
+	})
+	s.Equal([]string{"1", "2", "3"}, received)
+}
+
+func (s *memoryTestSuite) Test_Send_WhenTopicNotDeclared_Fail() {
+	s.brokerCfg.TopicAutoCreation = false
+	b := s.broker()
+	producer, err := b.AddProducer(s.Ctx, s.topic(1), NewProducerCfgBuilder().Retry(1, time.Millisecond).Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.AssertAppErr(producer.Send(s.Ctx, "key", "payload"), ErrCodeKafkaMessageWrite)
+
+	// topics are created by reconciliation
+	_, err = b.Admin().ReconcileTopics(s.Ctx, false)
+	s.NoError(err)
+	s.NoError(producer.Send(s.Ctx, "key", "payload"))
+}
+
+func (s *memoryTestSuite) Test_Write_PartitionByKeyHash() {
+	topic := s.topic(8)
+	b := s.broker()
+	_, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.DeclareTopics(s.Ctx))
+
+	w := (&memoryClients{cluster: s.cluster()}).writer(&kafka.Writer{Topic: topic.Topic, Balancer: &kafka.Hash{}})
+	for i := 0; i < 5; i++ {
+		s.NoError(w.WriteMessages(s.Ctx, kafka.Message{Key: []byte("key-1")}, kafka.Message{Key: []byte("key-2")}))
+	}
+
+	partitions := map[string]map[int]struct{}{}
+	for p := 0; p < 8; p++ {
+		msgs, err := s.cluster().snapshot(topic.Topic, p)
+		s.NoError(err)
+		for i, m := range msgs {
+			s.Equal(int64(i), m.Offset)
+			if partitions[string(m.Key)] == nil {
+				partitions[string(m.Key)] = map[int]struct{}{}
+			}
+			partitions[string(m.Key)][m.Partition] = struct{}{}
+		}
+	}
+	s.Len(partitions["key-1"], 1)
+	s.Len(partitions["key-2"], 1)
+}
+
+func (s *memoryTestSuite) Test_Reader_GroupRebalance_RedeliversUncommitted() {
+	cluster := s.cluster()
+	cluster.Lock()
+	cluster.createTopic(kafka.TopicConfig{Topic: "topic", NumPartitions: 2})
+	cluster.Unlock()
+	clients := &memoryClients{cluster: cluster}
+	w := clients.writer(&kafka.Writer{Topic: "topic", Balancer: &kafka.RoundRobin{}})
+	s.NoError(w.WriteMessages(s.Ctx, kafka.Message{Value: []byte("1")}, kafka.Message{Value: []byte("2")}))
+
+	// partitions are shared by group members
+	cfg := kafka.ReaderConfig{Topic: "topic", GroupID: "group", StartOffset: kafka.FirstOffset}
+	r1 := clients.reader(cfg)
+	r2 := clients.reader(cfg)
+	m1, err := r1.FetchMessage(s.Ctx)
+	s.NoError(err)
+	m2, err := r2.FetchMessage(s.Ctx)
+	s.NoError(err)
+	s.NotEqual(m1.Partition, m2.Partition)
+	s.NoError(r1.CommitMessages(s.Ctx, m1))
+	s.Equal(int64(0), r1.Stats().Lag+r2.Stats().Lag)
+
+	// the uncommitted message is redelivered to the remaining member
+	s.NoError(r2.Close())
+	m, err := r1.FetchMessage(s.Ctx)
+	s.NoError(err)
+	s.Equal(m2.Partition, m.Partition)
+	s.Equal(m2.Offset, m.Offset)
+
+	// closed reader
+	_, err = r2.FetchMessage(s.Ctx)
+	s.ErrorIs(err, io.EOF)
+	_ = r1.Close()
+}
+
+func (s *memoryTestSuite) Test_Reader_LastOffset_WaitsForNewMessages() {
+	cluster := s.cluster()
+	cluster.Lock()
+	cluster.createTopic(kafka.TopicConfig{Topic: "topic"})
+	cluster.Unlock()
+	clients := &memoryClients{cluster: cluster}
+	w := clients.writer(&kafka.Writer{Topic: "topic"})
+	s.NoError(w.WriteMessages(s.Ctx, kafka.Message{Value: []byte("old")}))
+
+	r := clients.reader(kafka.ReaderConfig{Topic: "topic", GroupID: "group", StartOffset: kafka.LastOffset})
+	defer func() { _ = r.Close() }()
+
+	// nothing to read
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Millisecond*50)
+	defer cancel()
+	_, err := r.FetchMessage(ctx)
+	s.True(errors.Is(err, context.DeadlineExceeded))
+
+	// a blocked reader receives a new message
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		_ = w.WriteMessages(s.Ctx, kafka.Message{Value: []byte("new")})
+	}()
+	m, err := r.ReadMessage(s.Ctx)
+	s.NoError(err)
+	s.Equal("new", string(m.Value))
+	s.Equal(int64(0), r.Stats().Lag)
+}
+
+func (s *memoryTestSuite) Test_Reader_NoGroup() {
+	cluster := s.cluster()
+	clients := &memoryClients{cluster: cluster}
+	r := clients.reader(kafka.ReaderConfig{Topic: "topic", Partition: 1})
+	defer func() { _ = r.Close() }()
+
+	// the partition is assigned once the topic is created
+	cluster.Lock()
+	cluster.createTopic(kafka.TopicConfig{Topic: "topic", NumPartitions: 2})
+	cluster.Unlock()
+	w := clients.writer(&kafka.Writer{Topic: "topic", Balancer: kafka.BalancerFunc(func(m kafka.Message, partitions ...int) int { return 1 })})
+	s.NoError(w.WriteMessages(s.Ctx, kafka.Message{Value: []byte("1")}))
+
+	m, err := r.FetchMessage(s.Ctx)
+	s.NoError(err)
+	s.Equal(1, m.Partition)
+	s.Error(r.CommitMessages(s.Ctx, m))
+}
+
+func (s *memoryTestSuite) Test_ManualCommit_RetryTopicsAndDLQ() {
+	topic := s.topic(1)
+	dlqTopic := s.topic(1)
+	b := s.broker()
+
+	dlqProducer, err := b.AddProducer(s.Ctx, dlqTopic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	var attempts sync.Map
+	s.NoError(b.AddSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		RetryTopics(time.Millisecond*10, time.Millisecond*20).
+		DLQProducer(dlqProducer).
+		Build(), func(payload []byte) error {
+		v, _ := attempts.LoadOrStore("n", new(int))
+		*v.(*int)++
+		return errors.New("failed")
+	}))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.NoError(producer.Send(s.Ctx, "key", "payload"))
+
+	// the message passes the topic and both retry topics before landing in DLQ
+	var records []*DLQRecord
+	s.await(func() bool {
+		records = nil
+		_ = b.DLQ(dlqTopic.Topic).Read(s.Ctx, nil, func(ctx context.Context, r *DLQRecord) error {
+			records = append(records, r)
+			return nil
+		})
+		return len(records) == 1
+	})
+	s.Equal(topic.Topic, records[0].Topic)
+	s.Equal("key", records[0].Key)
+	s.Equal("2", records[0].Headers[HeaderRetryAttempt])
+
+	// replay sends the message back to the origin topic
+	res, err := b.DLQ(dlqTopic.Topic).Replay(s.Ctx, nil, nil)
+	s.NoError(err)
+	s.Equal(1, res.Replayed)
+	msgs, err := s.cluster().snapshot(topic.Topic, 0)
+	s.NoError(err)
+	s.Len(msgs, 2)
+}
+
+func (s *memoryTestSuite) Test_Admin() {
+	b := s.broker()
+	topic := NewTopicCfgBuilder(kit.NewRandString()).WithPartitionNum(2).WithReplicaFactor(3).WithParams(TopicParam{Name: "retention.ms", Value: "1000"}).Build()
+	_, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	admin := b.Admin()
+
+	// reconcile creates declared topics
+	res, err := admin.ReconcileTopics(s.Ctx, true)
+	s.NoError(err)
+	s.Equal([]string{topic.Topic}, res.Created)
+	topics, err := admin.ListTopics(s.Ctx)
+	s.NoError(err)
+	s.Empty(topics)
+	_, err = admin.ReconcileTopics(s.Ctx, false)
+	s.NoError(err)
+
+	ds, err := admin.DescribeTopics(s.Ctx, topic.Topic)
+	s.NoError(err)
+	s.Equal(&TopicDescription{Topic: topic.Topic, Partitions: 2, ReplicaFactor: 3, Configs: map[string]string{"retention.ms": "1000"}}, ds[0])
+
+	// partitions and configs
+	s.NoError(admin.IncreasePartitions(s.Ctx, topic.Topic, 4))
+	s.AssertAppErr(admin.IncreasePartitions(s.Ctx, topic.Topic, 3), ErrCodeKafkaIncreasePartitions)
+	s.NoError(admin.AlterTopicConfigs(s.Ctx, topic.Topic, map[string]string{"retention.ms": "2000"}))
+	res, err = admin.ReconcileTopics(s.Ctx, true)
+	s.NoError(err)
+	s.Equal([]string{topic.Topic}, res.ConfigsAltered)
+	s.Len(res.Mismatches, 1)
+
+	// delete
+	s.NoError(admin.DeleteTopics(s.Ctx, topic.Topic))
+	s.AssertAppErr(admin.DeleteTopics(s.Ctx, topic.Topic), ErrCodeKafkaDeleteTopics)
+	_, err = admin.DescribeTopics(s.Ctx, topic.Topic)
+	s.AssertAppErr(err, ErrCodeKafkaTopicNotFound)
+}
+
+func (s *memoryTestSuite) Test_Close_LeavesGroup() {
+	topic := s.topic(2)
+	collector := &memoryTestCollector{}
+	cfg := NewSubscriberCfgBuilder().GroupId("group").Build()
+
+	b1 := s.broker()
+	s.NoError(b1.AddMessageSubscriber(s.Ctx, topic, cfg, collector.handler))
+	s.NoError(b1.Start(s.Ctx))
+	b2 := s.broker()
+	s.NoError(b2.AddMessageSubscriber(s.Ctx, topic, cfg, collector.handler))
+	s.NoError(b2.Start(s.Ctx))
+	defer b2.Close(s.Ctx)
+
+	// partitions of the closed broker are reassigned to the remaining one
+	b1.Close(s.Ctx)
+	s.cluster().Lock()
+	g := s.cluster().groups[memoryGroupKey{group: "group", topic: topic.Topic}]
+	s.Len(g.members, 1)
+	s.Len(g.members[0].assigned, 2)
+	s.cluster().Unlock()
+}
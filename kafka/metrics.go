@@ -0,0 +1,215 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	MessagesProducedCounter  = "kafka_messages_produced_counter"
+	ProduceErrorsCounter     = "kafka_produce_errors_counter"
+	MessagesConsumedCounter  = "kafka_messages_consumed_counter"
+	HandlerDurationHistogram = "kafka_handler_duration"
+	HandlerErrorsCounter     = "kafka_handler_errors_counter"
+	HandlerRetriesCounter    = "kafka_handler_retries_counter"
+	DLQSendsCounter          = "kafka_dlq_sends_counter"
+	CommitErrorsCounter      = "kafka_commit_errors_counter"
+	ConsumerLagGauge         = "kafka_consumer_lag"
+)
+
+// metrics kafka prometheus metrics of a broker
+// metrics of brokers are told apart by the const label "broker", so that a few brokers are registered with no conflict
+// nil metrics are allowed, nothing is collected then
+type metrics struct {
+	produced        *prometheus.CounterVec
+	produceErrors   *prometheus.CounterVec
+	consumed        *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	handlerErrors   *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+	dlqSends        *prometheus.CounterVec
+	commitErrors    *prometheus.CounterVec
+	lag             *lagCollector
+}
+
+// brokerLabel returns a value of the "broker" label: ClientId if set, otherwise Url
+func brokerLabel(cfg *BrokerConfig) string {
+	if cfg.ClientId != "" {
+		return cfg.ClientId
+	}
+	return cfg.Url
+}
+
+func newMetrics(broker string) *metrics {
+	labels := prometheus.Labels{"broker": broker}
+	return &metrics{
+		produced: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        MessagesProducedCounter,
+			Help:        "Counts messages written to kafka",
+			ConstLabels: labels,
+		}, []string{"topic"}),
+		produceErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        ProduceErrorsCounter,
+			Help:        "Counts messages failed to be written to kafka",
+			ConstLabels: labels,
+		}, []string{"topic"}),
+		consumed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        MessagesConsumedCounter,
+			Help:        "Counts messages fetched from kafka (retry topics are counted under the origin topic)",
+			ConstLabels: labels,
+		}, []string{"topic", "group"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        HandlerDurationHistogram,
+			Help:        "Handler execution duration in seconds",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"topic", "group"}),
+		handlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        HandlerErrorsCounter,
+			Help:        "Counts failed handler executions",
+			ConstLabels: labels,
+		}, []string{"topic", "group"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        HandlerRetriesCounter,
+			Help:        "Counts handling retries (in-process attempts and republishing to retry topics)",
+			ConstLabels: labels,
+		}, []string{"topic", "group"}),
+		dlqSends: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        DLQSendsCounter,
+			Help:        "Counts messages sent to DLQ",
+			ConstLabels: labels,
+		}, []string{"topic", "group"}),
+		commitErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        CommitErrorsCounter,
+			Help:        "Counts messages failed to be committed",
+			ConstLabels: labels,
+		}, []string{"topic", "group"}),
+		lag: newLagCollector(labels),
+	}
+}
+
+func (m *metrics) produce(topic string, n int, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.produceErrors.WithLabelValues(topic).Add(float64(n))
+		return
+	}
+	m.produced.WithLabelValues(topic).Add(float64(n))
+}
+
+func (m *metrics) consume(topic, group string) {
+	if m == nil {
+		return
+	}
+	m.consumed.WithLabelValues(topic, group).Inc()
+}
+
+func (m *metrics) handle(topic, group string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.handlerDuration.WithLabelValues(topic, group).Observe(duration.Seconds())
+	if err != nil {
+		m.handlerErrors.WithLabelValues(topic, group).Inc()
+	}
+}
+
+func (m *metrics) retry(topic, group string) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(topic, group).Inc()
+}
+
+func (m *metrics) dlq(topic, group string) {
+	if m == nil {
+		return
+	}
+	m.dlqSends.WithLabelValues(topic, group).Inc()
+}
+
+func (m *metrics) commitErr(topic, group string) {
+	if m == nil {
+		return
+	}
+	m.commitErrors.WithLabelValues(topic, group).Inc()
+}
+
+// trackLag starts reporting lag of the reader, returns a function to stop reporting
+func (m *metrics) trackLag(reader messageReader, topic, group string) func() {
+	if m == nil {
+		return func() {}
+	}
+	return m.lag.add(reader, topic, group)
+}
+
+func (m *metrics) collector() monitoring.MetricsCollector {
+	return func() monitoring.MetricsCollection {
+		if m == nil {
+			return nil
+		}
+		return monitoring.MetricsCollection{
+			m.produced,
+			m.produceErrors,
+			m.consumed,
+			m.handlerDuration,
+			m.handlerErrors,
+			m.retries,
+			m.dlqSends,
+			m.commitErrors,
+			m.lag,
+		}
+	}
+}
+
+type lagKey struct {
+	topic string
+	group string
+}
+
+// lagCollector reports lag taken from stats of active readers on scraping
+type lagCollector struct {
+	sync.Mutex
+	desc    *prometheus.Desc
+	readers map[messageReader]lagKey
+}
+
+func newLagCollector(labels prometheus.Labels) *lagCollector {
+	return &lagCollector{
+		desc:    prometheus.NewDesc(ConsumerLagGauge, "Consumer lag in messages reported by kafka reader", []string{"topic", "group"}, labels),
+		readers: map[messageReader]lagKey{},
+	}
+}
+
+func (c *lagCollector) add(reader messageReader, topic, group string) func() {
+	c.Lock()
+	defer c.Unlock()
+	c.readers[reader] = lagKey{topic: topic, group: group}
+	return func() {
+		c.Lock()
+		defer c.Unlock()
+		delete(c.readers, reader)
+	}
+}
+
+func (c *lagCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lagCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	// a subscriber with retry topics has a reader per topic, lag is reported per topic
+	lags := map[lagKey]int64{}
+	for r, k := range c.readers {
+		lags[k] += r.Stats().Lag
+	}
+	for k, lag := range lags {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(lag), k.topic, k.group)
+	}
+}
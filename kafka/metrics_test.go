@@ -0,0 +1,172 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type metricsTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *metricsTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestMetricsSuite(t *testing.T) {
+	suite.Run(t, new(metricsTestSuite))
+}
+
+// gather returns values of metrics by name and label values joined with "/"
+func (s *metricsTestSuite) gather(m *metrics) map[string]float64 {
+	reg := prometheus.NewRegistry()
+	for _, c := range m.collector()() {
+		s.NoError(reg.Register(c))
+	}
+	families, err := reg.Gather()
+	s.NoError(err)
+
+	r := map[string]float64{}
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			key := f.GetName()
+			for _, lbl := range metric.GetLabel() {
+				key += "/" + lbl.GetValue()
+			}
+			switch {
+			case metric.GetCounter() != nil:
+				r[key] = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				r[key] = metric.GetGauge().GetValue()
+			case metric.GetHistogram() != nil:
+				r[key] = float64(metric.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return r
+}
+
+func (s *metricsTestSuite) Test_Broker_MetricsProvider() {
+	b := NewMemoryBroker(s.logger)
+	// metrics are created on Init
+	s.Empty(b.GetCollector()())
+	s.NoError(b.Init(s.Ctx, &BrokerConfig{Url: kit.NewRandString()}))
+	s.Len(b.GetCollector()(), 9)
+}
+
+func (s *metricsTestSuite) Test_MetricsServer_WhenFewBrokers_Registered() {
+	b1, b2 := NewMemoryBroker(s.logger), NewMemoryBroker(s.logger)
+	s.NoError(b1.Init(s.Ctx, &BrokerConfig{ClientId: "b1", Url: kit.NewRandString()}))
+	s.NoError(b2.Init(s.Ctx, &BrokerConfig{ClientId: "b2", Url: kit.NewRandString()}))
+	srv := monitoring.NewMetricsServer(s.logger)
+	s.NoError(srv.Init(&monitoring.Config{Port: "9999"}, b1, b2))
+}
+
+func (s *metricsTestSuite) Test_FewBrokers_LabeledByBroker() {
+	m1, m2 := newMetrics("b1"), newMetrics("b2")
+	m1.produce("topic", 3, nil)
+	m2.produce("topic", 2, nil)
+	reg := prometheus.NewRegistry()
+	for _, m := range []*metrics{m1, m2} {
+		for _, c := range m.collector()() {
+			s.NoError(reg.Register(c))
+		}
+	}
+	families, err := reg.Gather()
+	s.NoError(err)
+	r := map[string]float64{}
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			if f.GetName() == MessagesProducedCounter {
+				r[metric.GetLabel()[0].GetValue()] = metric.GetCounter().GetValue()
+			}
+		}
+	}
+	s.Equal(map[string]float64{"b1": 3, "b2": 2}, r)
+}
+
+func (s *metricsTestSuite) Test_BrokerLabel() {
+	s.Equal("client", brokerLabel(&BrokerConfig{ClientId: "client", Url: "localhost:9092"}))
+	s.Equal("localhost:9092", brokerLabel(&BrokerConfig{Url: "localhost:9092"}))
+}
+
+func (s *metricsTestSuite) Test_Produce() {
+	m := newMetrics("broker")
+	m.produce("topic", 3, nil)
+	m.produce("topic", 2, errors.New("failed"))
+	values := s.gather(m)
+	s.Equal(float64(3), values[MessagesProducedCounter+"/broker/topic"])
+	s.Equal(float64(2), values[ProduceErrorsCounter+"/broker/topic"])
+}
+
+func (s *metricsTestSuite) Test_ManualCommit_HandleRetryDLQ() {
+	m := newMetrics("broker")
+	dlqProducer := &typedTestProducer{}
+	sub := newSubscriberManualCommitStrategy(s.logger, nil, &kafka.ReaderConfig{GroupID: "group"}, &dispatcher{topic: "topic", handlers: []HandlerFn{
+		func(payload []byte) error {
+			return errors.New("failed")
+		},
+	}}, &SubscriberManualCommitConfig{HandleMessageMaxRetryCount: 3}, dlqProducer, nil, nil, m, 1).(*subscriberManualCommit)
+
+	msg := kafka.Message{Key: []byte("key"), Value: []byte("value")}
+	err := sub.handleWithRetry(s.Ctx, "topic", msg)
+	s.Error(err)
+	s.True(sub.onFailure(s.Ctx, "topic", 0, msg, err))
+
+	values := s.gather(m)
+	s.Equal(float64(3), values[HandlerDurationHistogram+"/broker/group/topic"])
+	s.Equal(float64(3), values[HandlerErrorsCounter+"/broker/group/topic"])
+	s.Equal(float64(2), values[HandlerRetriesCounter+"/broker/group/topic"])
+	s.Equal(float64(1), values[DLQSendsCounter+"/broker/group/topic"])
+}
+
+func (s *metricsTestSuite) Test_ManualCommit_WhenHandled_NoRetries() {
+	m := newMetrics("broker")
+	sub := newSubscriberManualCommitStrategy(s.logger, nil, &kafka.ReaderConfig{GroupID: "group"}, &dispatcher{topic: "topic", handlers: []HandlerFn{
+		func(payload []byte) error {
+			return nil
+		},
+	}}, &SubscriberManualCommitConfig{HandleMessageMaxRetryCount: 3}, nil, nil, nil, m, 1).(*subscriberManualCommit)
+
+	s.NoError(sub.handleWithRetry(s.Ctx, "topic", kafka.Message{Key: []byte("key"), Value: []byte("value")}))
+
+	values := s.gather(m)
+	s.Equal(float64(1), values[HandlerDurationHistogram+"/broker/group/topic"])
+	s.Equal(float64(0), values[HandlerRetriesCounter+"/broker/group/topic"])
+}
+
+func (s *metricsTestSuite) Test_Lag() {
+	m := newMetrics("broker")
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: []string{"localhost:9092"}, Topic: "topic", MaxWait: time.Second})
+	defer func() { _ = reader.Close() }()
+
+	untrack := m.trackLag(reader, "topic", "group")
+	values := s.gather(m)
+	v, ok := values[ConsumerLagGauge+"/broker/group/topic"]
+	s.True(ok)
+	s.Equal(float64(0), v)
+
+	untrack()
+	_, ok = s.gather(m)[ConsumerLagGauge+"/broker/group/topic"]
+	s.False(ok)
+}
+
+func (s *metricsTestSuite) Test_NilMetrics() {
+	var m *metrics
+	m.produce("topic", 1, nil)
+	m.consume("topic", "group")
+	m.handle("topic", "group", time.Second, nil)
+	m.retry("topic", "group")
+	m.dlq("topic", "group")
+	m.commitErr("topic", "group")
+	m.trackLag(nil, "topic", "group")()
+}
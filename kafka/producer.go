@@ -19,31 +19,33 @@ type Producer interface {
 	// Send sends a message to broker
 	Send(ctx context.Context, key string, payload interface{}) error
 	// SendMany sends bulk of messages to broker
+	// if a message has no request context specified, it's taken from ctx
 	SendMany(ctx context.Context, messages ...*Message) error
 }
 
 type producerImpl struct {
 	topic           *TopicConfig
-	writer          *kafka.Writer
+	writer          messageWriter
 	logger          kit.CLoggerFunc
 	cancellationCtx context.Context
 	retryTimes      int
 	retryTimeout    time.Duration
+	mode            string
+	serializer      Serializer
 }
 
 func (p *producerImpl) l() kit.CLogger {
 	return p.logger().Cmp("kafka-producer")
 }
 
-func newProducer(ctx context.Context, logger kit.CLoggerFunc, topic *TopicConfig, cfg *ProducerConfig, urls []string, transport *kafka.Transport) Producer {
+func newProducer(ctx context.Context, logger kit.CLoggerFunc, topic *TopicConfig, cfg *ProducerConfig, clients clients, metrics *metrics) Producer {
 
 	// populate writer params
 	writer := &kafka.Writer{
-		Addr:        kafka.TCP(urls...),
 		Topic:       topic.Topic,
 		ErrorLogger: kafka.LoggerFunc(logger().Mth("producer").F(kit.KV{"topic": topic.Topic}).PrintfErr),
-		Transport:   transport,
 		Completion: func(messages []kafka.Message, err error) {
+			metrics.produce(topic.Topic, len(messages), err)
 			if err != nil {
 				logger().Mth("producer-completion").F(kit.KV{"topic": topic.Topic}).E(ErrKafkaProduceMsg(ctx, err)).Err()
 			}
@@ -65,10 +67,12 @@ func newProducer(ctx context.Context, logger kit.CLoggerFunc, topic *TopicConfig
 	}
 
 	r := &producerImpl{
-		writer:          writer,
+		writer:          clients.writer(writer),
 		logger:          logger,
 		topic:           topic,
 		cancellationCtx: ctx,
+		mode:            cfg.Mode,
+		serializer:      cfg.Serializer,
 	}
 
 	if cfg.RetryTimes != nil {
@@ -114,18 +118,20 @@ func (p *producerImpl) Send(ctx context.Context, key string, payload interface{}
 func (p *producerImpl) SendMany(ctx context.Context, messages ...*Message) error {
 	l := p.l().Mth("send-many").F(kit.KV{"topic": p.topic.Topic}).Dbg()
 
-	// prepare message
-	ctxRq, err := p.rqCtx(ctx)
-	if err != nil {
-		return err
-	}
-
+	// prepare message, a message might carry its own request context (e.g. relayed from outbox)
 	for _, m := range messages {
+		if m.Ctx != nil {
+			continue
+		}
+		ctxRq, err := p.rqCtx(ctx)
+		if err != nil {
+			return err
+		}
 		m.Ctx = ctxRq
 	}
 
 	// write message
-	err = p.sendWithRetry(ctx, messages...)
+	err := p.sendWithRetry(ctx, messages...)
 	if err != nil {
 		return err
 	}
@@ -146,22 +152,18 @@ func (p *producerImpl) sendWithRetry(ctx context.Context, messages ...*Message)
 	messagesToSend := make([]kafka.Message, 0, len(messages))
 	now := kit.Now()
 	for _, msg := range messages {
-
-		m, err := kit.Marshal(msg)
+		m, err := p.toKafkaMessage(ctx, msg)
 		if err != nil {
-			return ErrKafkaMessageMarshal(ctx, err, p.topic.Topic)
+			return err
 		}
-
-		messagesToSend = append(messagesToSend, kafka.Message{
-			Key:   []byte(msg.Key),
-			Value: m,
-			Time:  now,
-		})
+		m.Time = now
+		messagesToSend = append(messagesToSend, m)
 	}
 
 	// send with retry
+	var err error
 	for i := 0; i < p.retryTimes; i++ {
-		err := p.writer.WriteMessages(p.cancellationCtx, messagesToSend...)
+		err = p.writer.WriteMessages(p.cancellationCtx, messagesToSend...)
 		if err != nil {
 			if errors.Is(err, kafka.LeaderNotAvailable) {
 				time.Sleep(p.retryTimeout)
@@ -172,5 +174,43 @@ func (p *producerImpl) sendWithRetry(ctx context.Context, messages ...*Message)
 		}
 		break
 	}
+	// retries exhausted
+	if err != nil {
+		return ErrKafkaMessageWrite(ctx, err, p.topic.Topic)
+	}
 	return nil
 }
+
+// toKafkaMessage converts a message to kafka message according to the producer's mode
+func (p *producerImpl) toKafkaMessage(ctx context.Context, msg *Message) (kafka.Message, error) {
+	r := kafka.Message{
+		Key:     []byte(msg.Key),
+		Headers: mapToHeaders(msg.Headers),
+	}
+
+	// envelope mode
+	if p.mode != MessageModeHeaders {
+		m, err := kit.Marshal(msg)
+		if err != nil {
+			return r, ErrKafkaMessageMarshal(ctx, err, p.topic.Topic)
+		}
+		r.Value = m
+		return r, nil
+	}
+
+	// headers mode
+	hs, err := requestCtxToHeaders(msg.Ctx)
+	if err != nil {
+		return r, ErrKafkaMessageMarshal(ctx, err, p.topic.Topic)
+	}
+	r.Headers = append(r.Headers, hs...)
+	if p.serializer != nil {
+		r.Value, err = p.serializer.Serialize(ctx, p.topic.Topic, msg.Payload)
+		return r, err
+	}
+	r.Value, err = rawPayload(msg.Payload)
+	if err != nil {
+		return r, ErrKafkaMessageMarshal(ctx, err, p.topic.Topic)
+	}
+	return r, nil
+}
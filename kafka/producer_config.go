@@ -12,6 +12,8 @@ type ProducerConfig struct {
 	Async        bool
 	RetryTimes   *int
 	RetryTimeout *time.Duration
+	Mode         string
+	Serializer   Serializer
 }
 
 type ProducerConfigBuilder interface {
@@ -25,6 +27,11 @@ type ProducerConfigBuilder interface {
 	Retry(time int, timeout time.Duration) ProducerConfigBuilder
 	// RequiredAcks sets required acks value (0(None) default, 1(Single), -1(All))
 	RequiredAcks(v int) ProducerConfigBuilder
+	// Mode sets message mode MessageModeEnvelope or MessageModeHeaders (default: envelope)
+	Mode(mode string) ProducerConfigBuilder
+	// Serializer sets a serializer of payloads (e.g. NewProtobufSerializer, NewAvroSerializer), it switches the producer to headers mode
+	// the schema is registered on AddProducer, so an incompatible schema fails the producer
+	Serializer(s Serializer) ProducerConfigBuilder
 	// Build builds config
 	Build() *ProducerConfig
 }
@@ -65,6 +72,17 @@ func (p *producerConfigBuilder) Async(v bool) ProducerConfigBuilder {
 	return p
 }
 
+func (p *producerConfigBuilder) Mode(mode string) ProducerConfigBuilder {
+	p.cfg.Mode = mode
+	return p
+}
+
+func (p *producerConfigBuilder) Serializer(s Serializer) ProducerConfigBuilder {
+	p.cfg.Serializer = s
+	p.cfg.Mode = MessageModeHeaders
+	return p
+}
+
 func (p *producerConfigBuilder) Build() *ProducerConfig {
 	return p.cfg
 }
@@ -0,0 +1,534 @@
+package kafka
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protoSchema describes messages of a .proto file
+// it keeps what matters for wire compatibility: field numbers, kinds, cardinality and referenced types
+type protoSchema struct {
+	Package  string
+	Messages []*protoSchemaMessage
+}
+
+type protoSchemaMessage struct {
+	Name   string // Name full name
+	Fields []*protoSchemaField
+}
+
+type protoSchemaField struct {
+	Number   int
+	Name     string
+	Kind     string // Kind scalar type, message, enum, group or map
+	Repeated bool
+	Type     string // Type full name of a message or enum, key and value types of a map
+}
+
+// protoFileText prints a proto file as .proto text, which is registered as a protobuf schema
+// syntax, package, imports, messages, enums, fields and reserved numbers are printed, options aren't
+// referenced types are fully qualified, editions files are printed with proto2 syntax, which has the same wire format
+func protoFileText(file protoreflect.FileDescriptor) string {
+	p := &protoPrinter{}
+	if file.Syntax() == protoreflect.Proto3 {
+		p.line(0, `syntax = "proto3";`)
+	} else {
+		p.line(0, `syntax = "proto2";`)
+	}
+	if file.Package() != "" {
+		p.line(0, "")
+		p.line(0, "package %s;", file.Package())
+	}
+	if file.Imports().Len() > 0 {
+		p.line(0, "")
+		for i := 0; i < file.Imports().Len(); i++ {
+			p.line(0, "import %q;", file.Imports().Get(i).Path())
+		}
+	}
+	for i := 0; i < file.Enums().Len(); i++ {
+		p.line(0, "")
+		p.enum(0, file.Enums().Get(i))
+	}
+	for i := 0; i < file.Messages().Len(); i++ {
+		p.line(0, "")
+		p.message(0, file.Messages().Get(i))
+	}
+	return p.String()
+}
+
+type protoPrinter struct {
+	strings.Builder
+}
+
+func (p *protoPrinter) line(indent int, format string, args ...any) {
+	p.WriteString(strings.Repeat("  ", indent))
+	p.WriteString(fmt.Sprintf(format, args...))
+	p.WriteString("\n")
+}
+
+func (p *protoPrinter) message(indent int, msg protoreflect.MessageDescriptor) {
+	p.line(indent, "message %s {", msg.Name())
+	for i := 0; i < msg.Enums().Len(); i++ {
+		p.enum(indent+1, msg.Enums().Get(i))
+	}
+	for i := 0; i < msg.Messages().Len(); i++ {
+		// map entries are printed as map fields
+		if nested := msg.Messages().Get(i); !nested.IsMapEntry() {
+			p.message(indent+1, nested)
+		}
+	}
+	printed := map[protoreflect.OneofDescriptor]bool{}
+	for i := 0; i < msg.Fields().Len(); i++ {
+		f := msg.Fields().Get(i)
+		// a oneof is printed at the position of its first field
+		if oneof := f.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if !printed[oneof] {
+				printed[oneof] = true
+				p.line(indent+1, "oneof %s {", oneof.Name())
+				for j := 0; j < oneof.Fields().Len(); j++ {
+					of := oneof.Fields().Get(j)
+					p.line(indent+2, "%s %s = %d;", protoFieldType(of), of.Name(), of.Number())
+				}
+				p.line(indent+1, "}")
+			}
+			continue
+		}
+		p.line(indent+1, "%s%s %s = %d;", protoFieldLabel(f), protoFieldType(f), f.Name(), f.Number())
+	}
+	p.reserved(indent+1, msg.ReservedRanges(), msg.ReservedNames())
+	p.line(indent, "}")
+}
+
+func (p *protoPrinter) enum(indent int, enum protoreflect.EnumDescriptor) {
+	p.line(indent, "enum %s {", enum.Name())
+	for i := 0; i < enum.Values().Len(); i++ {
+		v := enum.Values().Get(i)
+		p.line(indent+1, "%s = %d;", v.Name(), v.Number())
+	}
+	var ranges []string
+	for i := 0; i < enum.ReservedRanges().Len(); i++ {
+		r := enum.ReservedRanges().Get(i)
+		ranges = append(ranges, protoRange(int64(r[0]), int64(r[1]), math.MaxInt32))
+	}
+	p.reservedLines(indent+1, ranges, enum.ReservedNames())
+	p.line(indent, "}")
+}
+
+func (p *protoPrinter) reserved(indent int, fieldRanges protoreflect.FieldRanges, names protoreflect.Names) {
+	var ranges []string
+	for i := 0; i < fieldRanges.Len(); i++ {
+		r := fieldRanges.Get(i)
+		// field ranges are half-open
+		ranges = append(ranges, protoRange(int64(r[0]), int64(r[1])-1, int64(protowire.MaxValidNumber)))
+	}
+	p.reservedLines(indent, ranges, names)
+}
+
+func (p *protoPrinter) reservedLines(indent int, ranges []string, names protoreflect.Names) {
+	if len(ranges) > 0 {
+		p.line(indent, "reserved %s;", strings.Join(ranges, ", "))
+	}
+	if names.Len() > 0 {
+		var quoted []string
+		for i := 0; i < names.Len(); i++ {
+			quoted = append(quoted, strconv.Quote(string(names.Get(i))))
+		}
+		p.line(indent, "reserved %s;", strings.Join(quoted, ", "))
+	}
+}
+
+func protoRange(from, to, max int64) string {
+	if from == to {
+		return strconv.FormatInt(from, 10)
+	}
+	if to == max {
+		return fmt.Sprintf("%d to max", from)
+	}
+	return fmt.Sprintf("%d to %d", from, to)
+}
+
+func protoFieldLabel(f protoreflect.FieldDescriptor) string {
+	switch {
+	case f.IsMap():
+		return ""
+	case f.Cardinality() == protoreflect.Repeated:
+		return "repeated "
+	case f.Syntax() == protoreflect.Proto3:
+		if f.HasOptionalKeyword() {
+			return "optional "
+		}
+		return ""
+	case f.Cardinality() == protoreflect.Required:
+		return "required "
+	}
+	return "optional "
+}
+
+// protoFieldType returns a scalar type name, a fully qualified name of a message or enum or a map type
+// groups are printed as message fields
+func protoFieldType(f protoreflect.FieldDescriptor) string {
+	if f.IsMap() {
+		return fmt.Sprintf("map<%s, %s>", protoFieldType(f.MapKey()), protoFieldType(f.MapValue()))
+	}
+	switch f.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return "." + string(f.Message().FullName())
+	case protoreflect.EnumKind:
+		return "." + string(f.Enum().FullName())
+	}
+	return f.Kind().String()
+}
+
+// protoScalars scalar type names
+var protoScalars = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+	"bool": true, "string": true, "bytes": true,
+}
+
+// parseProtoSchema parses .proto text into messages and their fields
+// options, services and extensions are skipped, referenced types are resolved by protobuf scoping rules among types of the file,
+// types which aren't found (e.g. imported) are considered messages with the name as written
+func parseProtoSchema(text string) (*protoSchema, error) {
+	tokens, err := protoTokenize(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens, enums: map[string]bool{}, types: map[string]bool{}}
+	schema := &protoSchema{}
+	for !p.eof() {
+		switch t := p.next(); t {
+		case ";":
+		case "syntax", "edition", "import", "option":
+			p.skipStatement()
+		case "package":
+			schema.Package = p.next()
+			if err := p.expect(";"); err != nil {
+				return nil, err
+			}
+		case "message":
+			if err := p.message(schema.Package); err != nil {
+				return nil, err
+			}
+		case "enum":
+			if err := p.enum(schema.Package); err != nil {
+				return nil, err
+			}
+		case "service", "extend":
+			p.skipStatement()
+		default:
+			return nil, fmt.Errorf("unexpected %q", t)
+		}
+	}
+	p.resolve()
+	schema.Messages = p.messages
+	return schema, nil
+}
+
+type protoParser struct {
+	tokens   []string
+	pos      int
+	messages []*protoSchemaMessage
+	enums    map[string]bool // enums full names
+	types    map[string]bool // messages and enums full names
+	scopes   map[*protoSchemaField]string
+}
+
+func (p *protoParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) next() string {
+	if p.eof() {
+		return ""
+	}
+	p.pos++
+	return p.tokens[p.pos-1]
+}
+
+func (p *protoParser) peek() string {
+	if p.eof() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) expect(token string) error {
+	if t := p.next(); t != token {
+		return fmt.Errorf("expected %q, got %q", token, t)
+	}
+	return nil
+}
+
+// skipStatement skips tokens up to the end of a statement, which is either ";" or a block
+func (p *protoParser) skipStatement() {
+	for !p.eof() {
+		switch p.next() {
+		case ";":
+			return
+		case "{":
+			p.skipBlock()
+			return
+		}
+	}
+}
+
+// skipBlock skips tokens up to the closing brace of the block opened
+func (p *protoParser) skipBlock() {
+	for depth := 1; depth > 0 && !p.eof(); {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+	}
+}
+
+func (p *protoParser) fullName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) message(scope string) error {
+	name := p.fullName(scope, p.next())
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	return p.messageBody(name)
+}
+
+func (p *protoParser) messageBody(name string) error {
+	msg := &protoSchemaMessage{Name: name}
+	p.messages = append(p.messages, msg)
+	p.types[name] = true
+	for {
+		switch t := p.peek(); t {
+		case "":
+			return fmt.Errorf("%s: unexpected end", name)
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			if err := p.message(name); err != nil {
+				return err
+			}
+		case "enum":
+			p.next()
+			if err := p.enum(name); err != nil {
+				return err
+			}
+		case "oneof":
+			p.next()
+			p.next()
+			if err := p.expect("{"); err != nil {
+				return err
+			}
+			for p.peek() != "}" && !p.eof() {
+				if p.peek() == "option" || p.peek() == ";" {
+					p.skipStatement()
+					continue
+				}
+				if err := p.field(msg, ""); err != nil {
+					return err
+				}
+			}
+			p.next()
+		case "option", "reserved", "extensions", "extend":
+			p.skipStatement()
+		case "map":
+			p.next()
+			if err := p.mapField(msg); err != nil {
+				return err
+			}
+		case "repeated", "optional", "required":
+			p.next()
+			if err := p.field(msg, t); err != nil {
+				return err
+			}
+		default:
+			if err := p.field(msg, ""); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *protoParser) field(msg *protoSchemaMessage, label string) error {
+	typ := p.next()
+	f := &protoSchemaField{Name: p.next(), Repeated: label == "repeated"}
+	if typ == "group" {
+		// a group declares a nested message named as the field
+		f.Kind, f.Type = "group", p.fullName(msg.Name, f.Name)
+		f.Name = strings.ToLower(f.Name)
+	} else if protoScalars[typ] {
+		f.Kind = typ
+	} else {
+		f.Type = typ
+		p.scope(f, msg.Name)
+	}
+	if err := p.number(msg, f); err != nil {
+		return err
+	}
+	if f.Kind == "group" {
+		if err := p.expect("{"); err != nil {
+			return err
+		}
+		return p.messageBody(f.Type)
+	}
+	return p.expect(";")
+}
+
+func (p *protoParser) mapField(msg *protoSchemaMessage) error {
+	if err := p.expect("<"); err != nil {
+		return err
+	}
+	key := p.next()
+	if err := p.expect(","); err != nil {
+		return err
+	}
+	value := p.next()
+	if err := p.expect(">"); err != nil {
+		return err
+	}
+	f := &protoSchemaField{Name: p.next(), Kind: "map", Repeated: true, Type: key + "," + value}
+	if !protoScalars[value] {
+		p.scope(f, msg.Name)
+	}
+	if err := p.number(msg, f); err != nil {
+		return err
+	}
+	return p.expect(";")
+}
+
+// number parses a field number and skips field options
+func (p *protoParser) number(msg *protoSchemaMessage, f *protoSchemaField) error {
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	n, err := strconv.ParseInt(p.next(), 0, 32)
+	if err != nil {
+		return fmt.Errorf("%s: invalid number of field %s", msg.Name, f.Name)
+	}
+	f.Number = int(n)
+	if p.peek() == "[" {
+		for !p.eof() && p.next() != "]" {
+		}
+	}
+	msg.Fields = append(msg.Fields, f)
+	return nil
+}
+
+func (p *protoParser) enum(scope string) error {
+	name := p.fullName(scope, p.next())
+	p.types[name] = true
+	p.enums[name] = true
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	p.skipBlock()
+	return nil
+}
+
+// scope remembers a scope of a field referencing a type, the type is resolved once the whole file is parsed
+func (p *protoParser) scope(f *protoSchemaField, scope string) {
+	if p.scopes == nil {
+		p.scopes = map[*protoSchemaField]string{}
+	}
+	p.scopes[f] = scope
+}
+
+func (p *protoParser) resolve() {
+	for f, scope := range p.scopes {
+		if f.Kind == "map" {
+			kv := strings.SplitN(f.Type, ",", 2)
+			f.Type = kv[0] + "," + p.resolveName(kv[1], scope)
+			continue
+		}
+		f.Type = p.resolveName(f.Type, scope)
+		f.Kind = "message"
+		if p.enums[f.Type] {
+			f.Kind = "enum"
+		}
+	}
+}
+
+// resolveName looks a name up from the innermost scope to the outermost one
+func (p *protoParser) resolveName(name, scope string) string {
+	if strings.HasPrefix(name, ".") {
+		return name[1:]
+	}
+	for {
+		if candidate := p.fullName(scope, name); p.types[candidate] {
+			return candidate
+		}
+		if scope == "" {
+			return name
+		}
+		if i := strings.LastIndex(scope, "."); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// protoTokenize splits .proto text into identifiers, numbers, strings and symbols, comments are dropped
+func protoTokenize(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(text[i:], "//"):
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(text) && text[j] != c {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, text[i:j+1])
+			i = j + 1
+		case isProtoIdentChar(c) || c == '.' || c == '-' || c == '+':
+			j := i + 1
+			for j < len(text) && (isProtoIdentChar(text[j]) || text[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+)
+
+// retry headers
+const (
+	HeaderRetryAttempt   = "x-retry-attempt"    // HeaderRetryAttempt number of the retry attempt
+	HeaderRetryTopic     = "x-retry-topic"      // HeaderRetryTopic original topic
+	HeaderRetryNotBefore = "x-retry-not-before" // HeaderRetryNotBefore unix time in ms before which the message mustn't be handled
+	HeaderRetryError     = "x-retry-error"      // HeaderRetryError error of the last attempt
+)
+
+// RetryTopicsConfig configures delayed retries through retry topics
+type RetryTopicsConfig struct {
+	Delays []time.Duration // Delays delay of each retry tier, a topic <topic>.retry.<delay> is declared for each tier
+}
+
+// RetryTopicName returns a name of the retry topic for the given topic and delay
+func RetryTopicName(topic string, delay time.Duration) string {
+	return topic + ".retry." + formatDelay(delay)
+}
+
+// formatDelay formats delay in the largest whole unit (e.g. 5s, 1m, 10m, 2h)
+func formatDelay(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	default:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+}
+
+// retryTopics republishes failed messages to retry topics
+// nil retryTopics means retry topics aren't configured
+type retryTopics struct {
+	delays []time.Duration
+	writer messageWriter
+	logger kit.CLoggerFunc
+}
+
+func newRetryTopics(logger kit.CLoggerFunc, cfg *RetryTopicsConfig, clients clients) *retryTopics {
+	if cfg == nil || len(cfg.Delays) == 0 {
+		return nil
+	}
+	return &retryTopics{
+		delays: cfg.Delays,
+		writer: clients.writer(&kafka.Writer{
+			Balancer:     &kafka.Hash{}, // keep messages with the same key in the same partition
+			RequiredAcks: kafka.RequireAll,
+		}),
+		logger: logger,
+	}
+}
+
+func (r *retryTopics) l() kit.CLogger {
+	return r.logger().Cmp("kafka-retry")
+}
+
+// tiers returns number of retry tiers
+func (r *retryTopics) tiers() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.delays)
+}
+
+// topic returns topic name of the given level, level 0 is the original topic
+func (r *retryTopics) topic(topic string, level int) string {
+	if level == 0 {
+		return topic
+	}
+	return RetryTopicName(topic, r.delays[level-1])
+}
+
+// waitDue waits until the message is due to be handled
+// returns false if ctx is cancelled
+func (r *retryTopics) waitDue(ctx context.Context, m kafka.Message) bool {
+	notBefore, ok := retryNotBefore(m)
+	if !ok {
+		return true
+	}
+	wait := time.Until(notBefore)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// publish republishes the failed message to the retry topic of the next level
+func (r *retryTopics) publish(ctx context.Context, topic string, level int, m kafka.Message, cause error) error {
+	retryTopic := r.topic(topic, level+1)
+	l := r.l().C(ctx).Mth("publish").F(kit.KV{"topic": topic, "retryTopic": retryTopic, "key": string(m.Key)})
+
+	// original headers without retry ones
+	headers := make([]kafka.Header, 0, len(m.Headers)+4)
+	for _, h := range m.Headers {
+		switch h.Key {
+		case HeaderRetryAttempt, HeaderRetryTopic, HeaderRetryNotBefore, HeaderRetryError:
+		default:
+			headers = append(headers, h)
+		}
+	}
+	headers = append(headers,
+		kafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(level + 1))},
+		kafka.Header{Key: HeaderRetryTopic, Value: []byte(topic)},
+		kafka.Header{Key: HeaderRetryNotBefore, Value: []byte(strconv.FormatInt(time.Now().Add(r.delays[level]).UnixMilli(), 10))},
+	)
+	if cause != nil {
+		headers = append(headers, kafka.Header{Key: HeaderRetryError, Value: []byte(cause.Error())})
+	}
+
+	err := r.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   retryTopic,
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	})
+	if err != nil {
+		return ErrKafkaRetryPublish(ctx, err, retryTopic)
+	}
+
+	l.Dbg("sent")
+	return nil
+}
+
+// retryNotBefore retrieves due time from retry headers
+func retryNotBefore(m kafka.Message) (time.Time, bool) {
+	for _, h := range m.Headers {
+		if h.Key == HeaderRetryNotBefore {
+			ms, err := strconv.ParseInt(string(h.Value), 10, 64)
+			if err != nil {
+				return time.Time{}, false
+			}
+			return time.UnixMilli(ms), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// retryTopicConfigs returns configs of retry topics for the given topic
+// retry topics inherit partitions and replication factor, but not configs of the original topic
+func retryTopicConfigs(topic *TopicConfig, cfg *RetryTopicsConfig) []*TopicConfig {
+	if cfg == nil {
+		return nil
+	}
+	r := make([]*TopicConfig, 0, len(cfg.Delays))
+	for _, d := range cfg.Delays {
+		r = append(r, &TopicConfig{
+			Topic:         RetryTopicName(topic.Topic, d),
+			Partitions:    topic.Partitions,
+			ReplicaFactor: topic.ReplicaFactor,
+		})
+	}
+	return r
+}
@@ -0,0 +1,124 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type retryTopicsTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *retryTopicsTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestRetryTopicsSuite(t *testing.T) {
+	suite.Run(t, new(retryTopicsTestSuite))
+}
+
+func (s *retryTopicsTestSuite) Test_RetryTopicName() {
+	s.Equal("orders.retry.5s", RetryTopicName("orders", time.Second*5))
+	s.Equal("orders.retry.1m", RetryTopicName("orders", time.Minute))
+	s.Equal("orders.retry.10m", RetryTopicName("orders", time.Minute*10))
+	s.Equal("orders.retry.2h", RetryTopicName("orders", time.Hour*2))
+	s.Equal("orders.retry.90s", RetryTopicName("orders", time.Second*90))
+	s.Equal("orders.retry.500ms", RetryTopicName("orders", time.Millisecond*500))
+}
+
+func (s *retryTopicsTestSuite) Test_Tiers() {
+	var r *retryTopics
+	s.Equal(0, r.tiers())
+	s.Nil(newRetryTopics(s.logger, nil, nil))
+	s.Nil(newRetryTopics(s.logger, &RetryTopicsConfig{}, nil))
+
+	r = newRetryTopics(s.logger, &RetryTopicsConfig{Delays: []time.Duration{time.Second * 5, time.Minute}}, &kafkaClients{urls: []string{"localhost:9092"}})
+	s.Equal(2, r.tiers())
+	s.Equal("orders", r.topic("orders", 0))
+	s.Equal("orders.retry.5s", r.topic("orders", 1))
+	s.Equal("orders.retry.1m", r.topic("orders", 2))
+}
+
+func (s *retryTopicsTestSuite) Test_RetryTopicConfigs() {
+	topic := NewTopicCfgBuilder("orders").
+		WithPartitionNum(3).
+		WithReplicaFactor(2).
+		WithParams(TopicParam{Name: "cleanup.policy", Value: "compact"}).
+		Build()
+	cfgs := retryTopicConfigs(topic, &RetryTopicsConfig{Delays: []time.Duration{time.Second * 5, time.Minute}})
+	s.Len(cfgs, 2)
+	s.Equal("orders.retry.5s", cfgs[0].Topic)
+	s.Equal("orders.retry.1m", cfgs[1].Topic)
+	s.Equal(3, *cfgs[0].Partitions)
+	s.Equal(2, cfgs[0].ReplicaFactor)
+	s.Empty(cfgs[0].Configs)
+	s.Empty(retryTopicConfigs(topic, nil))
+}
+
+func (s *retryTopicsTestSuite) Test_WaitDue() {
+	r := &retryTopics{}
+	notBefore := func(t time.Time) kafka.Message {
+		return kafka.Message{Headers: []kafka.Header{{Key: HeaderRetryNotBefore, Value: []byte(strconv.FormatInt(t.UnixMilli(), 10))}}}
+	}
+
+	// no header
+	s.True(r.waitDue(s.Ctx, kafka.Message{}))
+
+	// already due
+	s.True(r.waitDue(s.Ctx, notBefore(time.Now().Add(-time.Second))))
+
+	// waits
+	start := time.Now()
+	s.True(r.waitDue(s.Ctx, notBefore(time.Now().Add(time.Millisecond*200))))
+	s.GreaterOrEqual(time.Since(start), time.Millisecond*150)
+
+	// cancelled
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Millisecond*100)
+	defer cancel()
+	s.False(r.waitDue(ctx, notBefore(time.Now().Add(time.Hour))))
+}
+
+func (s *retryTopicsTestSuite) Test_OnFailure_WhenDecodeErrOrLastTier_DLQ() {
+	dlqProducer := &typedTestProducer{}
+	retry := newRetryTopics(s.logger, &RetryTopicsConfig{Delays: []time.Duration{time.Second}}, &kafkaClients{urls: []string{"localhost:9092"}})
+	sub := newSubscriberManualCommitStrategy(s.logger, nil, &kafka.ReaderConfig{}, &dispatcher{topic: "topic"}, nil, dlqProducer, retry, nil, nil, 1).(*subscriberManualCommit)
+	m := kafka.Message{Key: []byte("key"), Value: []byte("value")}
+
+	// decode error skips retry topics
+	s.True(sub.onFailure(s.Ctx, "topic", 0, m, ErrKafkaMsgUnmarshalPayload(s.Ctx, errors.New("invalid"))))
+	s.Len(dlqProducer.messages, 1)
+
+	// the last tier failed
+	s.True(sub.onFailure(s.Ctx, "topic", 1, m, errors.New("failed")))
+	s.Len(dlqProducer.messages, 2)
+	s.Equal("topic", dlqProducer.messages[1].Payload.(*DLQMessage).Topic)
+}
+
+func (s *retryTopicsTestSuite) Test_HandleWithRetry_WhenRetryTopics_SingleAttempt() {
+	calls := 0
+	retry := newRetryTopics(s.logger, &RetryTopicsConfig{Delays: []time.Duration{time.Second}}, &kafkaClients{urls: []string{"localhost:9092"}})
+	sub := newSubscriberManualCommitStrategy(s.logger, nil, &kafka.ReaderConfig{}, &dispatcher{topic: "topic", handlers: []HandlerFn{
+		func(payload []byte) error {
+			calls++
+			return errors.New("failed")
+		},
+	}}, &SubscriberManualCommitConfig{HandleMessageMaxRetryCount: 3}, nil, retry, nil, nil, 1).(*subscriberManualCommit)
+	s.Error(sub.handleWithRetry(s.Ctx, "topic", kafka.Message{Key: []byte("key"), Value: []byte("value")}))
+	s.Equal(1, calls)
+}
+
+func (s *retryTopicsTestSuite) Test_Builder() {
+	s.AssertAppErr(NewSubscriberCfgBuilder().RetryTopics().Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+	s.AssertAppErr(NewSubscriberCfgBuilder().RetryTopics(0).Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+	s.AssertAppErr(NewSubscriberCfgBuilder().CommitInterval(time.Second).RetryTopics(time.Second).Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+	s.NoError(NewSubscriberCfgBuilder().RetryTopics(time.Second*5, time.Minute).Validate(s.Ctx))
+}
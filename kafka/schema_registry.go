@@ -0,0 +1,159 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	SchemaTypeAvro     = "AVRO"     // SchemaTypeAvro Avro schema (JSON)
+	SchemaTypeProtobuf = "PROTOBUF" // SchemaTypeProtobuf protobuf schema (.proto text)
+)
+
+// Schema registered schema
+type Schema struct {
+	Id      int    `json:"id"`      // Id global schema id, it's carried by messages in the wire format
+	Subject string `json:"subject"` // Subject subject the schema is registered under (<topic>-value)
+	Version int    `json:"version"` // Version version of the schema within the subject
+	Type    string `json:"type"`    // Type schema type (SchemaTypeAvro, SchemaTypeProtobuf)
+	Schema  string `json:"schema"`  // Schema schema definition
+}
+
+// SchemaRegistry schema registry client
+type SchemaRegistry interface {
+	// Register registers a schema under the subject, registering the same schema again returns the existing one
+	// a schema which is not backward compatible with the latest version of the subject is rejected
+	Register(ctx context.Context, subject, schemaType, schema string) (*Schema, error)
+	// GetById returns a schema by id
+	GetById(ctx context.Context, id int) (*Schema, error)
+	// Latest returns the latest version of the subject
+	Latest(ctx context.Context, subject string) (*Schema, error)
+}
+
+// memorySchemaRegistry keeps schemas in memory
+type memorySchemaRegistry struct {
+	sync.RWMutex
+	schemas  []*Schema            // schemas by id - 1
+	subjects map[string][]*Schema // versions by subject
+	persist  func(schemas []*Schema) error
+}
+
+// NewMemorySchemaRegistry creates an in-memory schema registry with backward compatibility checks
+func NewMemorySchemaRegistry() SchemaRegistry {
+	return &memorySchemaRegistry{
+		subjects: map[string][]*Schema{},
+	}
+}
+
+// NewFileSchemaRegistry creates a schema registry persisted to a JSON file with backward compatibility checks
+// schemas are loaded from the file if it exists, it might be committed along with the code to check compatibility in CI
+func NewFileSchemaRegistry(ctx context.Context, path string) (SchemaRegistry, error) {
+	r := &memorySchemaRegistry{
+		subjects: map[string][]*Schema{},
+	}
+
+	// load schemas
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, ErrKafkaSchemaRegistryFile(ctx, err, path)
+	}
+	if len(data) > 0 {
+		var schemas []*Schema
+		if err := json.Unmarshal(data, &schemas); err != nil {
+			return nil, ErrKafkaSchemaRegistryFile(ctx, err, path)
+		}
+		for _, s := range schemas {
+			r.schemas = append(r.schemas, s)
+			r.subjects[s.Subject] = append(r.subjects[s.Subject], s)
+		}
+	}
+
+	// write schemas to a temp file and rename it, so that the file is never left partially written
+	r.persist = func(schemas []*Schema) error {
+		data, err := json.MarshalIndent(schemas, "", "  ")
+		if err != nil {
+			return err
+		}
+		tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+		if err := os.WriteFile(tmp, data, 0o644); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	}
+
+	return r, nil
+}
+
+func (r *memorySchemaRegistry) Register(ctx context.Context, subject, schemaType, schema string) (*Schema, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	versions := r.subjects[subject]
+	for _, s := range versions {
+		if s.Type == schemaType && s.Schema == schema {
+			return s, nil
+		}
+	}
+
+	// check compatibility with the latest version
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		if latest.Type != schemaType {
+			return nil, ErrKafkaSchemaIncompatible(ctx, subject, "schema type changed")
+		}
+		if err := checkSchemaCompatibility(schemaType, latest.Schema, schema); err != nil {
+			return nil, ErrKafkaSchemaIncompatible(ctx, subject, err.Error())
+		}
+	}
+
+	s := &Schema{
+		Id:      len(r.schemas) + 1,
+		Subject: subject,
+		Version: len(versions) + 1,
+		Type:    schemaType,
+		Schema:  schema,
+	}
+	if r.persist != nil {
+		if err := r.persist(append(append([]*Schema{}, r.schemas...), s)); err != nil {
+			return nil, ErrKafkaSchemaRegister(ctx, err, subject)
+		}
+	}
+	r.schemas = append(r.schemas, s)
+	r.subjects[subject] = append(versions, s)
+	return s, nil
+}
+
+func (r *memorySchemaRegistry) GetById(ctx context.Context, id int) (*Schema, error) {
+	r.RLock()
+	defer r.RUnlock()
+	if id < 1 || id > len(r.schemas) {
+		return nil, ErrKafkaSchemaNotFound(ctx, kit.KV{"id": id})
+	}
+	return r.schemas[id-1], nil
+}
+
+func (r *memorySchemaRegistry) Latest(ctx context.Context, subject string) (*Schema, error) {
+	r.RLock()
+	defer r.RUnlock()
+	versions := r.subjects[subject]
+	if len(versions) == 0 {
+		return nil, ErrKafkaSchemaNotFound(ctx, kit.KV{"subject": subject})
+	}
+	return versions[len(versions)-1], nil
+}
+
+// checkSchemaCompatibility checks that a new schema can read data written with the old one (backward compatibility)
+func checkSchemaCompatibility(schemaType, latest, schema string) error {
+	switch schemaType {
+	case SchemaTypeAvro:
+		return checkAvroCompatibility(latest, schema)
+	case SchemaTypeProtobuf:
+		return checkProtobufCompatibility(latest, schema)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	// wireMagicByte the first byte of a value in the schema registry wire format
+	wireMagicByte = byte(0)
+	// wireHeaderLen magic byte plus schema id
+	wireHeaderLen = 5
+)
+
+// Serializer converts payloads to kafka values and back
+// it's applicable to headers mode only as in envelope mode a payload is a part of JSON envelope
+type Serializer interface {
+	// Prepare registers a schema of the topic in a schema registry, an incompatible schema is rejected
+	// it's called on producer startup
+	Prepare(ctx context.Context, topic string) error
+	// Serialize converts a payload to a kafka value
+	Serialize(ctx context.Context, topic string, payload any) ([]byte, error)
+	// Deserialize converts a kafka value to v, which must be a pointer
+	Deserialize(ctx context.Context, topic string, data []byte, v any) error
+}
+
+// subjectName returns a registry subject of topic values (topic name strategy)
+func subjectName(topic string) string {
+	return topic + "-value"
+}
+
+// jsonSerializer marshals payloads to JSON with no schema
+type jsonSerializer struct{}
+
+// NewJSONSerializer creates a serializer marshaling payloads to JSON (default behavior)
+func NewJSONSerializer() Serializer {
+	return &jsonSerializer{}
+}
+
+func (s *jsonSerializer) Prepare(ctx context.Context, topic string) error {
+	return nil
+}
+
+func (s *jsonSerializer) Serialize(ctx context.Context, topic string, payload any) ([]byte, error) {
+	r, err := rawPayload(payload)
+	if err != nil {
+		return nil, ErrKafkaSerialize(ctx, err, topic)
+	}
+	return r, nil
+}
+
+func (s *jsonSerializer) Deserialize(ctx context.Context, topic string, data []byte, v any) error {
+	if err := kit.Unmarshal(data, v); err != nil {
+		return ErrKafkaMsgUnmarshalPayload(ctx, err)
+	}
+	return nil
+}
+
+// schemaIds caches ids of schemas registered for topics
+type schemaIds struct {
+	sync.RWMutex
+	ids map[string]int
+}
+
+// get returns a registered schema id of the topic, the schema is registered by prepare if it's not registered yet
+func (s *schemaIds) get(ctx context.Context, topic string, prepare func(ctx context.Context, topic string) error) (int, error) {
+	s.RLock()
+	id, ok := s.ids[topic]
+	s.RUnlock()
+	if ok {
+		return id, nil
+	}
+	if err := prepare(ctx, topic); err != nil {
+		return 0, err
+	}
+	s.RLock()
+	defer s.RUnlock()
+	return s.ids[topic], nil
+}
+
+func (s *schemaIds) set(topic string, id int) {
+	s.Lock()
+	defer s.Unlock()
+	if s.ids == nil {
+		s.ids = map[string]int{}
+	}
+	s.ids[topic] = id
+}
+
+// wireEncode builds a value in the schema registry wire format: magic byte, schema id (big endian) and payload
+func wireEncode(schemaId int, payload []byte) []byte {
+	r := make([]byte, wireHeaderLen, wireHeaderLen+len(payload))
+	r[0] = wireMagicByte
+	binary.BigEndian.PutUint32(r[1:wireHeaderLen], uint32(schemaId))
+	return append(r, payload...)
+}
+
+// wireDecode parses a value in the schema registry wire format and returns schema id and payload
+func wireDecode(ctx context.Context, data []byte) (int, []byte, error) {
+	if len(data) < wireHeaderLen || data[0] != wireMagicByte {
+		return 0, nil, ErrKafkaWireFormatInvalid(ctx)
+	}
+	return int(binary.BigEndian.Uint32(data[1:wireHeaderLen])), data[wireHeaderLen:], nil
+}
@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// AvroCodec encodes and decodes Avro binary data
+// the kit ships no implementation, it allows plugging an Avro library (e.g. github.com/hamba/avro/v2) with no dependency of the kit on it
+type AvroCodec interface {
+	// Marshal encodes v by the schema
+	Marshal(schema string, v any) ([]byte, error)
+	// Unmarshal decodes data written with the writer schema into v, the writer schema is resolved to the reader schema v is described by
+	Unmarshal(writerSchema, readerSchema string, data []byte, v any) error
+}
+
+// avroSerializer serializes payloads with Avro in the schema registry wire format
+type avroSerializer struct {
+	sync.RWMutex
+	registry SchemaRegistry
+	codec    AvroCodec
+	schema   string
+	ids      schemaIds
+	writers  map[int]string // writers schemas by id
+}
+
+// NewAvroSerializer creates a serializer of payloads by the Avro schema
+// a value is magic byte, schema id and Avro encoded payload (schema registry wire format)
+// a value is decoded with the schema it's written with (taken from the registry by id) resolved to the serializer's schema
+func NewAvroSerializer(registry SchemaRegistry, codec AvroCodec, schema string) Serializer {
+	return &avroSerializer{
+		registry: registry,
+		codec:    codec,
+		schema:   schema,
+		writers:  map[int]string{},
+	}
+}
+
+func (s *avroSerializer) Prepare(ctx context.Context, topic string) error {
+	schema, err := s.registry.Register(ctx, subjectName(topic), SchemaTypeAvro, s.schema)
+	if err != nil {
+		return err
+	}
+	s.ids.set(topic, schema.Id)
+	return nil
+}
+
+func (s *avroSerializer) Serialize(ctx context.Context, topic string, payload any) ([]byte, error) {
+	id, err := s.ids.get(ctx, topic, s.Prepare)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.codec.Marshal(s.schema, payload)
+	if err != nil {
+		return nil, ErrKafkaSerialize(ctx, err, topic)
+	}
+	return wireEncode(id, data), nil
+}
+
+func (s *avroSerializer) Deserialize(ctx context.Context, topic string, data []byte, v any) error {
+	id, payload, err := wireDecode(ctx, data)
+	if err != nil {
+		return err
+	}
+	writer, err := s.writer(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.codec.Unmarshal(writer, s.schema, payload, v); err != nil {
+		return ErrKafkaMsgUnmarshalPayload(ctx, err)
+	}
+	return nil
+}
+
+// writer returns a writer schema by id, schemas are immutable, so they are cached
+func (s *avroSerializer) writer(ctx context.Context, id int) (string, error) {
+	s.RLock()
+	schema, ok := s.writers[id]
+	s.RUnlock()
+	if ok {
+		return schema, nil
+	}
+	r, err := s.registry.GetById(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.writers[id] = r.Schema
+	return r.Schema, nil
+}
+
+// avroPromotions types which a writer type might be read as
+var avroPromotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+// checkAvroCompatibility checks that the schema can read data written with the latest one
+// a field added must have a default, a field kept must keep its type or a type it's promoted to
+func checkAvroCompatibility(latest, schema string) error {
+	var writer, reader any
+	if err := json.Unmarshal([]byte(latest), &writer); err != nil {
+		return fmt.Errorf("invalid latest schema: %w", err)
+	}
+	if err := json.Unmarshal([]byte(schema), &reader); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	return avroReadable(writer, reader, "")
+}
+
+// avroReadable checks if data of the writer type can be read as the reader type
+func avroReadable(writer, reader any, path string) error {
+	wt, rt := avroTypeName(writer), avroTypeName(reader)
+
+	// a writer union must be readable branch by branch
+	if wt == "union" {
+		for _, w := range writer.([]any) {
+			if err := avroReadable(w, reader, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// a reader union must have a branch to read the writer type
+	if rt == "union" {
+		for _, r := range reader.([]any) {
+			if avroReadable(writer, r, path) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %s isn't in union", avroPath(path), wt)
+	}
+
+	if wt == rt {
+		switch wt {
+		case "record":
+			return avroRecordReadable(writer.(map[string]any), reader.(map[string]any), path)
+		case "array":
+			return avroReadable(writer.(map[string]any)["items"], reader.(map[string]any)["items"], path+"[]")
+		case "map":
+			return avroReadable(writer.(map[string]any)["values"], reader.(map[string]any)["values"], path+"{}")
+		case "enum":
+			readerSymbols, _ := reader.(map[string]any)["symbols"].([]any)
+			writerSymbols, _ := writer.(map[string]any)["symbols"].([]any)
+			symbols := map[any]bool{}
+			for _, s := range readerSymbols {
+				symbols[s] = true
+			}
+			_, hasDefault := reader.(map[string]any)["default"]
+			for _, s := range writerSymbols {
+				if !symbols[s] && !hasDefault {
+					return fmt.Errorf("%s: enum symbol %v removed", avroPath(path), s)
+				}
+			}
+			return nil
+		case "fixed":
+			if !reflect.DeepEqual(writer.(map[string]any)["size"], reader.(map[string]any)["size"]) {
+				return fmt.Errorf("%s: fixed size changed", avroPath(path))
+			}
+		}
+		return nil
+	}
+
+	for _, t := range avroPromotions[wt] {
+		if t == rt {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: type changed from %s to %s", avroPath(path), wt, rt)
+}
+
+func avroRecordReadable(writer, reader map[string]any, path string) error {
+	writerFields := map[string]any{}
+	for _, f := range avroFields(writer) {
+		writerFields[fmt.Sprint(f["name"])] = f["type"]
+	}
+	for _, f := range avroFields(reader) {
+		name := fmt.Sprint(f["name"])
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		wt, ok := writerFields[name]
+		if !ok {
+			if _, hasDefault := f["default"]; !hasDefault {
+				return fmt.Errorf("%s: field added with no default", fieldPath)
+			}
+			continue
+		}
+		if err := avroReadable(wt, f["type"], fieldPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func avroFields(record map[string]any) []map[string]any {
+	fields, _ := record["fields"].([]any)
+	r := make([]map[string]any, 0, len(fields))
+	for _, f := range fields {
+		if m, ok := f.(map[string]any); ok {
+			r = append(r, m)
+		}
+	}
+	return r
+}
+
+// avroTypeName returns a type name of a schema which is either a name, a union or a complex type
+func avroTypeName(t any) string {
+	switch v := t.(type) {
+	case string:
+		return v
+	case []any:
+		return "union"
+	case map[string]any:
+		return avroTypeName(v["type"])
+	}
+	return ""
+}
+
+func avroPath(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return path
+}
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// protobufSerializer serializes protobuf messages in the schema registry wire format
+type protobufSerializer struct {
+	registry SchemaRegistry
+	desc     protoreflect.MessageDescriptor
+	schema   string
+	indexes  []byte
+	ids      schemaIds
+}
+
+// NewProtobufSerializer creates a serializer of protobuf messages of the given type (e.g. generated in grpc or centrifugo/proto)
+// a value is magic byte, schema id, message indexes and the protobuf encoded message (schema registry wire format)
+// the schema registered is .proto text of the message's proto file
+func NewProtobufSerializer(registry SchemaRegistry, msg proto.Message) Serializer {
+	desc := msg.ProtoReflect().Descriptor()
+	return &protobufSerializer{
+		registry: registry,
+		desc:     desc,
+		schema:   protoFileText(desc.ParentFile()),
+		indexes:  protoMessageIndexes(desc),
+	}
+}
+
+func (s *protobufSerializer) Prepare(ctx context.Context, topic string) error {
+	schema, err := s.registry.Register(ctx, subjectName(topic), SchemaTypeProtobuf, s.schema)
+	if err != nil {
+		return err
+	}
+	s.ids.set(topic, schema.Id)
+	return nil
+}
+
+func (s *protobufSerializer) Serialize(ctx context.Context, topic string, payload any) ([]byte, error) {
+	msg, ok := payload.(proto.Message)
+	if !ok || msg.ProtoReflect().Descriptor().FullName() != s.desc.FullName() {
+		return nil, ErrKafkaSerialize(ctx, fmt.Errorf("payload must be %s", s.desc.FullName()), topic)
+	}
+	id, err := s.ids.get(ctx, topic, s.Prepare)
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, ErrKafkaSerialize(ctx, err, topic)
+	}
+	return wireEncode(id, append(append([]byte{}, s.indexes...), data...)), nil
+}
+
+func (s *protobufSerializer) Deserialize(ctx context.Context, topic string, data []byte, v any) error {
+	_, payload, err := wireDecode(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	// skip message indexes
+	count, n := binary.Varint(payload)
+	if n <= 0 {
+		return ErrKafkaWireFormatInvalid(ctx)
+	}
+	payload = payload[n:]
+	for i := int64(0); i < count; i++ {
+		if _, n = binary.Varint(payload); n <= 0 {
+			return ErrKafkaWireFormatInvalid(ctx)
+		}
+		payload = payload[n:]
+	}
+
+	msg, ok := protoTarget(v)
+	if !ok {
+		return ErrKafkaMsgUnmarshalPayload(ctx, fmt.Errorf("%T isn't a protobuf message", v))
+	}
+	if err := proto.Unmarshal(payload, msg); err != nil {
+		return ErrKafkaMsgUnmarshalPayload(ctx, err)
+	}
+	return nil
+}
+
+// protoTarget returns a message to unmarshal to
+// v is either a message or a pointer to a message pointer (e.g. a typed subscriber's payload), the latter is allocated if nil
+func protoTarget(v any) (proto.Message, bool) {
+	if msg, ok := v.(proto.Message); ok {
+		return msg, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return nil, false
+	}
+	if _, ok := rv.Elem().Interface().(proto.Message); !ok {
+		return nil, false
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Elem().Type().Elem()))
+	}
+	return rv.Elem().Interface().(proto.Message), true
+}
+
+// protoMessageIndexes encodes a path of the message in its file
+// the first message is encoded as a single zero, otherwise it's a zigzag varint count followed by zigzag varint indexes
+func protoMessageIndexes(desc protoreflect.MessageDescriptor) []byte {
+	var indexes []int
+	for d := protoreflect.Descriptor(desc); ; d = d.Parent() {
+		if _, ok := d.(protoreflect.MessageDescriptor); !ok {
+			break
+		}
+		indexes = append([]int{d.Index()}, indexes...)
+	}
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return []byte{0}
+	}
+	r := binary.AppendVarint(nil, int64(len(indexes)))
+	for _, i := range indexes {
+		r = binary.AppendVarint(r, int64(i))
+	}
+	return r
+}
+
+// protoKindGroups kinds which are wire compatible with each other
+var protoKindGroups = map[string]string{
+	"int32": "varint", "uint32": "varint", "int64": "varint", "uint64": "varint", "bool": "varint",
+	"sint32": "zigzag", "sint64": "zigzag",
+	"fixed32": "fixed32", "sfixed32": "fixed32",
+	"fixed64": "fixed64", "sfixed64": "fixed64",
+	"string": "bytes", "bytes": "bytes",
+}
+
+// checkProtobufCompatibility checks that messages of the schema can read data written with the latest one
+// fields might be added and removed, but a field number kept must keep a wire compatible type
+func checkProtobufCompatibility(latest, schema string) error {
+	l, err := parseProtoSchema(latest)
+	if err != nil {
+		return fmt.Errorf("invalid latest schema: %w", err)
+	}
+	s, err := parseProtoSchema(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+
+	messages := make(map[string]*protoSchemaMessage, len(s.Messages))
+	for _, m := range s.Messages {
+		messages[m.Name] = m
+	}
+	for _, lm := range l.Messages {
+		m, ok := messages[lm.Name]
+		if !ok {
+			continue
+		}
+		fields := make(map[int]*protoSchemaField, len(m.Fields))
+		for _, f := range m.Fields {
+			fields[f.Number] = f
+		}
+		for _, lf := range lm.Fields {
+			f, ok := fields[lf.Number]
+			if !ok {
+				continue
+			}
+			kindsCompatible := lf.Kind == f.Kind || (protoKindGroups[lf.Kind] != "" && protoKindGroups[lf.Kind] == protoKindGroups[f.Kind])
+			if !kindsCompatible || lf.Repeated != f.Repeated || lf.Type != f.Type {
+				return fmt.Errorf("%s: field %d (%s) type changed", lm.Name, lf.Number, lf.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type serializerTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *serializerTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestSerializerSuite(t *testing.T) {
+	suite.Run(t, new(serializerTestSuite))
+}
+
+// serializerTestAvroCodec fake codec encoding payloads to JSON
+type serializerTestAvroCodec struct {
+	writers []string
+	readers []string
+}
+
+func (c *serializerTestAvroCodec) Marshal(schema string, v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (c *serializerTestAvroCodec) Unmarshal(writerSchema, readerSchema string, data []byte, v any) error {
+	c.writers = append(c.writers, writerSchema)
+	c.readers = append(c.readers, readerSchema)
+	return json.Unmarshal(data, v)
+}
+
+const (
+	serializerTestAvroV1 = `{"type":"record","name":"User","fields":[{"name":"id","type":"string"},{"name":"age","type":"int"}]}`
+	serializerTestAvroV2 = `{"type":"record","name":"User","fields":[{"name":"id","type":"string"},{"name":"age","type":"long"},{"name":"email","type":["null","string"],"default":null}]}`
+)
+
+func (s *serializerTestSuite) protoSchema(fields string) string {
+	return "syntax = \"proto3\";\npackage test;\nmessage User {\n" + fields + "\n}\n"
+}
+
+func (s *serializerTestSuite) Test_WireFormat() {
+	data := wireEncode(258, []byte("payload"))
+	s.Equal([]byte{0, 0, 0, 1, 2}, data[:wireHeaderLen])
+	id, payload, err := wireDecode(s.Ctx, data)
+	s.NoError(err)
+	s.Equal(258, id)
+	s.Equal([]byte("payload"), payload)
+}
+
+func (s *serializerTestSuite) Test_WireFormat_WhenInvalid_Fail() {
+	_, _, err := wireDecode(s.Ctx, []byte{0, 1})
+	s.AssertAppErr(err, ErrCodeKafkaWireFormatInvalid)
+	_, _, err = wireDecode(s.Ctx, []byte(`{"id":"1"}`))
+	s.AssertAppErr(err, ErrCodeKafkaWireFormatInvalid)
+	s.True(isDecodeErr(err))
+}
+
+func (s *serializerTestSuite) Test_JSON() {
+	serializer := NewJSONSerializer()
+	s.NoError(serializer.Prepare(s.Ctx, "topic"))
+	data, err := serializer.Serialize(s.Ctx, "topic", &typedTestPayload{Id: "1"})
+	s.NoError(err)
+	s.JSONEq(`{"id":"1"}`, string(data))
+	var v *typedTestPayload
+	s.NoError(serializer.Deserialize(s.Ctx, "topic", data, &v))
+	s.Equal("1", v.Id)
+}
+
+func (s *serializerTestSuite) Test_Protobuf() {
+	registry := NewMemorySchemaRegistry()
+	serializer := NewProtobufSerializer(registry, &timestamppb.Timestamp{})
+	s.NoError(serializer.Prepare(s.Ctx, "topic"))
+
+	schema, err := registry.Latest(s.Ctx, "topic-value")
+	s.NoError(err)
+	s.Equal(SchemaTypeProtobuf, schema.Type)
+	s.Equal(`syntax = "proto3";
+
+package google.protobuf;
+
+message Timestamp {
+  int64 seconds = 1;
+  int32 nanos = 2;
+}
+`, schema.Schema)
+
+	ts := timestamppb.New(time.Unix(1700000000, 5))
+	data, err := serializer.Serialize(s.Ctx, "topic", ts)
+	s.NoError(err)
+
+	// magic byte, schema id and a single zero index of the first message
+	id, payload, err := wireDecode(s.Ctx, data)
+	s.NoError(err)
+	s.Equal(schema.Id, id)
+	s.Equal(byte(0), payload[0])
+
+	// decode to a message and to a pointer to a nil message
+	v := &timestamppb.Timestamp{}
+	s.NoError(serializer.Deserialize(s.Ctx, "topic", data, v))
+	s.True(proto.Equal(ts, v))
+	var p *timestamppb.Timestamp
+	s.NoError(serializer.Deserialize(s.Ctx, "topic", data, &p))
+	s.True(proto.Equal(ts, p))
+}
+
+func (s *serializerTestSuite) Test_Protobuf_WhenPayloadTypeInvalid_Fail() {
+	serializer := NewProtobufSerializer(NewMemorySchemaRegistry(), &timestamppb.Timestamp{})
+	_, err := serializer.Serialize(s.Ctx, "topic", durationpb.New(time.Second))
+	s.AssertAppErr(err, ErrCodeKafkaSerialize)
+	_, err = serializer.Serialize(s.Ctx, "topic", &typedTestPayload{})
+	s.AssertAppErr(err, ErrCodeKafkaSerialize)
+
+	data, err := serializer.Serialize(s.Ctx, "topic", timestamppb.Now())
+	s.NoError(err)
+	err = serializer.Deserialize(s.Ctx, "topic", data, &typedTestPayload{})
+	s.AssertAppErr(err, ErrCodeKafkaMsgUnmarshalPayload)
+}
+
+func (s *serializerTestSuite) Test_ProtobufCompatibility() {
+	v1 := s.protoSchema("string id = 1; int32 age = 2;")
+
+	// fields added, removed, renamed or changed to a wire compatible kind
+	s.NoError(checkProtobufCompatibility(v1, s.protoSchema("bytes userId = 1; string email = 3;")))
+	s.NoError(checkProtobufCompatibility(v1, s.protoSchema("int64 age = 2 [deprecated = true];")))
+
+	// a field number kept with another kind or cardinality
+	s.Error(checkProtobufCompatibility(v1, s.protoSchema("string age = 2;")))
+	s.Error(checkProtobufCompatibility(v1, s.protoSchema("repeated int32 age = 2;")))
+	s.Error(checkProtobufCompatibility(v1, s.protoSchema("sint32 age = 2;")))
+
+	// invalid schema
+	s.Error(checkProtobufCompatibility(v1, "message User {"))
+}
+
+func (s *serializerTestSuite) Test_ParseProtoSchema() {
+	schema, err := parseProtoSchema(`
+		syntax = "proto3";
+		package test;
+		import "google/protobuf/timestamp.proto";
+		option go_package = "test/pb";
+
+		// a user
+		message User {
+			enum Status { UNKNOWN = 0; ACTIVE = 1; }
+			message Address { string city = 1; }
+			string id = 1;
+			Status status = 2;
+			repeated Address addresses = 3;
+			map<string, Address> named = 4;
+			oneof contact {
+				string email = 5;
+				string phone = 6;
+			}
+			google.protobuf.Timestamp created = 7;
+			.test.Group group = 8;
+			reserved 9 to 11;
+			/* options */
+			optional int32 age = 12 [json_name = "years"];
+		}
+		message Group { repeated User.Address addresses = 1; }
+		service Users { rpc Get(User) returns (User); }`)
+	s.NoError(err)
+	s.Equal("test", schema.Package)
+	s.Len(schema.Messages, 3)
+	s.Equal("test.User", schema.Messages[0].Name)
+	s.Equal("test.User.Address", schema.Messages[1].Name)
+	s.Equal("test.Group", schema.Messages[2].Name)
+	s.Equal([]*protoSchemaField{
+		{Number: 1, Name: "id", Kind: "string"},
+		{Number: 2, Name: "status", Kind: "enum", Type: "test.User.Status"},
+		{Number: 3, Name: "addresses", Kind: "message", Repeated: true, Type: "test.User.Address"},
+		{Number: 4, Name: "named", Kind: "map", Repeated: true, Type: "string,test.User.Address"},
+		{Number: 5, Name: "email", Kind: "string"},
+		{Number: 6, Name: "phone", Kind: "string"},
+		{Number: 7, Name: "created", Kind: "message", Type: "google.protobuf.Timestamp"},
+		{Number: 8, Name: "group", Kind: "message", Type: "test.Group"},
+		{Number: 12, Name: "age", Kind: "int32"},
+	}, schema.Messages[0].Fields)
+	s.Equal([]*protoSchemaField{{Number: 1, Name: "addresses", Kind: "message", Repeated: true, Type: "test.User.Address"}}, schema.Messages[2].Fields)
+
+	// printed text is parsed back
+	printed, err := parseProtoSchema(protoFileText((&timestamppb.Timestamp{}).ProtoReflect().Descriptor().ParentFile()))
+	s.NoError(err)
+	s.Equal([]*protoSchemaMessage{{Name: "google.protobuf.Timestamp", Fields: []*protoSchemaField{
+		{Number: 1, Name: "seconds", Kind: "int64"},
+		{Number: 2, Name: "nanos", Kind: "int32"},
+	}}}, printed.Messages)
+}
+
+func (s *serializerTestSuite) Test_AvroCompatibility() {
+	s.NoError(checkAvroCompatibility(serializerTestAvroV1, serializerTestAvroV2))
+	s.NoError(checkAvroCompatibility(serializerTestAvroV1, `{"type":"record","name":"User","fields":[{"name":"id","type":"string"}]}`))
+
+	// a field added with no default
+	s.Error(checkAvroCompatibility(serializerTestAvroV1, `{"type":"record","name":"User","fields":[{"name":"id","type":"string"},{"name":"age","type":"int"},{"name":"email","type":"string"}]}`))
+	// a type narrowed
+	s.Error(checkAvroCompatibility(serializerTestAvroV2, serializerTestAvroV1))
+	// an enum symbol removed
+	s.Error(checkAvroCompatibility(
+		`{"type":"enum","name":"Status","symbols":["ACTIVE","BLOCKED"]}`,
+		`{"type":"enum","name":"Status","symbols":["ACTIVE"]}`,
+	))
+	// nested types
+	s.NoError(checkAvroCompatibility(
+		`{"type":"record","name":"R","fields":[{"name":"tags","type":{"type":"array","items":"int"}}]}`,
+		`{"type":"record","name":"R","fields":[{"name":"tags","type":{"type":"array","items":"long"}}]}`,
+	))
+	s.Error(checkAvroCompatibility(
+		`{"type":"record","name":"R","fields":[{"name":"attrs","type":{"type":"map","values":"string"}}]}`,
+		`{"type":"record","name":"R","fields":[{"name":"attrs","type":{"type":"map","values":"int"}}]}`,
+	))
+}
+
+func (s *serializerTestSuite) Test_Avro() {
+	registry := NewMemorySchemaRegistry()
+	codec := &serializerTestAvroCodec{}
+	v1 := NewAvroSerializer(registry, codec, serializerTestAvroV1)
+	s.NoError(v1.Prepare(s.Ctx, "topic"))
+	data, err := v1.Serialize(s.Ctx, "topic", &typedTestPayload{Id: "1"})
+	s.NoError(err)
+
+	// a compatible schema is registered as the next version
+	v2 := NewAvroSerializer(registry, codec, serializerTestAvroV2)
+	s.NoError(v2.Prepare(s.Ctx, "topic"))
+	latest, err := registry.Latest(s.Ctx, "topic-value")
+	s.NoError(err)
+	s.Equal(2, latest.Version)
+
+	// a message is decoded with the schema it's written with resolved to the reader's schema
+	var v typedTestPayload
+	s.NoError(v2.Deserialize(s.Ctx, "topic", data, &v))
+	s.Equal("1", v.Id)
+	s.Equal([]string{serializerTestAvroV1}, codec.writers)
+	s.Equal([]string{serializerTestAvroV2}, codec.readers)
+}
+
+func (s *serializerTestSuite) Test_Avro_WhenSchemaIncompatible_Fail() {
+	registry := NewMemorySchemaRegistry()
+	s.NoError(NewAvroSerializer(registry, &serializerTestAvroCodec{}, serializerTestAvroV2).Prepare(s.Ctx, "topic"))
+	err := NewAvroSerializer(registry, &serializerTestAvroCodec{}, serializerTestAvroV1).Prepare(s.Ctx, "topic")
+	s.AssertAppErr(err, ErrCodeKafkaSchemaIncompatible)
+	err = NewProtobufSerializer(registry, &timestamppb.Timestamp{}).Prepare(s.Ctx, "topic")
+	s.AssertAppErr(err, ErrCodeKafkaSchemaIncompatible)
+}
+
+func (s *serializerTestSuite) Test_Avro_WhenSchemaNotFound_Fail() {
+	serializer := NewAvroSerializer(NewMemorySchemaRegistry(), &serializerTestAvroCodec{}, serializerTestAvroV1)
+	var v typedTestPayload
+	s.AssertAppErr(serializer.Deserialize(s.Ctx, "topic", wireEncode(10, []byte("{}")), &v), ErrCodeKafkaSchemaNotFound)
+}
+
+func (s *serializerTestSuite) Test_MemoryRegistry() {
+	registry := NewMemorySchemaRegistry()
+	r1, err := registry.Register(s.Ctx, "a-value", SchemaTypeAvro, serializerTestAvroV1)
+	s.NoError(err)
+	r2, err := registry.Register(s.Ctx, "b-value", SchemaTypeAvro, serializerTestAvroV1)
+	s.NoError(err)
+	s.NotEqual(r1.Id, r2.Id)
+
+	// the same schema isn't registered twice
+	r, err := registry.Register(s.Ctx, "a-value", SchemaTypeAvro, serializerTestAvroV1)
+	s.NoError(err)
+	s.Equal(r1, r)
+
+	r, err = registry.GetById(s.Ctx, r2.Id)
+	s.NoError(err)
+	s.Equal("b-value", r.Subject)
+	_, err = registry.GetById(s.Ctx, 100)
+	s.AssertAppErr(err, ErrCodeKafkaSchemaNotFound)
+	_, err = registry.Latest(s.Ctx, "c-value")
+	s.AssertAppErr(err, ErrCodeKafkaSchemaNotFound)
+}
+
+func (s *serializerTestSuite) Test_FileRegistry() {
+	path := filepath.Join(s.T().TempDir(), "schemas.json")
+	registry, err := NewFileSchemaRegistry(s.Ctx, path)
+	s.NoError(err)
+	_, err = registry.Register(s.Ctx, "topic-value", SchemaTypeAvro, serializerTestAvroV1)
+	s.NoError(err)
+	_, err = registry.Register(s.Ctx, "topic-value", SchemaTypeAvro, serializerTestAvroV2)
+	s.NoError(err)
+
+	// schemas are loaded and compatibility is checked against them
+	registry, err = NewFileSchemaRegistry(s.Ctx, path)
+	s.NoError(err)
+	latest, err := registry.Latest(s.Ctx, "topic-value")
+	s.NoError(err)
+	s.Equal(2, latest.Id)
+	s.Equal(serializerTestAvroV2, latest.Schema)
+	_, err = registry.Register(s.Ctx, "topic-value", SchemaTypeAvro, `{"type":"record","name":"User","fields":[{"name":"id","type":"int"}]}`)
+	s.AssertAppErr(err, ErrCodeKafkaSchemaIncompatible)
+}
+
+func (s *serializerTestSuite) Test_FileRegistry_WhenFileInvalid_Fail() {
+	path := filepath.Join(s.T().TempDir(), "schemas.json")
+	s.NoError(os.WriteFile(path, []byte("invalid"), 0o644))
+	_, err := NewFileSchemaRegistry(s.Ctx, path)
+	s.AssertAppErr(err, ErrCodeKafkaSchemaRegistryFile)
+}
+
+func (s *memoryTestSuite) Test_Serializer_Protobuf() {
+	topic := s.topic(1)
+	b := s.broker()
+	registry := NewMemorySchemaRegistry()
+
+	received := make(chan *timestamppb.Timestamp, 1)
+	s.NoError(AddTypedSubscriber(s.Ctx, b, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		Serializer(NewProtobufSerializer(registry, &timestamppb.Timestamp{})).
+		Build(), func(ctx context.Context, msg *timestamppb.Timestamp) error {
+		received <- msg
+		return nil
+	}))
+	producer, err := AddTypedProducer[*timestamppb.Timestamp](s.Ctx, b, topic, NewProducerCfgBuilder().
+		Serializer(NewProtobufSerializer(registry, &timestamppb.Timestamp{})).
+		Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	// the schema is registered on producer startup
+	_, err = registry.Latest(s.Ctx, subjectName(topic.Topic))
+	s.NoError(err)
+
+	ts := timestamppb.New(time.Unix(1700000000, 0))
+	s.NoError(producer.Send(s.Ctx, "key", ts))
+	select {
+	case msg := <-received:
+		s.True(proto.Equal(ts, msg))
+	case <-time.After(time.Second * 5):
+		s.Fail("message not received")
+	}
+}
+
+func (s *memoryTestSuite) Test_Serializer_MessageDescriptorDecode() {
+	topic := s.topic(1)
+	b := s.broker()
+	registry := NewMemorySchemaRegistry()
+	codec := &serializerTestAvroCodec{}
+
+	received := make(chan *typedTestPayload, 1)
+	s.NoError(b.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		Serializer(NewAvroSerializer(registry, codec, serializerTestAvroV1)).
+		Build(), func(ctx context.Context, m *MessageDescriptor) error {
+		v := &typedTestPayload{}
+		if err := m.Decode(ctx, v); err != nil {
+			return err
+		}
+		received <- v
+		return nil
+	}))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Serializer(NewAvroSerializer(registry, codec, serializerTestAvroV1)).Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.NoError(producer.Send(s.Ctx, "key", &typedTestPayload{Id: "1"}))
+	select {
+	case v := <-received:
+		s.Equal("1", v.Id)
+	case <-time.After(time.Second * 5):
+		s.Fail("message not received")
+	}
+}
+
+func (s *memoryTestSuite) Test_Serializer_WhenSchemaIncompatible_Fail() {
+	topic := s.topic(1)
+	b := s.broker()
+	registry := NewMemorySchemaRegistry()
+
+	_, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Serializer(NewAvroSerializer(registry, &serializerTestAvroCodec{}, serializerTestAvroV2)).Build())
+	s.NoError(err)
+	_, err = b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Serializer(NewAvroSerializer(registry, &serializerTestAvroCodec{}, serializerTestAvroV1)).Build())
+	s.AssertAppErr(err, ErrCodeKafkaSchemaIncompatible)
+}
+
+func (s *memoryTestSuite) Test_Serializer_WhenEnvelopeMode_Fail() {
+	topic := s.topic(1)
+	b := s.broker()
+	serializer := NewJSONSerializer()
+
+	_, err := b.AddProducer(s.Ctx, topic, &ProducerConfig{Mode: MessageModeEnvelope, Serializer: serializer})
+	s.AssertAppErr(err, ErrCodeKafkaSerializerModeInvalid)
+	err = b.AddMessageSubscriber(s.Ctx, topic, &SubscriberConfig{GroupId: "group", Serializer: serializer}, func(ctx context.Context, m *MessageDescriptor) error { return nil })
+	s.AssertAppErr(err, ErrCodeKafkaSerializerModeInvalid)
+	s.AssertAppErr(NewSubscriberCfgBuilder().Serializer(serializer).Mode(MessageModeEnvelope).Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+}
@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/mikhailbolshakov/kit"
@@ -14,29 +15,29 @@ const (
 )
 
 type subscriberStrategy interface {
-	start(ctx context.Context, topic string)
+	// start starts consuming, fetching is controlled by control
+	// ctx is cancelled on forcible stop, handlers and commits use it
+	start(ctx context.Context, topic string, control *subscriberControl)
 }
 
 type subscriber struct {
 	readerCfg *kafka.ReaderConfig
-	handlers  []HandlerFn
 	workers   int
 	logger    kit.CLoggerFunc
 	strategy  subscriberStrategy
+	control   *subscriberControl
 }
 
 func (s *subscriber) l() kit.CLogger {
 	return s.logger().Cmp("kafka-sub")
 }
 
-func newSubscriber(logger kit.CLoggerFunc, topic *TopicConfig, cfg *SubscriberConfig, urls []string, dialer *kafka.Dialer, handlers ...HandlerFn) *subscriber {
+func newSubscriber(logger kit.CLoggerFunc, topic *TopicConfig, cfg *SubscriberConfig, clients clients, dispatcher *dispatcher, metrics *metrics) *subscriber {
 
 	// setup reader
 	readerCfg := &kafka.ReaderConfig{
-		Brokers:     urls,
 		GroupID:     cfg.GroupId,
 		Topic:       topic.Topic,
-		Dialer:      dialer,
 		ErrorLogger: kafka.LoggerFunc(logger().Mth("subscriber").F(kit.KV{"topic": topic.Topic, "groupId": cfg.GroupId}).PrintfErr),
 	}
 	if cfg.CommitInterval != nil {
@@ -66,19 +67,24 @@ func newSubscriber(logger kit.CLoggerFunc, topic *TopicConfig, cfg *SubscriberCo
 	// subscriber
 	sub := &subscriber{
 		readerCfg: readerCfg,
-		handlers:  handlers,
 		workers:   subWorkersPerTopic,
 		logger:    logger,
+		control:   newSubscriberControl(),
 	}
 
 	if cfg.Workers != nil {
 		sub.workers = *cfg.Workers
 	}
 
-	if sub.manualCommit() {
-		sub.strategy = newSubscriberManualCommitStrategy(logger, readerCfg, handlers, cfg.ManualCommit, cfg.DLQProducer, sub.workers)
+	dedup := newDeduplicator(logger, cfg.Dedup, cfg.GroupId, cfg.Mode)
+
+	if dispatcher.batchHandler != nil {
+		sub.strategy = newSubscriberBatchStrategy(logger, clients, readerCfg, dispatcher, cfg.ManualCommit, cfg.DLQProducer, dedup, metrics, sub.workers, cfg.Batch)
+	} else if sub.manualCommit() {
+		retry := newRetryTopics(logger, cfg.RetryTopics, clients)
+		sub.strategy = newSubscriberManualCommitStrategy(logger, clients, readerCfg, dispatcher, cfg.ManualCommit, cfg.DLQProducer, retry, dedup, metrics, sub.workers)
 	} else {
-		sub.strategy = newSubscriberAutoCommitStrategy(logger, readerCfg, handlers, sub.workers)
+		sub.strategy = newSubscriberAutoCommitStrategy(logger, clients, readerCfg, dispatcher, dedup, metrics, sub.workers)
 	}
 
 	return sub
@@ -90,7 +96,80 @@ func (s *subscriber) manualCommit() bool {
 
 func (s *subscriber) start(ctx context.Context, topic string) {
 	s.l().C(ctx).Mth("start").F(kit.KV{"topic": topic}).Dbg()
-	s.strategy.start(ctx, topic)
+	s.control.init(ctx)
+	s.strategy.start(ctx, topic, s.control)
+}
+
+func (s *subscriber) pause(ctx context.Context) {
+	if s.control.pause() {
+		s.l().C(ctx).Mth("pause").F(kit.KV{"topic": s.readerCfg.Topic, "groupId": s.readerCfg.GroupID}).Inf("paused")
+	}
+}
+
+func (s *subscriber) resume(ctx context.Context) {
+	if s.control.resume() {
+		s.l().C(ctx).Mth("resume").F(kit.KV{"topic": s.readerCfg.Topic, "groupId": s.readerCfg.GroupID}).Inf("resumed")
+	}
+}
+
+// dispatcher passes consumed messages to handlers
+type dispatcher struct {
+	mode         string
+	serializer   Serializer
+	topic        string
+	handlers     []HandlerFn
+	msgHandlers  []MessageHandlerFn
+	batchHandler BatchHandlerFn
+}
+
+// describe builds a message descriptor which decodes its payload with the dispatcher's serializer
+func (d *dispatcher) describe(ctx context.Context, topic string, m kafka.Message) (context.Context, *MessageDescriptor, error) {
+	msgCtx, desc, err := describe(ctx, d.mode, topic, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	desc.serializer = d.serializer
+	return msgCtx, desc, nil
+}
+
+func (d *dispatcher) empty() bool {
+	return len(d.handlers) == 0 && len(d.msgHandlers) == 0 && d.batchHandler == nil
+}
+
+// accept checks if the message must be passed to handlers
+// in headers mode a key is optional as messages might be sent by non-kit producers
+func (d *dispatcher) accept(m kafka.Message) bool {
+	if d.mode == MessageModeHeaders {
+		return len(m.Value) != 0
+	}
+	return len(m.Value) != 0 && len(m.Key) != 0
 }
 
-func (s *subscriber) close() {}
+// dispatch runs handlers one by one
+// if failFast is set, it stops on the first error, otherwise all handlers are executed and errors are joined
+func (d *dispatcher) dispatch(ctx context.Context, m kafka.Message, failFast bool) error {
+	var errs []error
+	for _, h := range d.handlers {
+		if err := h(m.Value); err != nil {
+			if failFast {
+				return err
+			}
+			errs = append(errs, err)
+		}
+	}
+	if len(d.msgHandlers) > 0 {
+		msgCtx, desc, err := d.describe(ctx, d.topic, m)
+		if err != nil {
+			return err
+		}
+		for _, h := range d.msgHandlers {
+			if err := h(msgCtx, desc); err != nil {
+				if failFast {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -19,21 +19,30 @@ const (
 )
 
 type subscriberAutoCommit struct {
-	logger    kit.CLoggerFunc
-	readerCfg *kafka.ReaderConfig
-	handlers  []HandlerFn
-	workers   int
+	logger     kit.CLoggerFunc
+	clients    clients
+	readerCfg  *kafka.ReaderConfig
+	dispatcher *dispatcher
+	dedup      *deduplicator
+	metrics    *metrics
+	workers    int
 }
 
 func newSubscriberAutoCommitStrategy(logger kit.CLoggerFunc,
+	clients clients,
 	readerCfg *kafka.ReaderConfig,
-	handlers []HandlerFn,
+	dispatcher *dispatcher,
+	dedup *deduplicator,
+	metrics *metrics,
 	workers int) subscriberStrategy {
 	return &subscriberAutoCommit{
-		logger:    logger,
-		readerCfg: readerCfg,
-		handlers:  handlers,
-		workers:   workers,
+		logger:     logger,
+		clients:    clients,
+		readerCfg:  readerCfg,
+		dispatcher: dispatcher,
+		dedup:      dedup,
+		metrics:    metrics,
+		workers:    workers,
 	}
 }
 
@@ -41,10 +50,15 @@ func (s *subscriberAutoCommit) l() kit.CLogger {
 	return s.logger().Cmp("kafka-auto-sub")
 }
 
-func (s *subscriberAutoCommit) start(ctx context.Context, topic string) {
+func (s *subscriberAutoCommit) start(ctx context.Context, topic string, control *subscriberControl) {
 	s.l().C(ctx).Mth("start").F(kit.KV{"topic": topic}).Dbg()
 
-	reader := kafka.NewReader(*s.readerCfg)
+	// fetching has been stopped
+	if !control.begin() {
+		return
+	}
+
+	reader := s.clients.reader(*s.readerCfg)
 
 	// start goroutine to fetch messages
 	goroutine.New().
@@ -52,91 +66,129 @@ func (s *subscriberAutoCommit) start(ctx context.Context, topic string) {
 		WithRetry(goroutine.Unrestricted).
 		Go(ctx,
 			func() {
-
-				// close reader (may take some time)
-				defer func() { _ = reader.Close() }()
-
-				// run workers
-				workersChannels := make([]chan kafka.Message, s.workers)
-				for i := 0; i < s.workers; i++ {
-					workersChannels[i] = make(chan kafka.Message, workersChanCapacity)
-					s.subscriberWorker(ctx, topic, s.handlers, i, workersChannels[i])
-				}
-
-				// close all worker channels
-				defer kit.ForAll(workersChannels, func(c chan kafka.Message) { close(c) })
-
-				l := s.l().C(ctx).Mth("fetch").F(kit.KV{"topic": topic}).Dbg("started")
-				for {
-
-					// check if context is already cancelled
-					if ctx.Err() != nil {
-						l.Dbg("stopped")
-						return
-					}
-
-					// read message
-					m, err := reader.ReadMessage(ctx)
-					if err != nil {
-
-						// reader has been closed, restart
-						if stdErr.Is(err, io.EOF) || stdErr.Is(err, io.ErrUnexpectedEOF) {
-							l.Dbg("EOF -> restart")
-							time.AfterFunc(waitPeriodBeforeReaderRestart, func() { s.start(ctx, topic) })
-							return
-						}
-
-						s.l().Mth("fetch").F(kit.KV{"topic": topic}).E(ErrKafkaFetchMessage(err)).Err("fetch")
-						continue
-					}
-					l.TrcObj("%+v", m)
-
-					// send a message to the channel to be processed by workers
-					if len(m.Value) != 0 && len(m.Key) != 0 {
-						// send message to proper channel
-						workersChannels[s.chanIndexByKey(m.Key)] <- m
-					}
-				}
+				s.fetch(ctx, control, reader, topic)
+				// a panicked consumer is restarted, so it ends only when fetching returns
+				control.end()
 			},
 		)
 
 }
 
-func (s *subscriberAutoCommit) subscriberWorker(ctx context.Context, topic string, handlers []HandlerFn, workerTag int, receiverChan chan kafka.Message) {
+func (s *subscriberAutoCommit) fetch(ctx context.Context, control *subscriberControl, reader messageReader, topic string) {
+
+	// close reader (may take some time) after workers are done, it commits pending offsets
+	defer func() { _ = reader.Close() }()
+	defer s.metrics.trackLag(reader, topic, s.readerCfg.GroupID)()
+
+	// run workers
+	workers := &sync.WaitGroup{}
+	workersChannels := make([]chan kafka.Message, s.workers)
+	for i := 0; i < s.workers; i++ {
+		workersChannels[i] = make(chan kafka.Message, workersChanCapacity)
+		workers.Add(1)
+		s.subscriberWorker(ctx, topic, i, workersChannels[i], workers)
+	}
+
+	// close all worker channels and wait for workers to handle fetched messages
+	defer workers.Wait()
+	defer kit.ForAll(workersChannels, func(c chan kafka.Message) { close(c) })
+
+	l := s.l().C(ctx).Mth("fetch").F(kit.KV{"topic": topic}).Dbg("started")
+	for {
+
+		// wait while paused, leave if fetching stopped
+		fetchCtx, ok := control.fetching()
+		if !ok {
+			l.Dbg("stopped")
+			return
+		}
+
+		// read message
+		m, err := reader.ReadMessage(fetchCtx)
+		if err != nil {
+
+			// paused or stopped
+			if fetchCtx.Err() != nil {
+				continue
+			}
+
+			// reader has been closed, restart
+			if stdErr.Is(err, io.EOF) || stdErr.Is(err, io.ErrUnexpectedEOF) {
+				l.Dbg("EOF -> restart")
+				time.AfterFunc(waitPeriodBeforeReaderRestart, func() { s.start(ctx, topic, control) })
+				return
+			}
+
+			s.l().Mth("fetch").F(kit.KV{"topic": topic}).E(ErrKafkaFetchMessage(err)).Err("fetch")
+			continue
+		}
+		l.TrcObj("%+v", m)
+		s.metrics.consume(topic, s.readerCfg.GroupID)
+
+		// send a message to the channel to be processed by workers
+		if s.dispatcher.accept(m) {
+			// send message to proper channel
+			select {
+			case workersChannels[s.chanIndexByKey(m.Key)] <- m:
+			case <-ctx.Done():
+				l.Dbg("stopped")
+				return
+			}
+		}
+	}
+}
+
+func (s *subscriberAutoCommit) subscriberWorker(ctx context.Context, topic string, workerTag int, receiverChan chan kafka.Message, workers *sync.WaitGroup) {
 
 	goroutine.New().
 		WithLogger(s.l().Mth("sub-worker")).
 		WithRetry(goroutine.Unrestricted).
 		Go(ctx,
 			func() {
-				l := s.l().Mth("worker").F(kit.KV{"tag": workerTag, "topic": topic}).Dbg("started")
-				for {
-					select {
-					case msg, ok := <-receiverChan:
-
-						if !ok {
-							l.Dbg("closed")
-							return
-						}
-
-						l.DbgF("key: %s", string(msg.Key)).TrcF("%s", string(msg.Value))
-
-						// run handler
-						for _, handler := range handlers {
-							if err := handler(msg.Value); err != nil {
-								s.l().C(ctx).Mth("handler").F(kit.KV{"topic": topic, "key": msg.Key}).E(err).St().Err()
-							}
-						}
-
-					case <-ctx.Done():
-						l.Dbg("stopped")
-						return
-					}
-				}
+				s.work(ctx, topic, workerTag, receiverChan)
+				// a panicked worker is restarted, so it's done only when work returns
+				workers.Done()
 			},
 		)
 }
 
+// work handles messages of the channel until it's closed
+func (s *subscriberAutoCommit) work(ctx context.Context, topic string, workerTag int, receiverChan chan kafka.Message) {
+	l := s.l().Mth("worker").F(kit.KV{"tag": workerTag, "topic": topic}).Dbg("started")
+	for {
+		select {
+		case msg, ok := <-receiverChan:
+
+			if !ok {
+				l.Dbg("closed")
+				return
+			}
+
+			l.DbgF("key: %s", string(msg.Key)).TrcF("%s", string(msg.Value))
+
+			// skip already processed message
+			id, processed := s.dedup.check(ctx, topic, msg)
+			if processed {
+				continue
+			}
+
+			// run handlers
+			started := time.Now()
+			err := s.dispatcher.dispatch(ctx, msg, false)
+			s.metrics.handle(topic, s.readerCfg.GroupID, time.Since(started), err)
+			if err != nil {
+				s.l().C(ctx).Mth("handler").F(kit.KV{"topic": topic, "key": msg.Key}).E(err).St().Err()
+			} else {
+				s.dedup.markProcessed(ctx, id)
+			}
+
+		case <-ctx.Done():
+			l.Dbg("stopped")
+			return
+		}
+	}
+}
+
 var (
 	fnv1aPool = &sync.Pool{
 		New: func() interface{} {
@@ -0,0 +1,357 @@
+package kafka
+
+import (
+	"context"
+	stdErr "errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	batchMaxItems = 100
+	batchInterval = time.Second
+)
+
+// BatchHandlerFn handler function receiving a batch of messages of a single partition ordered by offset
+// ctx carries a new request context, request contexts restored from messages are available in descriptors
+// an error fails the whole batch, return *BatchError to fail particular messages only
+type BatchHandlerFn func(ctx context.Context, messages []*MessageDescriptor) error
+
+// BatchError reports messages of a batch failed to be handled, the rest of the batch is considered handled
+// failed messages are sent to DLQ and the batch is committed
+type BatchError struct {
+	Failed map[int]error // Failed errors by index of a message in the batch
+}
+
+// NewBatchError creates an empty batch error
+func NewBatchError() *BatchError {
+	return &BatchError{Failed: map[int]error{}}
+}
+
+// Fail marks a message of the batch failed
+func (e *BatchError) Fail(idx int, err error) *BatchError {
+	e.Failed[idx] = err
+	return e
+}
+
+// Empty returns true if no message failed
+func (e *BatchError) Empty() bool {
+	return len(e.Failed) == 0
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch: %d messages failed", len(e.Failed))
+}
+
+// validateBatchSubscriber checks a subscriber config is applicable to batch mode
+func validateBatchSubscriber(ctx context.Context, cfg *SubscriberConfig) error {
+	if cfg.CommitInterval != nil && *cfg.CommitInterval > 0 {
+		return ErrKafkaSubscriberConfigInvalid(ctx, "batch mode requires manual commit")
+	}
+	if cfg.RetryTopics != nil {
+		return ErrKafkaSubscriberConfigInvalid(ctx, "retry topics not supported in batch mode")
+	}
+	return nil
+}
+
+// subscriberBatch collects messages into batches per partition
+// a batch is handled when either max items are collected or interval expires and then the highest offset is committed
+// it shares commit, DLQ and retry settings with manual commit strategy
+type subscriberBatch struct {
+	*subscriberManualCommit
+	opt *batch.Options
+}
+
+func newSubscriberBatchStrategy(logger kit.CLoggerFunc,
+	clients clients,
+	readerCfg *kafka.ReaderConfig,
+	dispatcher *dispatcher,
+	manualCommitCfg *SubscriberManualCommitConfig,
+	dlqProducer Producer,
+	dedup *deduplicator,
+	metrics *metrics,
+	workers int,
+	opt *batch.Options) subscriberStrategy {
+
+	o := batch.Options{}
+	if opt != nil {
+		o = *opt
+	}
+	if o.MaxItems <= 0 {
+		o.MaxItems = batchMaxItems
+	}
+	if o.Interval <= 0 {
+		o.Interval = batchInterval
+	}
+	if o.MaxCapacity <= 0 {
+		o.MaxCapacity = workersChanCapacity
+	}
+
+	return &subscriberBatch{
+		subscriberManualCommit: newSubscriberManualCommitStrategy(logger, clients, readerCfg, dispatcher, manualCommitCfg, dlqProducer, nil, dedup, metrics, workers).(*subscriberManualCommit),
+		opt:                    &o,
+	}
+}
+
+func (s *subscriberBatch) l() kit.CLogger {
+	return s.logger().Cmp("kafka-batch-sub")
+}
+
+func (s *subscriberBatch) start(ctx context.Context, topic string, control *subscriberControl) {
+	s.l().C(ctx).Mth("start").F(kit.KV{"topic": topic}).Dbg()
+	s.consume(ctx, control, topic)
+}
+
+func (s *subscriberBatch) consume(ctx context.Context, control *subscriberControl, topic string) {
+	// fetching has been stopped
+	if !control.begin() {
+		return
+	}
+
+	reader := s.clients.reader(*s.readerCfg)
+
+	// start goroutine to fetch messages
+	goroutine.New().
+		WithLogger(s.l().Mth("fetch")).
+		WithRetry(goroutine.Unrestricted).
+		Go(ctx,
+			func() {
+				s.fetch(ctx, control, reader, topic)
+				// a panicked consumer is restarted, so it ends only when fetching returns
+				control.end()
+			},
+		)
+}
+
+func (s *subscriberBatch) fetch(ctx context.Context, control *subscriberControl, reader messageReader, topic string) {
+
+	// close reader (may take some time) after workers are done, so that collected batches are committed
+	defer func() { _ = reader.Close() }()
+	defer s.metrics.trackLag(reader, topic, s.readerCfg.GroupID)()
+
+	// run workers, all messages of a partition go to the same worker
+	workers := &sync.WaitGroup{}
+	workersChannels := make([]chan kafka.Message, s.workers)
+	for i := 0; i < s.workers; i++ {
+		workersChannels[i] = make(chan kafka.Message, s.opt.MaxCapacity)
+		workers.Add(1)
+		s.batchWorker(ctx, reader, topic, i, workersChannels[i], workers)
+	}
+
+	// close all worker channels and wait for workers to flush collected batches
+	defer workers.Wait()
+	defer kit.ForAll(workersChannels, func(c chan kafka.Message) { close(c) })
+
+	l := s.l().C(ctx).Mth("fetch").F(kit.KV{"topic": topic}).Dbg("started")
+	for {
+
+		// wait while paused, leave if fetching stopped
+		fetchCtx, ok := control.fetching()
+		if !ok {
+			l.Dbg("stopped")
+			return
+		}
+
+		// read message
+		m, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+
+			// paused or stopped
+			if fetchCtx.Err() != nil {
+				continue
+			}
+
+			// reader has been closed, restart
+			if stdErr.Is(err, io.EOF) || stdErr.Is(err, io.ErrUnexpectedEOF) {
+				l.Dbg("EOF -> restart")
+				time.AfterFunc(waitPeriodBeforeReaderRestart, func() { s.consume(ctx, control, topic) })
+				return
+			}
+
+			s.l().Mth("fetch").F(kit.KV{"topic": topic}).E(ErrKafkaFetchMessage(err)).Err("fetch")
+			continue
+		}
+
+		l.DbgF("key: %s", string(m.Key)).TrcF("%s", string(m.Value))
+		s.metrics.consume(topic, s.readerCfg.GroupID)
+
+		// send a message to the channel to be collected by workers
+		if s.dispatcher.accept(m) {
+			select {
+			case workersChannels[s.chanIndexByPartition(m.Partition)] <- m:
+			case <-ctx.Done():
+				l.Dbg("stopped")
+				return
+			}
+		}
+
+	}
+}
+
+func (s *subscriberBatch) batchWorker(ctx context.Context, reader messageReader, topic string, workerTag int, receiverChan chan kafka.Message, workers *sync.WaitGroup) {
+
+	goroutine.New().
+		WithLogger(s.l().Mth("batch-worker")).
+		WithRetry(goroutine.Unrestricted).
+		Go(ctx,
+			func() {
+				s.work(ctx, reader, topic, workerTag, receiverChan)
+				// a panicked worker is restarted, so it's done only when work returns
+				workers.Done()
+			},
+		)
+}
+
+// work collects messages of the channel into batches until it's closed
+func (s *subscriberBatch) work(ctx context.Context, reader messageReader, topic string, workerTag int, receiverChan chan kafka.Message) {
+	l := s.l().Mth("worker").F(kit.KV{"tag": workerTag, "topic": topic}).Dbg("started")
+
+	// collected messages by partition
+	// messages not flushed yet aren't committed, so they are redelivered after restart
+	batches := map[int][]kafka.Message{}
+
+	ticker := time.NewTicker(s.opt.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-receiverChan:
+
+			// fetching stopped, flush collected batches
+			if !ok {
+				for _, msgs := range batches {
+					s.flush(ctx, reader, topic, msgs)
+				}
+				l.Dbg("closed")
+				return
+			}
+
+			l.DbgF("key: %s", string(msg.Key)).TrcF("%s", string(msg.Value))
+
+			batches[msg.Partition] = append(batches[msg.Partition], msg)
+			if len(batches[msg.Partition]) >= s.opt.MaxItems {
+				s.flush(ctx, reader, topic, batches[msg.Partition])
+				delete(batches, msg.Partition)
+			}
+
+		// flush all partitions when interval expires
+		case <-ticker.C:
+			for partition, msgs := range batches {
+				s.flush(ctx, reader, topic, msgs)
+				delete(batches, partition)
+			}
+
+		case <-ctx.Done():
+			l.Dbg("stopped")
+			return
+		}
+	}
+}
+
+// flush handles a batch of a partition and commits the highest offset
+// failed messages are sent to DLQ, if a message cannot be sent, the batch isn't committed
+func (s *subscriberBatch) flush(ctx context.Context, reader messageReader, topic string, msgs []kafka.Message) {
+	l := s.l().C(ctx).Mth("flush").F(kit.KV{"topic": topic, "partition": msgs[0].Partition, "size": len(msgs)}).Dbg()
+
+	committable := true
+
+	// skip already processed messages, a message which cannot be decoded goes to DLQ immediately
+	pending := make([]kafka.Message, 0, len(msgs))
+	descriptors := make([]*MessageDescriptor, 0, len(msgs))
+	ids := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		id, processed := s.dedup.check(ctx, topic, m)
+		if processed {
+			l.DbgF("key: %s, duplicate skipped", string(m.Key))
+			continue
+		}
+		_, d, err := s.dispatcher.describe(ctx, topic, m)
+		if err != nil {
+			s.l().C(ctx).Mth("describe").F(kit.KV{"topic": topic, "key": m.Key}).E(err).St().Err()
+			committable = s.dlq(ctx, topic, m) && committable
+			continue
+		}
+		pending = append(pending, m)
+		descriptors = append(descriptors, d)
+		ids = append(ids, id)
+	}
+
+	// run handler
+	if len(descriptors) > 0 {
+		failed := s.handleBatchWithRetry(ctx, topic, descriptors)
+		for i, m := range pending {
+			if err, ok := failed[i]; ok {
+				s.l().C(ctx).Mth("handler").F(kit.KV{"topic": topic, "key": m.Key}).E(err).Err()
+				committable = s.dlq(ctx, topic, m) && committable
+				continue
+			}
+			s.dedup.markProcessed(ctx, ids[i])
+		}
+	}
+
+	// if DLQ isn't configured or failed, skip the batch without committing
+	if !committable {
+		l.Warn("not committed")
+		return
+	}
+
+	// the highest offset commits the whole batch
+	if err := s.commitWithRetry(ctx, topic, reader, msgs[len(msgs)-1]); err != nil {
+		s.metrics.commitErr(topic, s.readerCfg.GroupID)
+		s.l().C(ctx).Mth("commit").F(kit.KV{"topic": topic, "partition": msgs[0].Partition}).E(err).St().Err()
+	}
+}
+
+// handleBatchWithRetry runs batch handler and returns errors of failed messages by index
+// a batch failed as a whole is retried, messages failed particularly aren't retried as the rest of the batch is handled
+func (s *subscriberBatch) handleBatchWithRetry(ctx context.Context, topic string, msgs []*MessageDescriptor) map[int]error {
+	l := s.l().C(ctx).Mth("handle").F(kit.KV{"topic": topic})
+
+	batchCtx := kit.NewRequestCtx().WithNewRequestId().ToContext(ctx)
+
+	maxAttempts := s.manualCommitCfg.HandleMessageMaxRetryCount
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		started := time.Now()
+		err = s.dispatcher.batchHandler(batchCtx, msgs)
+		s.metrics.handle(topic, s.readerCfg.GroupID, time.Since(started), err)
+		if err == nil {
+			return nil
+		}
+
+		// particular messages failed
+		var batchErr *BatchError
+		if stdErr.As(err, &batchErr) {
+			if batchErr == nil {
+				return nil
+			}
+			return batchErr.Failed
+		}
+
+		l.E(err).St().ErrF("attempt %d failed", attempt+1)
+
+		if attempt < maxAttempts-1 {
+			s.metrics.retry(topic, s.readerCfg.GroupID)
+			// Exponential backoff: 100ms, 200ms, 400ms...
+			time.Sleep(time.Duration(s.manualCommitCfg.HandleMessageRetryBackoffStepMs*(1<<attempt)) * time.Millisecond)
+		}
+	}
+
+	// retries exceeded, the whole batch failed
+	failed := make(map[int]error, len(msgs))
+	for i := range msgs {
+		failed[i] = err
+	}
+	return failed
+}
@@ -0,0 +1,232 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit/batch"
+)
+
+// memoryTestBatchCollector collects handled batches
+type memoryTestBatchCollector struct {
+	sync.Mutex
+	batches  [][]*MessageDescriptor
+	attempts int
+	err      func(msgs []*MessageDescriptor) error
+}
+
+func (c *memoryTestBatchCollector) handler(ctx context.Context, msgs []*MessageDescriptor) error {
+	c.Lock()
+	defer c.Unlock()
+	c.attempts++
+	if c.err != nil {
+		if err := c.err(msgs); err != nil {
+			return err
+		}
+	}
+	c.batches = append(c.batches, msgs)
+	return nil
+}
+
+func (c *memoryTestBatchCollector) sizes() []int {
+	c.Lock()
+	defer c.Unlock()
+	var r []int
+	for _, b := range c.batches {
+		r = append(r, len(b))
+	}
+	return r
+}
+
+func (c *memoryTestBatchCollector) calls() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.attempts
+}
+
+// committed returns sum of committed offsets of the group
+func (s *memoryTestSuite) committed(group, topic string) int64 {
+	s.cluster().Lock()
+	defer s.cluster().Unlock()
+	g, ok := s.cluster().groups[memoryGroupKey{group: group, topic: topic}]
+	if !ok {
+		return 0
+	}
+	r := int64(0)
+	for _, offset := range g.committed {
+		r += offset
+	}
+	return r
+}
+
+func (s *memoryTestSuite) dlqRecords(b Broker, topic string) []*DLQRecord {
+	var records []*DLQRecord
+	_ = b.DLQ(topic).Read(s.Ctx, nil, func(ctx context.Context, r *DLQRecord) error {
+		records = append(records, r)
+		return nil
+	})
+	return records
+}
+
+func (s *memoryTestSuite) sendN(producer Producer, n int) {
+	for i := 0; i < n; i++ {
+		s.NoError(producer.Send(s.Ctx, fmt.Sprintf("key-%d", i), i))
+	}
+}
+
+func (s *memoryTestSuite) Test_Batch_FlushByMaxItems() {
+	topic := s.topic(1)
+	b := s.broker()
+
+	collector := &memoryTestBatchCollector{}
+	s.NoError(b.AddBatchSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		Batch(&batch.Options{MaxItems: 5, Interval: time.Hour}).
+		Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.sendN(producer, 10)
+
+	// batches are ordered by offset and the highest offset is committed
+	s.await(func() bool { return s.committed("group", topic.Topic) == 10 })
+	s.Equal([]int{5, 5}, collector.sizes())
+	for i, m := range append(collector.batches[0], collector.batches[1]...) {
+		s.Equal(int64(i), m.Offset)
+		s.Equal(fmt.Sprintf("key-%d", i), m.Key)
+		s.NotNil(m.Ctx)
+	}
+}
+
+func (s *memoryTestSuite) Test_Batch_FlushByInterval() {
+	topic := s.topic(2)
+	b := s.broker()
+
+	collector := &memoryTestBatchCollector{}
+	s.NoError(b.AddBatchSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		Workers(1).
+		Batch(&batch.Options{MaxItems: 100, Interval: time.Millisecond * 50}).
+		Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.sendN(producer, 6)
+
+	// a batch holds messages of a single partition only
+	s.await(func() bool { return s.committed("group", topic.Topic) == 6 })
+	total := 0
+	for _, msgs := range collector.batches {
+		for _, m := range msgs {
+			s.Equal(msgs[0].Partition, m.Partition)
+		}
+		total += len(msgs)
+	}
+	s.Equal(6, total)
+}
+
+func (s *memoryTestSuite) Test_Batch_PartialFailure_DLQ() {
+	topic := s.topic(1)
+	dlqTopic := s.topic(1)
+	b := s.broker()
+
+	dlqProducer, err := b.AddProducer(s.Ctx, dlqTopic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	collector := &memoryTestBatchCollector{
+		err: func(msgs []*MessageDescriptor) error {
+			return NewBatchError().Fail(1, errors.New("failed"))
+		},
+	}
+	s.NoError(b.AddBatchSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		DLQProducer(dlqProducer).
+		Batch(&batch.Options{MaxItems: 3, Interval: time.Hour}).
+		Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.sendN(producer, 3)
+
+	// only the failed message goes to DLQ, the batch isn't retried and is committed
+	s.await(func() bool { return s.committed("group", topic.Topic) == 3 })
+	s.Equal(1, collector.calls())
+	records := s.dlqRecords(b, dlqTopic.Topic)
+	s.Len(records, 1)
+	s.Equal("key-1", records[0].Key)
+	s.Equal(topic.Topic, records[0].Topic)
+}
+
+func (s *memoryTestSuite) Test_Batch_Failure_RetriedAndDLQ() {
+	topic := s.topic(1)
+	dlqTopic := s.topic(1)
+	b := s.broker()
+
+	dlqProducer, err := b.AddProducer(s.Ctx, dlqTopic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	collector := &memoryTestBatchCollector{
+		err: func(msgs []*MessageDescriptor) error { return errors.New("failed") },
+	}
+	s.NoError(b.AddBatchSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		DLQProducer(dlqProducer).
+		ManualCommitHandleMessageMaxRetryCount(2).
+		ManualCommitHandleMessageRetryBackoffStepMs(1).
+		ManualCommitMessageMaxRetryCount(3).
+		Batch(&batch.Options{MaxItems: 3, Interval: time.Hour}).
+		Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.sendN(producer, 3)
+
+	// the whole batch is retried and then goes to DLQ
+	s.await(func() bool { return s.committed("group", topic.Topic) == 3 })
+	s.Equal(2, collector.calls())
+	s.Len(s.dlqRecords(b, dlqTopic.Topic), 3)
+}
+
+func (s *memoryTestSuite) Test_Batch_WhenFailedWithNoDLQ_NotCommitted() {
+	topic := s.topic(1)
+	b := s.broker()
+
+	collector := &memoryTestBatchCollector{
+		err: func(msgs []*MessageDescriptor) error {
+			return NewBatchError().Fail(0, errors.New("failed"))
+		},
+	}
+	s.NoError(b.AddBatchSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		Batch(&batch.Options{MaxItems: 2, Interval: time.Hour}).
+		Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.sendN(producer, 2)
+
+	s.await(func() bool { return collector.calls() == 1 })
+	time.Sleep(time.Millisecond * 50)
+	s.Equal(int64(0), s.committed("group", topic.Topic))
+}
+
+func (s *memoryTestSuite) Test_Batch_WhenConfigInvalid_Fail() {
+	b := s.broker()
+	handler := (&memoryTestBatchCollector{}).handler
+
+	s.AssertAppErr(b.AddBatchSubscriber(s.Ctx, s.topic(1), NewSubscriberCfgBuilder().CommitInterval(time.Second).Build(), handler), ErrCodeKafkaSubscriberConfigInvalid)
+	s.AssertAppErr(b.AddBatchSubscriber(s.Ctx, s.topic(1), NewSubscriberCfgBuilder().RetryTopics(time.Second).Build(), handler), ErrCodeKafkaSubscriberConfigInvalid)
+	s.AssertAppErr(b.AddBatchSubscriber(s.Ctx, s.topic(1), NewSubscriberCfgBuilder().Build(), nil), ErrCodeKafkaSubNoHandlers)
+	s.AssertAppErr(NewSubscriberCfgBuilder().Batch(&batch.Options{MaxItems: -1}).Validate(s.Ctx), ErrCodeKafkaSubscriberConfigInvalid)
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
 )
 
 // SubscriberConfig specifies subscriber config params
@@ -21,6 +22,11 @@ type SubscriberConfig struct {
 	Logging          bool                          // if true subscriber logging enabled
 	ManualCommit     *SubscriberManualCommitConfig // configuration for manual commit behavior
 	DLQProducer      Producer                      // dead-letter queue producer for handling failed messages
+	Dedup            *DedupConfig                  // deduplication of consumed messages (default: disabled)
+	Mode             string                        // message mode MessageModeEnvelope or MessageModeHeaders (default: envelope)
+	RetryTopics      *RetryTopicsConfig            // delayed retries through retry topics (manual commit only, default: disabled)
+	Batch            *batch.Options                // batch sizing of batch subscribers (default: 100 messages or 1s)
+	Serializer       Serializer                    // serializer of payloads (headers mode only, default: JSON)
 }
 
 type SubscriberConfigBuilder interface {
@@ -51,6 +57,25 @@ type SubscriberConfigBuilder interface {
 	ManualCommitHandleMessageRetryBackoffStepMs(v int) SubscriberConfigBuilder
 	// DLQProducer sets a dead-letter queue producer for handling failed messages and returns the modified builder instance.
 	DLQProducer(p Producer) SubscriberConfigBuilder
+	// Dedup enables deduplication of consumed messages, a message is skipped if it's found in the store
+	// ids are marked as processed only after successful handling
+	Dedup(store DedupStore) SubscriberConfigBuilder
+	// DedupIdField specifies a payload field (dot separated path) to take a message id from (default: groupId:topic:partition:offset, the retry topic for retried messages)
+	DedupIdField(field string) SubscriberConfigBuilder
+	// Mode sets message mode MessageModeEnvelope or MessageModeHeaders (default: envelope)
+	// it must correspond to the producer's mode
+	Mode(mode string) SubscriberConfigBuilder
+	// RetryTopics enables delayed retries through retry topics <topic>.retry.<delay> (e.g. 5s, 1m, 10m)
+	// a failed message is republished to the next tier topic and handled again after the delay, only the final failure goes to DLQ
+	// in-process retries are disabled as they block the partition
+	RetryTopics(delays ...time.Duration) SubscriberConfigBuilder
+	// Batch sets batch sizing of batch subscribers (see AddBatchSubscriber)
+	// a batch of a partition is handled when either MaxItems messages are collected or Interval expires (default: 100 messages or 1s)
+	// MaxCapacity sets capacity of workers channels
+	Batch(opt *batch.Options) SubscriberConfigBuilder
+	// Serializer sets a serializer of payloads, it must correspond to the producer's one and switches the subscriber to headers mode
+	// typed subscribers and MessageDescriptor.Decode deserialize payloads with it
+	Serializer(s Serializer) SubscriberConfigBuilder
 	// Validate checks the current configuration for any errors or missing mandatory fields and returns an error if invalid.
 	Validate(ctx context.Context) error
 	// Build builds config
@@ -147,8 +172,75 @@ func (p *subscriberConfigBuilder) DLQProducer(v Producer) SubscriberConfigBuilde
 	return p
 }
 
+func (p *subscriberConfigBuilder) Dedup(store DedupStore) SubscriberConfigBuilder {
+	if p.cfg.Dedup == nil {
+		p.cfg.Dedup = &DedupConfig{}
+	}
+	p.cfg.Dedup.Store = store
+	return p
+}
+
+func (p *subscriberConfigBuilder) DedupIdField(field string) SubscriberConfigBuilder {
+	if p.cfg.Dedup == nil {
+		p.cfg.Dedup = &DedupConfig{}
+	}
+	p.cfg.Dedup.IdField = field
+	return p
+}
+
+func (p *subscriberConfigBuilder) Mode(mode string) SubscriberConfigBuilder {
+	p.cfg.Mode = mode
+	return p
+}
+
+func (p *subscriberConfigBuilder) RetryTopics(delays ...time.Duration) SubscriberConfigBuilder {
+	p.cfg.RetryTopics = &RetryTopicsConfig{Delays: delays}
+	return p
+}
+
+func (p *subscriberConfigBuilder) Batch(opt *batch.Options) SubscriberConfigBuilder {
+	p.cfg.Batch = opt
+	return p
+}
+
+func (p *subscriberConfigBuilder) Serializer(s Serializer) SubscriberConfigBuilder {
+	p.cfg.Serializer = s
+	p.cfg.Mode = MessageModeHeaders
+	return p
+}
+
 func (p *subscriberConfigBuilder) Validate(ctx context.Context) error {
 
+	// retry topics
+	if p.cfg.RetryTopics != nil {
+		if len(p.cfg.RetryTopics.Delays) == 0 {
+			return ErrKafkaSubscriberConfigInvalid(ctx, "retry topics delays must be specified")
+		}
+		for _, d := range p.cfg.RetryTopics.Delays {
+			if d <= 0 {
+				return ErrKafkaSubscriberConfigInvalid(ctx, "retry topics delays must be positive")
+			}
+		}
+	}
+
+	// batch
+	if p.cfg.Batch != nil && (p.cfg.Batch.MaxItems < 0 || p.cfg.Batch.Interval < 0 || p.cfg.Batch.MaxCapacity < 0) {
+		return ErrKafkaSubscriberConfigInvalid(ctx, "batch options must not be negative")
+	}
+
+	// mode
+	if !validMessageMode(p.cfg.Mode) {
+		return ErrKafkaSubscriberConfigInvalid(ctx, "invalid message mode")
+	}
+	if p.cfg.Serializer != nil && p.cfg.Mode != MessageModeHeaders {
+		return ErrKafkaSubscriberConfigInvalid(ctx, "serializer requires headers mode")
+	}
+
+	// dedup
+	if p.cfg.Dedup != nil && p.cfg.Dedup.Store == nil {
+		return ErrKafkaSubscriberConfigInvalid(ctx, "dedup store must be specified")
+	}
+
 	// auto commit
 	if p.cfg.CommitInterval != nil && *p.cfg.CommitInterval > 0 {
 		if p.cfg.ManualCommit != nil {
@@ -157,6 +249,9 @@ func (p *subscriberConfigBuilder) Validate(ctx context.Context) error {
 		if p.cfg.DLQProducer != nil {
 			return ErrKafkaSubscriberConfigInvalid(ctx, "dead-letter queue producer not suppoerted for auto commit")
 		}
+		if p.cfg.RetryTopics != nil {
+			return ErrKafkaSubscriberConfigInvalid(ctx, "retry topics not supported for auto commit")
+		}
 	} else {
 		if p.cfg.ManualCommit == nil {
 			p.cfg.ManualCommit = &SubscriberManualCommitConfig{}
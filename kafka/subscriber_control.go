@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	drainTimeout = time.Second * 30
+)
+
+// subscriberControl controls fetching of a subscriber
+// fetching might be paused and resumed, on draining fetching stops and running consumers are awaited
+type subscriberControl struct {
+	sync.Mutex
+	stopCtx   context.Context    // stopCtx cancelled when fetching stops
+	stopFn    context.CancelFunc // stopFn stops fetching
+	runCtx    context.Context    // runCtx cancelled on pause to interrupt the current fetch
+	runFn     context.CancelFunc // runFn interrupts the current fetch
+	resumed   chan struct{}      // resumed not nil when paused, closed on resume
+	stopped   bool               // stopped if true no consumers can be started
+	consumers sync.WaitGroup     // consumers running consumers
+}
+
+func newSubscriberControl() *subscriberControl {
+	return &subscriberControl{}
+}
+
+// init sets up fetching contexts, a subscriber paused before start doesn't fetch until resumed
+func (c *subscriberControl) init(ctx context.Context) {
+	c.Lock()
+	defer c.Unlock()
+	c.stopCtx, c.stopFn = context.WithCancel(ctx)
+	c.runCtx, c.runFn = context.WithCancel(c.stopCtx)
+	if c.resumed != nil {
+		c.runFn()
+	}
+}
+
+// begin registers a running consumer, returns false if fetching has been stopped
+func (c *subscriberControl) begin() bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.stopped {
+		return false
+	}
+	c.consumers.Add(1)
+	return true
+}
+
+// end unregisters a consumer
+func (c *subscriberControl) end() {
+	c.consumers.Done()
+}
+
+// fetching blocks while paused and returns a context for fetching which is cancelled on pause or stop
+// returns false if fetching has been stopped
+func (c *subscriberControl) fetching() (context.Context, bool) {
+	for {
+		c.Lock()
+		resumed, runCtx, stopCtx := c.resumed, c.runCtx, c.stopCtx
+		c.Unlock()
+
+		if resumed == nil {
+			return runCtx, stopCtx.Err() == nil
+		}
+
+		select {
+		case <-resumed:
+		case <-stopCtx.Done():
+			return nil, false
+		}
+	}
+}
+
+// stopping returns a context cancelled when fetching stops
+func (c *subscriberControl) stopping() context.Context {
+	c.Lock()
+	defer c.Unlock()
+	return c.stopCtx
+}
+
+// pause pauses fetching, returns false if already paused
+func (c *subscriberControl) pause() bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.resumed != nil {
+		return false
+	}
+	c.resumed = make(chan struct{})
+	if c.runFn != nil {
+		c.runFn()
+	}
+	return true
+}
+
+// resume resumes fetching, returns false if not paused
+func (c *subscriberControl) resume() bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.resumed == nil {
+		return false
+	}
+	close(c.resumed)
+	c.resumed = nil
+	if c.stopCtx != nil {
+		c.runCtx, c.runFn = context.WithCancel(c.stopCtx)
+	}
+	return true
+}
+
+func (c *subscriberControl) paused() bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.resumed != nil
+}
+
+// stop stops fetching, consumers hand over fetched messages to workers and finish once workers are done
+func (c *subscriberControl) stop() {
+	c.Lock()
+	defer c.Unlock()
+	c.stopped = true
+	if c.stopFn != nil {
+		c.stopFn()
+	}
+}
+
+// drainSubscribers stops fetching of all subscribers and waits for fetched messages to be handled and committed
+// returns false if the timeout expires or ctx is done before all subscribers are drained
+func drainSubscribers(ctx context.Context, subscribers map[subKey]*subscriber, timeout time.Duration) bool {
+	for _, s := range subscribers {
+		s.control.stop()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		for _, s := range subscribers {
+			s.control.consumers.Wait()
+		}
+		close(drained)
+	}()
+
+	if timeout <= 0 {
+		timeout = drainTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
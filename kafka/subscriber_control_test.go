@@ -0,0 +1,132 @@
+package kafka
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikhailbolshakov/kit/batch"
+)
+
+func (s *memoryTestSuite) Test_PauseResume() {
+	topic := s.topic(2)
+	b := s.broker()
+
+	collector := &memoryTestCollector{}
+	s.NoError(b.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().GroupId("group").Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	// a paused subscriber doesn't fetch
+	s.NoError(b.Pause(s.Ctx, topic.Topic, "group"))
+	s.NoError(b.Pause(s.Ctx, topic.Topic, "group"))
+	s.sendN(producer, 5)
+	time.Sleep(time.Millisecond * 100)
+	s.Equal(0, collector.len())
+	s.Equal(int64(0), s.committed("group", topic.Topic))
+
+	// messages are handled after resume
+	s.NoError(b.Resume(s.Ctx, topic.Topic, "group"))
+	s.await(func() bool { return s.committed("group", topic.Topic) == 5 })
+	s.Equal(5, collector.len())
+}
+
+func (s *memoryTestSuite) Test_PauseResume_WhenPausedBeforeStart() {
+	topic := s.topic(1)
+	b := s.broker()
+
+	collector := &memoryTestCollector{}
+	s.NoError(b.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().GroupId("group").Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Pause(s.Ctx, topic.Topic, "group"))
+	s.NoError(b.Start(s.Ctx))
+	defer b.Close(s.Ctx)
+
+	s.sendN(producer, 3)
+	time.Sleep(time.Millisecond * 100)
+	s.Equal(0, collector.len())
+
+	s.NoError(b.Resume(s.Ctx, topic.Topic, "group"))
+	s.await(func() bool { return collector.len() == 3 })
+}
+
+func (s *memoryTestSuite) Test_PauseResume_WhenSubscriberNotFound_Fail() {
+	b := s.broker()
+	s.AssertAppErr(b.Pause(s.Ctx, "topic", "group"), ErrCodeKafkaSubscriberNotFound)
+	s.AssertAppErr(b.Resume(s.Ctx, "topic", "group"), ErrCodeKafkaSubscriberNotFound)
+}
+
+func (s *memoryTestSuite) Test_Close_DrainsFetchedMessages() {
+	topic := s.topic(1)
+	b := s.broker()
+
+	var handled, started atomic.Int32
+	s.NoError(b.AddSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().GroupId("group").Workers(1).Build(), func(payload []byte) error {
+		started.Add(1)
+		time.Sleep(time.Millisecond * 20)
+		handled.Add(1)
+		return nil
+	}))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+
+	s.sendN(producer, 5)
+	s.await(func() bool { return started.Load() > 0 })
+
+	// in-flight and fetched messages are handled and committed before close returns
+	b.Close(s.Ctx)
+	s.Equal(int32(5), handled.Load())
+	s.Equal(int64(5), s.committed("group", topic.Topic))
+}
+
+func (s *memoryTestSuite) Test_Close_WhenDrainTimeout() {
+	s.brokerCfg.DrainTimeout = time.Millisecond * 50
+	topic := s.topic(1)
+	b := s.broker()
+
+	var started atomic.Int32
+	s.NoError(b.AddMessageSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().GroupId("group").Build(), func(ctx context.Context, m *MessageDescriptor) error {
+		started.Add(1)
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+
+	s.sendN(producer, 1)
+	s.await(func() bool { return started.Load() > 0 })
+
+	// a stuck handler doesn't block close longer than the drain timeout
+	now := time.Now()
+	b.Close(s.Ctx)
+	s.Less(time.Since(now), time.Second)
+	s.Equal(int64(0), s.committed("group", topic.Topic))
+}
+
+func (s *memoryTestSuite) Test_Close_FlushesCollectedBatches() {
+	topic := s.topic(1)
+	b := s.broker()
+
+	collector := &memoryTestBatchCollector{}
+	s.NoError(b.AddBatchSubscriber(s.Ctx, topic, NewSubscriberCfgBuilder().
+		GroupId("group").
+		Batch(&batch.Options{MaxItems: 100, Interval: time.Hour}).
+		Build(), collector.handler))
+	producer, err := b.AddProducer(s.Ctx, topic, NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	s.NoError(b.Start(s.Ctx))
+
+	s.sendN(producer, 3)
+	time.Sleep(time.Millisecond * 100)
+	s.Equal(0, collector.calls())
+
+	// a partial batch is flushed and committed on close
+	b.Close(s.Ctx)
+	s.Equal([]int{3}, collector.sizes())
+	s.Equal(int64(3), s.committed("group", topic.Topic))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	stdErr "errors"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/mikhailbolshakov/kit"
@@ -27,18 +28,26 @@ type SubscriberManualCommitConfig struct {
 
 type subscriberManualCommit struct {
 	logger          kit.CLoggerFunc
+	clients         clients
 	readerCfg       *kafka.ReaderConfig
-	handlers        []HandlerFn
+	dispatcher      *dispatcher
 	manualCommitCfg *SubscriberManualCommitConfig
 	dlqProducer     Producer
+	retry           *retryTopics
+	dedup           *deduplicator
+	metrics         *metrics
 	workers         int
 }
 
 func newSubscriberManualCommitStrategy(logger kit.CLoggerFunc,
+	clients clients,
 	readerCfg *kafka.ReaderConfig,
-	handlers []HandlerFn,
+	dispatcher *dispatcher,
 	manualCommitCfg *SubscriberManualCommitConfig,
 	dlqProducer Producer,
+	retry *retryTopics,
+	dedup *deduplicator,
+	metrics *metrics,
 	workers int) subscriberStrategy {
 
 	if manualCommitCfg == nil {
@@ -65,10 +74,14 @@ func newSubscriberManualCommitStrategy(logger kit.CLoggerFunc,
 
 	return &subscriberManualCommit{
 		logger:          logger,
+		clients:         clients,
 		readerCfg:       readerCfg,
-		handlers:        handlers,
+		dispatcher:      dispatcher,
 		manualCommitCfg: manualCommitCfg,
 		dlqProducer:     dlqProducer,
+		retry:           retry,
+		dedup:           dedup,
+		metrics:         metrics,
 		workers:         workers,
 	}
 }
@@ -77,10 +90,25 @@ func (s *subscriberManualCommit) l() kit.CLogger {
 	return s.logger().Cmp("kafka-manual-sub")
 }
 
-func (s *subscriberManualCommit) start(ctx context.Context, topic string) {
+func (s *subscriberManualCommit) start(ctx context.Context, topic string, control *subscriberControl) {
 	s.l().C(ctx).Mth("start").F(kit.KV{"topic": topic}).Dbg()
 
-	reader := kafka.NewReader(*s.readerCfg)
+	// consume the topic and its retry topics
+	for level := 0; level <= s.retry.tiers(); level++ {
+		s.consume(ctx, control, topic, level)
+	}
+}
+
+// consume fetches messages of the topic (level 0) or its retry topic (level > 0)
+func (s *subscriberManualCommit) consume(ctx context.Context, control *subscriberControl, topic string, level int) {
+	// fetching has been stopped
+	if !control.begin() {
+		return
+	}
+
+	readerCfg := *s.readerCfg
+	readerCfg.Topic = s.retry.topic(topic, level)
+	reader := s.clients.reader(readerCfg)
 
 	// start goroutine to fetch messages
 	goroutine.New().
@@ -88,105 +116,148 @@ func (s *subscriberManualCommit) start(ctx context.Context, topic string) {
 		WithRetry(goroutine.Unrestricted).
 		Go(ctx,
 			func() {
+				s.fetch(ctx, control, reader, readerCfg.Topic, topic, level)
+				// a panicked consumer is restarted, so it ends only when fetching returns
+				control.end()
+			},
+		)
 
-				// close reader (may take some time)
-				defer func() { _ = reader.Close() }()
+}
 
-				// run workers
-				workersChannels := make([]chan kafka.Message, s.workers)
-				for i := 0; i < s.workers; i++ {
-					workersChannels[i] = make(chan kafka.Message, workersChanCapacity)
-					s.subscriberWorker(ctx, reader, topic, i, workersChannels[i])
-				}
+func (s *subscriberManualCommit) fetch(ctx context.Context, control *subscriberControl, reader messageReader, readerTopic, topic string, level int) {
 
-				// close all worker channels
-				defer kit.ForAll(workersChannels, func(c chan kafka.Message) { close(c) })
+	// close reader (may take some time) after workers are done, so that processed messages are committed
+	defer func() { _ = reader.Close() }()
+	defer s.metrics.trackLag(reader, readerTopic, s.readerCfg.GroupID)()
 
-				l := s.l().C(ctx).Mth("fetch").F(kit.KV{"topic": topic}).Dbg("started")
-				for {
+	// run workers
+	workers := &sync.WaitGroup{}
+	workersChannels := make([]chan kafka.Message, s.workers)
+	for i := 0; i < s.workers; i++ {
+		workersChannels[i] = make(chan kafka.Message, workersChanCapacity)
+		workers.Add(1)
+		s.subscriberWorker(ctx, control, reader, topic, level, i, workersChannels[i], workers)
+	}
 
-					// check if context is already cancelled
-					if ctx.Err() != nil {
-						l.Dbg("stopped")
-						return
-					}
+	// close all worker channels and wait for workers to handle fetched messages
+	defer workers.Wait()
+	defer kit.ForAll(workersChannels, func(c chan kafka.Message) { close(c) })
 
-					// read message
-					m, err := reader.FetchMessage(ctx)
-					if err != nil {
+	l := s.l().C(ctx).Mth("fetch").F(kit.KV{"topic": readerTopic}).Dbg("started")
+	for {
 
-						// reader has been closed, restart
-						if stdErr.Is(err, io.EOF) || stdErr.Is(err, io.ErrUnexpectedEOF) {
-							l.Dbg("EOF -> restart")
-							time.AfterFunc(waitPeriodBeforeReaderRestart, func() { s.start(ctx, topic) })
-							return
-						}
+		// wait while paused, leave if fetching stopped
+		fetchCtx, ok := control.fetching()
+		if !ok {
+			l.Dbg("stopped")
+			return
+		}
 
-						s.l().Mth("fetch").F(kit.KV{"topic": topic}).E(ErrKafkaFetchMessage(err)).Err("fetch")
-						continue
-					}
+		// read message
+		m, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+
+			// paused or stopped
+			if fetchCtx.Err() != nil {
+				continue
+			}
 
-					l.DbgF("key: %s", string(m.Key)).TrcF("%s", string(m.Value))
+			// reader has been closed, restart
+			if stdErr.Is(err, io.EOF) || stdErr.Is(err, io.ErrUnexpectedEOF) {
+				l.Dbg("EOF -> restart")
+				time.AfterFunc(waitPeriodBeforeReaderRestart, func() { s.consume(ctx, control, topic, level) })
+				return
+			}
 
-					// send a message to the channel to be processed by workers
-					if len(m.Value) != 0 && len(m.Key) != 0 {
-						// send message to proper channel
-						workersChannels[s.chanIndexByPartition(m.Partition)] <- m
-					}
+			s.l().Mth("fetch").F(kit.KV{"topic": readerTopic}).E(ErrKafkaFetchMessage(err)).Err("fetch")
+			continue
+		}
 
-				}
-			},
-		)
+		l.DbgF("key: %s", string(m.Key)).TrcF("%s", string(m.Value))
+		s.metrics.consume(topic, s.readerCfg.GroupID)
+
+		// send a message to the channel to be processed by workers
+		if s.dispatcher.accept(m) {
+			// send message to proper channel
+			select {
+			case workersChannels[s.chanIndexByPartition(m.Partition)] <- m:
+			case <-ctx.Done():
+				l.Dbg("stopped")
+				return
+			}
+		}
 
+	}
 }
 
-func (s *subscriberManualCommit) subscriberWorker(ctx context.Context, reader *kafka.Reader, topic string, workerTag int, receiverChan chan kafka.Message) {
+func (s *subscriberManualCommit) subscriberWorker(ctx context.Context, control *subscriberControl, reader messageReader, topic string, level, workerTag int, receiverChan chan kafka.Message, workers *sync.WaitGroup) {
 
 	goroutine.New().
 		WithLogger(s.l().Mth("sub-worker")).
 		WithRetry(goroutine.Unrestricted).
 		Go(ctx,
 			func() {
-				l := s.l().Mth("worker").F(kit.KV{"tag": workerTag, "topic": topic}).Dbg("started")
-				for {
-					select {
-					case msg, ok := <-receiverChan:
-
-						if !ok {
-							l.Dbg("closed")
-							return
-						}
-
-						l.DbgF("key: %s", string(msg.Key)).TrcF("%s", string(msg.Value))
-
-						// run handler
-						if err := s.handleWithRetry(ctx, topic, msg); err != nil {
-							s.l().C(ctx).Mth("handler").F(kit.KV{"topic": topic, "key": msg.Key}).E(err).St().Err()
-
-							// try to send to DLQ
-							if !s.dlq(ctx, topic, msg) {
-								// if DLQ is not configured, just skip the message without committing
-								// otherwise commit the message and continue
-								continue
-							}
-
-						}
-
-						// commit with retry
-						if err := s.commitWithRetry(ctx, topic, reader, msg); err != nil {
-							s.l().C(ctx).Mth("commit").F(kit.KV{"topic": topic, "key": msg.Key}).E(err).St().Err()
-						}
-
-					case <-ctx.Done():
-						l.Dbg("stopped")
-						return
-					}
-				}
+				s.work(ctx, control, reader, topic, level, workerTag, receiverChan)
+				// a panicked worker is restarted, so it's done only when work returns
+				workers.Done()
 			},
 		)
 }
 
-func (s *subscriberManualCommit) commitWithRetry(ctx context.Context, topic string, reader *kafka.Reader, message kafka.Message) error {
+// work handles messages of the channel until it's closed
+func (s *subscriberManualCommit) work(ctx context.Context, control *subscriberControl, reader messageReader, topic string, level, workerTag int, receiverChan chan kafka.Message) {
+	l := s.l().Mth("worker").F(kit.KV{"tag": workerTag, "topic": topic, "level": level}).Dbg("started")
+	for {
+		select {
+		case msg, ok := <-receiverChan:
+
+			if !ok {
+				l.Dbg("closed")
+				return
+			}
+
+			l.DbgF("key: %s", string(msg.Key)).TrcF("%s", string(msg.Value))
+
+			// a retried message waits for its delay, it's redelivered later if fetching stops
+			if level > 0 && !s.retry.waitDue(control.stopping(), msg) {
+				l.Dbg("stopped")
+				return
+			}
+
+			// skip already processed message (e.g. redelivered after rebalance)
+			id, processed := s.dedup.check(ctx, topic, msg)
+
+			// run handler
+			if processed {
+				l.DbgF("key: %s, duplicate skipped", string(msg.Key))
+			} else if err := s.handleWithRetry(ctx, topic, msg); err != nil {
+				s.l().C(ctx).Mth("handler").F(kit.KV{"topic": topic, "key": msg.Key}).E(err).St().Err()
+
+				// try to send to the next retry topic or DLQ
+				if !s.onFailure(ctx, topic, level, msg, err) {
+					// if neither is configured, just skip the message without committing
+					// otherwise commit the message and continue
+					continue
+				}
+
+			} else {
+				s.dedup.markProcessed(ctx, id)
+			}
+
+			// commit with retry
+			if err := s.commitWithRetry(ctx, topic, reader, msg); err != nil {
+				s.metrics.commitErr(topic, s.readerCfg.GroupID)
+				s.l().C(ctx).Mth("commit").F(kit.KV{"topic": topic, "key": msg.Key}).E(err).St().Err()
+			}
+
+		case <-ctx.Done():
+			l.Dbg("stopped")
+			return
+		}
+	}
+}
+
+func (s *subscriberManualCommit) commitWithRetry(ctx context.Context, topic string, reader messageReader, message kafka.Message) error {
 	l := s.l().C(ctx).Mth("commit").F(kit.KV{"topic": topic})
 
 	for attempt := 0; attempt < s.manualCommitCfg.CommitMessageMaxRetryCount; attempt++ {
@@ -204,6 +275,7 @@ func (s *subscriberManualCommit) commitWithRetry(ctx context.Context, topic stri
 			return ErrKafkaManualCommitRetryCountExceeded(ctx)
 		}
 
+		return nil
 	}
 
 	return nil
@@ -213,20 +285,30 @@ func (s *subscriberManualCommit) handleWithRetry(ctx context.Context, topic stri
 	l := s.l().C(ctx).Mth("handle").F(kit.KV{"topic": topic})
 
 	handlerFn := func() error {
-		for _, handler := range s.handlers {
-			if err := handler(m.Value); err != nil {
-				return err
-			}
-		}
-		return nil
+		started := time.Now()
+		err := s.dispatcher.dispatch(ctx, m, true)
+		s.metrics.handle(topic, s.readerCfg.GroupID, time.Since(started), err)
+		return err
 	}
 
-	for attempt := 0; attempt < s.manualCommitCfg.HandleMessageMaxRetryCount; attempt++ {
+	// in-process retries block the partition, so they are disabled when retry topics are configured
+	maxAttempts := s.manualCommitCfg.HandleMessageMaxRetryCount
+	if s.retry != nil {
+		maxAttempts = 1
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
 		if err := handlerFn(); err != nil {
 
 			l.E(err).St().ErrF("attempt %d failed", attempt+1)
 
-			if attempt < s.manualCommitCfg.HandleMessageMaxRetryCount-1 {
+			// a message which cannot be decoded goes to DLQ immediately
+			if isDecodeErr(err) {
+				return err
+			}
+
+			if attempt < maxAttempts-1 {
+				s.metrics.retry(topic, s.readerCfg.GroupID)
 				// Exponential backoff: 100ms, 200ms, 400ms...
 				time.Sleep(time.Duration(s.manualCommitCfg.HandleMessageRetryBackoffStepMs*(1<<attempt)) * time.Millisecond)
 				continue
@@ -236,12 +318,28 @@ func (s *subscriberManualCommit) handleWithRetry(ctx context.Context, topic stri
 			return ErrKafkaHandleMessageManualCommitRetryCountExceeded(ctx)
 		}
 
+		return nil
 	}
 
 	return nil
 
 }
 
+// onFailure sends the failed message to the next retry topic if any, otherwise to DLQ
+// returns true if the message has been sent, so it can be committed
+func (s *subscriberManualCommit) onFailure(ctx context.Context, topic string, level int, m kafka.Message, cause error) bool {
+	// a message which cannot be decoded never succeeds
+	if level < s.retry.tiers() && !isDecodeErr(cause) {
+		if err := s.retry.publish(ctx, topic, level, m, cause); err != nil {
+			s.l().C(ctx).Mth("retry").F(kit.KV{"topic": topic, "key": m.Key}).E(err).St().Err()
+			return false
+		}
+		s.metrics.retry(topic, s.readerCfg.GroupID)
+		return true
+	}
+	return s.dlq(ctx, topic, m)
+}
+
 func (s *subscriberManualCommit) dlq(ctx context.Context, topic string, m kafka.Message) bool {
 	l := s.l().C(ctx).Mth("dlq").F(kit.KV{"topic": topic, "key": m.Key})
 
@@ -252,11 +350,13 @@ func (s *subscriberManualCommit) dlq(ctx context.Context, topic string, m kafka.
 	err := s.dlqProducer.Send(ctx, string(m.Key), &DLQMessage{
 		Topic:         topic,
 		FailedMessage: m.Value,
+		Headers:       headersToMap(m.Headers),
 	})
 	if err != nil {
 		l.E(err).St().Err()
 		return false
 	}
+	s.metrics.dlq(topic, s.readerCfg.GroupID)
 
 	l.Dbg("sent")
 	return true
@@ -8,7 +8,9 @@ type TopicConfig struct {
 	Topic string
 	// Partitions number of partitions
 	Partitions *int
-	// Configs config params
+	// ReplicaFactor replication factor (broker's default if not specified)
+	ReplicaFactor int
+	// Configs config params (e.g. retention.ms, cleanup.policy, min.insync.replicas)
 	Configs map[string]string
 }
 
@@ -21,6 +23,8 @@ type TopicParam struct {
 type TopicBuilder interface {
 	// WithPartitionNum setting num of partitions
 	WithPartitionNum(num int) TopicBuilder
+	// WithReplicaFactor setting replication factor
+	WithReplicaFactor(num int) TopicBuilder
 	// WithParams setting additional params
 	WithParams(params ...TopicParam) TopicBuilder
 	// Build builds config
@@ -45,6 +49,11 @@ func (t *topicConfigBuilder) WithPartitionNum(num int) TopicBuilder {
 	return t
 }
 
+func (t *topicConfigBuilder) WithReplicaFactor(num int) TopicBuilder {
+	t.cfg.ReplicaFactor = num
+	return t
+}
+
 func (t *topicConfigBuilder) WithParams(params ...TopicParam) TopicBuilder {
 	for _, p := range params {
 		t.cfg.Configs[p.Name] = p.Value
@@ -65,5 +74,9 @@ func getTopicCfg(t *TopicConfig) kafka.TopicConfig {
 	if t.Partitions != nil {
 		topicCfg.NumPartitions = *t.Partitions
 	}
+	if t.ReplicaFactor > 0 {
+		topicCfg.ReplicationFactor = t.ReplicaFactor
+	}
+	topicCfg.ConfigEntries = configEntries(t.Configs)
 	return topicCfg
 }
@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type topicTestSuite struct {
+	kit.Suite
+}
+
+func (s *topicTestSuite) SetupSuite() {
+	s.Suite.Init(func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) })
+}
+
+func TestTopicSuite(t *testing.T) {
+	suite.Run(t, new(topicTestSuite))
+}
+
+func (s *topicTestSuite) Test_GetTopicCfg_Defaults() {
+	cfg := getTopicCfg(NewTopicCfgBuilder("topic").Build())
+	s.Equal("topic", cfg.Topic)
+	s.Equal(-1, cfg.NumPartitions)
+	s.Equal(-1, cfg.ReplicationFactor)
+	s.Empty(cfg.ConfigEntries)
+}
+
+func (s *topicTestSuite) Test_GetTopicCfg_ConfigsAndReplication() {
+	cfg := getTopicCfg(NewTopicCfgBuilder("topic").
+		WithPartitionNum(3).
+		WithReplicaFactor(2).
+		WithParams(
+			TopicParam{Name: "retention.ms", Value: "60000"},
+			TopicParam{Name: "cleanup.policy", Value: "compact"},
+			TopicParam{Name: "min.insync.replicas", Value: "2"},
+		).
+		Build())
+	s.Equal(3, cfg.NumPartitions)
+	s.Equal(2, cfg.ReplicationFactor)
+	s.Equal([]kafka.ConfigEntry{
+		{ConfigName: "cleanup.policy", ConfigValue: "compact"},
+		{ConfigName: "min.insync.replicas", ConfigValue: "2"},
+		{ConfigName: "retention.ms", ConfigValue: "60000"},
+	}, cfg.ConfigEntries)
+}
+
+func (s *topicTestSuite) Test_ResponseErr() {
+	s.NoError(responseErr(nil))
+	s.NoError(responseErr(map[string]error{"t1": nil}))
+	err := responseErr(map[string]error{"t1": nil, "t2": errors.New("e2"), "t3": errors.New("e3")})
+	s.Error(err)
+	s.Equal("t2: e2; t3: e3", err.Error())
+}
+
+func (s *topicTestSuite) Test_Admin_WhenNotInitialized_Fail() {
+	admin := NewBroker(s.L).Admin()
+	_, err := admin.ListTopics(s.Ctx)
+	s.AssertAppErr(err, ErrCodeKafkaNotInitialized)
+	_, err = admin.ReconcileTopics(s.Ctx, true)
+	s.AssertAppErr(err, ErrCodeKafkaNotInitialized)
+	s.AssertAppErr(admin.DeleteTopics(s.Ctx, "topic"), ErrCodeKafkaNotInitialized)
+}
@@ -0,0 +1,119 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+// TypedHandlerFn handler function receiving a decoded payload and context with the request context restored from the message
+type TypedHandlerFn[T any] func(ctx context.Context, msg T) error
+
+// TypedProducer allows sending messages with a payload of the given type
+type TypedProducer[T any] interface {
+	// Send sends a message to broker
+	Send(ctx context.Context, key string, payload T) error
+	// SendMany sends bulk of messages to broker
+	// if a message has no request context specified, it's taken from ctx
+	SendMany(ctx context.Context, messages ...*MessageT[T]) error
+}
+
+type typedProducerImpl[T any] struct {
+	producer Producer
+}
+
+// NewTypedProducer wraps a producer to send messages with a payload of the given type
+func NewTypedProducer[T any](producer Producer) TypedProducer[T] {
+	return &typedProducerImpl[T]{
+		producer: producer,
+	}
+}
+
+func (p *typedProducerImpl[T]) Send(ctx context.Context, key string, payload T) error {
+	return p.producer.Send(ctx, key, payload)
+}
+
+func (p *typedProducerImpl[T]) SendMany(ctx context.Context, messages ...*MessageT[T]) error {
+	msgs := make([]*Message, 0, len(messages))
+	for _, m := range messages {
+		msgs = append(msgs, &Message{
+			Ctx:     m.Ctx,
+			Key:     m.Key,
+			Payload: m.Payload,
+			Headers: m.Headers,
+		})
+	}
+	return p.producer.SendMany(ctx, msgs...)
+}
+
+// AddTypedProducer adds a producer to the broker and wraps it to send messages with a payload of the given type
+func AddTypedProducer[T any](ctx context.Context, broker Broker, topic *TopicConfig, cfg *ProducerConfig) (TypedProducer[T], error) {
+	producer, err := broker.AddProducer(ctx, topic, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewTypedProducer[T](producer), nil
+}
+
+// AddTypedSubscriber adds a subscriber to the broker with handlers receiving a decoded payload
+// a payload is decoded once and passed to handlers one by one, a handler's error stops the chain
+// a message which cannot be decoded isn't retried and goes to the DLQ producer if it's configured
+func AddTypedSubscriber[T any](ctx context.Context, broker Broker, topic *TopicConfig, cfg *SubscriberConfig, handlers ...TypedHandlerFn[T]) error {
+	if len(handlers) == 0 {
+		return ErrKafkaSubNoHandlers(ctx)
+	}
+	return broker.AddMessageSubscriber(ctx, topic, cfg, typedHandler(handlers...))
+}
+
+// typedHandler converts typed handlers to a message handler
+func typedHandler[T any](handlers ...TypedHandlerFn[T]) MessageHandlerFn {
+	return func(ctx context.Context, m *MessageDescriptor) error {
+		payload, err := decodeMessage[T](ctx, m)
+		if err != nil {
+			return err
+		}
+		for _, h := range handlers {
+			if err := h(ctx, payload); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// decodeMessage decodes a message's payload with the subscriber's serializer if it's configured
+func decodeMessage[T any](ctx context.Context, m *MessageDescriptor) (T, error) {
+	if m.serializer == nil {
+		return decodePayload[T](ctx, m.mode, m.Payload)
+	}
+	var v T
+	err := m.serializer.Deserialize(ctx, m.Topic, m.Payload, &v)
+	return v, err
+}
+
+// decodePayload decodes a payload from JSON
+// in headers mode bytes and strings are taken as is, since a producer sends them raw
+func decodePayload[T any](ctx context.Context, mode string, payload []byte) (T, error) {
+	var v T
+	if mode == MessageModeHeaders {
+		switch p := any(&v).(type) {
+		case *[]byte:
+			*p = payload
+			return v, nil
+		case *string:
+			*p = string(payload)
+			return v, nil
+		}
+	}
+	if err := kit.Unmarshal(payload, &v); err != nil {
+		return v, ErrKafkaMsgUnmarshalPayload(ctx, err)
+	}
+	return v, nil
+}
+
+// isDecodeErr checks if a handler failed because a message cannot be decoded
+// such messages aren't retried as retries never succeed
+func isDecodeErr(err error) bool {
+	return kit.IsAppErrCode(err, ErrCodeKafkaDecodeMsgUnmarshal) || kit.IsAppErrCode(err, ErrCodeKafkaMsgUnmarshalPayload) ||
+		kit.IsAppErrCode(err, ErrCodeKafkaWireFormatInvalid)
+}
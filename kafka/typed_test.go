@@ -0,0 +1,156 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/suite"
+)
+
+type typedTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *typedTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestTypedSuite(t *testing.T) {
+	suite.Run(t, new(typedTestSuite))
+}
+
+type typedTestPayload struct {
+	Id string `json:"id"`
+}
+
+type typedTestProducer struct {
+	sync.Mutex
+	messages []*Message
+}
+
+func (p *typedTestProducer) Send(ctx context.Context, key string, payload interface{}) error {
+	return p.SendMany(ctx, &Message{Key: key, Payload: payload})
+}
+
+func (p *typedTestProducer) SendMany(ctx context.Context, messages ...*Message) error {
+	p.Lock()
+	defer p.Unlock()
+	p.messages = append(p.messages, messages...)
+	return nil
+}
+
+func (s *typedTestSuite) envelope(payload any) kafka.Message {
+	value, err := kit.Marshal(&Message{Ctx: kit.NewRequestCtx().WithNewRequestId(), Key: "key", Payload: payload})
+	s.NoError(err)
+	return kafka.Message{Key: []byte("key"), Value: value}
+}
+
+func (s *typedTestSuite) Test_TypedProducer() {
+	p := &typedTestProducer{}
+	tp := NewTypedProducer[*typedTestPayload](p)
+	s.NoError(tp.Send(s.Ctx, "key", &typedTestPayload{Id: "1"}))
+	s.NoError(tp.SendMany(s.Ctx, &MessageT[*typedTestPayload]{Key: "key", Payload: &typedTestPayload{Id: "2"}, Headers: map[string]string{"h": "v"}}))
+	s.Len(p.messages, 2)
+	s.Equal("1", p.messages[0].Payload.(*typedTestPayload).Id)
+	s.Equal("2", p.messages[1].Payload.(*typedTestPayload).Id)
+	s.Equal("v", p.messages[1].Headers["h"])
+}
+
+func (s *typedTestSuite) Test_TypedHandler_Envelope() {
+	var actual *typedTestPayload
+	var rCtx *kit.RequestContext
+	d := &dispatcher{topic: "topic", msgHandlers: []MessageHandlerFn{
+		typedHandler(func(ctx context.Context, msg *typedTestPayload) error {
+			actual = msg
+			rCtx, _ = kit.Request(ctx)
+			return nil
+		}),
+	}}
+	m := s.envelope(&typedTestPayload{Id: "1"})
+	s.NoError(d.dispatch(s.Ctx, m, true))
+	s.Equal("1", actual.Id)
+	s.NotEmpty(rCtx.GetRequestId())
+}
+
+func (s *typedTestSuite) Test_TypedHandler_Headers() {
+	var actual typedTestPayload
+	var raw string
+	d := &dispatcher{mode: MessageModeHeaders, topic: "topic", msgHandlers: []MessageHandlerFn{
+		typedHandler(func(ctx context.Context, msg typedTestPayload) error {
+			actual = msg
+			return nil
+		}),
+		typedHandler(func(ctx context.Context, msg string) error {
+			raw = msg
+			return nil
+		}),
+	}}
+	s.NoError(d.dispatch(s.Ctx, kafka.Message{Value: []byte(`{"id":"1"}`)}, true))
+	s.Equal("1", actual.Id)
+	s.Equal(`{"id":"1"}`, raw)
+}
+
+func (s *typedTestSuite) Test_TypedHandler_DecodeFailed() {
+	called := false
+	h := typedHandler(func(ctx context.Context, msg *typedTestPayload) error {
+		called = true
+		return nil
+	})
+	d := &dispatcher{topic: "topic", msgHandlers: []MessageHandlerFn{h}}
+
+	// invalid envelope
+	err := d.dispatch(s.Ctx, kafka.Message{Key: []byte("key"), Value: []byte("invalid")}, true)
+	s.AssertAppErr(err, ErrCodeKafkaDecodeMsgUnmarshal)
+	s.True(isDecodeErr(err))
+
+	// invalid payload
+	err = d.dispatch(s.Ctx, s.envelope("string instead of object"), true)
+	s.AssertAppErr(err, ErrCodeKafkaMsgUnmarshalPayload)
+	s.True(isDecodeErr(err))
+	s.False(called)
+
+	s.False(isDecodeErr(errors.New("another")))
+}
+
+func (s *typedTestSuite) Test_ManualCommit_WhenDecodeFailed_NoRetriesAndDLQ() {
+	calls := 0
+	dlqProducer := &typedTestProducer{}
+	sub := newSubscriberManualCommitStrategy(s.logger, nil, &kafka.ReaderConfig{}, &dispatcher{topic: "topic", msgHandlers: []MessageHandlerFn{
+		func(ctx context.Context, m *MessageDescriptor) error {
+			calls++
+			return nil
+		},
+		typedHandler(func(ctx context.Context, msg *typedTestPayload) error { return nil }),
+	}}, &SubscriberManualCommitConfig{HandleMessageMaxRetryCount: 3}, dlqProducer, nil, nil, nil, 1).(*subscriberManualCommit)
+
+	m := s.envelope("string instead of object")
+	err := sub.handleWithRetry(s.Ctx, "topic", m)
+	s.AssertAppErr(err, ErrCodeKafkaMsgUnmarshalPayload)
+	s.Equal(1, calls)
+
+	s.True(sub.dlq(s.Ctx, "topic", m))
+	s.Len(dlqProducer.messages, 1)
+	dlqMsg := dlqProducer.messages[0].Payload.(*DLQMessage)
+	s.Equal("topic", dlqMsg.Topic)
+	s.Equal(m.Value, dlqMsg.FailedMessage)
+}
+
+func (s *typedTestSuite) Test_ManualCommit_WhenHandlerFailed_Retried() {
+	calls := 0
+	sub := newSubscriberManualCommitStrategy(s.logger, nil, &kafka.ReaderConfig{}, &dispatcher{topic: "topic", msgHandlers: []MessageHandlerFn{
+		typedHandler(func(ctx context.Context, msg *typedTestPayload) error {
+			calls++
+			return errors.New("failed")
+		}),
+	}}, &SubscriberManualCommitConfig{HandleMessageMaxRetryCount: 3}, nil, nil, nil, nil, 1).(*subscriberManualCommit)
+
+	err := sub.handleWithRetry(s.Ctx, "topic", s.envelope(&typedTestPayload{Id: "1"}))
+	s.AssertAppErr(err, ErrCodeKafkaHandleMessageManualCommitRetryCountExceeded)
+	s.Equal(3, calls)
+}
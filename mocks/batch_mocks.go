@@ -7,6 +7,7 @@ package mocks
 import (
 	"context"
 
+	"github.com/mikhailbolshakov/kit/batch"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -38,9 +39,20 @@ func (_m *BatchWorker[T]) EXPECT() *BatchWorker_Expecter[T] {
 }
 
 // Close provides a mock function for the type BatchWorker
-func (_mock *BatchWorker[T]) Close(ctx context.Context) {
-	_mock.Called(ctx)
-	return
+func (_mock *BatchWorker[T]) Close(ctx context.Context) error {
+	ret := _mock.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Close")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = returnFunc(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
 }
 
 // BatchWorker_Close_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Close'
@@ -61,13 +73,13 @@ func (_c *BatchWorker_Close_Call[T]) Run(run func(ctx context.Context)) *BatchWo
 	return _c
 }
 
-func (_c *BatchWorker_Close_Call[T]) Return() *BatchWorker_Close_Call[T] {
-	_c.Call.Return()
+func (_c *BatchWorker_Close_Call[T]) Return(err error) *BatchWorker_Close_Call[T] {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *BatchWorker_Close_Call[T]) RunAndReturn(run func(ctx context.Context)) *BatchWorker_Close_Call[T] {
-	_c.Run(run)
+func (_c *BatchWorker_Close_Call[T]) RunAndReturn(run func(ctx context.Context) error) *BatchWorker_Close_Call[T] {
+	_c.Call.Return(run)
 	return _c
 }
 
@@ -105,6 +117,99 @@ func (_c *BatchWorker_Start_Call[T]) RunAndReturn(run func(ctx context.Context))
 	return _c
 }
 
+// TryWrite provides a mock function for the type BatchWorker
+func (_mock *BatchWorker[T]) TryWrite(ctx context.Context, item *T) error {
+	ret := _mock.Called(ctx, item)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TryWrite")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *T) error); ok {
+		r0 = returnFunc(ctx, item)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// BatchWorker_TryWrite_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TryWrite'
+type BatchWorker_TryWrite_Call[T any] struct {
+	*mock.Call
+}
+
+// TryWrite is a helper method to define mock.On call
+//   - ctx
+//   - item
+func (_e *BatchWorker_Expecter[T]) TryWrite(ctx interface{}, item interface{}) *BatchWorker_TryWrite_Call[T] {
+	return &BatchWorker_TryWrite_Call[T]{Call: _e.mock.On("TryWrite", ctx, item)}
+}
+
+func (_c *BatchWorker_TryWrite_Call[T]) Run(run func(ctx context.Context, item *T)) *BatchWorker_TryWrite_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*T))
+	})
+	return _c
+}
+
+func (_c *BatchWorker_TryWrite_Call[T]) Return(err error) *BatchWorker_TryWrite_Call[T] {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *BatchWorker_TryWrite_Call[T]) RunAndReturn(run func(ctx context.Context, item *T) error) *BatchWorker_TryWrite_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
+// WithFailureSink provides a mock function for the type BatchWorker
+func (_mock *BatchWorker[T]) WithFailureSink(sink batch.FailureSink[T]) batch.Worker[T] {
+	ret := _mock.Called(sink)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithFailureSink")
+	}
+
+	var r0 batch.Worker[T]
+	if returnFunc, ok := ret.Get(0).(func(batch.FailureSink[T]) batch.Worker[T]); ok {
+		r0 = returnFunc(sink)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(batch.Worker[T])
+		}
+	}
+	return r0
+}
+
+// BatchWorker_WithFailureSink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WithFailureSink'
+type BatchWorker_WithFailureSink_Call[T any] struct {
+	*mock.Call
+}
+
+// WithFailureSink is a helper method to define mock.On call
+//   - sink
+func (_e *BatchWorker_Expecter[T]) WithFailureSink(sink interface{}) *BatchWorker_WithFailureSink_Call[T] {
+	return &BatchWorker_WithFailureSink_Call[T]{Call: _e.mock.On("WithFailureSink", sink)}
+}
+
+func (_c *BatchWorker_WithFailureSink_Call[T]) Run(run func(sink batch.FailureSink[T])) *BatchWorker_WithFailureSink_Call[T] {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(batch.FailureSink[T]))
+	})
+	return _c
+}
+
+func (_c *BatchWorker_WithFailureSink_Call[T]) Return(worker batch.Worker[T]) *BatchWorker_WithFailureSink_Call[T] {
+	_c.Call.Return(worker)
+	return _c
+}
+
+func (_c *BatchWorker_WithFailureSink_Call[T]) RunAndReturn(run func(sink batch.FailureSink[T]) batch.Worker[T]) *BatchWorker_WithFailureSink_Call[T] {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Write provides a mock function for the type BatchWorker
 func (_mock *BatchWorker[T]) Write(ctx context.Context, item *T) {
 	_mock.Called(ctx, item)
@@ -166,6 +166,52 @@ func (_c *GoroutineErrGroup_Cmp_Call) RunAndReturn(run func(component string) go
 	return _c
 }
 
+// Ctx provides a mock function for the type GoroutineErrGroup
+func (_mock *GoroutineErrGroup) Ctx() context.Context {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ctx")
+	}
+
+	var r0 context.Context
+	if returnFunc, ok := ret.Get(0).(func() context.Context); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(context.Context)
+		}
+	}
+	return r0
+}
+
+// GoroutineErrGroup_Ctx_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Ctx'
+type GoroutineErrGroup_Ctx_Call struct {
+	*mock.Call
+}
+
+// Ctx is a helper method to define mock.On call
+func (_e *GoroutineErrGroup_Expecter) Ctx() *GoroutineErrGroup_Ctx_Call {
+	return &GoroutineErrGroup_Ctx_Call{Call: _e.mock.On("Ctx")}
+}
+
+func (_c *GoroutineErrGroup_Ctx_Call) Run(run func()) *GoroutineErrGroup_Ctx_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *GoroutineErrGroup_Ctx_Call) Return(context1 context.Context) *GoroutineErrGroup_Ctx_Call {
+	_c.Call.Return(context1)
+	return _c
+}
+
+func (_c *GoroutineErrGroup_Ctx_Call) RunAndReturn(run func() context.Context) *GoroutineErrGroup_Ctx_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Go provides a mock function for the type GoroutineErrGroup
 func (_mock *GoroutineErrGroup) Go(f func() error) {
 	_mock.Called(f)
@@ -247,6 +293,98 @@ func (_c *GoroutineErrGroup_Mth_Call) RunAndReturn(run func(method string) gorou
 	return _c
 }
 
+// SetLimit provides a mock function for the type GoroutineErrGroup
+func (_mock *GoroutineErrGroup) SetLimit(n int) goroutine.ErrGroup {
+	ret := _mock.Called(n)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetLimit")
+	}
+
+	var r0 goroutine.ErrGroup
+	if returnFunc, ok := ret.Get(0).(func(int) goroutine.ErrGroup); ok {
+		r0 = returnFunc(n)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(goroutine.ErrGroup)
+		}
+	}
+	return r0
+}
+
+// GoroutineErrGroup_SetLimit_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetLimit'
+type GoroutineErrGroup_SetLimit_Call struct {
+	*mock.Call
+}
+
+// SetLimit is a helper method to define mock.On call
+//   - n
+func (_e *GoroutineErrGroup_Expecter) SetLimit(n interface{}) *GoroutineErrGroup_SetLimit_Call {
+	return &GoroutineErrGroup_SetLimit_Call{Call: _e.mock.On("SetLimit", n)}
+}
+
+func (_c *GoroutineErrGroup_SetLimit_Call) Run(run func(n int)) *GoroutineErrGroup_SetLimit_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int))
+	})
+	return _c
+}
+
+func (_c *GoroutineErrGroup_SetLimit_Call) Return(errGroup goroutine.ErrGroup) *GoroutineErrGroup_SetLimit_Call {
+	_c.Call.Return(errGroup)
+	return _c
+}
+
+func (_c *GoroutineErrGroup_SetLimit_Call) RunAndReturn(run func(int) goroutine.ErrGroup) *GoroutineErrGroup_SetLimit_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// TryGo provides a mock function for the type GoroutineErrGroup
+func (_mock *GoroutineErrGroup) TryGo(f func() error) bool {
+	ret := _mock.Called(f)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TryGo")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(func() error) bool); ok {
+		r0 = returnFunc(f)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// GoroutineErrGroup_TryGo_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TryGo'
+type GoroutineErrGroup_TryGo_Call struct {
+	*mock.Call
+}
+
+// TryGo is a helper method to define mock.On call
+//   - f
+func (_e *GoroutineErrGroup_Expecter) TryGo(f interface{}) *GoroutineErrGroup_TryGo_Call {
+	return &GoroutineErrGroup_TryGo_Call{Call: _e.mock.On("TryGo", f)}
+}
+
+func (_c *GoroutineErrGroup_TryGo_Call) Run(run func(f func() error)) *GoroutineErrGroup_TryGo_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(func() error))
+	})
+	return _c
+}
+
+func (_c *GoroutineErrGroup_TryGo_Call) Return(b bool) *GoroutineErrGroup_TryGo_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *GoroutineErrGroup_TryGo_Call) RunAndReturn(run func(func() error) bool) *GoroutineErrGroup_TryGo_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Wait provides a mock function for the type GoroutineErrGroup
 func (_mock *GoroutineErrGroup) Wait() error {
 	ret := _mock.Called()
@@ -291,6 +429,52 @@ func (_c *GoroutineErrGroup_Wait_Call) RunAndReturn(run func() error) *Goroutine
 	return _c
 }
 
+// WithCollectErrors provides a mock function for the type GoroutineErrGroup
+func (_mock *GoroutineErrGroup) WithCollectErrors() goroutine.ErrGroup {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithCollectErrors")
+	}
+
+	var r0 goroutine.ErrGroup
+	if returnFunc, ok := ret.Get(0).(func() goroutine.ErrGroup); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(goroutine.ErrGroup)
+		}
+	}
+	return r0
+}
+
+// GoroutineErrGroup_WithCollectErrors_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WithCollectErrors'
+type GoroutineErrGroup_WithCollectErrors_Call struct {
+	*mock.Call
+}
+
+// WithCollectErrors is a helper method to define mock.On call
+func (_e *GoroutineErrGroup_Expecter) WithCollectErrors() *GoroutineErrGroup_WithCollectErrors_Call {
+	return &GoroutineErrGroup_WithCollectErrors_Call{Call: _e.mock.On("WithCollectErrors")}
+}
+
+func (_c *GoroutineErrGroup_WithCollectErrors_Call) Run(run func()) *GoroutineErrGroup_WithCollectErrors_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *GoroutineErrGroup_WithCollectErrors_Call) Return(errGroup goroutine.ErrGroup) *GoroutineErrGroup_WithCollectErrors_Call {
+	_c.Call.Return(errGroup)
+	return _c
+}
+
+func (_c *GoroutineErrGroup_WithCollectErrors_Call) RunAndReturn(run func() goroutine.ErrGroup) *GoroutineErrGroup_WithCollectErrors_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // WithLogger provides a mock function for the type GoroutineErrGroup
 func (_mock *GoroutineErrGroup) WithLogger(logger kit.CLogger) goroutine.ErrGroup {
 	ret := _mock.Called(logger)
@@ -494,6 +678,42 @@ func (_c *GoroutineGoroutine_Go_Call) RunAndReturn(run func(ctx context.Context,
 	return _c
 }
 
+// GoLeader provides a mock function for the type GoroutineGoroutine
+func (_mock *GoroutineGoroutine) GoLeader(ctx context.Context, leadership goroutine.Leadership, f func(ctx context.Context)) {
+	_mock.Called(ctx, leadership, f)
+	return
+}
+
+// GoroutineGoroutine_GoLeader_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GoLeader'
+type GoroutineGoroutine_GoLeader_Call struct {
+	*mock.Call
+}
+
+// GoLeader is a helper method to define mock.On call
+//   - ctx
+//   - leadership
+//   - f
+func (_e *GoroutineGoroutine_Expecter) GoLeader(ctx interface{}, leadership interface{}, f interface{}) *GoroutineGoroutine_GoLeader_Call {
+	return &GoroutineGoroutine_GoLeader_Call{Call: _e.mock.On("GoLeader", ctx, leadership, f)}
+}
+
+func (_c *GoroutineGoroutine_GoLeader_Call) Run(run func(ctx context.Context, leadership goroutine.Leadership, f func(ctx context.Context))) *GoroutineGoroutine_GoLeader_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(goroutine.Leadership), args[2].(func(ctx context.Context)))
+	})
+	return _c
+}
+
+func (_c *GoroutineGoroutine_GoLeader_Call) Return() *GoroutineGoroutine_GoLeader_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *GoroutineGoroutine_GoLeader_Call) RunAndReturn(run func(ctx context.Context, leadership goroutine.Leadership, f func(ctx context.Context))) *GoroutineGoroutine_GoLeader_Call {
+	_c.Run(run)
+	return _c
+}
+
 // Mth provides a mock function for the type GoroutineGoroutine
 func (_mock *GoroutineGoroutine) Mth(method string) goroutine.Goroutine {
 	ret := _mock.Called(method)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/mikhailbolshakov/kit/monitoring"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -39,6 +40,156 @@ func (_m *KafkaBroker) EXPECT() *KafkaBroker_Expecter {
 	return &KafkaBroker_Expecter{mock: &_m.Mock}
 }
 
+// Admin provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) Admin() kafka.TopicAdmin {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Admin")
+	}
+
+	var r0 kafka.TopicAdmin
+	if returnFunc, ok := ret.Get(0).(func() kafka.TopicAdmin); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.TopicAdmin)
+		}
+	}
+	return r0
+}
+
+// KafkaBroker_Admin_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Admin'
+type KafkaBroker_Admin_Call struct {
+	*mock.Call
+}
+
+// Admin is a helper method to define mock.On call
+func (_e *KafkaBroker_Expecter) Admin() *KafkaBroker_Admin_Call {
+	return &KafkaBroker_Admin_Call{Call: _e.mock.On("Admin")}
+}
+
+func (_c *KafkaBroker_Admin_Call) Run(run func()) *KafkaBroker_Admin_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_Admin_Call) Return(topicAdmin kafka.TopicAdmin) *KafkaBroker_Admin_Call {
+	_c.Call.Return(topicAdmin)
+	return _c
+}
+
+func (_c *KafkaBroker_Admin_Call) RunAndReturn(run func() kafka.TopicAdmin) *KafkaBroker_Admin_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// AddBatchSubscriber provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) AddBatchSubscriber(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.SubscriberConfig, handler kafka.BatchHandlerFn) error {
+	ret := _mock.Called(ctx, topic, cfg, handler)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddBatchSubscriber")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *kafka.TopicConfig, *kafka.SubscriberConfig, kafka.BatchHandlerFn) error); ok {
+		r0 = returnFunc(ctx, topic, cfg, handler)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// KafkaBroker_AddBatchSubscriber_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddBatchSubscriber'
+type KafkaBroker_AddBatchSubscriber_Call struct {
+	*mock.Call
+}
+
+// AddBatchSubscriber is a helper method to define mock.On call
+//   - ctx
+//   - topic
+//   - cfg
+//   - handler
+func (_e *KafkaBroker_Expecter) AddBatchSubscriber(ctx interface{}, topic interface{}, cfg interface{}, handler interface{}) *KafkaBroker_AddBatchSubscriber_Call {
+	return &KafkaBroker_AddBatchSubscriber_Call{Call: _e.mock.On("AddBatchSubscriber", ctx, topic, cfg, handler)}
+}
+
+func (_c *KafkaBroker_AddBatchSubscriber_Call) Run(run func(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.SubscriberConfig, handler kafka.BatchHandlerFn)) *KafkaBroker_AddBatchSubscriber_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*kafka.TopicConfig), args[2].(*kafka.SubscriberConfig), args[3].(kafka.BatchHandlerFn))
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_AddBatchSubscriber_Call) Return(err error) *KafkaBroker_AddBatchSubscriber_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *KafkaBroker_AddBatchSubscriber_Call) RunAndReturn(run func(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.SubscriberConfig, handler kafka.BatchHandlerFn) error) *KafkaBroker_AddBatchSubscriber_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// AddMessageSubscriber provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) AddMessageSubscriber(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.SubscriberConfig, handlers ...kafka.MessageHandlerFn) error {
+	var tmpRet mock.Arguments
+	if len(handlers) > 0 {
+		tmpRet = _mock.Called(ctx, topic, cfg, handlers)
+	} else {
+		tmpRet = _mock.Called(ctx, topic, cfg)
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for AddMessageSubscriber")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *kafka.TopicConfig, *kafka.SubscriberConfig, ...kafka.MessageHandlerFn) error); ok {
+		r0 = returnFunc(ctx, topic, cfg, handlers...)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// KafkaBroker_AddMessageSubscriber_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'AddMessageSubscriber'
+type KafkaBroker_AddMessageSubscriber_Call struct {
+	*mock.Call
+}
+
+// AddMessageSubscriber is a helper method to define mock.On call
+//   - ctx
+//   - topic
+//   - cfg
+//   - handlers
+func (_e *KafkaBroker_Expecter) AddMessageSubscriber(ctx interface{}, topic interface{}, cfg interface{}, handlers ...interface{}) *KafkaBroker_AddMessageSubscriber_Call {
+	return &KafkaBroker_AddMessageSubscriber_Call{Call: _e.mock.On("AddMessageSubscriber",
+		append([]interface{}{ctx, topic, cfg}, handlers...)...)}
+}
+
+func (_c *KafkaBroker_AddMessageSubscriber_Call) Run(run func(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.SubscriberConfig, handlers ...kafka.MessageHandlerFn)) *KafkaBroker_AddMessageSubscriber_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := args[3].([]kafka.MessageHandlerFn)
+		run(args[0].(context.Context), args[1].(*kafka.TopicConfig), args[2].(*kafka.SubscriberConfig), variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_AddMessageSubscriber_Call) Return(err error) *KafkaBroker_AddMessageSubscriber_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *KafkaBroker_AddMessageSubscriber_Call) RunAndReturn(run func(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.SubscriberConfig, handlers ...kafka.MessageHandlerFn) error) *KafkaBroker_AddMessageSubscriber_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // AddProducer provides a mock function for the type KafkaBroker
 func (_mock *KafkaBroker) AddProducer(ctx context.Context, topic *kafka.TopicConfig, cfg *kafka.ProducerConfig) (kafka.Producer, error) {
 	ret := _mock.Called(ctx, topic, cfg)
@@ -232,6 +383,99 @@ func (_c *KafkaBroker_DeclareTopics_Call) RunAndReturn(run func(ctx context.Cont
 	return _c
 }
 
+// DLQ provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) DLQ(topic string) kafka.DLQInspector {
+	ret := _mock.Called(topic)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DLQ")
+	}
+
+	var r0 kafka.DLQInspector
+	if returnFunc, ok := ret.Get(0).(func(string) kafka.DLQInspector); ok {
+		r0 = returnFunc(topic)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.DLQInspector)
+		}
+	}
+	return r0
+}
+
+// KafkaBroker_DLQ_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DLQ'
+type KafkaBroker_DLQ_Call struct {
+	*mock.Call
+}
+
+// DLQ is a helper method to define mock.On call
+//   - topic
+func (_e *KafkaBroker_Expecter) DLQ(topic interface{}) *KafkaBroker_DLQ_Call {
+	return &KafkaBroker_DLQ_Call{Call: _e.mock.On("DLQ", topic)}
+}
+
+func (_c *KafkaBroker_DLQ_Call) Run(run func(topic string)) *KafkaBroker_DLQ_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_DLQ_Call) Return(dLQInspector kafka.DLQInspector) *KafkaBroker_DLQ_Call {
+	_c.Call.Return(dLQInspector)
+	return _c
+}
+
+func (_c *KafkaBroker_DLQ_Call) RunAndReturn(run func(topic string) kafka.DLQInspector) *KafkaBroker_DLQ_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetCollector provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) GetCollector() monitoring.MetricsCollector {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetCollector")
+	}
+
+	var r0 monitoring.MetricsCollector
+	if returnFunc, ok := ret.Get(0).(func() monitoring.MetricsCollector); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(monitoring.MetricsCollector)
+		}
+	}
+	return r0
+}
+
+// KafkaBroker_GetCollector_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetCollector'
+type KafkaBroker_GetCollector_Call struct {
+	*mock.Call
+}
+
+// GetCollector is a helper method to define mock.On call
+func (_e *KafkaBroker_Expecter) GetCollector() *KafkaBroker_GetCollector_Call {
+	return &KafkaBroker_GetCollector_Call{Call: _e.mock.On("GetCollector")}
+}
+
+func (_c *KafkaBroker_GetCollector_Call) Run(run func()) *KafkaBroker_GetCollector_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_GetCollector_Call) Return(metricsCollector monitoring.MetricsCollector) *KafkaBroker_GetCollector_Call {
+	_c.Call.Return(metricsCollector)
+	return _c
+}
+
+func (_c *KafkaBroker_GetCollector_Call) RunAndReturn(run func() monitoring.MetricsCollector) *KafkaBroker_GetCollector_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Init provides a mock function for the type KafkaBroker
 func (_mock *KafkaBroker) Init(ctx context.Context, cfg *kafka.BrokerConfig) error {
 	ret := _mock.Called(ctx, cfg)
@@ -278,6 +522,100 @@ func (_c *KafkaBroker_Init_Call) RunAndReturn(run func(ctx context.Context, cfg
 	return _c
 }
 
+// Pause provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) Pause(ctx context.Context, topic string, groupId string) error {
+	ret := _mock.Called(ctx, topic, groupId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Pause")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, topic, groupId)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// KafkaBroker_Pause_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Pause'
+type KafkaBroker_Pause_Call struct {
+	*mock.Call
+}
+
+// Pause is a helper method to define mock.On call
+//   - ctx
+//   - topic
+//   - groupId
+func (_e *KafkaBroker_Expecter) Pause(ctx interface{}, topic interface{}, groupId interface{}) *KafkaBroker_Pause_Call {
+	return &KafkaBroker_Pause_Call{Call: _e.mock.On("Pause", ctx, topic, groupId)}
+}
+
+func (_c *KafkaBroker_Pause_Call) Run(run func(ctx context.Context, topic string, groupId string)) *KafkaBroker_Pause_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_Pause_Call) Return(err error) *KafkaBroker_Pause_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *KafkaBroker_Pause_Call) RunAndReturn(run func(ctx context.Context, topic string, groupId string) error) *KafkaBroker_Pause_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// Resume provides a mock function for the type KafkaBroker
+func (_mock *KafkaBroker) Resume(ctx context.Context, topic string, groupId string) error {
+	ret := _mock.Called(ctx, topic, groupId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Resume")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, topic, groupId)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// KafkaBroker_Resume_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Resume'
+type KafkaBroker_Resume_Call struct {
+	*mock.Call
+}
+
+// Resume is a helper method to define mock.On call
+//   - ctx
+//   - topic
+//   - groupId
+func (_e *KafkaBroker_Expecter) Resume(ctx interface{}, topic interface{}, groupId interface{}) *KafkaBroker_Resume_Call {
+	return &KafkaBroker_Resume_Call{Call: _e.mock.On("Resume", ctx, topic, groupId)}
+}
+
+func (_c *KafkaBroker_Resume_Call) Run(run func(ctx context.Context, topic string, groupId string)) *KafkaBroker_Resume_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *KafkaBroker_Resume_Call) Return(err error) *KafkaBroker_Resume_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *KafkaBroker_Resume_Call) RunAndReturn(run func(ctx context.Context, topic string, groupId string) error) *KafkaBroker_Resume_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Start provides a mock function for the type KafkaBroker
 func (_mock *KafkaBroker) Start(ctx context.Context) error {
 	ret := _mock.Called(ctx)
@@ -665,6 +1003,53 @@ func (_c *KafkaProducerConfigBuilder_Build_Call) RunAndReturn(run func() *kafka.
 	return _c
 }
 
+// Mode provides a mock function for the type KafkaProducerConfigBuilder
+func (_mock *KafkaProducerConfigBuilder) Mode(mode string) kafka.ProducerConfigBuilder {
+	ret := _mock.Called(mode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Mode")
+	}
+
+	var r0 kafka.ProducerConfigBuilder
+	if returnFunc, ok := ret.Get(0).(func(string) kafka.ProducerConfigBuilder); ok {
+		r0 = returnFunc(mode)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.ProducerConfigBuilder)
+		}
+	}
+	return r0
+}
+
+// KafkaProducerConfigBuilder_Mode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Mode'
+type KafkaProducerConfigBuilder_Mode_Call struct {
+	*mock.Call
+}
+
+// Mode is a helper method to define mock.On call
+//   - mode
+func (_e *KafkaProducerConfigBuilder_Expecter) Mode(mode interface{}) *KafkaProducerConfigBuilder_Mode_Call {
+	return &KafkaProducerConfigBuilder_Mode_Call{Call: _e.mock.On("Mode", mode)}
+}
+
+func (_c *KafkaProducerConfigBuilder_Mode_Call) Run(run func(mode string)) *KafkaProducerConfigBuilder_Mode_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *KafkaProducerConfigBuilder_Mode_Call) Return(producerConfigBuilder kafka.ProducerConfigBuilder) *KafkaProducerConfigBuilder_Mode_Call {
+	_c.Call.Return(producerConfigBuilder)
+	return _c
+}
+
+func (_c *KafkaProducerConfigBuilder_Mode_Call) RunAndReturn(run func(mode string) kafka.ProducerConfigBuilder) *KafkaProducerConfigBuilder_Mode_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // RequiredAcks provides a mock function for the type KafkaProducerConfigBuilder
 func (_mock *KafkaProducerConfigBuilder) RequiredAcks(v int) kafka.ProducerConfigBuilder {
 	ret := _mock.Called(v)
@@ -1036,6 +1421,100 @@ func (_c *KafkaSubscriberConfigBuilder_DLQProducer_Call) RunAndReturn(run func(p
 	return _c
 }
 
+// Dedup provides a mock function for the type KafkaSubscriberConfigBuilder
+func (_mock *KafkaSubscriberConfigBuilder) Dedup(store kafka.DedupStore) kafka.SubscriberConfigBuilder {
+	ret := _mock.Called(store)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Dedup")
+	}
+
+	var r0 kafka.SubscriberConfigBuilder
+	if returnFunc, ok := ret.Get(0).(func(kafka.DedupStore) kafka.SubscriberConfigBuilder); ok {
+		r0 = returnFunc(store)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.SubscriberConfigBuilder)
+		}
+	}
+	return r0
+}
+
+// KafkaSubscriberConfigBuilder_Dedup_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Dedup'
+type KafkaSubscriberConfigBuilder_Dedup_Call struct {
+	*mock.Call
+}
+
+// Dedup is a helper method to define mock.On call
+//   - store
+func (_e *KafkaSubscriberConfigBuilder_Expecter) Dedup(store interface{}) *KafkaSubscriberConfigBuilder_Dedup_Call {
+	return &KafkaSubscriberConfigBuilder_Dedup_Call{Call: _e.mock.On("Dedup", store)}
+}
+
+func (_c *KafkaSubscriberConfigBuilder_Dedup_Call) Run(run func(store kafka.DedupStore)) *KafkaSubscriberConfigBuilder_Dedup_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(kafka.DedupStore))
+	})
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_Dedup_Call) Return(subscriberConfigBuilder kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_Dedup_Call {
+	_c.Call.Return(subscriberConfigBuilder)
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_Dedup_Call) RunAndReturn(run func(store kafka.DedupStore) kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_Dedup_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// DedupIdField provides a mock function for the type KafkaSubscriberConfigBuilder
+func (_mock *KafkaSubscriberConfigBuilder) DedupIdField(field string) kafka.SubscriberConfigBuilder {
+	ret := _mock.Called(field)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DedupIdField")
+	}
+
+	var r0 kafka.SubscriberConfigBuilder
+	if returnFunc, ok := ret.Get(0).(func(string) kafka.SubscriberConfigBuilder); ok {
+		r0 = returnFunc(field)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.SubscriberConfigBuilder)
+		}
+	}
+	return r0
+}
+
+// KafkaSubscriberConfigBuilder_DedupIdField_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'DedupIdField'
+type KafkaSubscriberConfigBuilder_DedupIdField_Call struct {
+	*mock.Call
+}
+
+// DedupIdField is a helper method to define mock.On call
+//   - field
+func (_e *KafkaSubscriberConfigBuilder_Expecter) DedupIdField(field interface{}) *KafkaSubscriberConfigBuilder_DedupIdField_Call {
+	return &KafkaSubscriberConfigBuilder_DedupIdField_Call{Call: _e.mock.On("DedupIdField", field)}
+}
+
+func (_c *KafkaSubscriberConfigBuilder_DedupIdField_Call) Run(run func(field string)) *KafkaSubscriberConfigBuilder_DedupIdField_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_DedupIdField_Call) Return(subscriberConfigBuilder kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_DedupIdField_Call {
+	_c.Call.Return(subscriberConfigBuilder)
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_DedupIdField_Call) RunAndReturn(run func(field string) kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_DedupIdField_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // GroupId provides a mock function for the type KafkaSubscriberConfigBuilder
 func (_mock *KafkaSubscriberConfigBuilder) GroupId(groupId string) kafka.SubscriberConfigBuilder {
 	ret := _mock.Called(groupId)
@@ -1412,6 +1891,108 @@ func (_c *KafkaSubscriberConfigBuilder_MaxWait_Call) RunAndReturn(run func(to ti
 	return _c
 }
 
+// Mode provides a mock function for the type KafkaSubscriberConfigBuilder
+func (_mock *KafkaSubscriberConfigBuilder) Mode(mode string) kafka.SubscriberConfigBuilder {
+	ret := _mock.Called(mode)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Mode")
+	}
+
+	var r0 kafka.SubscriberConfigBuilder
+	if returnFunc, ok := ret.Get(0).(func(string) kafka.SubscriberConfigBuilder); ok {
+		r0 = returnFunc(mode)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.SubscriberConfigBuilder)
+		}
+	}
+	return r0
+}
+
+// KafkaSubscriberConfigBuilder_Mode_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Mode'
+type KafkaSubscriberConfigBuilder_Mode_Call struct {
+	*mock.Call
+}
+
+// Mode is a helper method to define mock.On call
+//   - mode
+func (_e *KafkaSubscriberConfigBuilder_Expecter) Mode(mode interface{}) *KafkaSubscriberConfigBuilder_Mode_Call {
+	return &KafkaSubscriberConfigBuilder_Mode_Call{Call: _e.mock.On("Mode", mode)}
+}
+
+func (_c *KafkaSubscriberConfigBuilder_Mode_Call) Run(run func(mode string)) *KafkaSubscriberConfigBuilder_Mode_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string))
+	})
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_Mode_Call) Return(subscriberConfigBuilder kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_Mode_Call {
+	_c.Call.Return(subscriberConfigBuilder)
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_Mode_Call) RunAndReturn(run func(mode string) kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_Mode_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// RetryTopics provides a mock function for the type KafkaSubscriberConfigBuilder
+func (_mock *KafkaSubscriberConfigBuilder) RetryTopics(delays ...time.Duration) kafka.SubscriberConfigBuilder {
+	var tmpRet mock.Arguments
+	if len(delays) > 0 {
+		tmpRet = _mock.Called(delays)
+	} else {
+		tmpRet = _mock.Called()
+	}
+	ret := tmpRet
+
+	if len(ret) == 0 {
+		panic("no return value specified for RetryTopics")
+	}
+
+	var r0 kafka.SubscriberConfigBuilder
+	if returnFunc, ok := ret.Get(0).(func(...time.Duration) kafka.SubscriberConfigBuilder); ok {
+		r0 = returnFunc(delays...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.SubscriberConfigBuilder)
+		}
+	}
+	return r0
+}
+
+// KafkaSubscriberConfigBuilder_RetryTopics_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'RetryTopics'
+type KafkaSubscriberConfigBuilder_RetryTopics_Call struct {
+	*mock.Call
+}
+
+// RetryTopics is a helper method to define mock.On call
+//   - delays
+func (_e *KafkaSubscriberConfigBuilder_Expecter) RetryTopics(delays ...interface{}) *KafkaSubscriberConfigBuilder_RetryTopics_Call {
+	return &KafkaSubscriberConfigBuilder_RetryTopics_Call{Call: _e.mock.On("RetryTopics",
+		append([]interface{}{}, delays...)...)}
+}
+
+func (_c *KafkaSubscriberConfigBuilder_RetryTopics_Call) Run(run func(delays ...time.Duration)) *KafkaSubscriberConfigBuilder_RetryTopics_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		variadicArgs := args[0].([]time.Duration)
+		run(variadicArgs...)
+	})
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_RetryTopics_Call) Return(subscriberConfigBuilder kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_RetryTopics_Call {
+	_c.Call.Return(subscriberConfigBuilder)
+	return _c
+}
+
+func (_c *KafkaSubscriberConfigBuilder_RetryTopics_Call) RunAndReturn(run func(delays ...time.Duration) kafka.SubscriberConfigBuilder) *KafkaSubscriberConfigBuilder_RetryTopics_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // StartOffset provides a mock function for the type KafkaSubscriberConfigBuilder
 func (_mock *KafkaSubscriberConfigBuilder) StartOffset(v int64) kafka.SubscriberConfigBuilder {
 	ret := _mock.Called(v)
@@ -1725,3 +2306,50 @@ func (_c *KafkaTopicBuilder_WithPartitionNum_Call) RunAndReturn(run func(num int
 	_c.Call.Return(run)
 	return _c
 }
+
+// WithReplicaFactor provides a mock function for the type KafkaTopicBuilder
+func (_mock *KafkaTopicBuilder) WithReplicaFactor(num int) kafka.TopicBuilder {
+	ret := _mock.Called(num)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WithReplicaFactor")
+	}
+
+	var r0 kafka.TopicBuilder
+	if returnFunc, ok := ret.Get(0).(func(int) kafka.TopicBuilder); ok {
+		r0 = returnFunc(num)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(kafka.TopicBuilder)
+		}
+	}
+	return r0
+}
+
+// KafkaTopicBuilder_WithReplicaFactor_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'WithReplicaFactor'
+type KafkaTopicBuilder_WithReplicaFactor_Call struct {
+	*mock.Call
+}
+
+// WithReplicaFactor is a helper method to define mock.On call
+//   - num
+func (_e *KafkaTopicBuilder_Expecter) WithReplicaFactor(num interface{}) *KafkaTopicBuilder_WithReplicaFactor_Call {
+	return &KafkaTopicBuilder_WithReplicaFactor_Call{Call: _e.mock.On("WithReplicaFactor", num)}
+}
+
+func (_c *KafkaTopicBuilder_WithReplicaFactor_Call) Run(run func(num int)) *KafkaTopicBuilder_WithReplicaFactor_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(int))
+	})
+	return _c
+}
+
+func (_c *KafkaTopicBuilder_WithReplicaFactor_Call) Return(topicBuilder kafka.TopicBuilder) *KafkaTopicBuilder_WithReplicaFactor_Call {
+	_c.Call.Return(topicBuilder)
+	return _c
+}
+
+func (_c *KafkaTopicBuilder_WithReplicaFactor_Call) RunAndReturn(run func(num int) kafka.TopicBuilder) *KafkaTopicBuilder_WithReplicaFactor_Call {
+	_c.Call.Return(run)
+	return _c
+}
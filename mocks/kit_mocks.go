@@ -1022,6 +1022,62 @@ func (_m *KitDistributedLock) EXPECT() *KitDistributedLock_Expecter {
 	return &KitDistributedLock_Expecter{mock: &_m.Mock}
 }
 
+// Extend provides a mock function for the type KitDistributedLock
+func (_mock *KitDistributedLock) Extend(ctx context.Context, ref string, releaseId string) (bool, error) {
+	ret := _mock.Called(ctx, ref, releaseId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Extend")
+	}
+
+	var r0 bool
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) (bool, error)); ok {
+		return returnFunc(ctx, ref, releaseId)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) bool); ok {
+		r0 = returnFunc(ctx, ref, releaseId)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = returnFunc(ctx, ref, releaseId)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// KitDistributedLock_Extend_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Extend'
+type KitDistributedLock_Extend_Call struct {
+	*mock.Call
+}
+
+// Extend is a helper method to define mock.On call
+//   - ctx
+//   - ref
+//   - releaseId
+func (_e *KitDistributedLock_Expecter) Extend(ctx interface{}, ref interface{}, releaseId interface{}) *KitDistributedLock_Extend_Call {
+	return &KitDistributedLock_Extend_Call{Call: _e.mock.On("Extend", ctx, ref, releaseId)}
+}
+
+func (_c *KitDistributedLock_Extend_Call) Run(run func(ctx context.Context, ref string, releaseId string)) *KitDistributedLock_Extend_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string), args[2].(string))
+	})
+	return _c
+}
+
+func (_c *KitDistributedLock_Extend_Call) Return(b bool, err error) *KitDistributedLock_Extend_Call {
+	_c.Call.Return(b, err)
+	return _c
+}
+
+func (_c *KitDistributedLock_Extend_Call) RunAndReturn(run func(ctx context.Context, ref string, releaseId string) (bool, error)) *KitDistributedLock_Extend_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Lock provides a mock function for the type KitDistributedLock
 func (_mock *KitDistributedLock) Lock(ctx context.Context, ref string) (string, error) {
 	ret := _mock.Called(ctx, ref)
@@ -1077,6 +1133,67 @@ func (_c *KitDistributedLock_Lock_Call) RunAndReturn(run func(ctx context.Contex
 	return _c
 }
 
+// TryLock provides a mock function for the type KitDistributedLock
+func (_mock *KitDistributedLock) TryLock(ctx context.Context, ref string) (string, bool, error) {
+	ret := _mock.Called(ctx, ref)
+
+	if len(ret) == 0 {
+		panic("no return value specified for TryLock")
+	}
+
+	var r0 string
+	var r1 bool
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (string, bool, error)); ok {
+		return returnFunc(ctx, ref)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) string); ok {
+		r0 = returnFunc(ctx, ref)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) bool); ok {
+		r1 = returnFunc(ctx, ref)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, string) error); ok {
+		r2 = returnFunc(ctx, ref)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// KitDistributedLock_TryLock_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'TryLock'
+type KitDistributedLock_TryLock_Call struct {
+	*mock.Call
+}
+
+// TryLock is a helper method to define mock.On call
+//   - ctx
+//   - ref
+func (_e *KitDistributedLock_Expecter) TryLock(ctx interface{}, ref interface{}) *KitDistributedLock_TryLock_Call {
+	return &KitDistributedLock_TryLock_Call{Call: _e.mock.On("TryLock", ctx, ref)}
+}
+
+func (_c *KitDistributedLock_TryLock_Call) Run(run func(ctx context.Context, ref string)) *KitDistributedLock_TryLock_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *KitDistributedLock_TryLock_Call) Return(s string, b bool, err error) *KitDistributedLock_TryLock_Call {
+	_c.Call.Return(s, b, err)
+	return _c
+}
+
+func (_c *KitDistributedLock_TryLock_Call) RunAndReturn(run func(ctx context.Context, ref string) (string, bool, error)) *KitDistributedLock_TryLock_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // UnLock provides a mock function for the type KitDistributedLock
 func (_mock *KitDistributedLock) UnLock(ctx context.Context, ref string, releaseId string) {
 	_mock.Called(ctx, ref, releaseId)
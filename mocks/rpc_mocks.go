@@ -178,6 +178,63 @@ func (_m *RpcClient) EXPECT() *RpcClient_Expecter {
 	return &RpcClient_Expecter{mock: &_m.Mock}
 }
 
+// Await provides a mock function for the type RpcClient
+func (_mock *RpcClient) Await(ctx context.Context, msg *rpc.Message) (*rpc.Message, error) {
+	ret := _mock.Called(ctx, msg)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Await")
+	}
+
+	var r0 *rpc.Message
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *rpc.Message) (*rpc.Message, error)); ok {
+		return returnFunc(ctx, msg)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *rpc.Message) *rpc.Message); ok {
+		r0 = returnFunc(ctx, msg)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*rpc.Message)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *rpc.Message) error); ok {
+		r1 = returnFunc(ctx, msg)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// RpcClient_Await_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Await'
+type RpcClient_Await_Call struct {
+	*mock.Call
+}
+
+// Await is a helper method to define mock.On call
+//   - ctx
+//   - msg
+func (_e *RpcClient_Expecter) Await(ctx interface{}, msg interface{}) *RpcClient_Await_Call {
+	return &RpcClient_Await_Call{Call: _e.mock.On("Await", ctx, msg)}
+}
+
+func (_c *RpcClient_Await_Call) Run(run func(ctx context.Context, msg *rpc.Message)) *RpcClient_Await_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(context.Context), args[1].(*rpc.Message))
+	})
+	return _c
+}
+
+func (_c *RpcClient_Await_Call) Return(message *rpc.Message, err error) *RpcClient_Await_Call {
+	_c.Call.Return(message, err)
+	return _c
+}
+
+func (_c *RpcClient_Await_Call) RunAndReturn(run func(ctx context.Context, msg *rpc.Message) (*rpc.Message, error)) *RpcClient_Await_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Call provides a mock function for the type RpcClient
 func (_mock *RpcClient) Call(ctx context.Context, msg *rpc.Message, callback rpc.ResponseCallback) error {
 	ret := _mock.Called(ctx, msg, callback)
@@ -0,0 +1,19 @@
+package monitoring
+
+import (
+	"github.com/mikhailbolshakov/kit/goroutine"
+)
+
+type poolMonitoring struct{}
+
+// PoolMetrics returns metrics of goroutine pools (goroutine.Pool)
+// they live in goroutine package which can't depend on monitoring
+func PoolMetrics() MetricsProvider {
+	return poolMonitoring{}
+}
+
+func (poolMonitoring) GetCollector() MetricsCollector {
+	return func() MetricsCollection {
+		return goroutine.PoolCollectors()
+	}
+}
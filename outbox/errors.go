@@ -0,0 +1,61 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	ErrCodeOutboxMessageContextInvalid = "OBX-001"
+	ErrCodeOutboxMessageMarshal        = "OBX-002"
+	ErrCodeOutboxPut                   = "OBX-003"
+	ErrCodeOutboxTxEmpty               = "OBX-004"
+	ErrCodeOutboxTopicEmpty            = "OBX-005"
+	ErrCodeOutboxClaim                 = "OBX-006"
+	ErrCodeOutboxUpdate                = "OBX-007"
+	ErrCodeOutboxNoProducer            = "OBX-008"
+	ErrCodeOutboxMessageUnmarshal      = "OBX-009"
+	ErrCodeOutboxCleanup               = "OBX-010"
+	ErrCodeOutboxEnsureTable           = "OBX-011"
+	ErrCodeOutboxTableInvalid          = "OBX-012"
+)
+
+var (
+	ErrOutboxMessageContextInvalid = func(ctx context.Context, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxMessageContextInvalid, "invalid or empty request context").C(ctx).F(kit.KV{"topic": topic}).Err()
+	}
+	ErrOutboxMessageMarshal = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxMessageMarshal, "marshal message").Wrap(cause).C(ctx).F(kit.KV{"topic": topic}).Err()
+	}
+	ErrOutboxPut = func(ctx context.Context, cause error, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxPut, "put message").Wrap(cause).C(ctx).F(kit.KV{"topic": topic}).Err()
+	}
+	ErrOutboxTxEmpty = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxTxEmpty, "transaction empty").C(ctx).Err()
+	}
+	ErrOutboxTopicEmpty = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxTopicEmpty, "topic empty").C(ctx).Err()
+	}
+	ErrOutboxClaim = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxClaim, "claim messages").Wrap(cause).C(ctx).Err()
+	}
+	ErrOutboxUpdate = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxUpdate, "update messages").Wrap(cause).C(ctx).Err()
+	}
+	ErrOutboxNoProducer = func(ctx context.Context, topic string) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxNoProducer, "no producer for topic").C(ctx).F(kit.KV{"topic": topic}).Err()
+	}
+	ErrOutboxMessageUnmarshal = func(ctx context.Context, cause error, id int64) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxMessageUnmarshal, "unmarshal message").Wrap(cause).C(ctx).F(kit.KV{"id": id}).Err()
+	}
+	ErrOutboxCleanup = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxCleanup, "cleanup").Wrap(cause).C(ctx).Err()
+	}
+	ErrOutboxEnsureTable = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxEnsureTable, "ensure table").Wrap(cause).C(ctx).Err()
+	}
+	ErrOutboxTableInvalid = func(ctx context.Context, table string) error {
+		return kit.NewAppErrBuilder(ErrCodeOutboxTableInvalid, "invalid table name").C(ctx).F(kit.KV{"table": table}).Err()
+	}
+)
@@ -0,0 +1,166 @@
+package outbox
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusPending   = "pending"   // StatusPending message waits to be sent
+	StatusDelivered = "delivered" // StatusDelivered message has been sent to kafka
+	StatusFailed    = "failed"    // StatusFailed message hasn't been sent within MaxAttempts
+
+	defaultTable           = "outbox"
+	defaultBatchSize       = 100
+	defaultPollInterval    = time.Second
+	defaultMaxAttempts     = 10
+	defaultRetryDelay      = time.Second
+	defaultMaxRetryDelay   = time.Minute
+	defaultRetention       = time.Hour
+	defaultCleanupInterval = time.Minute
+)
+
+var tableNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// Config outbox configuration
+type Config struct {
+	Table           string        // Table outbox table name, might be schema qualified (default: outbox)
+	BatchSize       int           // BatchSize max number of messages claimed by relay per iteration (default: 100)
+	PollInterval    time.Duration // PollInterval how often relay checks outbox when it's empty (default: 1s)
+	MaxAttempts     int           // MaxAttempts number of send attempts after which a message is marked as failed (default: 10)
+	RetryDelay      time.Duration // RetryDelay delay before the first retry, it's doubled with each attempt (default: 1s)
+	MaxRetryDelay   time.Duration // MaxRetryDelay max delay between retries (default: 1m)
+	Retention       time.Duration // Retention how long delivered messages are kept (default: 1h)
+	CleanupInterval time.Duration // CleanupInterval how often delivered messages are cleaned up (default: 1m)
+}
+
+// Outbox writes kafka messages into outbox table within the caller's transaction
+// so that messages are published if and only if the transaction is committed
+type Outbox interface {
+	// Put puts a message to be sent to the topic, ctx must contain a request context
+	Put(ctx context.Context, tx *gorm.DB, topic, key string, payload any) error
+	// PutMany puts bulk of messages to be sent to the topic
+	// if a message has no request context specified, it's taken from ctx
+	PutMany(ctx context.Context, tx *gorm.DB, topic string, messages ...*kafka.Message) error
+}
+
+// message outbox table record
+type message struct {
+	Id            int64      `gorm:"column:id;primaryKey"`
+	Topic         string     `gorm:"column:topic"`
+	Key           string     `gorm:"column:key"`
+	Message       string     `gorm:"column:message"`
+	Status        string     `gorm:"column:status"`
+	Attempts      int        `gorm:"column:attempts"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at"`
+	LastError     *string    `gorm:"column:last_error"`
+	CreatedAt     time.Time  `gorm:"column:created_at"`
+	DeliveredAt   *time.Time `gorm:"column:delivered_at"`
+}
+
+type outboxImpl struct {
+	cfg    *Config
+	logger kit.CLoggerFunc
+}
+
+func NewOutbox(cfg *Config, logger kit.CLoggerFunc) Outbox {
+	return &outboxImpl{
+		cfg:    applyDefaults(cfg),
+		logger: logger,
+	}
+}
+
+func applyDefaults(cfg *Config) *Config {
+	c := &Config{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Table == "" {
+		c.Table = defaultTable
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = defaultPollInterval
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultMaxAttempts
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = defaultRetryDelay
+	}
+	if c.MaxRetryDelay <= 0 {
+		c.MaxRetryDelay = defaultMaxRetryDelay
+	}
+	if c.Retention <= 0 {
+		c.Retention = defaultRetention
+	}
+	if c.CleanupInterval <= 0 {
+		c.CleanupInterval = defaultCleanupInterval
+	}
+	return c
+}
+
+func (o *outboxImpl) l() kit.CLogger {
+	return o.logger().Cmp("outbox")
+}
+
+func (o *outboxImpl) Put(ctx context.Context, tx *gorm.DB, topic, key string, payload any) error {
+	return o.PutMany(ctx, tx, topic, &kafka.Message{Key: key, Payload: payload})
+}
+
+func (o *outboxImpl) PutMany(ctx context.Context, tx *gorm.DB, topic string, messages ...*kafka.Message) error {
+	l := o.l().C(ctx).Mth("put").F(kit.KV{"topic": topic}).Dbg()
+
+	if tx == nil {
+		return ErrOutboxTxEmpty(ctx)
+	}
+	if topic == "" {
+		return ErrOutboxTopicEmpty(ctx)
+	}
+	if !tableNameRegexp.MatchString(o.cfg.Table) {
+		return ErrOutboxTableInvalid(ctx, o.cfg.Table)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	now := kit.Now()
+	records := make([]*message, 0, len(messages))
+	for _, m := range messages {
+		msg := *m
+		if msg.Ctx == nil {
+			rCtx, ok := kit.Request(ctx)
+			if !ok {
+				return ErrOutboxMessageContextInvalid(ctx, topic)
+			}
+			msg.Ctx = rCtx
+		}
+		body, err := kit.Marshal(msg)
+		if err != nil {
+			return ErrOutboxMessageMarshal(ctx, err, topic)
+		}
+		records = append(records, &message{
+			Topic:         topic,
+			Key:           msg.Key,
+			Message:       string(body),
+			Status:        StatusPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		})
+	}
+
+	// id is omitted to be generated by the sequence, so the insertion order is preserved
+	if err := tx.WithContext(ctx).Table(o.cfg.Table).Omit("id").Create(&records).Error; err != nil {
+		return ErrOutboxPut(ctx, err, topic)
+	}
+
+	l.F(kit.KV{"count": len(records)}).Dbg("ok")
+	return nil
+}
@@ -0,0 +1,386 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/mikhailbolshakov/kit/storages/pg"
+	"gorm.io/gorm"
+)
+
+const (
+	cleanupBatchSize = 1000
+
+	tableDDL = `
+		create table if not exists %[1]s (
+			id bigserial primary key,
+			topic varchar not null,
+			key varchar not null default '',
+			message jsonb not null,
+			status varchar(16) not null,
+			attempts int not null default 0,
+			next_attempt_at timestamp not null,
+			last_error text,
+			created_at timestamp not null,
+			delivered_at timestamp
+		);
+		create index if not exists %[2]s_pending_idx on %[1]s (id) where status = 'pending';
+		create index if not exists %[2]s_key_idx on %[1]s (topic, key, id) where status = 'pending';
+		create index if not exists %[2]s_delivered_idx on %[1]s (delivered_at) where status = 'delivered';`
+)
+
+// Relay ships outbox messages to kafka
+//
+// Messages are claimed with FOR UPDATE SKIP LOCKED, so multiple relay instances can run simultaneously.
+// Delivery is at least once: if a process dies after sending but before committing, messages are resent.
+// Messages with the same topic and key are sent in the order they were put, a message isn't sent
+// until all the previous messages with the same key are delivered or failed
+type Relay interface {
+	// EnsureTable creates outbox table if it doesn't exist
+	// it's helpful for tests, normally the table is created by migrations
+	EnsureTable(ctx context.Context) error
+	// Process runs a single relay iteration and returns number of delivered messages
+	Process(ctx context.Context) (int, error)
+	// Cleanup removes delivered messages older than retention period
+	Cleanup(ctx context.Context) error
+	// Start starts relay and cleanup loops
+	Start(ctx context.Context)
+	// Close stops relay, it waits for the current iteration to complete
+	Close(ctx context.Context)
+}
+
+type relayImpl struct {
+	sync.Mutex
+	storage   *pg.Storage
+	producers map[string]kafka.Producer
+	cfg       *Config
+	logger    kit.CLoggerFunc
+	cancelFn  context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewRelay creates a relay, producers are specified per topic
+// producers must be synchronous, otherwise send errors aren't detected
+func NewRelay(storage *pg.Storage, producers map[string]kafka.Producer, cfg *Config, logger kit.CLoggerFunc) Relay {
+	return &relayImpl{
+		storage:   storage,
+		producers: producers,
+		cfg:       applyDefaults(cfg),
+		logger:    logger,
+	}
+}
+
+func (r *relayImpl) l() kit.CLogger {
+	return r.logger().Cmp("outbox-relay")
+}
+
+func (r *relayImpl) EnsureTable(ctx context.Context) error {
+	r.l().C(ctx).Mth("ensure-table").Dbg()
+
+	if !tableNameRegexp.MatchString(r.cfg.Table) {
+		return ErrOutboxTableInvalid(ctx, r.cfg.Table)
+	}
+	// index names cannot be schema qualified
+	idxPrefix := r.cfg.Table
+	for i := len(idxPrefix) - 1; i >= 0; i-- {
+		if idxPrefix[i] == '.' {
+			idxPrefix = idxPrefix[i+1:]
+			break
+		}
+	}
+	if err := r.storage.Instance.WithContext(ctx).Exec(fmt.Sprintf(tableDDL, r.cfg.Table, idxPrefix)).Error; err != nil {
+		return ErrOutboxEnsureTable(ctx, err)
+	}
+	return nil
+}
+
+func (r *relayImpl) Start(ctx context.Context) {
+	r.l().C(ctx).Mth("start").Dbg()
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.cancelFn != nil {
+		return
+	}
+	var loopCtx context.Context
+	loopCtx, r.cancelFn = context.WithCancel(ctx)
+
+	r.wg.Add(2)
+	goroutine.New().
+		WithLogger(r.l().Mth("relay")).
+		Go(ctx, func() {
+			defer r.wg.Done()
+			r.relayLoop(loopCtx)
+		})
+	goroutine.New().
+		WithLogger(r.l().Mth("cleanup")).
+		Go(ctx, func() {
+			defer r.wg.Done()
+			r.cleanupLoop(loopCtx)
+		})
+}
+
+func (r *relayImpl) Close(ctx context.Context) {
+	r.l().C(ctx).Mth("close").Dbg()
+
+	r.Lock()
+	cancelFn := r.cancelFn
+	r.cancelFn = nil
+	r.Unlock()
+
+	if cancelFn != nil {
+		cancelFn()
+		r.wg.Wait()
+	}
+}
+
+func (r *relayImpl) relayLoop(ctx context.Context) {
+	for {
+		n, err := r.Process(ctx)
+		if err != nil {
+			r.l().C(ctx).Mth("relay").E(err).Err()
+		}
+		// a full batch means there might be more messages, so continue immediately
+		if err == nil && n >= r.cfg.BatchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-time.After(r.cfg.PollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *relayImpl) cleanupLoop(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(r.cfg.CleanupInterval):
+			if err := r.Cleanup(ctx); err != nil {
+				r.l().C(ctx).Mth("cleanup").E(err).Err()
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *relayImpl) Process(ctx context.Context) (int, error) {
+	l := r.l().C(ctx).Mth("process")
+
+	if !tableNameRegexp.MatchString(r.cfg.Table) {
+		return 0, ErrOutboxTableInvalid(ctx, r.cfg.Table)
+	}
+
+	// kafka is called within the transaction, so it mustn't be interrupted by ctx cancellation
+	// otherwise sent messages would be rolled back and resent
+	txCtx := context.WithoutCancel(ctx)
+
+	delivered := 0
+	err := r.storage.Instance.WithContext(txCtx).Transaction(func(tx *gorm.DB) error {
+		messages, err := r.claim(ctx, tx)
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		// group by topic preserving order
+		var topics []string
+		byTopic := map[string][]*message{}
+		for _, m := range messages {
+			if _, ok := byTopic[m.Topic]; !ok {
+				topics = append(topics, m.Topic)
+			}
+			byTopic[m.Topic] = append(byTopic[m.Topic], m)
+		}
+
+		for _, topic := range topics {
+			sendErr := r.send(txCtx, topic, byTopic[topic])
+			if sendErr != nil {
+				l.E(sendErr).F(kit.KV{"topic": topic}).Err()
+				if err := r.markFailed(ctx, tx, byTopic[topic], sendErr); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := r.markDelivered(ctx, tx, byTopic[topic]); err != nil {
+				return err
+			}
+			delivered += len(byTopic[topic])
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if delivered > 0 {
+		l.F(kit.KV{"delivered": delivered}).Dbg("ok")
+	}
+	return delivered, nil
+}
+
+// claim locks a batch of pending messages skipping ones locked by other relay instances
+// messages having a previous pending message with the same key not claimed by this batch are excluded to keep ordering
+func (r *relayImpl) claim(ctx context.Context, tx *gorm.DB) ([]*message, error) {
+	var messages []*message
+	err := tx.Raw(fmt.Sprintf(`select * from %s where status = ? and next_attempt_at <= ? order by id limit ? for update skip locked`, r.cfg.Table),
+		StatusPending, kit.Now(), r.cfg.BatchSize).Scan(&messages).Error
+	if err != nil {
+		return nil, ErrOutboxClaim(ctx, err)
+	}
+
+	// the first claimed id per key
+	type topicKey struct{ topic, key string }
+	firstIds := map[topicKey]int64{}
+	var keys [][]any
+	for _, m := range messages {
+		if m.Key == "" {
+			continue
+		}
+		tk := topicKey{m.Topic, m.Key}
+		if _, ok := firstIds[tk]; !ok {
+			firstIds[tk] = m.Id
+			keys = append(keys, []any{m.Topic, m.Key})
+		}
+	}
+	if len(keys) == 0 {
+		return messages, nil
+	}
+
+	// find the first pending id per key across the whole table
+	var heads []struct {
+		Topic string `gorm:"column:topic"`
+		Key   string `gorm:"column:key"`
+		Id    int64  `gorm:"column:id"`
+	}
+	err = tx.Raw(fmt.Sprintf(`select topic, key, min(id) as id from %s where status = ? and (topic, key) in ? group by topic, key`, r.cfg.Table),
+		StatusPending, keys).Scan(&heads).Error
+	if err != nil {
+		return nil, ErrOutboxClaim(ctx, err)
+	}
+	blocked := map[topicKey]struct{}{}
+	for _, h := range heads {
+		tk := topicKey{h.Topic, h.Key}
+		if h.Id < firstIds[tk] {
+			blocked[tk] = struct{}{}
+		}
+	}
+	if len(blocked) == 0 {
+		return messages, nil
+	}
+
+	res := make([]*message, 0, len(messages))
+	for _, m := range messages {
+		if _, ok := blocked[topicKey{m.Topic, m.Key}]; !ok {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (r *relayImpl) send(ctx context.Context, topic string, messages []*message) error {
+	producer, ok := r.producers[topic]
+	if !ok {
+		return ErrOutboxNoProducer(ctx, topic)
+	}
+	kafkaMessages := make([]*kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		// payload is kept raw to be sent exactly as it was put
+		msg := &kafka.MessageT[json.RawMessage]{}
+		if err := kit.Unmarshal([]byte(m.Message), msg); err != nil {
+			return ErrOutboxMessageUnmarshal(ctx, err, m.Id)
+		}
+		kafkaMessages = append(kafkaMessages, &kafka.Message{
+			Ctx:     msg.Ctx,
+			Key:     msg.Key,
+			Payload: msg.Payload,
+		})
+	}
+	return producer.SendMany(ctx, kafkaMessages...)
+}
+
+func (r *relayImpl) markDelivered(ctx context.Context, tx *gorm.DB, messages []*message) error {
+	err := tx.Table(r.cfg.Table).Where("id in ?", ids(messages)).
+		Updates(map[string]any{"status": StatusDelivered, "delivered_at": kit.Now()}).Error
+	if err != nil {
+		return ErrOutboxUpdate(ctx, err)
+	}
+	return nil
+}
+
+func (r *relayImpl) markFailed(ctx context.Context, tx *gorm.DB, messages []*message, sendErr error) error {
+	now := kit.Now()
+	for _, m := range messages {
+		attempts := m.Attempts + 1
+		status := StatusPending
+		if attempts >= r.cfg.MaxAttempts {
+			status = StatusFailed
+			r.l().C(ctx).Mth("failed").F(kit.KV{"id": m.Id, "topic": m.Topic, "key": m.Key}).Err("max attempts exceeded")
+		}
+		err := tx.Table(r.cfg.Table).Where("id = ?", m.Id).
+			Updates(map[string]any{
+				"status":          status,
+				"attempts":        attempts,
+				"next_attempt_at": now.Add(r.retryDelay(attempts)),
+				"last_error":      sendErr.Error(),
+			}).Error
+		if err != nil {
+			return ErrOutboxUpdate(ctx, err)
+		}
+	}
+	return nil
+}
+
+// retryDelay calculates exponential backoff
+func (r *relayImpl) retryDelay(attempts int) time.Duration {
+	delay := r.cfg.RetryDelay
+	for i := 1; i < attempts && delay < r.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxRetryDelay)
+}
+
+func (r *relayImpl) Cleanup(ctx context.Context) error {
+	l := r.l().C(ctx).Mth("cleanup").Dbg()
+
+	if !tableNameRegexp.MatchString(r.cfg.Table) {
+		return ErrOutboxTableInvalid(ctx, r.cfg.Table)
+	}
+
+	// remove by chunks to avoid long locks
+	total := int64(0)
+	for {
+		res := r.storage.Instance.WithContext(ctx).Exec(fmt.Sprintf(`delete from %[1]s where id in (select id from %[1]s where status = ? and delivered_at < ? limit ?)`, r.cfg.Table),
+			StatusDelivered, kit.Now().Add(-r.cfg.Retention), cleanupBatchSize)
+		if res.Error != nil {
+			return ErrOutboxCleanup(ctx, res.Error)
+		}
+		total += res.RowsAffected
+		if res.RowsAffected < cleanupBatchSize || ctx.Err() != nil {
+			break
+		}
+	}
+
+	if total > 0 {
+		l.F(kit.KV{"deleted": total}).Dbg("ok")
+	}
+	return nil
+}
+
+func ids(messages []*message) []int64 {
+	res := make([]int64, 0, len(messages))
+	for _, m := range messages {
+		res = append(res, m.Id)
+	}
+	return res
+}
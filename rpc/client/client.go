@@ -2,34 +2,47 @@ package client
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/mikhailbolshakov/kit"
 	"github.com/mikhailbolshakov/kit/kafka"
 	"github.com/mikhailbolshakov/kit/rpc"
+	"github.com/mikhailbolshakov/kit/rpc/transport"
 	"github.com/mitchellh/mapstructure"
 )
 
 type msgTypeBodyProviders map[rpc.MessageType]func() interface{}
 
 type rpcClient struct {
-	callProducer      kafka.Producer
+	transport         rpc.Transport
 	rqPool            *rpc.RequestPool
 	logger            kit.CLoggerFunc
 	bodyTypeProviders msgTypeBodyProviders
 	distributedKeys   rpc.DistributedKeys
 	clusterSupport    bool
+	deferNotOwned     bool
 }
 
+// NewClient creates a client sending requests by the kafka producer
+// ResponseHandler must be set up as a subscriber of a response topic
 func NewClient(logger kit.CLoggerFunc, callProducer kafka.Producer, distributedKeys rpc.DistributedKeys, config *rpc.Config) rpc.Client {
-	return &rpcClient{
-		callProducer:      callProducer,
+	return NewClientWithTransport(logger, transport.NewKafkaTransport(callProducer), distributedKeys, config)
+}
+
+// NewClientWithTransport creates a client sending requests and receiving responses by the transport
+func NewClientWithTransport(logger kit.CLoggerFunc, tr rpc.Transport, distributedKeys rpc.DistributedKeys, config *rpc.Config) rpc.Client {
+	r := &rpcClient{
+		transport:         tr,
 		logger:            logger,
 		rqPool:            rpc.NewRequestPool(logger, config.CallTimeOut),
 		bodyTypeProviders: map[rpc.MessageType]func() interface{}{},
 		distributedKeys:   distributedKeys,
 		clusterSupport:    config.ClusterSupport,
+		deferNotOwned:     config.BroadcastRequests,
 	}
+	tr.Listen(r.response)
+	return r
 }
 
 func (r *rpcClient) l() kit.CLogger {
@@ -65,23 +78,71 @@ func (r *rpcClient) RegisterBodyTypeProvider(messageType rpc.MessageType, provid
 }
 
 func (r *rpcClient) Call(ctx context.Context, msg *rpc.Message, callback rpc.ResponseCallback) error {
-	l := r.l().C(ctx).Mth("call").F(kit.KV{"type": msg.Type, "key": msg.Key, "rqId": msg.RequestId}).Dbg()
+	r.l().C(ctx).Mth("call").F(kit.KV{"type": msg.Type, "key": msg.Key, "rqId": msg.RequestId}).Dbg()
+	return r.call(ctx, &rpc.Request{Ctx: ctx, Msg: msg, Callback: callback})
+}
+
+func (r *rpcClient) Await(ctx context.Context, msg *rpc.Message) (*rpc.Message, error) {
+	l := r.l().C(ctx).Mth("await").F(kit.KV{"type": msg.Type, "key": msg.Key, "rqId": msg.RequestId}).Dbg()
+
+	// the response and the expiration are signaled by the request pool
+	msg.ResponseRequired = true
+	rsCh := make(chan *rpc.Message, 1)
+	expiredCh := make(chan struct{}, 1)
+	rq := &rpc.Request{
+		Ctx: ctx,
+		Msg: msg,
+		Callback: func(ctx context.Context, rqMsg, rsMsg *rpc.Message) error {
+			rsCh <- rsMsg
+			return nil
+		},
+		Expired: func(ctx context.Context, msg *rpc.Message) error {
+			expiredCh <- struct{}{}
+			return nil
+		},
+	}
+	if err := r.call(ctx, rq); err != nil {
+		r.rqPool.Remove(msg.RequestId)
+		return nil, err
+	}
+
+	select {
+	case rs := <-rsCh:
+		if err := rpc.ResponseErr(ctx, rs); err != nil {
+			return nil, err
+		}
+		l.Dbg("ok")
+		return rs, nil
+	case <-expiredCh:
+		return nil, rpc.ErrRpcCallTimeout(ctx, msg.RequestId, msg.Key)
+	case <-ctx.Done():
+		// a late response finds no request in pool
+		r.rqPool.Remove(msg.RequestId)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, rpc.ErrRpcCallTimeout(ctx, msg.RequestId, msg.Key)
+		}
+		return nil, rpc.ErrRpcCallCancelled(ctx, msg.RequestId, msg.Key)
+	}
+}
+
+func (r *rpcClient) call(ctx context.Context, rq *rpc.Request) error {
+	l := r.l().C(ctx).Mth("call").F(kit.KV{"type": rq.Msg.Type, "key": rq.Msg.Key, "rqId": rq.Msg.RequestId})
 	// validate request
-	if msg.RequestId == "" {
+	if rq.Msg.RequestId == "" {
 		return rpc.ErrRpcMsgNoRequestId(ctx)
 	}
-	if msg.Key == "" {
+	if rq.Msg.Key == "" {
 		return rpc.ErrRpcMsgNoKey(ctx)
 	}
-	if msg.ResponseRequired && callback == nil {
+	if rq.Msg.ResponseRequired && rq.Callback == nil {
 		return rpc.ErrRpcCallNoCb(ctx)
 	}
-	if msg.ResponseRequired {
+	if rq.Msg.ResponseRequired {
 		// put request to pool
-		r.rqPool.Queue(ctx, &rpc.Request{Ctx: ctx, Msg: msg, Callback: callback})
+		r.rqPool.Queue(ctx, rq)
 	}
-	// send to kafka
-	err := r.callProducer.Send(ctx, msg.Key, msg)
+	// send by transport
+	err := r.transport.Send(ctx, rq.Msg)
 	if err != nil {
 		return err
 	}
@@ -90,16 +151,14 @@ func (r *rpcClient) Call(ctx context.Context, msg *rpc.Message, callback rpc.Res
 }
 
 func (r *rpcClient) ResponseHandler(msg []byte) error {
+	return transport.KafkaHandler(r.response)(msg)
+}
 
-	// decode response
-	rawMsg, ctx, err := kafka.Decode[*rpc.RawMessage](context.Background(), msg)
-	if err != nil {
-		return err
-	}
+func (r *rpcClient) response(ctx context.Context, rawMsg *rpc.RawMessage) error {
 	l := r.l().C(ctx).Mth("response").F(kit.KV{"type": rawMsg.Type, "key": rawMsg.Key, "rqId": rawMsg.RequestId}).Dbg()
 
 	// validate raw message
-	err = r.validateRawMessage(ctx, rawMsg)
+	err := r.validateRawMessage(ctx, rawMsg)
 	if err != nil {
 		return err
 	}
@@ -112,6 +171,22 @@ func (r *rpcClient) ResponseHandler(msg []byte) error {
 		}
 	}
 
+	// if every node receives the request, a node not owning the key rejects at once, while the owner still might respond
+	// so the rejection is kept and returned on expiration unless the owner responds before
+	if r.deferNotOwned && rawMsg.Error != nil && rawMsg.Error.Code == rpc.ErrCodeRpcNotOwner {
+		rsMsg := &rpc.Message{
+			Type:      rawMsg.Type,
+			RequestId: rawMsg.RequestId,
+			Key:       rawMsg.Key,
+			Error:     rawMsg.Error,
+		}
+		if !r.rqPool.KeepNotOwned(rawMsg.RequestId, rsMsg) {
+			return rpc.ErrRpcRespNoRequestInPool(ctx, rawMsg.RequestId, rawMsg.Key)
+		}
+		l.Dbg("not owner, awaiting owner")
+		return nil
+	}
+
 	// check for request in pool
 	rq := r.rqPool.TryDequeue(rawMsg.RequestId)
 	if rq == nil {
@@ -124,13 +199,17 @@ func (r *rpcClient) ResponseHandler(msg []byte) error {
 		RequestId:        rawMsg.RequestId,
 		Key:              rawMsg.Key,
 		ResponseRequired: rawMsg.ResponseRequired,
+		Error:            rawMsg.Error,
 	}
 
 	// check for body type provider
 	// if provider registered, try to convert raw body to the provided type
 	// otherwise response with the raw body
+	// a response with error has no body
 	provider, ok := r.bodyTypeProviders[rawMsg.Type]
-	if ok {
+	if rawMsg.Error != nil {
+		rsMsg.Body = nil
+	} else if ok {
 		bodyTyped := provider()
 		d, _ := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 			TagName:    "json",
@@ -2,6 +2,8 @@ package client
 
 import (
 	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -9,6 +11,9 @@ import (
 	"github.com/mikhailbolshakov/kit/kafka"
 	"github.com/mikhailbolshakov/kit/mocks"
 	"github.com/mikhailbolshakov/kit/rpc"
+	"github.com/mikhailbolshakov/kit/rpc/server"
+	"github.com/mikhailbolshakov/kit/rpc/transport"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -164,3 +169,363 @@ func (s *rpcClientTestSuite) Test_Call_WhenRequestTimeout_Ok() {
 	s.Equal(0, rpcCl.rqPool.Len())
 	s.Equal(msg.RequestId, actualExpiredMsg.RequestId)
 }
+
+func (s *rpcClientTestSuite) Test_Call_OverMemoryBroker_Ok() {
+	requestTopic := &kafka.TopicConfig{Topic: kit.NewRandString()}
+	responseTopic := &kafka.TopicConfig{Topic: kit.NewRandString()}
+	brokerCfg := &kafka.BrokerConfig{ClientId: kit.NewRandString(), Url: kit.NewRandString(), TopicAutoCreation: true}
+	subCfg := kafka.NewSubscriberCfgBuilder().Workers(1).CommitInterval(time.Millisecond * 50).Build()
+
+	// server side
+	srvBroker := kafka.NewMemoryBroker(s.logger)
+	s.NoError(srvBroker.Init(s.Ctx, brokerCfg))
+	srvProducer, err := srvBroker.AddProducer(s.Ctx, responseTopic, kafka.NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	srv := server.NewServer(s.logger, srvProducer, rpc.NewDistributedKeys(), &rpc.Config{})
+	srv.RegisterType(rpc.MessageType(1), func(ctx context.Context, msg *rpc.Message) error {
+		return srv.Response(ctx, &rpc.Message{
+			Type:      msg.Type,
+			Key:       msg.Key,
+			RequestId: msg.RequestId,
+			Body:      &Body{Value: msg.Body.(*Body).Value + "-response"},
+		})
+	}, func() interface{} { return &Body{} })
+	s.NoError(srvBroker.AddSubscriber(s.Ctx, requestTopic, subCfg, srv.RequestHandler))
+	s.NoError(srvBroker.Start(s.Ctx))
+	defer srvBroker.Close(s.Ctx)
+
+	// client side
+	clBroker := kafka.NewMemoryBroker(s.logger)
+	s.NoError(clBroker.Init(s.Ctx, brokerCfg))
+	clProducer, err := clBroker.AddProducer(s.Ctx, requestTopic, kafka.NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	rpcCl := NewClient(s.logger, clProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5})
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+	s.NoError(clBroker.AddSubscriber(s.Ctx, responseTopic, subCfg, rpcCl.ResponseHandler))
+	s.NoError(clBroker.Start(s.Ctx))
+	defer clBroker.Close(s.Ctx)
+
+	// call
+	msg := &rpc.Message{
+		Type:             rpc.MessageType(1),
+		Key:              kit.NewRandString(),
+		RequestId:        kit.NewRandString(),
+		ResponseRequired: true,
+		Body:             &Body{Value: "request"},
+	}
+	responses := make(chan *rpc.Message, 1)
+	s.NoError(rpcCl.Call(s.Ctx, msg, func(ctx context.Context, rqMsg, rsMsg *rpc.Message) error {
+		responses <- rsMsg
+		return nil
+	}))
+
+	select {
+	case rsMsg := <-responses:
+		s.Equal(msg.RequestId, rsMsg.RequestId)
+		s.Equal("request-response", rsMsg.Body.(*Body).Value)
+	case <-time.After(time.Second * 3):
+		s.Fatal("no response")
+	}
+}
+
+// respond sets up the call producer to respond to a request with the body
+func (s *rpcClientTestSuite) respond(rpcCl rpc.Client, body interface{}) {
+	s.callProducer.On("Send", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		rqMsg := args.Get(2).(*rpc.Message)
+		rsMsg := &rpc.Message{Key: rqMsg.Key, RequestId: rqMsg.RequestId, Type: rqMsg.Type, Body: body}
+		kafkaMsgBytes, _ := kit.Marshal(&kafka.Message{Key: rsMsg.Key, Payload: rsMsg})
+		go func() { _ = rpcCl.ResponseHandler(kafkaMsgBytes) }()
+	}).Return(nil)
+}
+
+func (s *rpcClientTestSuite) Test_Await_Ok() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5}).(*rpcClient)
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+	s.respond(rpcCl, &Body{Value: "response"})
+	msg := &rpc.Message{
+		Type:      rpc.MessageType(1),
+		Key:       kit.NewRandString(),
+		RequestId: kit.NewRandString(),
+		Body:      &Body{Value: kit.NewRandString()},
+	}
+	rsMsg, err := rpcCl.Await(s.Ctx, msg)
+	s.NoError(err)
+	s.Equal(msg.RequestId, rsMsg.RequestId)
+	s.Equal("response", rsMsg.Body.(*Body).Value)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
+
+func (s *rpcClientTestSuite) Test_CallSync_Ok() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5})
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+	s.respond(rpcCl, &Body{Value: "response"})
+	rs, err := rpc.CallSync[*Body, *Body](s.Ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.NoError(err)
+	s.Equal("response", rs.Value)
+}
+
+func (s *rpcClientTestSuite) Test_CallSync_WhenNoBodyTypeProvider_Fail() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5})
+	s.respond(rpcCl, &Body{Value: "response"})
+	_, err := rpc.CallSync[*Body, *Body](s.Ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.AssertAppErr(err, rpc.ErrCodeRpcRespBodyType)
+}
+
+func (s *rpcClientTestSuite) Test_Await_WhenCtxDeadline_Timeout() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5}).(*rpcClient)
+	s.callProducer.On("Send", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Millisecond*100)
+	defer cancel()
+	_, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.AssertAppErr(err, rpc.ErrCodeRpcCallTimeout)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
+
+func (s *rpcClientTestSuite) Test_Await_WhenCtxCancelled_Fail() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5}).(*rpcClient)
+	s.callProducer.On("Send", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	ctx, cancel := context.WithCancel(s.Ctx)
+	time.AfterFunc(time.Millisecond*100, cancel)
+	_, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.AssertAppErr(err, rpc.ErrCodeRpcCallCancelled)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
+
+func (s *rpcClientTestSuite) Test_Await_WhenRequestExpired_Timeout() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Millisecond * 100})
+	var expired atomic.Bool
+	rpcCl.SetExpirationCallback(func(ctx context.Context, msg *rpc.Message) error {
+		expired.Store(true)
+		return nil
+	})
+	s.callProducer.On("Send", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	rpcCl.Start(s.Ctx)
+	defer rpcCl.Close(s.Ctx)
+	_, err := rpc.CallSync[*Body, *Body](s.Ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.AssertAppErr(err, rpc.ErrCodeRpcCallTimeout)
+	if err := <-kit.Await(func() (bool, error) {
+		return expired.Load(), nil
+	}, time.Millisecond*100, time.Second*3); err != nil {
+		s.Fatal(err)
+	}
+}
+
+func (s *rpcClientTestSuite) Test_Await_WhenSendFailed_Fail() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5}).(*rpcClient)
+	s.callProducer.On("Send", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("failed"))
+	_, err := rpc.CallSync[*Body, *Body](s.Ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.Error(err)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
+
+func (s *rpcClientTestSuite) Test_CallSync_OverMemoryBroker_Ok() {
+	requestTopic := &kafka.TopicConfig{Topic: kit.NewRandString()}
+	responseTopic := &kafka.TopicConfig{Topic: kit.NewRandString()}
+	brokerCfg := &kafka.BrokerConfig{ClientId: kit.NewRandString(), Url: kit.NewRandString(), TopicAutoCreation: true}
+	subCfg := kafka.NewSubscriberCfgBuilder().Workers(1).CommitInterval(time.Millisecond * 50).Build()
+
+	// server side
+	srvBroker := kafka.NewMemoryBroker(s.logger)
+	s.NoError(srvBroker.Init(s.Ctx, brokerCfg))
+	srvProducer, err := srvBroker.AddProducer(s.Ctx, responseTopic, kafka.NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	srv := server.NewServer(s.logger, srvProducer, rpc.NewDistributedKeys(), &rpc.Config{})
+	srv.RegisterType(rpc.MessageType(1), func(ctx context.Context, msg *rpc.Message) error {
+		return srv.Response(ctx, &rpc.Message{
+			Type:      msg.Type,
+			Key:       msg.Key,
+			RequestId: msg.RequestId,
+			Body:      &Body{Value: msg.Body.(*Body).Value + "-response"},
+		})
+	}, func() interface{} { return &Body{} })
+	s.NoError(srvBroker.AddSubscriber(s.Ctx, requestTopic, subCfg, srv.RequestHandler))
+	s.NoError(srvBroker.Start(s.Ctx))
+	defer srvBroker.Close(s.Ctx)
+
+	// client side
+	clBroker := kafka.NewMemoryBroker(s.logger)
+	s.NoError(clBroker.Init(s.Ctx, brokerCfg))
+	clProducer, err := clBroker.AddProducer(s.Ctx, requestTopic, kafka.NewProducerCfgBuilder().Build())
+	s.NoError(err)
+	rpcCl := NewClient(s.logger, clProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5})
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+	s.NoError(clBroker.AddSubscriber(s.Ctx, responseTopic, subCfg, rpcCl.ResponseHandler))
+	s.NoError(clBroker.Start(s.Ctx))
+	defer clBroker.Close(s.Ctx)
+
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Second*3)
+	defer cancel()
+	rs, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.NoError(err)
+	s.Equal("request-response", rs.Value)
+}
+
+func (s *rpcClientTestSuite) Test_CallSync_OverMemoryTransport_Ok() {
+	clTransport, srvTransport := transport.NewMemoryTransport(s.logger)
+
+	srv := server.NewServerWithTransport(s.logger, srvTransport, rpc.NewDistributedKeys(), &rpc.Config{})
+	srv.RegisterType(rpc.MessageType(1), func(ctx context.Context, msg *rpc.Message) error {
+		return srv.Response(ctx, &rpc.Message{
+			Type:      msg.Type,
+			Key:       msg.Key,
+			RequestId: msg.RequestId,
+			Body:      &Body{Value: msg.Body.(*Body).Value + "-response"},
+		})
+	}, func() interface{} { return &Body{} })
+
+	rpcCl := NewClientWithTransport(s.logger, clTransport, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5})
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Second*3)
+	defer cancel()
+	rs, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.NoError(err)
+	s.Equal("request-response", rs.Value)
+}
+
+// clusterKeys is a cluster keys stub with all keys owned by another node
+type clusterKeys struct {
+	rpc.DistributedKeys
+	owner string
+}
+
+func (c *clusterKeys) NodeId() string                                         { return "node-1" }
+func (c *clusterKeys) Owner(ctx context.Context, key string) (string, error)  { return c.owner, nil }
+func (c *clusterKeys) OnOwnershipChange(callback rpc.OwnershipChangeCallback) {}
+func (c *clusterKeys) Start(ctx context.Context) error                        { return nil }
+func (c *clusterKeys) Close(ctx context.Context)                              {}
+
+func (s *rpcClientTestSuite) Test_CallSync_WhenKeyNotOwned_NotOwner() {
+	clTransport, srvTransport := transport.NewMemoryTransport(s.logger)
+
+	keys := &clusterKeys{DistributedKeys: rpc.NewDistributedKeys(), owner: "node-2"}
+	srv := server.NewServerWithTransport(s.logger, srvTransport, keys, &rpc.Config{ClusterSupport: true, RejectNotOwned: true})
+	srv.RegisterType(rpc.MessageType(1), func(ctx context.Context, msg *rpc.Message) error {
+		return srv.Response(ctx, &rpc.Message{Type: msg.Type, Key: msg.Key, RequestId: msg.RequestId, Body: msg.Body})
+	}, func() interface{} { return &Body{} })
+
+	// the not-owner reply is returned at once
+	rpcCl := NewClientWithTransport(s.logger, clTransport, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5}).(*rpcClient)
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+	rpcCl.Start(s.Ctx)
+	defer rpcCl.Close(s.Ctx)
+
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Second*3)
+	defer cancel()
+	_, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.AssertAppErr(err, rpc.ErrCodeRpcNotOwner)
+	owner, ok := rpc.NotOwner(err)
+	s.True(ok)
+	s.Equal("node-2", owner)
+	s.Equal(0, rpcCl.rqPool.Len())
+
+	// the key is owned, so the request is handled
+	key := kit.NewRandString()
+	keys.Set(key)
+	rs, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), key, &Body{Value: "request"})
+	s.NoError(err)
+	s.Equal("request", rs.Value)
+}
+
+func (s *rpcClientTestSuite) Test_CallSync_WhenKeyNotOwnedAndBroadcast_NotOwnerOnExpiration() {
+	clTransport, srvTransport := transport.NewMemoryTransport(s.logger)
+
+	keys := &clusterKeys{DistributedKeys: rpc.NewDistributedKeys(), owner: "node-2"}
+	srv := server.NewServerWithTransport(s.logger, srvTransport, keys, &rpc.Config{ClusterSupport: true, RejectNotOwned: true})
+	srv.RegisterType(rpc.MessageType(1), func(ctx context.Context, msg *rpc.Message) error {
+		return srv.Response(ctx, &rpc.Message{Type: msg.Type, Key: msg.Key, RequestId: msg.RequestId, Body: msg.Body})
+	}, func() interface{} { return &Body{} })
+
+	// the owner might respond, so the not-owner reply is returned once the call expires
+	rpcCl := NewClientWithTransport(s.logger, clTransport, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Millisecond * 300, BroadcastRequests: true}).(*rpcClient)
+	rpcCl.RegisterBodyTypeProvider(rpc.MessageType(1), func() interface{} { return &Body{} })
+	rpcCl.Start(s.Ctx)
+	defer rpcCl.Close(s.Ctx)
+
+	ctx, cancel := context.WithTimeout(s.Ctx, time.Second*3)
+	defer cancel()
+	started := time.Now()
+	_, err := rpc.CallSync[*Body, *Body](ctx, rpcCl, rpc.MessageType(1), kit.NewRandString(), &Body{Value: "request"})
+	s.AssertAppErr(err, rpc.ErrCodeRpcNotOwner)
+	owner, ok := rpc.NotOwner(err)
+	s.True(ok)
+	s.Equal("node-2", owner)
+	s.GreaterOrEqual(time.Since(started), time.Millisecond*300)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
+
+func (s *rpcClientTestSuite) Test_Call_WhenNotOwner_RespondedAtOnce() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5}).(*rpcClient)
+	msg := &rpc.Message{
+		Type:             rpc.MessageType(1),
+		Key:              kit.NewRandString(),
+		RequestId:        kit.NewRandString(),
+		ResponseRequired: true,
+		Body:             &Body{Value: kit.NewRandString()},
+	}
+	s.callProducer.On("Send", s.Ctx, msg.Key, msg).Return(nil)
+	rpcCl.RegisterBodyTypeProvider(msg.Type, func() interface{} { return &Body{} })
+	var rsCount atomic.Int32
+	var actualRsMsg atomic.Pointer[rpc.Message]
+	s.NoError(rpcCl.Call(s.Ctx, msg, func(ctx context.Context, rqMsg, rsMsg *rpc.Message) error {
+		rsCount.Add(1)
+		actualRsMsg.Store(rsMsg)
+		return nil
+	}))
+
+	notOwnerBytes, _ := kit.Marshal(&kafka.Message{Key: msg.Key, Payload: &rpc.Message{
+		Key:       msg.Key,
+		RequestId: msg.RequestId,
+		Type:      msg.Type,
+		Error:     &rpc.ResponseError{Code: rpc.ErrCodeRpcNotOwner, Message: "not owner", Owner: "node-2"},
+	}})
+	s.NoError(rpcCl.ResponseHandler(notOwnerBytes))
+	s.Equal(int32(1), rsCount.Load())
+	s.Equal(rpc.ErrCodeRpcNotOwner, actualRsMsg.Load().Error.Code)
+	s.Equal("node-2", actualRsMsg.Load().Error.Owner)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
+
+func (s *rpcClientTestSuite) Test_Call_WhenBroadcastAndNotOwnerRepliesFirst_OwnerResponseWins() {
+	rpcCl := NewClient(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{CallTimeOut: time.Second * 5, BroadcastRequests: true}).(*rpcClient)
+	msg := &rpc.Message{
+		Type:             rpc.MessageType(1),
+		Key:              kit.NewRandString(),
+		RequestId:        kit.NewRandString(),
+		ResponseRequired: true,
+		Body:             &Body{Value: kit.NewRandString()},
+	}
+	s.callProducer.On("Send", s.Ctx, msg.Key, msg).Return(nil)
+	rpcCl.RegisterBodyTypeProvider(msg.Type, func() interface{} { return &Body{} })
+	var rsCount atomic.Int32
+	var actualRsMsg atomic.Pointer[rpc.Message]
+	s.NoError(rpcCl.Call(s.Ctx, msg, func(ctx context.Context, rqMsg, rsMsg *rpc.Message) error {
+		rsCount.Add(1)
+		actualRsMsg.Store(rsMsg)
+		return nil
+	}))
+
+	// every node receives the request, a not-owner replies first
+	notOwnerBytes, _ := kit.Marshal(&kafka.Message{Key: msg.Key, Payload: &rpc.Message{
+		Key:       msg.Key,
+		RequestId: msg.RequestId,
+		Type:      msg.Type,
+		Error:     &rpc.ResponseError{Code: rpc.ErrCodeRpcNotOwner, Message: "not owner", Owner: "node-2"},
+	}})
+	s.NoError(rpcCl.ResponseHandler(notOwnerBytes))
+	s.Equal(int32(0), rsCount.Load())
+	s.Equal(1, rpcCl.rqPool.Len())
+
+	// the owner responds
+	ownerBytes, _ := kit.Marshal(&kafka.Message{Key: msg.Key, Payload: &rpc.Message{
+		Key:       msg.Key,
+		RequestId: msg.RequestId,
+		Type:      msg.Type,
+		Body:      &Body{Value: "response"},
+	}})
+	s.NoError(rpcCl.ResponseHandler(ownerBytes))
+	s.Equal(int32(1), rsCount.Load())
+	s.Nil(actualRsMsg.Load().Error)
+	s.Equal("response", actualRsMsg.Load().Body.(*Body).Value)
+	s.Equal(0, rpcCl.rqPool.Len())
+}
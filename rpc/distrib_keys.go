@@ -1,6 +1,9 @@
 package rpc
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // DistributedKeys is useful when some processing happens in cluster environment (multiple replicas)
 // some business keys might be processed on particular replicas
@@ -11,6 +14,33 @@ type DistributedKeys interface {
 	Check(key string) bool
 }
 
+// OwnershipChange describes a change of a key's owner
+// an empty owner means the key is released or expired, an empty previous owner means the key wasn't owned
+type OwnershipChange struct {
+	Key       string `json:"key"`
+	Owner     string `json:"owner,omitempty"`
+	PrevOwner string `json:"prev,omitempty"`
+}
+
+// OwnershipChangeCallback is called when a key's owner changes
+type OwnershipChangeCallback func(ctx context.Context, change *OwnershipChange)
+
+// ClusterKeys is DistributedKeys shared by nodes of a cluster
+// each key is owned by a single node, Set takes the ownership over and Check tells if the current node owns a key
+type ClusterKeys interface {
+	DistributedKeys
+	// NodeId returns id of the current node
+	NodeId() string
+	// Owner returns a node owning the key, empty if the key isn't owned
+	Owner(ctx context.Context, key string) (string, error)
+	// OnOwnershipChange sets up a callback called when an owner of any key changes
+	OnOwnershipChange(callback OwnershipChangeCallback)
+	// Start starts heartbeats and listening to ownership changes
+	Start(ctx context.Context) error
+	// Close releases keys of the node and stops background processes
+	Close(ctx context.Context)
+}
+
 type distrKeysImpl struct {
 	sync.RWMutex
 	keys map[string]struct{}
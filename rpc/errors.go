@@ -11,6 +11,15 @@ const (
 	ErrCodeRpcRespNoRequestInPool = "RPC-003"
 	ErrCodeRpcRespInvalidBody     = "RPC-004"
 	ErrCodeRpcCallNoCb            = "RPC-005"
+	ErrCodeRpcCallTimeout         = "RPC-006"
+	ErrCodeRpcCallCancelled       = "RPC-007"
+	ErrCodeRpcRespBodyType        = "RPC-008"
+	ErrCodeRpcTransportMarshal    = "RPC-009"
+	ErrCodeRpcTransportUnmarshal  = "RPC-010"
+	ErrCodeRpcTransportSend       = "RPC-011"
+	ErrCodeRpcTransportListen     = "RPC-012"
+	ErrCodeRpcNotOwner            = "RPC-013"
+	ErrCodeRpcTransportNoReplyTo  = "RPC-014"
 )
 
 var (
@@ -29,4 +38,53 @@ var (
 	ErrRpcRespInvalidBody = func(cause error, ctx context.Context, rqId, key string) error {
 		return kit.NewAppErrBuilder(ErrCodeRpcRespInvalidBody, "no Request in pool").Wrap(cause).C(ctx).F(kit.KV{"rqId": rqId, "key": key}).Err()
 	}
+	ErrRpcCallTimeout = func(ctx context.Context, rqId, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcCallTimeout, "call timeout").C(ctx).F(kit.KV{"rqId": rqId, "key": key}).Err()
+	}
+	ErrRpcCallCancelled = func(ctx context.Context, rqId, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcCallCancelled, "call cancelled").C(ctx).F(kit.KV{"rqId": rqId, "key": key}).Err()
+	}
+	ErrRpcRespBodyType = func(ctx context.Context, rqId, key string, body interface{}) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcRespBodyType, "unexpected response body type %T", body).C(ctx).F(kit.KV{"rqId": rqId, "key": key}).Err()
+	}
+	ErrRpcTransportMarshal = func(cause error, ctx context.Context, rqId, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcTransportMarshal, "transport: marshal").Wrap(cause).C(ctx).F(kit.KV{"rqId": rqId, "key": key}).Err()
+	}
+	ErrRpcTransportUnmarshal = func(cause error, ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcTransportUnmarshal, "transport: unmarshal").Wrap(cause).C(ctx).Err()
+	}
+	ErrRpcTransportSend = func(cause error, ctx context.Context, channel string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcTransportSend, "transport: send").Wrap(cause).C(ctx).F(kit.KV{"channel": channel}).Err()
+	}
+	ErrRpcNotOwner = func(ctx context.Context, rqId, key, owner string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcNotOwner, "not owner, retry on the owner node").C(ctx).F(kit.KV{"rqId": rqId, "key": key, "owner": owner}).Err()
+	}
+	ErrRpcTransportListen = func(cause error, ctx context.Context, channel string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcTransportListen, "transport: listen").Wrap(cause).C(ctx).F(kit.KV{"channel": channel}).Err()
+	}
+	ErrRpcTransportNoReplyTo = func(ctx context.Context, rqId, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRpcTransportNoReplyTo, "transport: no reply address").C(ctx).F(kit.KV{"rqId": rqId, "key": key}).Err()
+	}
 )
+
+// ResponseErr converts an error of a response to an application error, nil is returned if the response has no error
+func ResponseErr(ctx context.Context, msg *Message) error {
+	if msg == nil || msg.Error == nil {
+		return nil
+	}
+	if msg.Error.Code == ErrCodeRpcNotOwner {
+		return ErrRpcNotOwner(ctx, msg.RequestId, msg.Key, msg.Error.Owner)
+	}
+	return kit.NewAppErrBuilder(msg.Error.Code, msg.Error.Message).C(ctx).F(kit.KV{"rqId": msg.RequestId, "key": msg.Key}).Err()
+}
+
+// NotOwner checks if a call is rejected as the key is owned by another node and returns the owner node
+// an empty owner means the key isn't owned by any node
+func NotOwner(err error) (string, bool) {
+	appErr, ok := kit.IsAppErr(err)
+	if !ok || appErr.Code() != ErrCodeRpcNotOwner {
+		return "", false
+	}
+	owner, _ := appErr.Fields()["owner"].(string)
+	return owner, true
+}
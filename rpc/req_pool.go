@@ -13,6 +13,10 @@ type Request struct {
 	Callback ResponseCallback
 	Msg      *Message
 	Ctx      context.Context
+	// Expired is called as the request expires in addition to the pool's expiration callback
+	Expired Callback
+	// notOwned a not-owner reply, the request is responded with it on expiration unless the owner responds before
+	notOwned *Message
 }
 
 // RequestPool manages incoming requests with ttl and execute handler as a Request expires
@@ -73,6 +77,18 @@ func (c *RequestPool) TryDequeue(rqId string) *Request {
 	return nil
 }
 
+// KeepNotOwned keeps a not-owner reply of the request rather than dequeuing it, so that the owner still might respond
+// the request is responded with the reply on expiration, it returns false if the request isn't in pool
+func (c *RequestPool) KeepNotOwned(rqId string, rs *Message) bool {
+	c.Lock()
+	defer c.Unlock()
+	if r, ok := c.rqs[rqId]; ok {
+		r.notOwned = rs
+		return true
+	}
+	return false
+}
+
 func (c *RequestPool) Start(ctx context.Context) {
 	c.cancelCtx, c.cancelFn = context.WithCancel(ctx)
 	goroutine.New().WithLogger(c.l()).Go(ctx, func() {
@@ -95,10 +111,28 @@ func (c *RequestPool) Start(ctx context.Context) {
 					}
 				}()
 				// execute handlers for all the expired
-				if len(expired) > 0 && c.expirationCallback != nil {
+				for rqId, val := range expired {
+					// no one but not-owners replied
+					if val.notOwned != nil && val.Callback != nil {
+						delete(expired, rqId)
+						if err := val.Callback(val.Ctx, val.Msg, val.notOwned); err != nil {
+							c.l().C(val.Ctx).E(err).Err()
+						}
+						continue
+					}
+					if val.Expired != nil {
+						if err := val.Expired(val.Ctx, val.Msg); err != nil {
+							c.l().C(val.Ctx).E(err).Err()
+						}
+					}
+				}
+				c.RLock()
+				expirationCallback := c.expirationCallback
+				c.RUnlock()
+				if len(expired) > 0 && expirationCallback != nil {
 					goroutine.New().WithLogger(c.l()).Go(ctx, func() {
 						for _, val := range expired {
-							if err := c.expirationCallback(val.Ctx, val.Msg); err != nil {
+							if err := expirationCallback(val.Ctx, val.Msg); err != nil {
 								c.l().C(val.Ctx).E(err).Err()
 							}
 						}
@@ -8,11 +8,13 @@ import (
 type MessageType uint
 
 type Message struct {
-	Type             MessageType `json:"type"`
-	RequestId        string      `json:"rqId"`
-	Key              string      `json:"key"`
-	ResponseRequired bool        `json:"respReq"`
-	Body             interface{} `json:"body"`
+	Type             MessageType    `json:"type"`
+	RequestId        string         `json:"rqId"`
+	Key              string         `json:"key"`
+	ResponseRequired bool           `json:"respReq"`
+	Body             interface{}    `json:"body"`
+	Error            *ResponseError `json:"err,omitempty"`
+	ReplyTo          string         `json:"replyTo,omitempty"` // ReplyTo address of the requesting node, it's set by transports routing responses per node
 }
 
 type RawMessage struct {
@@ -21,6 +23,15 @@ type RawMessage struct {
 	Key              string                 `json:"key"`
 	ResponseRequired bool                   `json:"respReq"`
 	Body             map[string]interface{} `json:"body"`
+	Error            *ResponseError         `json:"err,omitempty"`
+	ReplyTo          string                 `json:"replyTo,omitempty"`
+}
+
+// ResponseError is sent by a server instead of a response body when a request cannot be handled
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"msg"`
+	Owner   string `json:"owner,omitempty"` // Owner node owning the request key (ErrCodeRpcNotOwner)
 }
 
 // MessageBodyTypeProvider should return an instance of the type to which reply body is cast
@@ -29,9 +40,27 @@ type MessageBodyTypeProvider func() interface{}
 type Callback func(ctx context.Context, msg *Message) error
 type ResponseCallback func(ctx context.Context, rqMsg, rsMsg *Message) error
 
+// MessageHandler handles a message received by a transport, ctx carries the request context restored from the message
+type MessageHandler func(ctx context.Context, msg *RawMessage) error
+
+// Transport delivers messages of a client to a server and back (see rpc/transport for implementations)
+// a client's transport sends requests and receives responses, a server's transport does the opposite
+type Transport interface {
+	// Send sends a message to the other side, the request context is taken from ctx
+	Send(ctx context.Context, msg *Message) error
+	// Listen sets up a handler of messages received from the other side
+	Listen(handler MessageHandler)
+}
+
 type Client interface {
 	// Call makes a rpcClient call
+	// if a server cannot handle the request, the response has Error populated (see ResponseErr)
 	Call(ctx context.Context, msg *Message, callback ResponseCallback) error
+	// Await makes a rpcClient call and blocks until the response arrives
+	// it fails with ErrCodeRpcCallTimeout when either the call timeout or ctx deadline expires
+	// a response error is returned as an application error (e.g. ErrCodeRpcNotOwner)
+	// the response body is converted by the registered MessageBodyTypeProvider
+	Await(ctx context.Context, msg *Message) (*Message, error)
 	// ResponseHandler must be setup as a subscriber of a response topic
 	ResponseHandler(msg []byte) error
 	// RegisterBodyTypeProvider allows providing a type to a response body to convert to
@@ -53,6 +82,13 @@ type Config struct {
 	// Thus, handler skips messages with keys which aren't presented in connection list
 	// Note, it's client's responsibility to support connection list in sync
 	ClusterSupport bool
+	// RejectNotOwned with ClusterSupport a server replies to a request of a key it doesn't own with ErrCodeRpcNotOwner rather than skipping it
+	// the reply carries the owner node if DistributedKeys is ClusterKeys
+	// a client returns a not-owner reply at once unless BroadcastRequests is set
+	RejectNotOwned bool
+	// BroadcastRequests must be set on a client if the transport delivers every request to all the servers (e.g. kafka subscribers of different groups)
+	// then not-owners reply as well as the owner does, so the client keeps a not-owner reply awaiting the owner and returns it once CallTimeOut expires
+	BroadcastRequests bool
 }
 
 type Server interface {
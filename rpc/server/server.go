@@ -7,6 +7,7 @@ import (
 	"github.com/mikhailbolshakov/kit"
 	"github.com/mikhailbolshakov/kit/kafka"
 	"github.com/mikhailbolshakov/kit/rpc"
+	"github.com/mikhailbolshakov/kit/rpc/transport"
 	"github.com/mitchellh/mapstructure"
 )
 
@@ -17,23 +18,34 @@ type msgType struct {
 type msgTypes map[rpc.MessageType]*msgType
 
 type rpcServer struct {
-	callProducer    kafka.Producer
+	transport       rpc.Transport
 	rqPool          *rpc.RequestPool
 	logger          kit.CLoggerFunc
 	msgTypes        msgTypes
 	clusterSupport  bool
+	rejectNotOwned  bool
 	distributedKeys rpc.DistributedKeys
 }
 
+// NewServer creates a server sending responses by the kafka producer
+// RequestHandler must be set up as a subscriber of a request topic
 func NewServer(logger kit.CLoggerFunc, callProducer kafka.Producer, distributedKeys rpc.DistributedKeys, config *rpc.Config) rpc.Server {
-	return &rpcServer{
-		callProducer:    callProducer,
+	return NewServerWithTransport(logger, transport.NewKafkaTransport(callProducer), distributedKeys, config)
+}
+
+// NewServerWithTransport creates a server receiving requests and sending responses by the transport
+func NewServerWithTransport(logger kit.CLoggerFunc, tr rpc.Transport, distributedKeys rpc.DistributedKeys, config *rpc.Config) rpc.Server {
+	r := &rpcServer{
+		transport:       tr,
 		logger:          logger,
 		rqPool:          rpc.NewRequestPool(logger, config.CallTimeOut),
 		distributedKeys: distributedKeys,
 		msgTypes:        msgTypes{},
 		clusterSupport:  config.ClusterSupport,
+		rejectNotOwned:  config.RejectNotOwned,
 	}
+	tr.Listen(r.request)
+	return r
 }
 
 func (r *rpcServer) l() kit.CLogger {
@@ -65,16 +77,14 @@ func (r *rpcServer) validateRawMessage(ctx context.Context, msg *rpc.RawMessage)
 }
 
 func (r *rpcServer) RequestHandler(msg []byte) error {
+	return transport.KafkaHandler(r.request)(msg)
+}
 
-	// decode response
-	rawMsg, ctx, err := kafka.Decode[*rpc.RawMessage](context.Background(), msg)
-	if err != nil {
-		return err
-	}
+func (r *rpcServer) request(ctx context.Context, rawMsg *rpc.RawMessage) error {
 	l := r.l().C(ctx).Mth("request").F(kit.KV{"type": rawMsg.Type, "key": rawMsg.Key, "rqId": rawMsg.RequestId}).Dbg()
 
 	// validate raw message
-	err = r.validateRawMessage(ctx, rawMsg)
+	err := r.validateRawMessage(ctx, rawMsg)
 	if err != nil {
 		return err
 	}
@@ -82,6 +92,9 @@ func (r *rpcServer) RequestHandler(msg []byte) error {
 	// cluster support, check key in the connection list
 	if r.clusterSupport && r.distributedKeys != nil {
 		if !r.distributedKeys.Check(rawMsg.Key) {
+			if r.rejectNotOwned && rawMsg.ResponseRequired {
+				return r.rejectNotOwner(ctx, rawMsg)
+			}
 			l.Dbg("no key, skip")
 			return nil
 		}
@@ -101,6 +114,7 @@ func (r *rpcServer) RequestHandler(msg []byte) error {
 		RequestId:        rawMsg.RequestId,
 		Key:              rawMsg.Key,
 		ResponseRequired: rawMsg.ResponseRequired,
+		ReplyTo:          rawMsg.ReplyTo,
 	}
 
 	// check for registered message type
@@ -141,6 +155,38 @@ func (r *rpcServer) RequestHandler(msg []byte) error {
 	return nil
 }
 
+// rejectNotOwner replies to a request of a key owned by another node with the owner
+func (r *rpcServer) rejectNotOwner(ctx context.Context, rawMsg *rpc.RawMessage) error {
+	l := r.l().C(ctx).Mth("reject").F(kit.KV{"type": rawMsg.Type, "key": rawMsg.Key, "rqId": rawMsg.RequestId})
+
+	// owner is known if keys are tracked across the cluster
+	var owner string
+	if clusterKeys, ok := r.distributedKeys.(rpc.ClusterKeys); ok {
+		var err error
+		owner, err = clusterKeys.Owner(ctx, rawMsg.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := r.transport.Send(ctx, &rpc.Message{
+		Type:      rawMsg.Type,
+		RequestId: rawMsg.RequestId,
+		Key:       rawMsg.Key,
+		Error: &rpc.ResponseError{
+			Code:    rpc.ErrCodeRpcNotOwner,
+			Message: "not owner",
+			Owner:   owner,
+		},
+		ReplyTo: rawMsg.ReplyTo,
+	})
+	if err != nil {
+		return err
+	}
+	l.F(kit.KV{"owner": owner}).Dbg("ok")
+	return nil
+}
+
 func (r *rpcServer) RegisterType(messageType rpc.MessageType, callback rpc.Callback, provider rpc.MessageBodyTypeProvider) {
 	r.msgTypes[messageType] = &msgType{
 		callback:     callback,
@@ -162,8 +208,12 @@ func (r *rpcServer) Response(ctx context.Context, msg *rpc.Message) error {
 	if rq == nil {
 		return rpc.ErrRpcRespNoRequestInPool(ctx, msg.RequestId, msg.Key)
 	}
-	// send to kafka
-	err := r.callProducer.Send(ctx, msg.Key, msg)
+	// the response goes back to the requesting node
+	if msg.ReplyTo == "" {
+		msg.ReplyTo = rq.Msg.ReplyTo
+	}
+	// send by transport
+	err := r.transport.Send(ctx, msg)
 	if err != nil {
 		return err
 	}
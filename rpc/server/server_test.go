@@ -9,6 +9,7 @@ import (
 	"github.com/mikhailbolshakov/kit/kafka"
 	"github.com/mikhailbolshakov/kit/mocks"
 	"github.com/mikhailbolshakov/kit/rpc"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -140,3 +141,49 @@ func (s *rpcServerTestSuite) Test_Call_WhenRequestTimeout_Ok() {
 	s.Equal(0, rpcServer.rqPool.Len())
 	s.Equal(msg.RequestId, actualExpiredMsg.RequestId)
 }
+
+func (s *rpcServerTestSuite) Test_Call_WhenKeyNotOwned_RejectNotOwner() {
+	rpcServer := NewServer(s.logger, s.callProducer, rpc.NewDistributedKeys(),
+		&rpc.Config{ClusterSupport: true, RejectNotOwned: true}).(*rpcServer)
+	msg := &rpc.Message{
+		Type:             rpc.MessageType(1),
+		Key:              kit.NewRandString(),
+		RequestId:        kit.NewRandString(),
+		ResponseRequired: true,
+		Body:             &Body{Value: kit.NewRandString()},
+	}
+	rejectMsg := &rpc.Message{
+		Type:      msg.Type,
+		Key:       msg.Key,
+		RequestId: msg.RequestId,
+		Error:     &rpc.ResponseError{Code: rpc.ErrCodeRpcNotOwner, Message: "not owner"},
+	}
+	s.callProducer.On("Send", mock.Anything, msg.Key, rejectMsg).Return(nil)
+	called := false
+	rpcServer.RegisterType(msg.Type, func(ctx context.Context, msg *rpc.Message) error {
+		called = true
+		return nil
+	}, func() interface{} { return &Body{} })
+	kafkaMsgBytes, _ := kit.Marshal(&kafka.Message{Key: msg.Key, Payload: msg})
+	s.Nil(rpcServer.RequestHandler(kafkaMsgBytes))
+	s.False(called)
+	s.Equal(0, rpcServer.rqPool.Len())
+	s.AssertCalled(&s.callProducer.Mock, "Send", mock.Anything, msg.Key, rejectMsg)
+}
+
+func (s *rpcServerTestSuite) Test_Call_WhenKeyNotOwnedAndNoReject_Skip() {
+	rpcServer := NewServer(s.logger, s.callProducer, rpc.NewDistributedKeys(), &rpc.Config{ClusterSupport: true}).(*rpcServer)
+	msg := &rpc.Message{
+		Type:             rpc.MessageType(1),
+		Key:              kit.NewRandString(),
+		RequestId:        kit.NewRandString(),
+		ResponseRequired: true,
+		Body:             &Body{Value: kit.NewRandString()},
+	}
+	rpcServer.RegisterType(msg.Type, func(ctx context.Context, msg *rpc.Message) error {
+		return nil
+	}, func() interface{} { return &Body{} })
+	kafkaMsgBytes, _ := kit.Marshal(&kafka.Message{Key: msg.Key, Payload: msg})
+	s.Nil(rpcServer.RequestHandler(kafkaMsgBytes))
+	s.AssertNotCalled(&s.callProducer.Mock, "Send", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,28 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+// CallSync makes a call with a request body and blocks until the response arrives (see Client.Await)
+// a response body type provider must be registered for the message type, so that the body is converted to TRs
+func CallSync[TRq, TRs any](ctx context.Context, client Client, msgType MessageType, key string, rq TRq) (TRs, error) {
+	var r TRs
+	rs, err := client.Await(ctx, &Message{
+		Type:             msgType,
+		RequestId:        kit.NewId(),
+		Key:              key,
+		ResponseRequired: true,
+		Body:             rq,
+	})
+	if err != nil {
+		return r, err
+	}
+	body, ok := rs.Body.(TRs)
+	if !ok {
+		return r, ErrRpcRespBodyType(ctx, rs.RequestId, rs.Key, rs.Body)
+	}
+	return body, nil
+}
@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/mikhailbolshakov/kit/rpc"
+)
+
+// KafkaTransport sends messages by a kafka producer and receives messages by a kafka subscriber
+type KafkaTransport struct {
+	producer kafka.Producer
+	handler  rpc.MessageHandler
+}
+
+// NewKafkaTransport creates a transport sending messages to the producer's topic
+// Handler must be set up as a subscriber of the topic the other side sends to
+func NewKafkaTransport(producer kafka.Producer) *KafkaTransport {
+	return &KafkaTransport{
+		producer: producer,
+	}
+}
+
+func (t *KafkaTransport) Send(ctx context.Context, msg *rpc.Message) error {
+	return t.producer.Send(ctx, msg.Key, msg)
+}
+
+func (t *KafkaTransport) Listen(handler rpc.MessageHandler) {
+	t.handler = handler
+}
+
+// Handler handles kafka messages passing them to the listener
+func (t *KafkaTransport) Handler(msg []byte) error {
+	if t.handler == nil {
+		return nil
+	}
+	return KafkaHandler(t.handler)(msg)
+}
+
+// KafkaHandler converts a message handler to a kafka subscriber's handler
+func KafkaHandler(handler rpc.MessageHandler) kafka.HandlerFn {
+	return func(msg []byte) error {
+		rawMsg, ctx, err := kafka.Decode[*rpc.RawMessage](context.Background(), msg)
+		if err != nil {
+			return err
+		}
+		return handler(ctx, rawMsg)
+	}
+}
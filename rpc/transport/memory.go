@@ -0,0 +1,67 @@
+package transport
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/mikhailbolshakov/kit/rpc"
+)
+
+// memoryTransport delivers messages to the peer in-process
+type memoryTransport struct {
+	sync.RWMutex
+	logger  kit.CLoggerFunc
+	peer    *memoryTransport
+	handler rpc.MessageHandler
+}
+
+// NewMemoryTransport creates a pair of connected in-process transports for a client and a server
+// messages are marshaled and delivered asynchronously, so the semantics is the same as with a broker
+// it's intended for tests and single process setups
+func NewMemoryTransport(logger kit.CLoggerFunc) (client rpc.Transport, server rpc.Transport) {
+	cl := &memoryTransport{logger: logger}
+	srv := &memoryTransport{logger: logger, peer: cl}
+	cl.peer = srv
+	return cl, srv
+}
+
+func (t *memoryTransport) l() kit.CLogger {
+	return t.logger().Cmp("rpc-memory-transport")
+}
+
+func (t *memoryTransport) Send(ctx context.Context, msg *rpc.Message) error {
+	data, err := encode(ctx, msg)
+	if err != nil {
+		return err
+	}
+	handler := t.peer.getHandler()
+	if handler == nil {
+		t.l().C(ctx).Mth("send").F(kit.KV{"rqId": msg.RequestId, "key": msg.Key}).Warn("no listener, skip")
+		return nil
+	}
+	goroutine.New().WithLogger(t.l().Mth("deliver")).Go(context.Background(), func() {
+		rCtx, rawMsg, err := decode(data)
+		if err != nil {
+			t.l().Mth("deliver").E(err).Err()
+			return
+		}
+		if err := handler(rCtx, rawMsg); err != nil {
+			t.l().C(rCtx).Mth("deliver").F(kit.KV{"rqId": rawMsg.RequestId, "key": rawMsg.Key}).E(err).Err()
+		}
+	})
+	return nil
+}
+
+func (t *memoryTransport) Listen(handler rpc.MessageHandler) {
+	t.Lock()
+	defer t.Unlock()
+	t.handler = handler
+}
+
+func (t *memoryTransport) getHandler() rpc.MessageHandler {
+	t.RLock()
+	defer t.RUnlock()
+	return t.handler
+}
@@ -0,0 +1,280 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/mikhailbolshakov/kit/rpc"
+	kitRedis "github.com/mikhailbolshakov/kit/storages/redis"
+)
+
+const (
+	defaultRedisWorkers       = 4
+	defaultRedisGroup         = "rpc"
+	defaultRedisStreamMaxLen  = 10000
+	redisResponseChannelInfix = ":rs:"
+	redisStreamDataField      = "data"
+	// a blocking read returns at least once per this period, so that workers notice closing
+	redisReadBlock = time.Second
+)
+
+// RedisClientConfig redis client transport configuration
+type RedisClientConfig struct {
+	RequestStream   string // RequestStream stream requests are added to
+	ResponseChannel string // ResponseChannel channel responses are received from, it must be unique per node (default: <RequestStream>:rs:<random id>)
+	StreamMaxLen    int64  // StreamMaxLen approximate max length of the request stream, older requests are trimmed (default: 10000)
+	Workers         int    // Workers number of responses handled concurrently (default: 4)
+}
+
+// RedisServerConfig redis server transport configuration
+type RedisServerConfig struct {
+	RequestStream string // RequestStream stream requests are read from
+	Group         string // Group consumer group shared by the servers, each request is delivered to a single server of the group (default: "rpc")
+	Consumer      string // Consumer name of the node in the group, it must be unique per node (default: random id)
+	Workers       int    // Workers number of requests handled concurrently (default: 4)
+}
+
+// redisTransport is a common part of the client and server transports
+type redisTransport struct {
+	logger  kit.CLoggerFunc
+	redis   *kitRedis.Redis
+	handler rpc.MessageHandler
+	workers sync.WaitGroup
+}
+
+func (t *redisTransport) l() kit.CLogger {
+	return t.logger().Cmp("rpc-redis-transport")
+}
+
+func (t *redisTransport) Listen(handler rpc.MessageHandler) {
+	t.handler = handler
+}
+
+// startWorkers runs n workers, a panicked worker is restarted, so it's done only when it returns
+func (t *redisTransport) startWorkers(ctx context.Context, n int, work func()) {
+	t.workers.Add(n)
+	for i := 0; i < n; i++ {
+		goroutine.New().WithLogger(t.l().Mth("worker")).WithRetry(goroutine.Unrestricted).Go(ctx, func() {
+			work()
+			t.workers.Done()
+		})
+	}
+}
+
+func (t *redisTransport) handle(data []byte) {
+	rCtx, rawMsg, err := decode(data)
+	if err != nil {
+		t.l().Mth("handle").E(err).Err()
+		return
+	}
+	if t.handler == nil {
+		return
+	}
+	if err := t.handler(rCtx, rawMsg); err != nil {
+		t.l().C(rCtx).Mth("handle").F(kit.KV{"rqId": rawMsg.RequestId, "key": rawMsg.Key}).E(err).Err()
+	}
+}
+
+// RedisClientTransport sends requests to a redis stream and receives responses from a pub/sub channel of the node
+// servers reply to the channel taken from a request, so a node receives responses to its own requests only
+// pub/sub has at most once semantics, a response published when the client doesn't listen is lost and the request expires
+type RedisClientTransport struct {
+	redisTransport
+	cfg    *RedisClientConfig
+	pubSub *redis.PubSub
+}
+
+// NewRedisClientTransport creates a client transport, it must be paired with servers' RedisServerTransport reading RequestStream
+func NewRedisClientTransport(logger kit.CLoggerFunc, client *kitRedis.Redis, cfg *RedisClientConfig) *RedisClientTransport {
+	c := &RedisClientConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.ResponseChannel == "" {
+		c.ResponseChannel = c.RequestStream + redisResponseChannelInfix + kit.NewId()
+	}
+	if c.StreamMaxLen <= 0 {
+		c.StreamMaxLen = defaultRedisStreamMaxLen
+	}
+	if c.Workers <= 0 {
+		c.Workers = defaultRedisWorkers
+	}
+	return &RedisClientTransport{
+		redisTransport: redisTransport{
+			logger: logger,
+			redis:  client,
+		},
+		cfg: c,
+	}
+}
+
+func (t *RedisClientTransport) Send(ctx context.Context, msg *rpc.Message) error {
+	// the response is routed back to this node
+	rq := *msg
+	rq.ReplyTo = t.cfg.ResponseChannel
+	data, err := encode(ctx, &rq)
+	if err != nil {
+		return err
+	}
+	err = t.redis.Instance.XAdd(ctx, &redis.XAddArgs{
+		Stream: t.cfg.RequestStream,
+		MaxLen: t.cfg.StreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{redisStreamDataField: data},
+	}).Err()
+	if err != nil {
+		return rpc.ErrRpcTransportSend(err, ctx, t.cfg.RequestStream)
+	}
+	return nil
+}
+
+// Start subscribes to ResponseChannel and starts handling responses
+func (t *RedisClientTransport) Start(ctx context.Context) error {
+	l := t.l().C(ctx).Mth("start").F(kit.KV{"channel": t.cfg.ResponseChannel})
+
+	// subscription is confirmed before returning, so that no response to a request sent afterward is lost
+	t.pubSub = t.redis.Instance.Subscribe(ctx, t.cfg.ResponseChannel)
+	if _, err := t.pubSub.Receive(ctx); err != nil {
+		_ = t.pubSub.Close()
+		return rpc.ErrRpcTransportListen(err, ctx, t.cfg.ResponseChannel)
+	}
+
+	ch := t.pubSub.Channel()
+	t.startWorkers(ctx, t.cfg.Workers, func() {
+		for m := range ch {
+			t.handle([]byte(m.Payload))
+		}
+	})
+
+	l.Dbg("ok")
+	return nil
+}
+
+// Close unsubscribes and waits for responses being handled
+func (t *RedisClientTransport) Close(ctx context.Context) {
+	l := t.l().C(ctx).Mth("close")
+	if t.pubSub != nil {
+		_ = t.pubSub.Close()
+		t.workers.Wait()
+	}
+	l.Dbg("ok")
+}
+
+// RedisServerTransport reads requests from a redis stream by a consumer group and publishes responses to the channel of the requesting node
+// each request is delivered to a single server of the group
+// a request is acknowledged once handled, requests pending on a closed node are discarded as they expire on clients anyway
+type RedisServerTransport struct {
+	redisTransport
+	cfg      *RedisServerConfig
+	cancelFn context.CancelFunc
+}
+
+// NewRedisServerTransport creates a server transport, it must be paired with clients' RedisClientTransport adding to RequestStream
+func NewRedisServerTransport(logger kit.CLoggerFunc, client *kitRedis.Redis, cfg *RedisServerConfig) *RedisServerTransport {
+	c := &RedisServerConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Group == "" {
+		c.Group = defaultRedisGroup
+	}
+	if c.Consumer == "" {
+		c.Consumer = kit.NewId()
+	}
+	if c.Workers <= 0 {
+		c.Workers = defaultRedisWorkers
+	}
+	return &RedisServerTransport{
+		redisTransport: redisTransport{
+			logger: logger,
+			redis:  client,
+		},
+		cfg: c,
+	}
+}
+
+// Send publishes a response to the channel the request came from
+func (t *RedisServerTransport) Send(ctx context.Context, msg *rpc.Message) error {
+	if msg.ReplyTo == "" {
+		return rpc.ErrRpcTransportNoReplyTo(ctx, msg.RequestId, msg.Key)
+	}
+	data, err := encode(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if err := t.redis.Instance.Publish(ctx, msg.ReplyTo, data).Err(); err != nil {
+		return rpc.ErrRpcTransportSend(err, ctx, msg.ReplyTo)
+	}
+	return nil
+}
+
+// Start joins the consumer group and starts handling requests
+// the group is created if it doesn't exist, it reads requests added after the creation
+func (t *RedisServerTransport) Start(ctx context.Context) error {
+	l := t.l().C(ctx).Mth("start").F(kit.KV{"stream": t.cfg.RequestStream, "group": t.cfg.Group})
+
+	err := t.redis.Instance.XGroupCreateMkStream(ctx, t.cfg.RequestStream, t.cfg.Group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return rpc.ErrRpcTransportListen(err, ctx, t.cfg.RequestStream)
+	}
+
+	var workCtx context.Context
+	workCtx, t.cancelFn = context.WithCancel(ctx)
+	t.startWorkers(workCtx, t.cfg.Workers, func() { t.work(workCtx) })
+
+	l.Dbg("ok")
+	return nil
+}
+
+// Close stops reading, waits for requests being handled and leaves the consumer group
+func (t *RedisServerTransport) Close(ctx context.Context) {
+	l := t.l().C(ctx).Mth("close")
+	if t.cancelFn != nil {
+		t.cancelFn()
+		t.workers.Wait()
+		// pending requests of the consumer are discarded along with it
+		if err := t.redis.Instance.XGroupDelConsumer(ctx, t.cfg.RequestStream, t.cfg.Group, t.cfg.Consumer).Err(); err != nil {
+			l.E(err).Err("leave group")
+		}
+	}
+	l.Dbg("ok")
+}
+
+func (t *RedisServerTransport) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		streams, err := t.redis.Instance.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    t.cfg.Group,
+			Consumer: t.cfg.Consumer,
+			Streams:  []string{t.cfg.RequestStream, ">"},
+			Count:    1,
+			Block:    redisReadBlock,
+		}).Result()
+		if err != nil {
+			// redis.Nil means no request within the block period
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				t.l().Mth("read").E(rpc.ErrRpcTransportListen(err, ctx, t.cfg.RequestStream)).Err()
+				select {
+				case <-time.After(redisReadBlock):
+				case <-ctx.Done():
+				}
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, m := range stream.Messages {
+				if data, ok := m.Values[redisStreamDataField].(string); ok {
+					t.handle([]byte(data))
+				}
+				if err := t.redis.Instance.XAck(ctx, t.cfg.RequestStream, t.cfg.Group, m.ID).Err(); err != nil && ctx.Err() == nil {
+					t.l().Mth("ack").E(err).Err()
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+//go:build integration
+
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/rpc"
+	kitRedis "github.com/mikhailbolshakov/kit/storages/redis"
+	"github.com/stretchr/testify/suite"
+)
+
+type redisTransportTestSuite struct {
+	transportTestSuite
+	redis *kitRedis.Redis
+}
+
+func (s *redisTransportTestSuite) SetupSuite() {
+	s.transportTestSuite.SetupSuite()
+	var err error
+	s.redis, err = kitRedis.Open(s.Ctx, &kitRedis.Config{Host: "localhost", Port: "6379"}, s.logger)
+	if err != nil {
+		s.T().Fatal(err)
+	}
+}
+
+func (s *redisTransportTestSuite) TearDownSuite() {
+	s.redis.Close()
+}
+
+func TestRedisTransportSuite(t *testing.T) {
+	suite.Run(t, new(redisTransportTestSuite))
+}
+
+func (s *redisTransportTestSuite) Test_Redis() {
+	requests := kit.NewRandString()
+	cl := NewRedisClientTransport(s.logger, s.redis, &RedisClientConfig{RequestStream: requests})
+	srv := NewRedisServerTransport(s.logger, s.redis, &RedisServerConfig{RequestStream: requests})
+	clCh, srvCh := s.listen(cl), s.listen(srv)
+	s.NoError(srv.Start(s.Ctx))
+	defer srv.Close(s.Ctx)
+	s.NoError(cl.Start(s.Ctx))
+	defer cl.Close(s.Ctx)
+	defer s.redis.Instance.Del(s.Ctx, requests)
+
+	s.NoError(cl.Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key", Body: map[string]interface{}{"val": "request"}}))
+	r := s.receive(srvCh)
+	s.Equal("request", r.msg.Body["val"])
+	s.NotEmpty(r.msg.ReplyTo)
+	expected, _ := kit.Request(s.Ctx)
+	actual, _ := kit.Request(r.ctx)
+	s.Equal(expected.GetRequestId(), actual.GetRequestId())
+
+	s.NoError(srv.Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key", Body: map[string]interface{}{"val": "response"}, ReplyTo: r.msg.ReplyTo}))
+	r = s.receive(clCh)
+	s.Equal("response", r.msg.Body["val"])
+
+	// no reply address
+	s.AssertAppErr(srv.Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key"}), rpc.ErrCodeRpcTransportNoReplyTo)
+}
+
+func (s *redisTransportTestSuite) Test_Redis_RequestToSingleServer_ResponseToRequestingClient() {
+	requests := kit.NewRandString()
+	defer s.redis.Instance.Del(s.Ctx, requests)
+
+	// two servers share the group
+	var srvs []*RedisServerTransport
+	var srvChs []chan *received
+	for i := 0; i < 2; i++ {
+		srv := NewRedisServerTransport(s.logger, s.redis, &RedisServerConfig{RequestStream: requests})
+		srvChs = append(srvChs, s.listen(srv))
+		s.NoError(srv.Start(s.Ctx))
+		defer srv.Close(s.Ctx)
+		srvs = append(srvs, srv)
+	}
+	var cls []*RedisClientTransport
+	var clChs []chan *received
+	for i := 0; i < 2; i++ {
+		cl := NewRedisClientTransport(s.logger, s.redis, &RedisClientConfig{RequestStream: requests})
+		clChs = append(clChs, s.listen(cl))
+		s.NoError(cl.Start(s.Ctx))
+		defer cl.Close(s.Ctx)
+		cls = append(cls, cl)
+	}
+
+	for i, cl := range cls {
+		s.NoError(cl.Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: kit.NewRandString(), Key: "key", Body: map[string]interface{}{"client": float64(i)}}))
+	}
+
+	// each request is received by a single server
+	var rqs []*received
+	timeout := time.After(time.Second * 3)
+	for len(rqs) < len(cls) {
+		select {
+		case r := <-srvChs[0]:
+			rqs = append(rqs, r)
+		case r := <-srvChs[1]:
+			rqs = append(rqs, r)
+		case <-timeout:
+			s.Fatal("no request")
+		}
+	}
+	time.Sleep(time.Millisecond * 500)
+	s.Empty(srvChs[0])
+	s.Empty(srvChs[1])
+
+	// a response goes to the requesting client only
+	for _, rq := range rqs {
+		s.NoError(srvs[0].Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: rq.msg.RequestId, Key: "key", Body: rq.msg.Body, ReplyTo: rq.msg.ReplyTo}))
+	}
+	for i, clCh := range clChs {
+		r := s.receive(clCh)
+		s.Equal(float64(i), r.msg.Body["client"])
+	}
+	time.Sleep(time.Millisecond * 500)
+	s.Empty(clChs[0])
+	s.Empty(clChs[1])
+}
@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/rpc"
+)
+
+// envelope carries a message along with the request context
+type envelope struct {
+	Ctx *kit.RequestContext `json:"ctx"`
+	Msg *rpc.Message        `json:"msg"`
+}
+
+type rawEnvelope struct {
+	Ctx *kit.RequestContext `json:"ctx"`
+	Msg *rpc.RawMessage     `json:"msg"`
+}
+
+// encode marshals a message along with the request context taken from ctx
+func encode(ctx context.Context, msg *rpc.Message) ([]byte, error) {
+	rCtx, _ := kit.Request(ctx)
+	r, err := kit.Marshal(&envelope{Ctx: rCtx, Msg: msg})
+	if err != nil {
+		return nil, rpc.ErrRpcTransportMarshal(err, ctx, msg.RequestId, msg.Key)
+	}
+	return r, nil
+}
+
+// decode unmarshals a message and returns a context with the request context restored
+// a body is left raw, so that it's converted by a body type provider the same way as with any transport
+func decode(data []byte) (context.Context, *rpc.RawMessage, error) {
+	var e rawEnvelope
+	if err := kit.Unmarshal(data, &e); err != nil {
+		return nil, nil, rpc.ErrRpcTransportUnmarshal(err, context.Background())
+	}
+	return e.Ctx.ToContext(context.Background()), e.Msg, nil
+}
@@ -0,0 +1,112 @@
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/mikhailbolshakov/kit/mocks"
+	"github.com/mikhailbolshakov/kit/rpc"
+	"github.com/stretchr/testify/suite"
+)
+
+type transportTestSuite struct {
+	kit.Suite
+	logger kit.CLoggerFunc
+}
+
+func (s *transportTestSuite) SetupSuite() {
+	s.logger = func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) }
+	s.Suite.Init(s.logger)
+}
+
+func TestTransportSuite(t *testing.T) {
+	suite.Run(t, new(transportTestSuite))
+}
+
+type received struct {
+	ctx context.Context
+	msg *rpc.RawMessage
+}
+
+func (s *transportTestSuite) listen(t rpc.Transport) chan *received {
+	ch := make(chan *received, 10)
+	t.Listen(func(ctx context.Context, msg *rpc.RawMessage) error {
+		ch <- &received{ctx: ctx, msg: msg}
+		return nil
+	})
+	return ch
+}
+
+func (s *transportTestSuite) receive(ch chan *received) *received {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(time.Second * 3):
+		s.Fatal("no message")
+	}
+	return nil
+}
+
+func (s *transportTestSuite) Test_EncodeDecode() {
+	msg := &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key", ResponseRequired: true, Body: map[string]interface{}{"val": "1"}}
+	data, err := encode(s.Ctx, msg)
+	s.NoError(err)
+	ctx, rawMsg, err := decode(data)
+	s.NoError(err)
+	s.Equal(msg.RequestId, rawMsg.RequestId)
+	s.Equal(msg.Key, rawMsg.Key)
+	s.True(rawMsg.ResponseRequired)
+	s.Equal("1", rawMsg.Body["val"])
+	expected, _ := kit.Request(s.Ctx)
+	actual, _ := kit.Request(ctx)
+	s.Equal(expected.GetRequestId(), actual.GetRequestId())
+
+	_, _, err = decode([]byte("invalid"))
+	s.AssertAppErr(err, rpc.ErrCodeRpcTransportUnmarshal)
+}
+
+func (s *transportTestSuite) Test_Memory() {
+	cl, srv := NewMemoryTransport(s.logger)
+	clCh, srvCh := s.listen(cl), s.listen(srv)
+
+	// client to server
+	s.NoError(cl.Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key", Body: map[string]interface{}{"val": "request"}}))
+	r := s.receive(srvCh)
+	s.Equal("rq", r.msg.RequestId)
+	s.Equal("request", r.msg.Body["val"])
+	expected, _ := kit.Request(s.Ctx)
+	actual, _ := kit.Request(r.ctx)
+	s.Equal(expected.GetRequestId(), actual.GetRequestId())
+
+	// server to client
+	s.NoError(srv.Send(s.Ctx, &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key", Body: map[string]interface{}{"val": "response"}}))
+	r = s.receive(clCh)
+	s.Equal("response", r.msg.Body["val"])
+}
+
+func (s *transportTestSuite) Test_Memory_WhenNoListener_Skipped() {
+	cl, _ := NewMemoryTransport(s.logger)
+	s.NoError(cl.Send(s.Ctx, &rpc.Message{RequestId: "rq", Key: "key"}))
+}
+
+func (s *transportTestSuite) Test_Kafka() {
+	producer := &mocks.KafkaProducer{}
+	msg := &rpc.Message{Type: rpc.MessageType(1), RequestId: "rq", Key: "key", Body: map[string]interface{}{"val": "1"}}
+	producer.On("Send", s.Ctx, msg.Key, msg).Return(nil)
+	t := NewKafkaTransport(producer)
+	s.NoError(t.Send(s.Ctx, msg))
+	producer.AssertExpectations(s.T())
+
+	// kafka messages are passed to the listener
+	ch := s.listen(t)
+	rqCtx, _ := kit.Request(s.Ctx)
+	data, _ := kit.Marshal(&kafka.Message{Ctx: rqCtx, Key: msg.Key, Payload: msg})
+	s.NoError(t.Handler(data))
+	r := s.receive(ch)
+	s.Equal("rq", r.msg.RequestId)
+	actual, _ := kit.Request(r.ctx)
+	s.Equal(rqCtx.GetRequestId(), actual.GetRequestId())
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	ErrCodeScheduleInvalid       = "SCH-001"
+	ErrCodeScheduleTzInvalid     = "SCH-002"
+	ErrCodeJobNameEmpty          = "SCH-003"
+	ErrCodeJobFnEmpty            = "SCH-004"
+	ErrCodeJobAlreadyExists      = "SCH-005"
+	ErrCodeJobMissedRunInvalid   = "SCH-006"
+	ErrCodeJobPanic              = "SCH-007"
+	ErrCodeScheduleNeverFires    = "SCH-008"
+	ErrCodeScheduleFieldInvalid  = "SCH-009"
+	ErrCodeScheduleIntervalLimit = "SCH-010"
+	ErrCodeFireStoreEmpty        = "SCH-011"
+)
+
+var (
+	ErrScheduleInvalid = func(ctx context.Context, spec string) error {
+		return kit.NewAppErrBuilder(ErrCodeScheduleInvalid, "invalid schedule spec").C(ctx).F(kit.KV{"spec": spec}).Business().Err()
+	}
+	ErrScheduleTzInvalid = func(ctx context.Context, cause error, tz string) error {
+		return kit.NewAppErrBuilder(ErrCodeScheduleTzInvalid, "invalid schedule time zone").C(ctx).Wrap(cause).F(kit.KV{"tz": tz}).Business().Err()
+	}
+	ErrJobNameEmpty = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodeJobNameEmpty, "job name empty").C(ctx).Business().Err()
+	}
+	ErrJobFnEmpty = func(ctx context.Context, job string) error {
+		return kit.NewAppErrBuilder(ErrCodeJobFnEmpty, "job func empty").C(ctx).F(kit.KV{"job": job}).Business().Err()
+	}
+	ErrJobAlreadyExists = func(ctx context.Context, job string) error {
+		return kit.NewAppErrBuilder(ErrCodeJobAlreadyExists, "job already exists").C(ctx).F(kit.KV{"job": job}).Business().Err()
+	}
+	ErrJobMissedRunInvalid = func(ctx context.Context, job, policy string) error {
+		return kit.NewAppErrBuilder(ErrCodeJobMissedRunInvalid, "invalid missed run policy").C(ctx).F(kit.KV{"job": job, "policy": policy}).Business().Err()
+	}
+	ErrJobPanic = func(ctx context.Context, job string) error {
+		return kit.NewAppErrBuilder(ErrCodeJobPanic, "job panicked").C(ctx).F(kit.KV{"job": job}).Err()
+	}
+	ErrScheduleNeverFires = func(ctx context.Context, spec string) error {
+		return kit.NewAppErrBuilder(ErrCodeScheduleNeverFires, "schedule never fires").C(ctx).F(kit.KV{"spec": spec}).Business().Err()
+	}
+	ErrScheduleFieldInvalid = func(ctx context.Context, spec, field string) error {
+		return kit.NewAppErrBuilder(ErrCodeScheduleFieldInvalid, "invalid schedule field").C(ctx).F(kit.KV{"spec": spec, "field": field}).Business().Err()
+	}
+	ErrScheduleIntervalLimit = func(ctx context.Context, spec string) error {
+		return kit.NewAppErrBuilder(ErrCodeScheduleIntervalLimit, "schedule interval must be at least one second").C(ctx).F(kit.KV{"spec": spec}).Business().Err()
+	}
+	ErrFireStoreEmpty = func(ctx context.Context, job string) error {
+		return kit.NewAppErrBuilder(ErrCodeFireStoreEmpty, "fire store must be specified along with lock").C(ctx).F(kit.KV{"job": job}).Business().Err()
+	}
+)
@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FireStore keeps the last fire time of each job
+// to fire a job once across the cluster, the store must be shared by all the nodes (see storages/redis)
+type FireStore interface {
+	// Advance records at as the last fire time of the job
+	// it returns false if a fire at or after at has been already recorded, so the fire must be skipped
+	Advance(ctx context.Context, job string, at time.Time) (bool, error)
+}
+
+type memoryFireStore struct {
+	mu    sync.Mutex
+	fires map[string]time.Time
+}
+
+// NewMemoryFireStore creates an in-memory fire store, it's suitable for a single process only
+func NewMemoryFireStore() FireStore {
+	return &memoryFireStore{
+		fires: map[string]time.Time{},
+	}
+}
+
+func (m *memoryFireStore) Advance(ctx context.Context, job string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last, ok := m.fires[job]; ok && !at.After(last) {
+		return false, nil
+	}
+	m.fires[job] = at
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/mikhailbolshakov/kit/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	JobLastRunGauge      = "scheduler_job_last_run"
+	JobLastSuccessGauge  = "scheduler_job_last_success"
+	JobDurationHistogram = "scheduler_job_duration"
+	JobRunCounter        = "scheduler_job_run_counter"
+	JobFailureCounter    = "scheduler_job_failure_counter"
+	JobMissedCounter     = "scheduler_job_missed_counter"
+)
+
+type metrics struct {
+	lastRun     *prometheus.GaugeVec
+	lastSuccess *prometheus.GaugeVec
+	duration    *prometheus.HistogramVec
+	runs        *prometheus.CounterVec
+	failures    *prometheus.CounterVec
+	missed      *prometheus.CounterVec
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		lastRun: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: JobLastRunGauge,
+			Help: "Unix time of the last job run",
+		}, []string{"job"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: JobLastSuccessGauge,
+			Help: "Unix time of the last successful job run",
+		}, []string{"job"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    JobDurationHistogram,
+			Help:    "Job run duration in seconds",
+			Buckets: prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"job"}),
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: JobRunCounter,
+			Help: "Counts job runs on this node",
+		}, []string{"job"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: JobFailureCounter,
+			Help: "Counts failed job runs (including panics and lock errors)",
+		}, []string{"job"}),
+		missed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: JobMissedCounter,
+			Help: "Counts fires skipped according to the missed run policy",
+		}, []string{"job"}),
+	}
+}
+
+func (m *metrics) run(job string, started time.Time, duration time.Duration, err error) {
+	m.runs.WithLabelValues(job).Inc()
+	m.lastRun.WithLabelValues(job).Set(float64(started.Unix()))
+	m.duration.WithLabelValues(job).Observe(duration.Seconds())
+	if err != nil {
+		m.failures.WithLabelValues(job).Inc()
+	} else {
+		m.lastSuccess.WithLabelValues(job).Set(float64(started.Unix()))
+	}
+}
+
+func (m *metrics) failure(job string) {
+	m.failures.WithLabelValues(job).Inc()
+}
+
+func (m *metrics) skipped(job string, n int) {
+	if n > 0 {
+		m.missed.WithLabelValues(job).Add(float64(n))
+	}
+}
+
+func (m *metrics) collector() monitoring.MetricsCollector {
+	return func() monitoring.MetricsCollection {
+		return monitoring.MetricsCollection{
+			m.lastRun,
+			m.lastSuccess,
+			m.duration,
+			m.runs,
+			m.failures,
+			m.missed,
+		}
+	}
+}
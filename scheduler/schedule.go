@@ -0,0 +1,285 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	everyPrefix = "@every "
+	// a schedule is searched for the next fire within this number of years
+	scheduleSearchYears = 5
+)
+
+var (
+	descriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+	monthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	dowNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// Schedule calculates fire times
+type Schedule interface {
+	// Next returns the next fire time strictly after t
+	// zero time is returned if the schedule never fires
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule parses a schedule spec. Supported formats:
+//
+//   - cron expression with 5 fields (minute hour dom month dow) or 6 fields (second minute hour dom month dow)
+//   - descriptors: @yearly (@annually), @monthly, @weekly, @daily (@midnight), @hourly
+//   - interval: "@every 10m" or just "10m"
+//
+// tz is either a predefined kit time zone (see kit.GetTzLocation) or an IANA time zone name (UTC if empty)
+// intervals are aligned to the unix epoch, so all the nodes of a cluster calculate the same fire times
+func ParseSchedule(ctx context.Context, spec, tz string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, ErrScheduleInvalid(ctx, spec)
+	}
+
+	// interval
+	if every, ok := strings.CutPrefix(spec, everyPrefix); ok {
+		return parseInterval(ctx, spec, every)
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		return newIntervalSchedule(ctx, spec, d)
+	}
+
+	loc, err := location(ctx, tz)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := spec
+	if d, ok := descriptors[strings.ToLower(spec)]; ok {
+		expr = d
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		// seconds aren't specified, fire at the beginning of a minute
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, ErrScheduleInvalid(ctx, spec)
+	}
+
+	s := &cronSchedule{loc: loc}
+	if s.second, err = parseField(ctx, spec, fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.minute, err = parseField(ctx, spec, fields[1], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(ctx, spec, fields[2], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(ctx, spec, fields[3], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(ctx, spec, fields[4], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	// 7 is an alias of sunday
+	if s.dow, err = parseField(ctx, spec, fields[5], 0, 7, dowNames); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) > 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domAny, s.dowAny = isAny(fields[3]), isAny(fields[5])
+
+	// reject expressions like "0 0 30 2 *"
+	if s.Next(kit.Now()).IsZero() {
+		return nil, ErrScheduleNeverFires(ctx, spec)
+	}
+
+	return s, nil
+}
+
+func location(ctx context.Context, tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	if loc := kit.GetTzLocation(tz); loc != nil {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, ErrScheduleTzInvalid(ctx, err, tz)
+	}
+	return loc, nil
+}
+
+func parseInterval(ctx context.Context, spec, every string) (Schedule, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(every))
+	if err != nil {
+		return nil, ErrScheduleInvalid(ctx, spec)
+	}
+	return newIntervalSchedule(ctx, spec, d)
+}
+
+func newIntervalSchedule(ctx context.Context, spec string, d time.Duration) (Schedule, error) {
+	if d < time.Second {
+		return nil, ErrScheduleIntervalLimit(ctx, spec)
+	}
+	return &intervalSchedule{every: d.Truncate(time.Second)}, nil
+}
+
+func isAny(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parseField parses a single cron field into a bit set
+// supported syntax: "*", "?", "5", "1-5", "*/15", "10/5", "1-30/2", "mon-fri", and comma separated lists of them
+func parseField(ctx context.Context, spec, field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, ErrScheduleFieldInvalid(ctx, spec, field)
+			}
+		}
+
+		var from, to int
+		switch {
+		case isAny(rng):
+			from, to = min, max
+		case strings.Contains(rng, "-"):
+			fromStr, toStr, _ := strings.Cut(rng, "-")
+			var ok bool
+			if from, ok = fieldValue(fromStr, names); !ok {
+				return 0, ErrScheduleFieldInvalid(ctx, spec, field)
+			}
+			if to, ok = fieldValue(toStr, names); !ok {
+				return 0, ErrScheduleFieldInvalid(ctx, spec, field)
+			}
+		default:
+			var ok bool
+			if from, ok = fieldValue(rng, names); !ok {
+				return 0, ErrScheduleFieldInvalid(ctx, spec, field)
+			}
+			to = from
+			// "10/5" means starting from 10 up to max
+			if hasStep {
+				to = max
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return 0, ErrScheduleFieldInvalid(ctx, spec, field)
+		}
+		for i := from; i <= to; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func fieldValue(s string, names map[string]int) (int, bool) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, true
+	}
+	v, err := strconv.Atoi(s)
+	return v, err == nil
+}
+
+// intervalSchedule fires every given period aligned to the unix epoch
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func (s *intervalSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.every).Add(s.every)
+}
+
+// cronSchedule keeps allowed values of each field as a bit set
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	// domAny, dowAny specify if day of month / day of week isn't restricted
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+
+	// start from the next second
+	t = t.In(s.loc).Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + scheduleSearchYears
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for s.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for s.hour&(1<<uint(t.Hour())) == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for s.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t.In(origLoc)
+}
+
+// dayMatches follows the standard cron semantic:
+// if both day of month and day of week are restricted, a day matches if any of them matches
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) > 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) > 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,348 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/mikhailbolshakov/kit/monitoring"
+)
+
+const (
+	// MissedRunSkip missed fires are skipped, the job waits for the next regular fire
+	MissedRunSkip = "skip"
+	// MissedRunOnce missed fires are coalesced into a single immediate run
+	MissedRunOnce = "once"
+	// MissedRunAll every missed fire is run one by one (bounded by Config.MaxMissedRuns)
+	MissedRunAll = "all"
+
+	defaultLockPrefix    = "scheduler:"
+	defaultMaxMissedRuns = 10
+)
+
+// JobFn is a job function
+type JobFn func(ctx context.Context) error
+
+// Job describes a scheduled job
+type Job struct {
+	Name      string        // Name unique job name, it must be the same on all the nodes of a cluster
+	Spec      string        // Spec cron expression or interval (see ParseSchedule)
+	Tz        string        // Tz time zone of cron expression (default: UTC)
+	MissedRun string        // MissedRun policy applied when fires are missed (default: skip)
+	Timeout   time.Duration // Timeout of a single run, run context is cancelled when exceeded (default: unlimited)
+	Fn        JobFn         // Fn job function
+}
+
+// Config scheduler configuration
+type Config struct {
+	LockPrefix    string    // LockPrefix prefix of lock refs (default: "scheduler:")
+	FireStore     FireStore // FireStore keeps the last fire time of jobs, it's mandatory if a distributed lock is specified
+	MaxMissedRuns int       // MaxMissedRuns max number of missed fires run with MissedRunAll policy (default: 10)
+}
+
+// Scheduler runs jobs by schedule
+// if a distributed lock is specified, each fire is run once across the cluster
+type Scheduler interface {
+	monitoring.MetricsProvider
+	// Add adds a job, the job is started immediately if the scheduler is already started
+	Add(ctx context.Context, job *Job) error
+	// Remove stops and removes a job
+	Remove(ctx context.Context, name string)
+	// Jobs returns names of registered jobs
+	Jobs() []string
+	// Start starts all registered jobs
+	Start(ctx context.Context)
+	// Close stops all jobs, running jobs are cancelled
+	Close(ctx context.Context)
+}
+
+type scheduledJob struct {
+	*Job
+	schedule Schedule
+	cancelFn context.CancelFunc
+}
+
+type schedulerImpl struct {
+	sync.RWMutex
+	lock     kit.DistributedLock
+	cfg      *Config
+	logger   kit.CLoggerFunc
+	metrics  *metrics
+	jobs     map[string]*scheduledJob
+	ctx      context.Context
+	cancelFn context.CancelFunc
+}
+
+// NewScheduler creates a new scheduler
+// lock might be nil, then jobs are fired on every node
+func NewScheduler(lock kit.DistributedLock, cfg *Config, logger kit.CLoggerFunc) Scheduler {
+	c := &Config{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.LockPrefix == "" {
+		c.LockPrefix = defaultLockPrefix
+	}
+	if c.MaxMissedRuns <= 0 {
+		c.MaxMissedRuns = defaultMaxMissedRuns
+	}
+	return &schedulerImpl{
+		lock:    lock,
+		cfg:     c,
+		logger:  logger,
+		metrics: newMetrics(),
+		jobs:    map[string]*scheduledJob{},
+	}
+}
+
+func (s *schedulerImpl) l() kit.CLogger {
+	return s.logger().Cmp("scheduler")
+}
+
+func (s *schedulerImpl) GetCollector() monitoring.MetricsCollector {
+	return s.metrics.collector()
+}
+
+func (s *schedulerImpl) Add(ctx context.Context, job *Job) error {
+	s.l().C(ctx).Mth("add").F(kit.KV{"job": job.Name}).Dbg()
+
+	if job.Name == "" {
+		return ErrJobNameEmpty(ctx)
+	}
+	if job.Fn == nil {
+		return ErrJobFnEmpty(ctx, job.Name)
+	}
+	if s.lock != nil && s.cfg.FireStore == nil {
+		return ErrFireStoreEmpty(ctx, job.Name)
+	}
+
+	j := &scheduledJob{Job: &Job{}}
+	*j.Job = *job
+	if j.MissedRun == "" {
+		j.MissedRun = MissedRunSkip
+	}
+	if j.MissedRun != MissedRunSkip && j.MissedRun != MissedRunOnce && j.MissedRun != MissedRunAll {
+		return ErrJobMissedRunInvalid(ctx, j.Name, j.MissedRun)
+	}
+
+	var err error
+	if j.schedule, err = ParseSchedule(ctx, j.Spec, j.Tz); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.jobs[j.Name]; ok {
+		return ErrJobAlreadyExists(ctx, j.Name)
+	}
+	s.jobs[j.Name] = j
+
+	// already started
+	if s.ctx != nil {
+		s.startJob(s.ctx, j)
+	}
+
+	return nil
+}
+
+func (s *schedulerImpl) Remove(ctx context.Context, name string) {
+	s.l().C(ctx).Mth("remove").F(kit.KV{"job": name}).Dbg()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if j, ok := s.jobs[name]; ok {
+		if j.cancelFn != nil {
+			j.cancelFn()
+		}
+		delete(s.jobs, name)
+	}
+}
+
+func (s *schedulerImpl) Jobs() []string {
+	s.RLock()
+	defer s.RUnlock()
+	var res []string
+	for name := range s.jobs {
+		res = append(res, name)
+	}
+	return res
+}
+
+func (s *schedulerImpl) Start(ctx context.Context) {
+	s.l().C(ctx).Mth("start").Dbg()
+
+	s.Lock()
+	defer s.Unlock()
+
+	// restart forcibly
+	if s.cancelFn != nil {
+		s.cancelFn()
+	}
+	s.ctx, s.cancelFn = context.WithCancel(ctx)
+
+	for _, j := range s.jobs {
+		s.startJob(s.ctx, j)
+	}
+}
+
+func (s *schedulerImpl) Close(ctx context.Context) {
+	s.l().C(ctx).Mth("close").Dbg()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.cancelFn != nil {
+		s.cancelFn()
+	}
+	s.ctx, s.cancelFn = nil, nil
+	for _, j := range s.jobs {
+		j.cancelFn = nil
+	}
+}
+
+// startJob runs a job loop, must be called under lock
+func (s *schedulerImpl) startJob(ctx context.Context, j *scheduledJob) {
+	var jobCtx context.Context
+	jobCtx, j.cancelFn = context.WithCancel(ctx)
+
+	goroutine.New().
+		WithLogger(s.l().Mth("job").F(kit.KV{"job": j.Name})).
+		WithRetry(goroutine.Unrestricted).
+		Go(ctx, func() { s.loop(jobCtx, j) })
+}
+
+func (s *schedulerImpl) loop(ctx context.Context, j *scheduledJob) {
+	l := s.l().C(ctx).Mth("loop").F(kit.KV{"job": j.Name}).Dbg("started")
+
+	// pending keeps fires which are due after the current one (missed fires being caught up)
+	var pending []time.Time
+	next := j.schedule.Next(kit.Now())
+	for !next.IsZero() {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.Dbg("stopped")
+			return
+		}
+		s.fire(ctx, j, next)
+		if len(pending) == 0 {
+			pending = s.nextFires(j, next)
+		}
+		next, pending = pending[0], pending[1:]
+	}
+}
+
+// nextFires calculates fires following the previous one considering the missed run policy
+// missed fires are detected once per catch-up, so they are neither reported nor counted twice
+func (s *schedulerImpl) nextFires(j *scheduledJob, prev time.Time) []time.Time {
+	now := kit.Now()
+	next := j.schedule.Next(prev)
+	if next.IsZero() || next.After(now) {
+		return []time.Time{next}
+	}
+
+	// fires have been missed either because the previous run took too long or the process was suspended
+	var missed []time.Time
+	total := 0
+	for t := next; !t.IsZero() && !t.After(now); t = j.schedule.Next(t) {
+		total++
+		missed = append(missed, t)
+		// keep only the latest fires
+		if len(missed) > s.cfg.MaxMissedRuns {
+			missed = missed[1:]
+		}
+	}
+
+	s.l().Mth("missed").F(kit.KV{"job": j.Name, "missed": total, "policy": j.MissedRun}).Warn("missed fires")
+
+	switch j.MissedRun {
+	case MissedRunAll:
+		s.metrics.skipped(j.Name, total-len(missed))
+		return missed
+	case MissedRunOnce:
+		s.metrics.skipped(j.Name, total-1)
+		return missed[len(missed)-1:]
+	default:
+		s.metrics.skipped(j.Name, total)
+		return []time.Time{j.schedule.Next(now)}
+	}
+}
+
+// fire runs a job for the given fire time, if lock is specified the fire happens once across the cluster
+func (s *schedulerImpl) fire(ctx context.Context, j *scheduledJob, at time.Time) {
+	l := s.l().C(ctx).Mth("fire").F(kit.KV{"job": j.Name, "at": at})
+
+	if s.lock != nil {
+		// the lock prevents concurrent runs of the job across the cluster
+		ref := s.cfg.LockPrefix + j.Name
+		releaseId, locked, err := s.lock.TryLock(ctx, ref)
+		if err != nil {
+			s.metrics.failure(j.Name)
+			l.E(err).Err("lock")
+			return
+		}
+		if !locked {
+			l.Dbg("running on another node")
+			return
+		}
+		// the lock must be released even if the job is being stopped
+		defer s.lock.UnLock(kit.Detach(ctx), ref, releaseId)
+
+		// the shared last fire time protects from firing again on a node which is lagging behind (clock skew or catching up missed fires)
+		advanced, err := s.cfg.FireStore.Advance(ctx, j.Name, at)
+		if err != nil {
+			s.metrics.failure(j.Name)
+			l.E(err).Err("fire store")
+			return
+		}
+		if !advanced {
+			l.Dbg("fired on another node")
+			return
+		}
+	}
+
+	started := kit.Now()
+	err := s.run(ctx, j)
+	duration := time.Since(started)
+	s.metrics.run(j.Name, started, duration, err)
+
+	if err != nil {
+		l.E(err).Err()
+		return
+	}
+	l.F(kit.KV{"duration": duration}).Dbg("ok")
+}
+
+// run executes a job function with panic recovery
+func (s *schedulerImpl) run(ctx context.Context, j *scheduledJob) error {
+	// each run has its own request context
+	rCtx := kit.NewRequestCtx().WithNewRequestId().WithKv("job", j.Name)
+	if r, ok := kit.Request(ctx); ok {
+		rCtx.WithApp(r.GetApp())
+	}
+	var runCtx context.Context
+	var cancelFn context.CancelFunc
+	if j.Timeout > 0 {
+		runCtx, cancelFn = context.WithTimeout(rCtx.ToContext(ctx), j.Timeout)
+	} else {
+		runCtx, cancelFn = context.WithCancel(rCtx.ToContext(ctx))
+	}
+	defer cancelFn()
+
+	done := make(chan error, 1)
+	goroutine.New().
+		WithLogger(s.l().Mth("run").F(kit.KV{"job": j.Name})).
+		Go(runCtx, func() {
+			// if f panics, the error remains and the panic itself is logged by the wrapper
+			err := ErrJobPanic(runCtx, j.Name)
+			defer func() { done <- err }()
+			err = j.Fn(runCtx)
+		})
+	return <-done
+}
@@ -0,0 +1,266 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/atomic"
+)
+
+type memLockStorage struct {
+	mu    sync.Mutex
+	locks map[string]string
+}
+
+func (m *memLockStorage) Lock(ctx context.Context, ref, releaseId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.locks[ref]; ok {
+		return false, nil
+	}
+	m.locks[ref] = releaseId
+	return true, nil
+}
+
+func (m *memLockStorage) UnLock(ctx context.Context, ref, releaseId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locks[ref] == releaseId {
+		delete(m.locks, ref)
+	}
+	return nil
+}
+
+type schedulerTestSuite struct {
+	kit.Suite
+	lock kit.DistributedLock
+}
+
+func (s *schedulerTestSuite) SetupSuite() {
+	s.Suite.Init(func() kit.CLogger { return kit.L(kit.InitLogger(&kit.LogConfig{Level: kit.TraceLevel})) })
+}
+
+func (s *schedulerTestSuite) SetupTest() {
+	s.lock = kit.NewDistributedLock(&memLockStorage{locks: map[string]string{}}, &kit.DistributedLockCfg{}, s.L)
+}
+
+func TestSchedulerSuite(t *testing.T) {
+	suite.Run(t, new(schedulerTestSuite))
+}
+
+func (s *schedulerTestSuite) Test_ParseSchedule() {
+	from := time.Date(2025, 3, 14, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		tz   string
+		next time.Time
+	}{
+		{"* * * * *", "", time.Date(2025, 3, 14, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * *", "", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 */10 * * * *", "", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"45 * * * * *", "", time.Date(2025, 3, 14, 10, 20, 45, 0, time.UTC)},
+		{"0 9 * * mon-fri", "", time.Date(2025, 3, 17, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", "", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 8 1,15 * *", "", time.Date(2025, 3, 15, 8, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", "", time.Date(2025, 3, 16, 0, 0, 0, 0, time.UTC)},
+		{"@daily", "", time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", "", time.Date(2025, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", "", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", "", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@every 1m", "", time.Date(2025, 3, 14, 10, 21, 0, 0, time.UTC)},
+		{"5s", "", time.Date(2025, 3, 14, 10, 20, 35, 0, time.UTC)},
+		// 12:00 at +3 is 09:00 UTC
+		{"0 12 * * *", kit.TzP3, time.Date(2025, 3, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 12 * * *", "Asia/Tokyo", time.Date(2025, 3, 15, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		sch, err := ParseSchedule(s.Ctx, tt.spec, tt.tz)
+		s.NoError(err, tt.spec)
+		s.Equal(tt.next, sch.Next(from), tt.spec)
+	}
+}
+
+func (s *schedulerTestSuite) Test_ParseSchedule_WhenInvalid_Fail() {
+	tests := []struct {
+		spec string
+		tz   string
+		code string
+	}{
+		{"", "", ErrCodeScheduleInvalid},
+		{"* * *", "", ErrCodeScheduleInvalid},
+		{"@every abc", "", ErrCodeScheduleInvalid},
+		{"@every 100ms", "", ErrCodeScheduleIntervalLimit},
+		{"60 * * * *", "", ErrCodeScheduleFieldInvalid},
+		{"* * * 13 *", "", ErrCodeScheduleFieldInvalid},
+		{"*/0 * * * *", "", ErrCodeScheduleFieldInvalid},
+		{"5-1 * * * *", "", ErrCodeScheduleFieldInvalid},
+		{"* * * * xyz", "", ErrCodeScheduleFieldInvalid},
+		{"0 0 30 2 *", "", ErrCodeScheduleNeverFires},
+		{"* * * * *", "Unknown/Zone", ErrCodeScheduleTzInvalid},
+	}
+	for _, tt := range tests {
+		_, err := ParseSchedule(s.Ctx, tt.spec, tt.tz)
+		s.AssertAppErr(err, tt.code)
+	}
+}
+
+func (s *schedulerTestSuite) Test_Add_WhenInvalid_Fail() {
+	fn := func(ctx context.Context) error { return nil }
+	s.AssertAppErr(NewScheduler(s.lock, nil, s.L).Add(s.Ctx, &Job{Name: "job", Spec: "1s", Fn: fn}), ErrCodeFireStoreEmpty)
+
+	sch := NewScheduler(s.lock, &Config{FireStore: NewMemoryFireStore()}, s.L)
+	s.AssertAppErr(sch.Add(s.Ctx, &Job{Spec: "1s", Fn: fn}), ErrCodeJobNameEmpty)
+	s.AssertAppErr(sch.Add(s.Ctx, &Job{Name: "job", Spec: "1s"}), ErrCodeJobFnEmpty)
+	s.AssertAppErr(sch.Add(s.Ctx, &Job{Name: "job", Spec: "1s", MissedRun: "unknown", Fn: fn}), ErrCodeJobMissedRunInvalid)
+	s.NoError(sch.Add(s.Ctx, &Job{Name: "job", Spec: "1s", Fn: fn}))
+	s.AssertAppErr(sch.Add(s.Ctx, &Job{Name: "job", Spec: "1s", Fn: fn}), ErrCodeJobAlreadyExists)
+}
+
+func (s *schedulerTestSuite) Test_FireOnceAcrossNodes() {
+	runs := atomic.NewInt32(0)
+	job := &Job{
+		Name: kit.NewRandString(),
+		Spec: "@every 1s",
+		Fn: func(ctx context.Context) error {
+			runs.Inc()
+			return nil
+		},
+	}
+
+	// two nodes share the same lock and fire store
+	store := NewMemoryFireStore()
+	var nodes []Scheduler
+	for i := 0; i < 2; i++ {
+		sch := NewScheduler(s.lock, &Config{FireStore: store}, s.L)
+		s.NoError(sch.Add(s.Ctx, job))
+		sch.Start(s.Ctx)
+		nodes = append(nodes, sch)
+	}
+
+	time.Sleep(time.Millisecond * 3500)
+	for _, n := range nodes {
+		n.Close(s.Ctx)
+	}
+
+	// 3 fires within 3.5s, each fired once
+	s.GreaterOrEqual(runs.Load(), int32(3))
+	s.LessOrEqual(runs.Load(), int32(4))
+}
+
+func (s *schedulerTestSuite) Test_LaggingNode_DoesNotFireAgain() {
+	runs := atomic.NewInt32(0)
+	name := kit.NewRandString()
+
+	// another node has already fired the job up to 2s ahead, e.g. this node's clock is lagging
+	store := NewMemoryFireStore()
+	recorded := kit.Now().Truncate(time.Second).Add(time.Second * 2)
+	_, err := store.Advance(s.Ctx, name, recorded)
+	s.NoError(err)
+
+	sch := NewScheduler(s.lock, &Config{FireStore: store}, s.L)
+	s.NoError(sch.Add(s.Ctx, &Job{
+		Name: name,
+		Spec: "@every 1s",
+		Fn: func(ctx context.Context) error {
+			runs.Inc()
+			return nil
+		},
+	}))
+	sch.Start(s.Ctx)
+	defer sch.Close(s.Ctx)
+
+	// fires up to the recorded one are skipped, the first run happens at the next one
+	time.Sleep(time.Until(recorded.Add(time.Millisecond * 500)))
+	s.Equal(int32(0), runs.Load())
+	s.NoError(<-kit.Await(func() (bool, error) { return runs.Load() == 1, nil }, time.Millisecond*100, time.Second*2))
+}
+
+func (s *schedulerTestSuite) Test_WhenPanic_NextFireRun() {
+	runs := atomic.NewInt32(0)
+	sch := NewScheduler(nil, nil, s.L)
+	sch.Start(s.Ctx)
+	defer sch.Close(s.Ctx)
+
+	s.NoError(sch.Add(s.Ctx, &Job{
+		Name: kit.NewRandString(),
+		Spec: "@every 1s",
+		Fn: func(ctx context.Context) error {
+			runs.Inc()
+			panic("panic")
+		},
+	}))
+
+	s.NoError(<-kit.Await(func() (bool, error) { return runs.Load() >= 2, nil }, time.Millisecond*100, time.Second*4))
+}
+
+func (s *schedulerTestSuite) Test_MissedRun() {
+	tests := []struct {
+		policy  string
+		runs    int32
+		skipped float64
+	}{
+		{MissedRunSkip, 1, 2},
+		{MissedRunOnce, 2, 1},
+		{MissedRunAll, 3, 0},
+	}
+	for _, tt := range tests {
+		runs := atomic.NewInt32(0)
+		firstDone := make(chan struct{})
+		name := kit.NewRandString()
+		sch := NewScheduler(nil, nil, s.L)
+		s.NoError(sch.Add(s.Ctx, &Job{
+			Name:      name,
+			Spec:      "@every 1s",
+			MissedRun: tt.policy,
+			Fn: func(ctx context.Context) error {
+				// the first run takes long enough to miss two fires
+				if runs.Inc() == 1 {
+					time.Sleep(time.Millisecond * 2500)
+					close(firstDone)
+				}
+				return nil
+			},
+		}))
+		sch.Start(s.Ctx)
+
+		// missed fires are run right after the first run, the next regular fire is in 0.5s
+		<-firstDone
+		time.Sleep(time.Millisecond * 300)
+		sch.Close(s.Ctx)
+		s.Equal(tt.runs, runs.Load(), tt.policy)
+
+		// missed fires are counted once, not on every catch-up run
+		m := &dto.Metric{}
+		s.NoError(sch.(*schedulerImpl).metrics.missed.WithLabelValues(name).Write(m))
+		s.Equal(tt.skipped, m.GetCounter().GetValue(), tt.policy)
+	}
+}
+
+func (s *schedulerTestSuite) Test_Remove() {
+	runs := atomic.NewInt32(0)
+	name := kit.NewRandString()
+	sch := NewScheduler(nil, nil, s.L)
+	sch.Start(s.Ctx)
+	defer sch.Close(s.Ctx)
+
+	s.NoError(sch.Add(s.Ctx, &Job{
+		Name: name,
+		Spec: "@every 1s",
+		Fn: func(ctx context.Context) error {
+			runs.Inc()
+			return nil
+		},
+	}))
+	s.Equal([]string{name}, sch.Jobs())
+	s.NoError(<-kit.Await(func() (bool, error) { return runs.Load() == 1, nil }, time.Millisecond*100, time.Second*3))
+
+	sch.Remove(s.Ctx, name)
+	s.Empty(sch.Jobs())
+	time.Sleep(time.Millisecond * 1500)
+	s.Equal(int32(1), runs.Load())
+}
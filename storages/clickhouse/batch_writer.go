@@ -0,0 +1,96 @@
+package clickhouse
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
+)
+
+type batchWriterImpl[T any] struct {
+	ch     *ClickHouse
+	table  string
+	query  string
+	logger kit.CLoggerFunc
+}
+
+// NewBatchWriter creates a batch.Writer inserting items into the table with a native batch
+// columns are mapped by `ch` struct tags (field name if no tag, `ch:"-"` is skipped), so the table might have more columns filled by defaults
+func NewBatchWriter[T any](ch *ClickHouse, table string) (batch.Writer[T], error) {
+	var v T
+	cols := structColumns(reflect.TypeOf(v))
+	if len(cols) == 0 {
+		return nil, ErrClickBatchWriterNoColumns(table)
+	}
+	return &batchWriterImpl[T]{
+		ch:     ch,
+		table:  table,
+		query:  fmt.Sprintf("INSERT INTO %s (%s)", table, strings.Join(cols, ", ")),
+		logger: ch.logger,
+	}, nil
+}
+
+func (w *batchWriterImpl[T]) l() kit.CLogger {
+	return w.logger().Cmp("click-batch").F(kit.KV{"table": w.table})
+}
+
+func (w *batchWriterImpl[T]) Write(ctx context.Context, items []*T) error {
+	l := w.l().C(ctx).Mth("write").F(kit.KV{"items": len(items)})
+
+	b, err := w.ch.Instance.PrepareBatch(ctx, w.query)
+	if err != nil {
+		return ErrClickBatchPrepare(ctx, err, w.table)
+	}
+	defer func() {
+		if !b.IsSent() {
+			_ = b.Abort()
+		}
+	}()
+
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if err := b.AppendStruct(item); err != nil {
+			return ErrClickBatchAppend(ctx, err, w.table)
+		}
+	}
+	if err := b.Send(); err != nil {
+		return ErrClickBatchSend(ctx, err, w.table)
+	}
+
+	l.Dbg("ok")
+	return nil
+}
+
+// structColumns returns column names of a struct the same way the driver maps struct fields on AppendStruct
+func structColumns(t reflect.Type) []string {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var cols []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Name
+		if tag := f.Tag.Get("ch"); tag != "" {
+			name = tag
+		}
+		switch {
+		case name == "-", f.PkgPath != "" && !f.Anonymous:
+			continue
+		case f.Anonymous:
+			if f.Type.Kind() != reflect.Ptr {
+				cols = append(cols, structColumns(f.Type)...)
+			}
+		default:
+			cols = append(cols, name)
+		}
+	}
+	return cols
+}
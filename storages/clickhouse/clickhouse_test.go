@@ -5,8 +5,10 @@ package clickhouse
 import (
 	"fmt"
 	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type clickHouseTestSuite struct {
@@ -62,3 +64,38 @@ func (s *clickHouseTestSuite) Test_CreateDropTable_Select() {
 	s.Equal(int64(1), result.Col1)
 	s.Equal(uint64(1), result.Count)
 }
+
+type batchRow struct {
+	Id      int64  `ch:"id"`
+	Name    string `ch:"name"`
+	Skipped string `ch:"-"`
+}
+
+func (s *clickHouseTestSuite) Test_BatchWriter() {
+	ch, err := Open(config, s.logger)
+	s.NoError(err)
+	defer ch.Close()
+	s.NoError(ch.Instance.Exec(s.Ctx, "DROP TABLE IF EXISTS _test_batch"))
+	s.NoError(ch.Instance.Exec(s.Ctx, "CREATE TABLE _test_batch (id Int64, name String, created DateTime DEFAULT now()) ENGINE=MergeTree() order by (id)"))
+	defer func() {
+		s.NoError(ch.Instance.Exec(s.Ctx, "DROP TABLE _test_batch"))
+	}()
+
+	writer, err := NewBatchWriter[batchRow](ch, "_test_batch")
+	s.NoError(err)
+	worker := batch.NewBatchWorker[batchRow](writer, &batch.Options{Interval: time.Millisecond * 100, MaxItems: 10, MaxCapacity: 100}, s.logger)
+	worker.Start(s.Ctx)
+	for i := 0; i < 25; i++ {
+		worker.Write(s.Ctx, &batchRow{Id: int64(i), Name: kit.NewRandString(), Skipped: "skipped"})
+	}
+	s.NoError(worker.Close(s.Ctx))
+
+	var cnt uint64
+	s.NoError(ch.Instance.QueryRow(s.Ctx, "SELECT count() FROM _test_batch").Scan(&cnt))
+	s.Equal(uint64(25), cnt)
+}
+
+func (s *clickHouseTestSuite) Test_BatchWriter_WhenNotStruct_Fail() {
+	_, err := NewBatchWriter[int](&ClickHouse{logger: s.logger}, "_test_batch")
+	s.AssertAppErr(err, ErrCodeClickBatchWriterNoColumns)
+}
@@ -1,6 +1,8 @@
 package clickhouse
 
 import (
+	"context"
+
 	"github.com/mikhailbolshakov/kit"
 )
 
@@ -11,6 +13,10 @@ const (
 	ErrCodeClickLockLifeViewCreation = "CH-010"
 	ErrCodeClickLockTimeout          = "CH-011"
 	ErrCodeClickPing                 = "CH-012"
+	ErrCodeClickBatchWriterNoColumns = "CH-013"
+	ErrCodeClickBatchPrepare         = "CH-014"
+	ErrCodeClickBatchAppend          = "CH-015"
+	ErrCodeClickBatchSend            = "CH-016"
 )
 
 var (
@@ -23,4 +29,16 @@ var (
 	ErrClickPing = func(cause error) error {
 		return kit.NewAppErrBuilder(ErrCodeClickPing, "ping").Wrap(cause).Err()
 	}
+	ErrClickBatchWriterNoColumns = func(table string) error {
+		return kit.NewAppErrBuilder(ErrCodeClickBatchWriterNoColumns, "batch writer: no columns, item must be a struct").F(kit.KV{"table": table}).Err()
+	}
+	ErrClickBatchPrepare = func(ctx context.Context, cause error, table string) error {
+		return kit.NewAppErrBuilder(ErrCodeClickBatchPrepare, "batch writer: prepare").Wrap(cause).C(ctx).F(kit.KV{"table": table}).Err()
+	}
+	ErrClickBatchAppend = func(ctx context.Context, cause error, table string) error {
+		return kit.NewAppErrBuilder(ErrCodeClickBatchAppend, "batch writer: append").Wrap(cause).C(ctx).F(kit.KV{"table": table}).Err()
+	}
+	ErrClickBatchSend = func(ctx context.Context, cause error, table string) error {
+		return kit.NewAppErrBuilder(ErrCodeClickBatchSend, "batch writer: send").Wrap(cause).C(ctx).F(kit.KV{"table": table}).Err()
+	}
 )
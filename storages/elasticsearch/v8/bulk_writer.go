@@ -0,0 +1,175 @@
+package v8
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
+)
+
+const (
+	BulkOpIndex  = "index"  // BulkOpIndex creates or replaces a document
+	BulkOpCreate = "create" // BulkOpCreate creates a document, it fails if the document exists
+)
+
+// BulkItemError describes an item rejected by ES within a bulk request
+type BulkItemError[T any] struct {
+	Item   *T     // Item rejected
+	Index  string // Index the item was written to
+	Id     string // Id of the document
+	Status int    // Status http status code of the item
+	Type   string // Type of ES error
+	Reason string // Reason of ES error
+}
+
+// BulkWriterConfig bulk writer configuration
+type BulkWriterConfig[T any] struct {
+	Index        string                                                // Index (or alias) documents are written to
+	IndexFn      func(item *T) string                                  // IndexFn returns an index of the item, it overrides Index
+	IdFn         func(item *T) string                                  // IdFn returns a document id, if not set ids are generated by ES
+	Op           string                                                // Op bulk action, index or create (default: index)
+	Refresh      bool                                                  // Refresh enforces refresh after each bulk. It helpful for tests but MUST NOT BE USED ON PROD
+	OnItemErrors func(ctx context.Context, failed []*BulkItemError[T]) // OnItemErrors receives items rejected by ES, if not set Write fails when any item is rejected
+}
+
+type bulkResponse struct {
+	Errors bool                           `json:"errors"`
+	Items  []map[string]*bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Index  string `json:"_index"`
+	Id     string `json:"_id"`
+	Status int    `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+type bulkWriterImpl[T any] struct {
+	processorImpl
+	client *esapi.API
+	cfg    *BulkWriterConfig[T]
+	logger kit.CLoggerFunc
+}
+
+// NewBulkWriter creates a batch.Writer writing items with a single _bulk request
+// items are encoded to json, items rejected by ES are reported per item
+func NewBulkWriter[T any](es Es, cfg *BulkWriterConfig[T], logger kit.CLoggerFunc) (batch.Writer[T], error) {
+	c := &BulkWriterConfig[T]{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Index == "" && c.IndexFn == nil {
+		return nil, ErrBulkWriterIndexEmpty()
+	}
+	if c.Op == "" {
+		c.Op = BulkOpIndex
+	}
+	if c.Op != BulkOpIndex && c.Op != BulkOpCreate {
+		return nil, ErrBulkWriterOpInvalid(c.Op)
+	}
+	return &bulkWriterImpl[T]{
+		client: es.GetClient().API,
+		cfg:    c,
+		logger: logger,
+	}, nil
+}
+
+func (w *bulkWriterImpl[T]) l() kit.CLogger {
+	return w.logger().Cmp("es-bulk")
+}
+
+func (w *bulkWriterImpl[T]) Write(ctx context.Context, items []*T) error {
+	l := w.l().C(ctx).Mth("write").F(kit.KV{"items": len(items)})
+
+	body, sent, err := w.body(ctx, items)
+	if err != nil {
+		return err
+	}
+	if len(sent) == 0 {
+		return nil
+	}
+
+	var response bulkResponse
+	if err := w.Do(ctx, func() (*esapi.Response, error) {
+		opts := []func(*esapi.BulkRequest){w.client.Bulk.WithContext(ctx)}
+		if w.cfg.Refresh {
+			opts = append(opts, w.client.Bulk.WithRefresh("true"))
+		}
+		return w.client.Bulk(bytes.NewReader(body), opts...)
+	}, func(code int, data io.ReadCloser) error {
+		return kit.NewDecoder(data).Decode(&response)
+	}); err != nil {
+		return ErrBulk(ctx, err)
+	}
+
+	if !response.Errors {
+		l.Dbg("ok")
+		return nil
+	}
+
+	// items of the response go in order of the request
+	var failed []*BulkItemError[T]
+	for i, res := range response.Items {
+		for _, item := range res {
+			if item == nil || item.Error == nil || i >= len(sent) {
+				continue
+			}
+			failed = append(failed, &BulkItemError[T]{
+				Item:   sent[i],
+				Index:  item.Index,
+				Id:     item.Id,
+				Status: item.Status,
+				Type:   item.Error.Type,
+				Reason: item.Error.Reason,
+			})
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	l.F(kit.KV{"failed": len(failed)}).Warn("items rejected")
+	if w.cfg.OnItemErrors != nil {
+		w.cfg.OnItemErrors(ctx, failed)
+		return nil
+	}
+	return ErrBulkItems(ctx, len(failed), failed[0].Type, failed[0].Reason)
+}
+
+// body builds ndjson bulk request body, it returns items put into the body
+func (w *bulkWriterImpl[T]) body(ctx context.Context, items []*T) ([]byte, []*T, error) {
+	var buf bytes.Buffer
+	sent := make([]*T, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		meta := map[string]string{"_index": w.cfg.Index}
+		if w.cfg.IndexFn != nil {
+			meta["_index"] = w.cfg.IndexFn(item)
+		}
+		if w.cfg.IdFn != nil {
+			meta["_id"] = w.cfg.IdFn(item)
+		}
+		action, err := kit.JsonEncode(map[string]map[string]string{w.cfg.Op: meta})
+		if err != nil {
+			return nil, nil, ErrBulkEncode(ctx, err)
+		}
+		doc, err := kit.JsonEncode(item)
+		if err != nil {
+			return nil, nil, ErrBulkEncode(ctx, err)
+		}
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+		sent = append(sent, item)
+	}
+	return buf.Bytes(), sent, nil
+}
@@ -34,6 +34,11 @@ var (
 	ErrCodeExecuteResponseProcessing                 = "ESv8-026"
 	ErrCodeExecute                                   = "ESv8-027"
 	ErrCodeDocUpdate                                 = "ESv8-028"
+	ErrCodeBulk                                      = "ESv8-029"
+	ErrCodeBulkItems                                 = "ESv8-030"
+	ErrCodeBulkEncode                                = "ESv8-031"
+	ErrCodeBulkWriterIndexEmpty                      = "ESv8-032"
+	ErrCodeBulkWriterOpInvalid                       = "ESv8-033"
 )
 
 var (
@@ -121,4 +126,19 @@ var (
 	ErrIndexBuilderNoWriteIndexForAlias = func(ctx context.Context, alias string) error {
 		return kit.NewAppErrBuilder(ErrCodeIndexBuilderNoWriteIndexForAlias, "es index builder: no write index").F(kit.KV{"alias": alias}).C(ctx).Err()
 	}
+	ErrBulk = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeBulk, "es: bulk").Wrap(cause).C(ctx).Err()
+	}
+	ErrBulkItems = func(ctx context.Context, failed int, errType, reason string) error {
+		return kit.NewAppErrBuilder(ErrCodeBulkItems, "es: bulk items rejected").C(ctx).F(kit.KV{"failed": failed, "type": errType, "reason": reason}).Err()
+	}
+	ErrBulkEncode = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeBulkEncode, "es: bulk encode").Wrap(cause).C(ctx).Err()
+	}
+	ErrBulkWriterIndexEmpty = func() error {
+		return kit.NewAppErrBuilder(ErrCodeBulkWriterIndexEmpty, "es bulk writer: index not specified").Err()
+	}
+	ErrBulkWriterOpInvalid = func(op string) error {
+		return kit.NewAppErrBuilder(ErrCodeBulkWriterOpInvalid, "es bulk writer: invalid op %s", op).Err()
+	}
 )
@@ -3,9 +3,12 @@
 package v8
 
 import (
+	"context"
 	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
 	"github.com/stretchr/testify/suite"
 	"testing"
+	"time"
 )
 
 type test struct {
@@ -246,3 +249,58 @@ func (s *esTestSuite) Test_Alias() {
 	s.NoError(err)
 	s.NotEmpty(curMappings)
 }
+
+func (s *esTestSuite) Test_BulkWriter() {
+	client, err := NewEs(&Config{
+		Url: url,
+	}, s.L)
+	s.NoError(err)
+
+	index := "bulk-" + kit.NewRandString()
+	mappings := `{"mappings":{
+		"properties":{
+			"user":{
+				"type":"keyword"
+			},
+			"retweets":{
+				"type":"long"
+			}}}}`
+	s.NoError(client.Index().Create(s.Ctx, index, StringMapping(mappings)))
+	defer client.Index().Delete(s.Ctx, []string{index})
+
+	// the second document with the same id is rejected by create op
+	var failed []*BulkItemError[test]
+	writer, err := NewBulkWriter[test](client, &BulkWriterConfig[test]{
+		Index:   index,
+		IdFn:    func(item *test) string { return item.User },
+		Op:      BulkOpCreate,
+		Refresh: true,
+		OnItemErrors: func(ctx context.Context, items []*BulkItemError[test]) {
+			failed = append(failed, items...)
+		},
+	}, s.L)
+	s.NoError(err)
+
+	worker := batch.NewBatchWorker[test](writer, &batch.Options{Interval: time.Millisecond * 100, MaxItems: 10, MaxCapacity: 100}, s.L)
+	worker.Start(s.Ctx)
+	worker.Write(s.Ctx, &test{User: "1", Message: "first"})
+	worker.Write(s.Ctx, &test{User: "2", Message: "second"})
+	worker.Write(s.Ctx, &test{User: "1", Message: "duplicate"})
+	s.NoError(worker.Close(s.Ctx))
+
+	search, err := client.Search().Search(s.Ctx, index, MatchAllQuery())
+	s.NoError(err)
+	s.Equal(int64(2), search.Hits.Total.Value)
+	s.Len(failed, 1)
+	s.Equal("duplicate", failed[0].Item.Message)
+	s.Equal(409, failed[0].Status)
+}
+
+func (s *esTestSuite) Test_BulkWriter_WhenNoIndex_Fail() {
+	client, err := NewEs(&Config{
+		Url: url,
+	}, s.L)
+	s.NoError(err)
+	_, err = NewBulkWriter[test](client, nil, s.L)
+	s.AssertAppErr(err, ErrCodeBulkWriterIndexEmpty)
+}
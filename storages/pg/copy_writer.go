@@ -0,0 +1,117 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/batch"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// CopyWriterConfig copy writer configuration
+type CopyWriterConfig struct {
+	Table string // Table to copy into, might be schema qualified (default: table name of the GORM model)
+}
+
+type copyWriterImpl[T any] struct {
+	storage *Storage
+	table   pgx.Identifier
+	fields  []*schema.Field
+	columns []string
+}
+
+// NewCopyWriter creates a batch.Writer inserting items with COPY FROM on the GORM connection
+// columns are taken from the GORM model T (gorm tags and naming strategy), auto increment columns are skipped
+func NewCopyWriter[T any](storage *Storage, cfg *CopyWriterConfig) (batch.Writer[T], error) {
+	stmt := &gorm.Statement{DB: storage.Instance}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, ErrPgCopyWriterModel(err)
+	}
+
+	table := stmt.Schema.Table
+	if cfg != nil && cfg.Table != "" {
+		table = cfg.Table
+	}
+
+	w := &copyWriterImpl[T]{
+		storage: storage,
+		table:   pgx.Identifier(strings.Split(table, ".")),
+	}
+	for _, f := range stmt.Schema.Fields {
+		if f.DBName == "" || !f.Creatable || f.AutoIncrement {
+			continue
+		}
+		w.fields = append(w.fields, f)
+		w.columns = append(w.columns, f.DBName)
+	}
+	if len(w.columns) == 0 {
+		return nil, ErrPgCopyWriterModel(nil)
+	}
+	return w, nil
+}
+
+func (w *copyWriterImpl[T]) l() kit.CLogger {
+	return w.storage.logger().Pr("db").Cmp("pg-copy").F(kit.KV{"table": w.table.Sanitize()})
+}
+
+func (w *copyWriterImpl[T]) Write(ctx context.Context, items []*T) error {
+	l := w.l().C(ctx).Mth("write").F(kit.KV{"items": len(items)})
+
+	rows := make([][]any, 0, len(items))
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		v := reflect.ValueOf(item).Elem()
+		row := make([]any, len(w.fields))
+		for i, f := range w.fields {
+			var zero bool
+			row[i], zero = f.ValueOf(ctx, v)
+			// fill created_at / updated_at the same way GORM does on create
+			if zero && f.DataType == schema.Time && (f.AutoCreateTime == schema.UnixTime || f.AutoUpdateTime == schema.UnixTime) {
+				row[i] = w.storage.Instance.NowFunc()
+			}
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	db, err := w.storage.Instance.DB()
+	if err != nil {
+		return ErrPgCopy(ctx, err, w.table.Sanitize())
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return ErrPgCopy(ctx, err, w.table.Sanitize())
+	}
+	defer func() { _ = conn.Close() }()
+
+	var copied int64
+	if err := w.copy(ctx, conn, rows, &copied); err != nil {
+		return ErrPgCopy(ctx, err, w.table.Sanitize())
+	}
+
+	l.F(kit.KV{"copied": copied}).Dbg("ok")
+	return nil
+}
+
+// copy runs COPY FROM on the pgx connection underlying the sql connection
+func (w *copyWriterImpl[T]) copy(ctx context.Context, conn *sql.Conn, rows [][]any, copied *int64) error {
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return ErrPgCopyDriverNotSupported(ctx)
+		}
+		n, err := c.Conn().CopyFrom(ctx, w.table, w.columns, pgx.CopyFromRows(rows))
+		*copied = n
+		return err
+	})
+}
@@ -0,0 +1,231 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	// AdvisoryLockScopeSession lock is held by a dedicated session until unlocked or the session dies
+	AdvisoryLockScopeSession = "session"
+	// AdvisoryLockScopeTransaction lock is held by a dedicated transaction until unlocked (committed) or the session dies
+	AdvisoryLockScopeTransaction = "transaction"
+)
+
+// AdvisoryLockConfig advisory lock storage configuration
+type AdvisoryLockConfig struct {
+	Scope     string // Scope of advisory lock, session or transaction (default: session)
+	Namespace string // Namespace is mixed into the hash of ref to avoid collision of lock ids between different applications
+}
+
+// DistributedLockStorage is a postgres implementation of kit.DistributedLockStorage based on advisory locks
+// ref is hashed to a bigint lock id. Each lock holds a dedicated connection, so if the connection dies
+// the lock is released by postgres automatically
+type DistributedLockStorage interface {
+	kit.DistributedLockStorage
+	// Extend checks if a connection holding the lock is still alive
+	// it returns false if the lock isn't held by releaseId anymore
+	Extend(ctx context.Context, ref, releaseId string) (bool, error)
+	// Close releases all the locks and closes connections
+	Close(ctx context.Context)
+}
+
+type advisoryLock struct {
+	releaseId string
+	lockId    int64
+	conn      *sql.Conn
+	tx        *sql.Tx
+}
+
+type advisoryLockStorageImpl struct {
+	mu      sync.Mutex
+	storage *Storage
+	cfg     *AdvisoryLockConfig
+	locks   map[string]*advisoryLock
+}
+
+func NewDistributedLockStorage(storage *Storage, cfg *AdvisoryLockConfig) DistributedLockStorage {
+	c := &AdvisoryLockConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Scope == "" {
+		c.Scope = AdvisoryLockScopeSession
+	}
+	return &advisoryLockStorageImpl{
+		storage: storage,
+		cfg:     c,
+		locks:   map[string]*advisoryLock{},
+	}
+}
+
+func (s *advisoryLockStorageImpl) l() kit.CLogger {
+	return s.storage.logger().Pr("db").Cmp("pg-lock")
+}
+
+// lockId calculates advisory lock id by hashing ref
+func (s *advisoryLockStorageImpl) lockId(ref string) int64 {
+	return int64(xxhash.Sum64String(s.cfg.Namespace + ref))
+}
+
+func (s *advisoryLockStorageImpl) Lock(ctx context.Context, ref, releaseId string) (bool, error) {
+	l := s.l().C(ctx).Mth("lock").F(kit.KV{"ref": ref}).Dbg()
+
+	if s.cfg.Scope != AdvisoryLockScopeSession && s.cfg.Scope != AdvisoryLockScopeTransaction {
+		return false, ErrPgAdvisoryLockScopeInvalid(ctx, s.cfg.Scope)
+	}
+
+	// the lock is already held by this instance
+	if s.held(ref) {
+		return false, nil
+	}
+
+	db, err := s.storage.Instance.DB()
+	if err != nil {
+		return false, ErrPgAdvisoryLock(ctx, err, ref)
+	}
+
+	// the connection isn't bound to ctx once it's taken, so the lock outlives the request context
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, ErrPgAdvisoryLock(ctx, err, ref)
+	}
+
+	lock := &advisoryLock{
+		releaseId: releaseId,
+		lockId:    s.lockId(ref),
+		conn:      conn,
+	}
+
+	var locked bool
+	if s.cfg.Scope == AdvisoryLockScopeTransaction {
+		lock.tx, err = conn.BeginTx(context.Background(), nil)
+		if err == nil {
+			err = lock.tx.QueryRowContext(ctx, "select pg_try_advisory_xact_lock($1)", lock.lockId).Scan(&locked)
+			if err != nil || !locked {
+				_ = lock.tx.Rollback()
+			}
+		}
+	} else {
+		err = conn.QueryRowContext(ctx, "select pg_try_advisory_lock($1)", lock.lockId).Scan(&locked)
+	}
+	if err != nil {
+		// the lock might have been taken before the query failed
+		discard(conn)
+		return false, ErrPgAdvisoryLock(ctx, err, ref)
+	}
+	if !locked {
+		_ = conn.Close()
+		return false, nil
+	}
+
+	s.mu.Lock()
+	s.locks[ref] = lock
+	s.mu.Unlock()
+
+	l.F(kit.KV{"lockId": lock.lockId}).Dbg("locked")
+	return true, nil
+}
+
+func (s *advisoryLockStorageImpl) held(ref string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.locks[ref]
+	return ok
+}
+
+func (s *advisoryLockStorageImpl) UnLock(ctx context.Context, ref, releaseId string) error {
+	l := s.l().C(ctx).Mth("unlock").F(kit.KV{"ref": ref}).Dbg()
+
+	s.mu.Lock()
+	lock, ok := s.locks[ref]
+	if !ok || lock.releaseId != releaseId {
+		s.mu.Unlock()
+		l.Dbg("not held")
+		return nil
+	}
+	delete(s.locks, ref)
+	s.mu.Unlock()
+
+	if err := s.release(ctx, lock); err != nil {
+		return ErrPgAdvisoryUnLock(ctx, err, ref)
+	}
+
+	l.Dbg("unlocked")
+	return nil
+}
+
+func (s *advisoryLockStorageImpl) Extend(ctx context.Context, ref, releaseId string) (bool, error) {
+	s.mu.Lock()
+	lock, ok := s.locks[ref]
+	s.mu.Unlock()
+	if !ok || lock.releaseId != releaseId {
+		return false, nil
+	}
+
+	// if the session is dead, postgres has already released the lock
+	var err error
+	if lock.tx != nil {
+		_, err = lock.tx.ExecContext(ctx, "select 1")
+	} else {
+		err = lock.conn.PingContext(ctx)
+	}
+	if err != nil {
+		s.l().C(ctx).Mth("extend").F(kit.KV{"ref": ref}).E(ErrPgAdvisoryLockConnLost(ctx, err, ref)).Err()
+		s.mu.Lock()
+		if cur, ok := s.locks[ref]; ok && cur == lock {
+			delete(s.locks, ref)
+		}
+		s.mu.Unlock()
+		// the session might be alive (e.g. ctx is cancelled), it mustn't be pooled with the lock held
+		discard(lock.conn)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *advisoryLockStorageImpl) Close(ctx context.Context) {
+	l := s.l().C(ctx).Mth("close").Dbg()
+
+	s.mu.Lock()
+	locks := s.locks
+	s.locks = map[string]*advisoryLock{}
+	s.mu.Unlock()
+
+	for ref, lock := range locks {
+		if err := s.release(ctx, lock); err != nil {
+			l.E(ErrPgAdvisoryUnLock(ctx, err, ref)).Err()
+		}
+	}
+}
+
+func (s *advisoryLockStorageImpl) release(ctx context.Context, lock *advisoryLock) error {
+	var err error
+	if lock.tx != nil {
+		err = lock.tx.Commit()
+	} else {
+		// unlock must happen even if the caller's request is cancelled
+		_, err = lock.conn.ExecContext(context.WithoutCancel(ctx), "select pg_advisory_unlock($1)", lock.lockId)
+	}
+	if err != nil {
+		discard(lock.conn)
+		return err
+	}
+	// closing returns the session to the pool
+	_ = lock.conn.Close()
+	return nil
+}
+
+// discard closes the physical connection rather than returning it to the pool,
+// so that postgres releases a lock which might still be held by the session
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(driverConn any) error {
+		return driver.ErrBadConn
+	})
+	_ = conn.Close()
+}
@@ -1,12 +1,31 @@
 package pg
 
-import "github.com/mikhailbolshakov/kit"
+import (
+	"context"
+
+	"github.com/mikhailbolshakov/kit"
+)
 
 const (
 	ErrCodePostgresOpen = "PG-001"
 	ErrCodePgEmptyJsonb = "PG-002"
 	ErrCodePgSetJsonb   = "PG-003"
 	ErrCodePgGetJsonb   = "PG-004"
+
+	ErrCodePgAdvisoryLock             = "PG-005"
+	ErrCodePgAdvisoryUnLock           = "PG-006"
+	ErrCodePgAdvisoryLockScopeInvalid = "PG-007"
+	ErrCodePgAdvisoryLockConnLost     = "PG-008"
+
+	ErrCodePgInboxCheck        = "PG-009"
+	ErrCodePgInboxMark         = "PG-010"
+	ErrCodePgInboxEnsureTable  = "PG-011"
+	ErrCodePgInboxCleanup      = "PG-012"
+	ErrCodePgInboxTableInvalid = "PG-013"
+
+	ErrCodePgCopyWriterModel        = "PG-014"
+	ErrCodePgCopy                   = "PG-015"
+	ErrCodePgCopyDriverNotSupported = "PG-016"
 )
 
 var (
@@ -22,4 +41,40 @@ var (
 	ErrPgGetJsonb = func(cause error) error {
 		return kit.NewAppErrBuilder(ErrCodePgGetJsonb, "get JSONB").Wrap(cause).Err()
 	}
+	ErrPgAdvisoryLock = func(ctx context.Context, cause error, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodePgAdvisoryLock, "advisory lock").Wrap(cause).C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrPgAdvisoryUnLock = func(ctx context.Context, cause error, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodePgAdvisoryUnLock, "advisory unlock").Wrap(cause).C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrPgAdvisoryLockScopeInvalid = func(ctx context.Context, scope string) error {
+		return kit.NewAppErrBuilder(ErrCodePgAdvisoryLockScopeInvalid, "advisory lock: invalid scope %s", scope).C(ctx).Err()
+	}
+	ErrPgAdvisoryLockConnLost = func(ctx context.Context, cause error, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodePgAdvisoryLockConnLost, "advisory lock: connection lost").Wrap(cause).C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrPgInboxCheck = func(ctx context.Context, cause error, id string) error {
+		return kit.NewAppErrBuilder(ErrCodePgInboxCheck, "inbox: check").Wrap(cause).C(ctx).F(kit.KV{"id": id}).Err()
+	}
+	ErrPgInboxMark = func(ctx context.Context, cause error, id string) error {
+		return kit.NewAppErrBuilder(ErrCodePgInboxMark, "inbox: mark").Wrap(cause).C(ctx).F(kit.KV{"id": id}).Err()
+	}
+	ErrPgInboxEnsureTable = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodePgInboxEnsureTable, "inbox: ensure table").Wrap(cause).C(ctx).Err()
+	}
+	ErrPgInboxCleanup = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodePgInboxCleanup, "inbox: cleanup").Wrap(cause).C(ctx).Err()
+	}
+	ErrPgInboxTableInvalid = func(ctx context.Context, table string) error {
+		return kit.NewAppErrBuilder(ErrCodePgInboxTableInvalid, "inbox: invalid table name").C(ctx).F(kit.KV{"table": table}).Err()
+	}
+	ErrPgCopyWriterModel = func(cause error) error {
+		return kit.NewAppErrBuilder(ErrCodePgCopyWriterModel, "copy writer: invalid model").Wrap(cause).Err()
+	}
+	ErrPgCopy = func(ctx context.Context, cause error, table string) error {
+		return kit.NewAppErrBuilder(ErrCodePgCopy, "copy").Wrap(cause).C(ctx).F(kit.KV{"table": table}).Err()
+	}
+	ErrPgCopyDriverNotSupported = func(ctx context.Context) error {
+		return kit.NewAppErrBuilder(ErrCodePgCopyDriverNotSupported, "copy: pgx driver required").C(ctx).Err()
+	}
 )
@@ -0,0 +1,125 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+)
+
+const (
+	defaultInboxTable     = "inbox"
+	defaultInboxRetention = time.Hour * 24 * 7
+
+	inboxTableDDL = `
+		create table if not exists %[1]s (
+			id varchar primary key,
+			processed_at timestamp not null
+		);
+		create index if not exists %[2]s_processed_at_idx on %[1]s (processed_at);`
+)
+
+var inboxTableRegexp = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*\.)?([a-zA-Z_][a-zA-Z0-9_]*)$`)
+
+// InboxConfig inbox store configuration
+type InboxConfig struct {
+	Table     string        // Table inbox table name, might be schema qualified (default: inbox)
+	Retention time.Duration // Retention how long processed ids are kept (default: 7d)
+}
+
+// InboxStore keeps ids of processed messages in a postgres inbox table
+// it can be passed to kafka subscribers as kafka.DedupStore
+type InboxStore interface {
+	// Processed checks if an id has been already processed
+	Processed(ctx context.Context, id string) (bool, error)
+	// MarkProcessed marks an id processed
+	MarkProcessed(ctx context.Context, id string) error
+	// EnsureTable creates inbox table if it doesn't exist
+	EnsureTable(ctx context.Context) error
+	// Cleanup removes ids processed earlier than retention period
+	Cleanup(ctx context.Context) error
+}
+
+type inboxStoreImpl struct {
+	storage *Storage
+	cfg     *InboxConfig
+}
+
+func NewInboxStore(storage *Storage, cfg *InboxConfig) InboxStore {
+	c := &InboxConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Table == "" {
+		c.Table = defaultInboxTable
+	}
+	if c.Retention <= 0 {
+		c.Retention = defaultInboxRetention
+	}
+	return &inboxStoreImpl{
+		storage: storage,
+		cfg:     c,
+	}
+}
+
+func (s *inboxStoreImpl) l() kit.CLogger {
+	return s.storage.logger().Pr("db").Cmp("pg-inbox")
+}
+
+func (s *inboxStoreImpl) Processed(ctx context.Context, id string) (bool, error) {
+	if !inboxTableRegexp.MatchString(s.cfg.Table) {
+		return false, ErrPgInboxTableInvalid(ctx, s.cfg.Table)
+	}
+	var exists bool
+	err := s.storage.Instance.WithContext(ctx).
+		Raw(fmt.Sprintf("select exists(select 1 from %s where id = ?)", s.cfg.Table), id).
+		Scan(&exists).Error
+	if err != nil {
+		return false, ErrPgInboxCheck(ctx, err, id)
+	}
+	return exists, nil
+}
+
+func (s *inboxStoreImpl) MarkProcessed(ctx context.Context, id string) error {
+	if !inboxTableRegexp.MatchString(s.cfg.Table) {
+		return ErrPgInboxTableInvalid(ctx, s.cfg.Table)
+	}
+	err := s.storage.Instance.WithContext(ctx).
+		Exec(fmt.Sprintf("insert into %s (id, processed_at) values (?, ?) on conflict (id) do nothing", s.cfg.Table), id, kit.Now()).Error
+	if err != nil {
+		return ErrPgInboxMark(ctx, err, id)
+	}
+	return nil
+}
+
+func (s *inboxStoreImpl) EnsureTable(ctx context.Context) error {
+	s.l().C(ctx).Mth("ensure-table").Dbg()
+
+	parts := inboxTableRegexp.FindStringSubmatch(s.cfg.Table)
+	if parts == nil {
+		return ErrPgInboxTableInvalid(ctx, s.cfg.Table)
+	}
+	// index name cannot be schema qualified
+	if err := s.storage.Instance.WithContext(ctx).Exec(fmt.Sprintf(inboxTableDDL, s.cfg.Table, parts[2])).Error; err != nil {
+		return ErrPgInboxEnsureTable(ctx, err)
+	}
+	return nil
+}
+
+func (s *inboxStoreImpl) Cleanup(ctx context.Context) error {
+	l := s.l().C(ctx).Mth("cleanup").Dbg()
+
+	if !inboxTableRegexp.MatchString(s.cfg.Table) {
+		return ErrPgInboxTableInvalid(ctx, s.cfg.Table)
+	}
+	res := s.storage.Instance.WithContext(ctx).
+		Exec(fmt.Sprintf("delete from %s where processed_at < ?", s.cfg.Table), kit.Now().Add(-s.cfg.Retention))
+	if res.Error != nil {
+		return ErrPgInboxCleanup(ctx, res.Error)
+	}
+
+	l.F(kit.KV{"deleted": res.RowsAffected}).Dbg("ok")
+	return nil
+}
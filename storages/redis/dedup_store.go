@@ -0,0 +1,59 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultDedupKeyPrefix = "dedup:"
+	defaultDedupTtl       = time.Hour * 24
+)
+
+// DedupConfig dedup store configuration
+type DedupConfig struct {
+	Prefix string        // Prefix of keys (default: "dedup:")
+	Ttl    time.Duration // Ttl how long processed ids are kept (default: 24h)
+}
+
+// DedupStore keeps ids of processed messages in redis, ids expire by ttl
+// it implements kafka.DedupStore, so it can be passed to kafka subscribers
+type DedupStore struct {
+	redis *Redis
+	cfg   *DedupConfig
+}
+
+func NewDedupStore(redis *Redis, cfg *DedupConfig) *DedupStore {
+	c := &DedupConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Prefix == "" {
+		c.Prefix = defaultDedupKeyPrefix
+	}
+	if c.Ttl <= 0 {
+		c.Ttl = defaultDedupTtl
+	}
+	return &DedupStore{
+		redis: redis,
+		cfg:   c,
+	}
+}
+
+// Processed checks if an id has been already processed
+func (s *DedupStore) Processed(ctx context.Context, id string) (bool, error) {
+	cnt, err := s.redis.Instance.Exists(ctx, s.cfg.Prefix+id).Result()
+	if err != nil {
+		return false, ErrRedisDedupCheck(ctx, err, id)
+	}
+	return cnt > 0, nil
+}
+
+// MarkProcessed marks an id processed
+func (s *DedupStore) MarkProcessed(ctx context.Context, id string) error {
+	// SETNX keeps the original ttl if the id is already marked
+	if err := s.redis.Instance.SetNX(ctx, s.cfg.Prefix+id, 1, s.cfg.Ttl).Err(); err != nil {
+		return ErrRedisDedupMark(ctx, err, id)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+	"github.com/mikhailbolshakov/kit/rpc"
+)
+
+const (
+	defaultKeysPrefix           = "rpc-keys:"
+	defaultKeysTtl              = time.Second * 30
+	keysHeartbeatPeriodDivider  = 3
+	keysHeartbeatBatchSize      = 100
+	keysChangesChannelSuffix    = "changes"
+	keysOwnerKeyInfix           = "key:"
+	keysExpiredEventChannelMask = "__keyevent@%d__:expired"
+)
+
+var (
+	// takes over a key, the change is published in the same script, so that changes are ordered as writes
+	keysSetScript = `
+		local prev = redis.call("GET", KEYS[1])
+		redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+		if prev ~= ARGV[1] then
+			redis.call("PUBLISH", ARGV[3], cjson.encode({key = ARGV[4], owner = ARGV[1], prev = prev or nil}))
+		end
+		return 1`
+	// removes a key if it's still owned by the node
+	keysRemoveScript = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			redis.call("DEL", KEYS[1])
+			redis.call("PUBLISH", ARGV[2], cjson.encode({key = ARGV[3], prev = ARGV[1]}))
+			return 1
+		end
+		return 0`
+	// prolongs keys owned by the node, a key which expired is taken over again
+	// returns 0 if a key is prolonged, 1 if it's taken over again or the current owner if the key is lost
+	keysHeartbeatScript = `
+		local r = {}
+		for i, key in ipairs(KEYS) do
+			local owner = redis.call("GET", key)
+			if owner == ARGV[1] then
+				redis.call("PEXPIRE", key, ARGV[2])
+				r[i] = 0
+			elseif not owner then
+				redis.call("SET", key, ARGV[1], "PX", ARGV[2])
+				redis.call("PUBLISH", ARGV[3], cjson.encode({key = ARGV[3 + i], owner = ARGV[1]}))
+				r[i] = 1
+			else
+				r[i] = owner
+			end
+		end
+		return r`
+)
+
+// DistributedKeysConfig redis distributed keys configuration
+type DistributedKeysConfig struct {
+	NodeId            string        // NodeId id of the node reported as an owner of its keys, e.g. an address or a topic of the node (default: random)
+	Prefix            string        // Prefix of keys and channels (default: "rpc-keys:")
+	Ttl               time.Duration // Ttl how long a key is owned with no heartbeat, keys of a dead node expire by ttl (default: 30s)
+	HeartbeatInterval time.Duration // HeartbeatInterval interval of prolonging keys (default: Ttl/3)
+}
+
+type distributedKeysImpl struct {
+	sync.RWMutex
+	redis     *Redis
+	cfg       *DistributedKeysConfig
+	keys      map[string]struct{}
+	callbacks []rpc.OwnershipChangeCallback
+	pubSub    *redis.PubSub
+	cancelFn  context.CancelFunc
+	routines  sync.WaitGroup
+}
+
+// NewDistributedKeys creates rpc.ClusterKeys which records key to node ownership in redis
+// owned keys are prolonged by heartbeats, so keys of a dead node expire by ttl
+// ownership changes are published to all nodes, expiration is notified if redis keyspace notifications of expired events are enabled (notify-keyspace-events Ex)
+func NewDistributedKeys(redis *Redis, cfg *DistributedKeysConfig) rpc.ClusterKeys {
+	c := &DistributedKeysConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.NodeId == "" {
+		c.NodeId = kit.NewId()
+	}
+	if c.Prefix == "" {
+		c.Prefix = defaultKeysPrefix
+	}
+	if c.Ttl <= 0 {
+		c.Ttl = defaultKeysTtl
+	}
+	if c.HeartbeatInterval <= 0 || c.HeartbeatInterval >= c.Ttl {
+		c.HeartbeatInterval = c.Ttl / keysHeartbeatPeriodDivider
+	}
+	return &distributedKeysImpl{
+		redis: redis,
+		cfg:   c,
+		keys:  map[string]struct{}{},
+	}
+}
+
+func (s *distributedKeysImpl) l() kit.CLogger {
+	return s.redis.l().Cmp("redis-keys")
+}
+
+func (s *distributedKeysImpl) ownerKey(key string) string {
+	return s.cfg.Prefix + keysOwnerKeyInfix + key
+}
+
+func (s *distributedKeysImpl) changesChannel() string {
+	return s.cfg.Prefix + keysChangesChannelSuffix
+}
+
+func (s *distributedKeysImpl) NodeId() string {
+	return s.cfg.NodeId
+}
+
+func (s *distributedKeysImpl) Set(key string) {
+	ctx := context.Background()
+	l := s.l().C(ctx).Mth("set").F(kit.KV{"key": key})
+
+	s.Lock()
+	s.keys[key] = struct{}{}
+	s.Unlock()
+
+	err := s.redis.Instance.Eval(ctx, keysSetScript, []string{s.ownerKey(key)}, s.cfg.NodeId, s.cfg.Ttl.Milliseconds(), s.changesChannel(), key).Err()
+	if err != nil {
+		l.E(ErrRedisKeysSet(ctx, err, key)).Err()
+		return
+	}
+	l.Dbg("ok")
+}
+
+func (s *distributedKeysImpl) Remove(key string) {
+	ctx := context.Background()
+	l := s.l().C(ctx).Mth("remove").F(kit.KV{"key": key})
+
+	s.Lock()
+	delete(s.keys, key)
+	s.Unlock()
+
+	// the key is removed only if it's still owned by the node
+	err := s.redis.Instance.Eval(ctx, keysRemoveScript, []string{s.ownerKey(key)}, s.cfg.NodeId, s.changesChannel(), key).Err()
+	if err != nil {
+		l.E(ErrRedisKeysRemove(ctx, err, key)).Err()
+		return
+	}
+	l.Dbg("ok")
+}
+
+func (s *distributedKeysImpl) Check(key string) bool {
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+func (s *distributedKeysImpl) Owner(ctx context.Context, key string) (string, error) {
+	owner, err := s.redis.Instance.Get(ctx, s.ownerKey(key)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		return "", ErrRedisKeysOwner(ctx, err, key)
+	}
+	return owner, nil
+}
+
+func (s *distributedKeysImpl) OnOwnershipChange(callback rpc.OwnershipChangeCallback) {
+	s.Lock()
+	defer s.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
+func (s *distributedKeysImpl) Start(ctx context.Context) error {
+	l := s.l().C(ctx).Mth("start").F(kit.KV{"nodeId": s.cfg.NodeId})
+
+	// subscription is confirmed before returning, so that no change is missed afterward
+	expiredChannel := fmt.Sprintf(keysExpiredEventChannelMask, s.redis.Instance.Options().DB)
+	s.pubSub = s.redis.Instance.Subscribe(ctx, s.changesChannel(), expiredChannel)
+	if _, err := s.pubSub.Receive(ctx); err != nil {
+		_ = s.pubSub.Close()
+		return ErrRedisKeysSubscribe(ctx, err)
+	}
+
+	var runCtx context.Context
+	runCtx, s.cancelFn = context.WithCancel(context.Background())
+	// panicked routines are restarted, so they are done only when they return
+	s.routines.Add(2)
+	goroutine.New().WithLogger(s.l().Mth("listen")).WithRetry(goroutine.Unrestricted).Go(ctx, func() {
+		s.listen(s.pubSub.Channel(), expiredChannel)
+		s.routines.Done()
+	})
+	goroutine.New().WithLogger(s.l().Mth("heartbeat")).WithRetry(goroutine.Unrestricted).Go(ctx, func() {
+		s.heartbeats(runCtx)
+		s.routines.Done()
+	})
+
+	l.Dbg("ok")
+	return nil
+}
+
+func (s *distributedKeysImpl) Close(ctx context.Context) {
+	l := s.l().C(ctx).Mth("close")
+
+	// release keys, so that other nodes can take them over with no waiting for expiration
+	for _, key := range s.ownedKeys() {
+		s.Remove(key)
+	}
+
+	if s.cancelFn != nil {
+		s.cancelFn()
+		_ = s.pubSub.Close()
+		s.routines.Wait()
+	}
+	l.Dbg("ok")
+}
+
+func (s *distributedKeysImpl) ownedKeys() []string {
+	s.RLock()
+	defer s.RUnlock()
+	r := make([]string, 0, len(s.keys))
+	for key := range s.keys {
+		r = append(r, key)
+	}
+	return r
+}
+
+// listen receives ownership changes and expiration events
+func (s *distributedKeysImpl) listen(ch <-chan *redis.Message, expiredChannel string) {
+	prefix := s.cfg.Prefix + keysOwnerKeyInfix
+	for m := range ch {
+		var change *rpc.OwnershipChange
+		if m.Channel == expiredChannel {
+			if !strings.HasPrefix(m.Payload, prefix) {
+				continue
+			}
+			change = &rpc.OwnershipChange{Key: strings.TrimPrefix(m.Payload, prefix)}
+		} else {
+			change = &rpc.OwnershipChange{}
+			if err := kit.Unmarshal([]byte(m.Payload), change); err != nil {
+				s.l().Mth("listen").E(err).Err()
+				continue
+			}
+		}
+		// a key owned by the node is taken over by another node
+		// an expired key isn't dropped, it's taken over again by the next heartbeat if no one else has taken it
+		if change.Owner != "" && change.Owner != s.cfg.NodeId {
+			s.lose(change.Key)
+		}
+		s.notify(change)
+	}
+}
+
+func (s *distributedKeysImpl) lose(key string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.keys, key)
+}
+
+func (s *distributedKeysImpl) notify(change *rpc.OwnershipChange) {
+	s.RLock()
+	callbacks := s.callbacks
+	s.RUnlock()
+	for _, cb := range callbacks {
+		cb(context.Background(), change)
+	}
+}
+
+// heartbeats periodically prolongs keys owned by the node
+func (s *distributedKeysImpl) heartbeats(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			keys := s.ownedKeys()
+			for i := 0; i < len(keys); i += keysHeartbeatBatchSize {
+				s.heartbeat(ctx, keys[i:min(i+keysHeartbeatBatchSize, len(keys))])
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *distributedKeysImpl) heartbeat(ctx context.Context, keys []string) {
+	l := s.l().C(ctx).Mth("heartbeat")
+
+	ownerKeys := make([]string, 0, len(keys))
+	args := []any{s.cfg.NodeId, s.cfg.Ttl.Milliseconds(), s.changesChannel()}
+	for _, key := range keys {
+		ownerKeys = append(ownerKeys, s.ownerKey(key))
+		args = append(args, key)
+	}
+	r, err := s.redis.Instance.Eval(ctx, keysHeartbeatScript, ownerKeys, args...).Slice()
+	if err != nil {
+		// temporary failure, try again on the next tick while keys are still valid
+		l.E(ErrRedisKeysHeartbeat(ctx, err)).Err()
+		return
+	}
+	for i, v := range r {
+		// the key is lost, the new owner has notified the cluster
+		if owner, ok := v.(string); ok {
+			l.F(kit.KV{"key": keys[i], "owner": owner}).Warn("key lost")
+			s.lose(keys[i])
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/goroutine"
+)
+
+const (
+	defaultLockKeyPrefix   = "lock:"
+	defaultLockLeaseTtl    = time.Second * 30
+	fencingTokenKeySuffix  = ":fencing"
+	lockRenewPeriodDivider = 3
+)
+
+var (
+	// acquires a lock and issues the next fencing token in a single hit
+	// the fencing counter never expires, so tokens grow monotonically even if the lock key expires
+	lockScript = `
+		if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+			return redis.call("INCR", KEYS[2])
+		else
+			return 0
+		end`
+	// prolongs a lease if it's still owned by the release id
+	extendScript = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+		else
+			return 0
+		end`
+	// releases a lock if it's still owned by the release id
+	unlockScript = `
+		if redis.call("GET", KEYS[1]) == ARGV[1] then
+			return redis.call("DEL", KEYS[1])
+		else
+			return 0
+		end`
+)
+
+// DistributedLockConfig redis lock storage configuration
+type DistributedLockConfig struct {
+	Prefix      string        // Prefix of lock keys (default: "lock:")
+	LeaseTtl    time.Duration // LeaseTtl ttl of a lock key, lease is renewed by watchdog while holder is alive (default: 30s)
+	RenewPeriod time.Duration // RenewPeriod period of lease renewal (default: LeaseTtl/3)
+}
+
+// DistributedLockStorage is a redis implementation of kit.DistributedLockStorage
+// once a lock is acquired, a watchdog keeps extending the lease unless the lock is released
+// each acquisition issues a fencing token, which grows monotonically per ref
+type DistributedLockStorage interface {
+	kit.DistributedLockStorage
+	// Extend prolongs the lease of a lock owned by releaseId
+	// it returns false if the lock isn't owned by releaseId anymore
+	Extend(ctx context.Context, ref, releaseId string) (bool, error)
+	// FencingToken returns a fencing token issued when the lock was acquired by releaseId
+	// downstream writers should reject requests with a token lower than the last seen one
+	FencingToken(ctx context.Context, ref, releaseId string) (int64, error)
+	// Close stops all watchdogs (leases aren't released and expire by ttl)
+	Close(ctx context.Context)
+}
+
+type lease struct {
+	releaseId string
+	token     int64
+	cancelFn  context.CancelFunc
+}
+
+type distributedLockStorageImpl struct {
+	mu     sync.RWMutex
+	redis  *Redis
+	cfg    *DistributedLockConfig
+	leases map[string]*lease
+}
+
+func NewDistributedLockStorage(redis *Redis, cfg *DistributedLockConfig) DistributedLockStorage {
+	c := &DistributedLockConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Prefix == "" {
+		c.Prefix = defaultLockKeyPrefix
+	}
+	if c.LeaseTtl <= 0 {
+		c.LeaseTtl = defaultLockLeaseTtl
+	}
+	if c.RenewPeriod <= 0 || c.RenewPeriod >= c.LeaseTtl {
+		c.RenewPeriod = c.LeaseTtl / lockRenewPeriodDivider
+	}
+	return &distributedLockStorageImpl{
+		redis:  redis,
+		cfg:    c,
+		leases: map[string]*lease{},
+	}
+}
+
+func (s *distributedLockStorageImpl) l() kit.CLogger {
+	return s.redis.l().Cmp("redis-lock")
+}
+
+func (s *distributedLockStorageImpl) key(ref string) string {
+	return s.cfg.Prefix + ref
+}
+
+func (s *distributedLockStorageImpl) Lock(ctx context.Context, ref, releaseId string) (bool, error) {
+	l := s.l().C(ctx).Mth("lock").F(kit.KV{"ref": ref}).Dbg()
+
+	key := s.key(ref)
+	token, err := s.redis.Instance.Eval(ctx, lockScript, []string{key, key + fencingTokenKeySuffix}, releaseId, s.cfg.LeaseTtl.Milliseconds()).Int64()
+	if err != nil {
+		return false, ErrRedisLock(ctx, err, ref)
+	}
+	if token == 0 {
+		return false, nil
+	}
+
+	// register lease and run watchdog
+	leaseCtx, cancelFn := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if prev, ok := s.leases[ref]; ok {
+		prev.cancelFn()
+	}
+	s.leases[ref] = &lease{releaseId: releaseId, token: token, cancelFn: cancelFn}
+	s.mu.Unlock()
+	s.watchdog(leaseCtx, ctx, ref, releaseId)
+
+	l.F(kit.KV{"token": token}).Dbg("locked")
+	return true, nil
+}
+
+func (s *distributedLockStorageImpl) UnLock(ctx context.Context, ref, releaseId string) error {
+	l := s.l().C(ctx).Mth("unlock").F(kit.KV{"ref": ref}).Dbg()
+
+	// stop watchdog first to avoid extending a released lease
+	s.removeLease(ref, releaseId)
+
+	cnt, err := s.redis.Instance.Eval(ctx, unlockScript, []string{s.key(ref)}, releaseId).Int()
+	if err != nil {
+		return ErrRedisUnLock(ctx, err, ref)
+	}
+
+	l.Dbg("unlocked: ", cnt > 0)
+	return nil
+}
+
+func (s *distributedLockStorageImpl) Extend(ctx context.Context, ref, releaseId string) (bool, error) {
+	cnt, err := s.redis.Instance.Eval(ctx, extendScript, []string{s.key(ref)}, releaseId, s.cfg.LeaseTtl.Milliseconds()).Int()
+	if err != nil {
+		return false, ErrRedisLockExtend(ctx, err, ref)
+	}
+	return cnt > 0, nil
+}
+
+func (s *distributedLockStorageImpl) FencingToken(ctx context.Context, ref, releaseId string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ls, ok := s.leases[ref]; ok && ls.releaseId == releaseId {
+		return ls.token, nil
+	}
+	return 0, ErrRedisLockNotHeld(ctx, ref)
+}
+
+func (s *distributedLockStorageImpl) Close(ctx context.Context) {
+	s.l().C(ctx).Mth("close").Dbg()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ls := range s.leases {
+		ls.cancelFn()
+	}
+	s.leases = map[string]*lease{}
+}
+
+func (s *distributedLockStorageImpl) removeLease(ref, releaseId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ls, ok := s.leases[ref]; ok && ls.releaseId == releaseId {
+		ls.cancelFn()
+		delete(s.leases, ref)
+	}
+}
+
+// watchdog periodically extends the lease unless it's cancelled or the lock is lost
+func (s *distributedLockStorageImpl) watchdog(leaseCtx, ctx context.Context, ref, releaseId string) {
+	goroutine.New().
+		WithLogger(s.l().Mth("watchdog").F(kit.KV{"ref": ref})).
+		Go(ctx, func() {
+			ticker := time.NewTicker(s.cfg.RenewPeriod)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					extended, err := s.Extend(leaseCtx, ref, releaseId)
+					if err != nil {
+						// temporary failure, try again on the next tick while lease is still valid
+						s.l().C(ctx).Mth("watchdog").E(err).Err()
+						continue
+					}
+					if !extended {
+						s.l().C(ctx).Mth("watchdog").F(kit.KV{"ref": ref}).Warn("lease lost")
+						s.removeLease(ref, releaseId)
+						return
+					}
+				case <-leaseCtx.Done():
+					return
+				}
+			}
+		})
+}
@@ -10,6 +10,18 @@ const (
 	ErrCodeRedisPriorityQueuePushErr      = "RDS-002"
 	ErrCodeRedisPriorityQueuePopErr       = "RDS-003"
 	ErrCodeRedisPriorityQueuePopRemoveErr = "RDS-004"
+	ErrCodeRedisLock                      = "RDS-005"
+	ErrCodeRedisUnLock                    = "RDS-006"
+	ErrCodeRedisLockExtend                = "RDS-007"
+	ErrCodeRedisLockNotHeld               = "RDS-008"
+	ErrCodeRedisDedupCheck                = "RDS-009"
+	ErrCodeRedisDedupMark                 = "RDS-010"
+	ErrCodeRedisKeysSet                   = "RDS-011"
+	ErrCodeRedisKeysRemove                = "RDS-012"
+	ErrCodeRedisKeysOwner                 = "RDS-013"
+	ErrCodeRedisKeysHeartbeat             = "RDS-014"
+	ErrCodeRedisKeysSubscribe             = "RDS-015"
+	ErrCodeRedisFireAdvance               = "RDS-016"
 )
 
 var (
@@ -25,4 +37,40 @@ var (
 	ErrRedisPriorityQueuePopRemoveErr = func(ctx context.Context, cause error) error {
 		return kit.NewAppErrBuilder(ErrCodeRedisPriorityQueuePopRemoveErr, "priority queue: pop and remove").Wrap(cause).Err()
 	}
+	ErrRedisLock = func(ctx context.Context, cause error, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisLock, "lock").Wrap(cause).C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrRedisUnLock = func(ctx context.Context, cause error, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisUnLock, "unlock").Wrap(cause).C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrRedisLockExtend = func(ctx context.Context, cause error, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisLockExtend, "lock: extend lease").Wrap(cause).C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrRedisLockNotHeld = func(ctx context.Context, ref string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisLockNotHeld, "lock isn't held").C(ctx).F(kit.KV{"ref": ref}).Err()
+	}
+	ErrRedisDedupCheck = func(ctx context.Context, cause error, id string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisDedupCheck, "dedup: check").Wrap(cause).C(ctx).F(kit.KV{"id": id}).Err()
+	}
+	ErrRedisDedupMark = func(ctx context.Context, cause error, id string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisDedupMark, "dedup: mark").Wrap(cause).C(ctx).F(kit.KV{"id": id}).Err()
+	}
+	ErrRedisKeysSet = func(ctx context.Context, cause error, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisKeysSet, "keys: set").Wrap(cause).C(ctx).F(kit.KV{"key": key}).Err()
+	}
+	ErrRedisKeysRemove = func(ctx context.Context, cause error, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisKeysRemove, "keys: remove").Wrap(cause).C(ctx).F(kit.KV{"key": key}).Err()
+	}
+	ErrRedisKeysOwner = func(ctx context.Context, cause error, key string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisKeysOwner, "keys: owner").Wrap(cause).C(ctx).F(kit.KV{"key": key}).Err()
+	}
+	ErrRedisKeysHeartbeat = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisKeysHeartbeat, "keys: heartbeat").Wrap(cause).C(ctx).Err()
+	}
+	ErrRedisKeysSubscribe = func(ctx context.Context, cause error) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisKeysSubscribe, "keys: subscribe").Wrap(cause).C(ctx).Err()
+	}
+	ErrRedisFireAdvance = func(ctx context.Context, cause error, job string) error {
+		return kit.NewAppErrBuilder(ErrCodeRedisFireAdvance, "fire store: advance").Wrap(cause).C(ctx).F(kit.KV{"job": job}).Err()
+	}
 )
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultFireKeyPrefix = "scheduler:fire:"
+)
+
+var (
+	// records a fire time if it's later than the recorded one
+	// keys don't expire, there is one key per job
+	fireAdvanceScript = `
+		local last = redis.call("GET", KEYS[1])
+		if last and tonumber(last) >= tonumber(ARGV[1]) then
+			return 0
+		end
+		redis.call("SET", KEYS[1], ARGV[1])
+		return 1`
+)
+
+// FireStoreConfig fire store configuration
+type FireStoreConfig struct {
+	Prefix string // Prefix of keys (default: "scheduler:fire:")
+}
+
+// FireStore keeps the last fire time of scheduler jobs in redis
+// it implements scheduler.FireStore, so that all the nodes of a cluster share fire times
+type FireStore struct {
+	redis *Redis
+	cfg   *FireStoreConfig
+}
+
+func NewFireStore(redis *Redis, cfg *FireStoreConfig) *FireStore {
+	c := &FireStoreConfig{}
+	if cfg != nil {
+		*c = *cfg
+	}
+	if c.Prefix == "" {
+		c.Prefix = defaultFireKeyPrefix
+	}
+	return &FireStore{
+		redis: redis,
+		cfg:   c,
+	}
+}
+
+// Advance records at as the last fire time of the job, it returns false if a fire at or after at has been already recorded
+func (s *FireStore) Advance(ctx context.Context, job string, at time.Time) (bool, error) {
+	r, err := s.redis.Instance.Eval(ctx, fireAdvanceScript, []string{s.cfg.Prefix + job}, at.UnixMilli()).Int()
+	if err != nil {
+		return false, ErrRedisFireAdvance(ctx, err, job)
+	}
+	return r == 1, nil
+}
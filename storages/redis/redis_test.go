@@ -3,8 +3,12 @@
 package redis
 
 import (
+	"context"
 	"fmt"
 	"github.com/mikhailbolshakov/kit"
+	"github.com/mikhailbolshakov/kit/kafka"
+	"github.com/mikhailbolshakov/kit/rpc"
+	"github.com/mikhailbolshakov/kit/scheduler"
 	"github.com/stretchr/testify/suite"
 	"testing"
 	"time"
@@ -90,6 +94,170 @@ func (s *redisTestSuite) Test_Distributed_Lock() {
 
 }
 
+func (s *redisTestSuite) Test_DistributedLockStorage() {
+	cl, err := Open(s.Ctx, config, s.L)
+	s.NoError(err)
+	defer cl.Close()
+
+	storage := NewDistributedLockStorage(cl, &DistributedLockConfig{LeaseTtl: time.Second, RenewPeriod: time.Millisecond * 200})
+	defer storage.Close(s.Ctx)
+
+	ref, releaseId := kit.NewRandString(), kit.NewRandString()
+
+	// apply lock
+	locked, err := storage.Lock(s.Ctx, ref, releaseId)
+	s.NoError(err)
+	s.True(locked)
+	token1, err := storage.FencingToken(s.Ctx, ref, releaseId)
+	s.NoError(err)
+	s.Greater(token1, int64(0))
+
+	// lease is renewed by watchdog, so lock is still held after ttl expires
+	time.Sleep(time.Second * 2)
+	locked, err = storage.Lock(s.Ctx, ref, kit.NewRandString())
+	s.NoError(err)
+	s.False(locked)
+
+	// unlock
+	s.NoError(storage.UnLock(s.Ctx, ref, releaseId))
+	_, err = storage.FencingToken(s.Ctx, ref, releaseId)
+	s.Error(err)
+
+	// lock again, fencing token must grow
+	releaseId = kit.NewRandString()
+	locked, err = storage.Lock(s.Ctx, ref, releaseId)
+	s.NoError(err)
+	s.True(locked)
+	token2, err := storage.FencingToken(s.Ctx, ref, releaseId)
+	s.NoError(err)
+	s.Greater(token2, token1)
+	s.NoError(storage.UnLock(s.Ctx, ref, releaseId))
+}
+
+func (s *redisTestSuite) Test_DistributedLockStorage_WithDistributedLock() {
+	cl, err := Open(s.Ctx, config, s.L)
+	s.NoError(err)
+	defer cl.Close()
+
+	storage := NewDistributedLockStorage(cl, nil)
+	defer storage.Close(s.Ctx)
+
+	lock := kit.NewDistributedLock(storage, &kit.DistributedLockCfg{AwaitPeriod: time.Second}, s.L)
+	ref := kit.NewRandString()
+
+	releaseId, err := lock.Lock(s.Ctx, ref)
+	s.NoError(err)
+	s.NotEmpty(releaseId)
+
+	// another attempt fails by timeout
+	_, err = lock.Lock(s.Ctx, ref)
+	s.True(kit.IsAppErrCode(err, kit.ErrCodeDistributedLockFailed))
+
+	lock.UnLock(s.Ctx, ref, releaseId)
+	releaseId, err = lock.Lock(s.Ctx, ref)
+	s.NoError(err)
+	lock.UnLock(s.Ctx, ref, releaseId)
+}
+
+func (s *redisTestSuite) Test_DedupStore() {
+	cl, err := Open(s.Ctx, config, s.L)
+	s.NoError(err)
+	defer cl.Close()
+
+	var store kafka.DedupStore = NewDedupStore(cl, &DedupConfig{Ttl: time.Second})
+	id := kit.NewRandString()
+
+	processed, err := store.Processed(s.Ctx, id)
+	s.NoError(err)
+	s.False(processed)
+
+	s.NoError(store.MarkProcessed(s.Ctx, id))
+	processed, err = store.Processed(s.Ctx, id)
+	s.NoError(err)
+	s.True(processed)
+
+	// expired
+	time.Sleep(time.Millisecond * 1500)
+	processed, err = store.Processed(s.Ctx, id)
+	s.NoError(err)
+	s.False(processed)
+}
+
+func (s *redisTestSuite) Test_FireStore() {
+	cl, err := Open(s.Ctx, config, s.L)
+	s.NoError(err)
+	defer cl.Close()
+
+	var store scheduler.FireStore = NewFireStore(cl, nil)
+	job := kit.NewRandString()
+	at := kit.Now().Truncate(time.Second)
+	defer cl.Instance.Del(s.Ctx, defaultFireKeyPrefix+job)
+
+	advanced, err := store.Advance(s.Ctx, job, at)
+	s.NoError(err)
+	s.True(advanced)
+
+	// the same or an earlier fire is rejected
+	advanced, err = store.Advance(s.Ctx, job, at)
+	s.NoError(err)
+	s.False(advanced)
+	advanced, err = store.Advance(s.Ctx, job, at.Add(-time.Second))
+	s.NoError(err)
+	s.False(advanced)
+
+	advanced, err = store.Advance(s.Ctx, job, at.Add(time.Second))
+	s.NoError(err)
+	s.True(advanced)
+}
+
+func (s *redisTestSuite) Test_DistributedKeys() {
+	cl, err := Open(s.Ctx, config, s.L)
+	s.NoError(err)
+	defer cl.Close()
+
+	prefix := kit.NewRandString() + ":"
+	node1 := NewDistributedKeys(cl, &DistributedKeysConfig{NodeId: "node-1", Prefix: prefix, Ttl: time.Second})
+	node2 := NewDistributedKeys(cl, &DistributedKeysConfig{NodeId: "node-2", Prefix: prefix, Ttl: time.Second})
+	s.NoError(node1.Start(s.Ctx))
+	s.NoError(node2.Start(s.Ctx))
+	defer node2.Close(s.Ctx)
+
+	changes := make(chan *rpc.OwnershipChange, 10)
+	node2.OnOwnershipChange(func(ctx context.Context, change *rpc.OwnershipChange) {
+		changes <- change
+	})
+
+	// node-1 owns the key
+	key := kit.NewRandString()
+	node1.Set(key)
+	s.True(node1.Check(key))
+	s.False(node2.Check(key))
+	owner, err := node2.Owner(s.Ctx, key)
+	s.NoError(err)
+	s.Equal("node-1", owner)
+	s.Equal(&rpc.OwnershipChange{Key: key, Owner: "node-1"}, <-changes)
+
+	// key is kept by heartbeats after ttl
+	time.Sleep(time.Second * 2)
+	owner, err = node2.Owner(s.Ctx, key)
+	s.NoError(err)
+	s.Equal("node-1", owner)
+
+	// node-2 takes over the key, node-1 drops it
+	node2.Set(key)
+	s.Equal(&rpc.OwnershipChange{Key: key, Owner: "node-2", PrevOwner: "node-1"}, <-changes)
+	s.Eventually(func() bool { return !node1.Check(key) }, time.Second, time.Millisecond*10)
+
+	// node-1 takes over the key again and closes, the key is released
+	node1.Set(key)
+	s.Equal(&rpc.OwnershipChange{Key: key, Owner: "node-1", PrevOwner: "node-2"}, <-changes)
+	node1.Close(s.Ctx)
+	s.Equal(&rpc.OwnershipChange{Key: key, PrevOwner: "node-1"}, <-changes)
+	owner, err = node2.Owner(s.Ctx, key)
+	s.NoError(err)
+	s.Empty(owner)
+}
+
 func (s *redisTestSuite) Test_Json() {
 
 	s.T().Skip("Redis 8 support")
@@ -117,10 +285,10 @@ func (s *redisTestSuite) Test_Json() {
 
 	// set json
 	someJs, _ := kit.JsonEncode(some)
-	s.NoError(cl.Instance.JSONSet(s.Ctx, key, ".", someJs).Err())
+	s.NoError(cl.Instance.Do(s.Ctx, "JSON.SET", key, ".", someJs).Err())
 
 	// get json
-	rsTxt, err := cl.Instance.JSONGet(s.Ctx, key, ".").Result()
+	rsTxt, err := cl.Instance.Do(s.Ctx, "JSON.GET", key, ".").Text()
 	s.NoError(err)
 	s.NotEmpty(rsTxt)
 	resSome, err := kit.JsonDecode[Some]([]byte(rsTxt))
@@ -129,7 +297,7 @@ func (s *redisTestSuite) Test_Json() {
 	s.Equal(some, *resSome)
 
 	// get attribute
-	rsAttrTxt, err := cl.Instance.JSONGet(s.Ctx, key, "$.a").Result()
+	rsAttrTxt, err := cl.Instance.Do(s.Ctx, "JSON.GET", key, "$.a").Text()
 	s.NoError(err)
 	resAttr, err := kit.JsonDecodePlainSlice[string]([]byte(rsAttrTxt))
 	s.NoError(err)
@@ -140,12 +308,13 @@ func (s *redisTestSuite) Test_Json() {
 	some.B = 2
 	some.A = "another"
 	some.Nested.Slice = []string{"a", "b", "c", "d"}
-	s.NoError(cl.Instance.JSONSet(s.Ctx, key, "$.b", some.B).Err())
-	s.NoError(cl.Instance.JSONSet(s.Ctx, key, "$.a", fmt.Sprintf("\"%s\"", some.A)).Err())
-	s.NoError(cl.Instance.JSONSet(s.Ctx, key, "$.nested.slice", some.Nested.Slice).Err())
+	s.NoError(cl.Instance.Do(s.Ctx, "JSON.SET", key, "$.b", some.B).Err())
+	s.NoError(cl.Instance.Do(s.Ctx, "JSON.SET", key, "$.a", fmt.Sprintf("\"%s\"", some.A)).Err())
+	sliceJs, _ := kit.JsonEncode(some.Nested.Slice)
+	s.NoError(cl.Instance.Do(s.Ctx, "JSON.SET", key, "$.nested.slice", sliceJs).Err())
 
 	// get json
-	rsTxt, err = cl.Instance.JSONGet(s.Ctx, key, ".").Result()
+	rsTxt, err = cl.Instance.Do(s.Ctx, "JSON.GET", key, ".").Text()
 	s.NoError(err)
 	s.NotEmpty(rsTxt)
 	resSome, err = kit.JsonDecode[Some]([]byte(rsTxt))